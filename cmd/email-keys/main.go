@@ -0,0 +1,64 @@
+// Reports accounts whose emails collide after normalisation.
+// Dry run by default, -apply writes the keys like the service does on startup.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	stdlog "log"
+	"sort"
+
+	"github.com/Neroframe/AuthService/config"
+	mongoadapter "github.com/Neroframe/AuthService/internal/adapters/mongo"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/usecase"
+	mongopkg "github.com/Neroframe/AuthService/pkg/mongo"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	apply := flag.Bool("apply", false, "write email keys instead of only reporting")
+	flag.Parse()
+
+	if err := godotenv.Load(".env"); err != nil {
+		println("No .env file found, falling back to real env")
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		stdlog.Fatalf("config load error: %v", err)
+	}
+
+	ctx := context.Background()
+	client, err := mongopkg.NewClient(ctx, mongopkg.Config(cfg.Mongo))
+	if err != nil {
+		stdlog.Fatalf("mongo connect: %v", err)
+	}
+	defer client.Disconnect(ctx)
+
+	repo, err := mongoadapter.NewUserRepository(ctx, client.DB)
+	if err != nil {
+		stdlog.Fatalf("mongo repo init: %v", err)
+	}
+
+	norm := domain.EmailNormalizer{FoldAliases: cfg.Email.FoldAliases}
+	report, err := usecase.MigrateEmailKeys(ctx, repo, norm, !*apply)
+	if err != nil {
+		stdlog.Fatalf("migration: %v", err)
+	}
+
+	fmt.Printf("scanned %d, keyed %d, invalid %d, duplicate keys %d\n", report.Scanned, report.Keyed, len(report.Invalid), len(report.Duplicates))
+	for _, id := range report.Invalid {
+		fmt.Printf("invalid email: user %s\n", id)
+	}
+
+	keys := make([]string, 0, len(report.Duplicates))
+	for key := range report.Duplicates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("duplicate %s: kept by %s, also %v\n", key, report.Owners[key], report.Duplicates[key])
+	}
+}
@@ -0,0 +1,51 @@
+// Replaces the global unique indexes on users and relation tuples with the
+// per-tenant ones. Dry run by default, -apply builds the new indexes and then
+// drops the old ones.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	stdlog "log"
+
+	"github.com/Neroframe/AuthService/config"
+	mongoadapter "github.com/Neroframe/AuthService/internal/adapters/mongo"
+	mongopkg "github.com/Neroframe/AuthService/pkg/mongo"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	apply := flag.Bool("apply", false, "drop the old indexes instead of only reporting")
+	flag.Parse()
+
+	if err := godotenv.Load(".env"); err != nil {
+		println("No .env file found, falling back to real env")
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		stdlog.Fatalf("config load error: %v", err)
+	}
+
+	ctx := context.Background()
+	client, err := mongopkg.NewClient(ctx, mongopkg.Config(cfg.Mongo))
+	if err != nil {
+		stdlog.Fatalf("mongo connect: %v", err)
+	}
+	defer client.Disconnect(ctx)
+
+	legacy, err := mongoadapter.MigrateTenantIndexes(ctx, client.DB, !*apply)
+	if err != nil {
+		stdlog.Fatalf("migration: %v", err)
+	}
+
+	verb := "would drop"
+	if *apply {
+		verb = "dropped"
+	}
+	fmt.Printf("%s %d old indexes\n", verb, len(legacy))
+	for _, name := range legacy {
+		fmt.Printf("%s %s\n", verb, name)
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	_ "embed"
 	"time"
 
 	"github.com/caarlos0/env/v10"
@@ -8,14 +9,34 @@ import (
 
 type (
 	Config struct {
-		Version string `env:"APP_VERSION" envDefault:"1.0.0"`
-		Server  Server `envPrefix:"GRPC_"`
-		Mongo   Mongo
-		Nats    Nats
-		Redis   Redis
-		JWT     JWT
-		Gomail  Gomail
-		Log     Log
+		Version     string `env:"APP_VERSION" envDefault:"1.0.0"`
+		Server      Server `envPrefix:"GRPC_"`
+		Mongo       Mongo
+		Nats        Nats
+		Events      Events
+		Outbox      Outbox
+		Consumer    Consumer
+		Webhook     Webhook
+		Erasure     Erasure
+		Export      Export
+		Import      Import
+		Scim        Scim
+		Audit       Audit
+		RBAC        RBAC
+		Policy      Policy
+		Tenant      Tenant
+		Redis       Redis
+		JWT         JWT
+		Email       Email
+		EmailChange EmailChange
+		Invitation  Invitation
+		Gomail      Gomail
+		SMS         SMS
+		Log         Log
+
+		// Proxies in front of gRPC and SCIM whose X-Forwarded-For is believed,
+		// CIDRs or addresses, empty trusts none
+		TrustedProxies []string `env:"TRUSTED_PROXIES" envSeparator:","`
 	}
 
 	// ------------ Server (gRPC) ------------
@@ -56,12 +77,156 @@ type (
 		Name          string        `env:"NATS_NAME" envDefault:"AuthService-NATS-Client"`
 		MaxReconnects int           `env:"NATS_MAX_RECONNECTS"`
 		ReconnectWait time.Duration `env:"NATS_RECONNECT_WAIT"`
+		AckTimeout    time.Duration `env:"NATS_ACK_TIMEOUT" envDefault:"5s"`
+		Stream        NatsStream
 		NatsSubjects  NatsSubjects
 	}
 
+	// JetStream stream holding all user events
+	NatsStream struct {
+		Name            string        `env:"NATS_STREAM_NAME" envDefault:"USERS"`
+		Subjects        []string      `env:"NATS_STREAM_SUBJECTS" envDefault:"user.>" envSeparator:","`
+		Retention       string        `env:"NATS_STREAM_RETENTION" envDefault:"limits"` // "limits", "interest" or "workqueue"
+		MaxAge          time.Duration `env:"NATS_STREAM_MAX_AGE" envDefault:"720h"`
+		Replicas        int           `env:"NATS_STREAM_REPLICAS" envDefault:"1"`
+		DuplicateWindow time.Duration `env:"NATS_STREAM_DUPLICATE_WINDOW" envDefault:"2m"`
+	}
+
 	NatsSubjects struct {
-		UserRegistered string
-		UserLoggedIn   string
+		UserRegistered      string `env:"NATS_SUBJECT_USER_REGISTERED" envDefault:"user.registered"`
+		UserLoggedIn        string `env:"NATS_SUBJECT_USER_LOGGED_IN" envDefault:"user.logged_in"`
+		UserLoggedOut       string `env:"NATS_SUBJECT_USER_LOGGED_OUT" envDefault:"user.logged_out"`
+		UserProfileUpdated  string `env:"NATS_SUBJECT_USER_PROFILE_UPDATED" envDefault:"user.profile_updated"`
+		UserEmailVerified   string `env:"NATS_SUBJECT_USER_EMAIL_VERIFIED" envDefault:"user.email_verified"`
+		UserRoleChanged     string `env:"NATS_SUBJECT_USER_ROLE_CHANGED" envDefault:"user.role_changed"`
+		PasswordChanged     string `env:"NATS_SUBJECT_PASSWORD_CHANGED" envDefault:"user.password_changed"`
+		PasswordReset       string `env:"NATS_SUBJECT_PASSWORD_RESET" envDefault:"user.password_reset"`
+		UserDeleted         string `env:"NATS_SUBJECT_USER_DELETED" envDefault:"user.deleted"`
+		UserVerifiedSet     string `env:"NATS_SUBJECT_USER_VERIFIED_SET" envDefault:"user.verified_set"`
+		UserRestored        string `env:"NATS_SUBJECT_USER_RESTORED" envDefault:"user.restored"`
+		UserErased          string `env:"NATS_SUBJECT_USER_ERASED" envDefault:"user.erased"`
+		UserEmailChanged    string `env:"NATS_SUBJECT_USER_EMAIL_CHANGED" envDefault:"user.email_changed"`
+		UserPhoneVerified   string `env:"NATS_SUBJECT_USER_PHONE_VERIFIED" envDefault:"user.phone_verified"`
+		UserStatusChanged   string `env:"NATS_SUBJECT_USER_STATUS_CHANGED" envDefault:"user.status_changed"`
+		GroupMembersAdded   string `env:"NATS_SUBJECT_GROUP_MEMBERS_ADDED" envDefault:"user.group_members_added"`
+		GroupMembersRemoved string `env:"NATS_SUBJECT_GROUP_MEMBERS_REMOVED" envDefault:"user.group_members_removed"`
+	}
+
+	// ------------ Events ------------
+	Events struct {
+		Source   string `env:"EVENTS_SOURCE" envDefault:"/auth-service"` // CloudEvents source attribute
+		Encoding string `env:"EVENTS_ENCODING" envDefault:"json"`        // "json" or "protobuf"
+	}
+
+	// ------------ Outbox ------------
+	Outbox struct {
+		PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" envDefault:"1s"`
+		BatchSize    int           `env:"OUTBOX_BATCH_SIZE" envDefault:"100"`
+		LockTTL      time.Duration `env:"OUTBOX_LOCK_TTL" envDefault:"10s"`    // relay lease held by one replica
+		Retention    time.Duration `env:"OUTBOX_RETENTION" envDefault:"168h"`  // sent records kept for
+		MaxAttempts  int           `env:"OUTBOX_MAX_ATTEMPTS" envDefault:"10"` // then dead-lettered
+		Backoff      time.Duration `env:"OUTBOX_BACKOFF" envDefault:"1s"`      // doubles per failed attempt
+		MaxBackoff   time.Duration `env:"OUTBOX_MAX_BACKOFF" envDefault:"5m"`
+	}
+
+	// ------------ Inbound consumers ------------
+	Consumer struct {
+		Stream         string        `env:"CONSUMER_STREAM" envDefault:"AUTH_INBOUND"`         // JetStream stream of the inbound subjects
+		StreamMaxAge   time.Duration `env:"CONSUMER_STREAM_MAX_AGE" envDefault:"720h"`         // also how long DLQ messages are kept
+		DurablePrefix  string        `env:"CONSUMER_DURABLE_PREFIX" envDefault:"auth-service"` // one durable per inbound subject
+		DLQSubject     string        `env:"CONSUMER_DLQ_SUBJECT" envDefault:"auth.dlq"`        // prefix, in the inbound stream
+		MaxRetries     int           `env:"CONSUMER_MAX_RETRIES" envDefault:"5"`
+		Backoff        time.Duration `env:"CONSUMER_BACKOFF" envDefault:"500ms"`
+		MaxBackoff     time.Duration `env:"CONSUMER_MAX_BACKOFF" envDefault:"30s"`
+		HandlerTimeout time.Duration `env:"CONSUMER_HANDLER_TIMEOUT" envDefault:"10s"`
+		AckWait        time.Duration `env:"CONSUMER_ACK_WAIT" envDefault:"30s"`       // stream messages, longer than the handler timeout
+		ProcessedTTL   time.Duration `env:"CONSUMER_PROCESSED_TTL" envDefault:"168h"` // dedup window
+
+		StudentWithdrawnSubject string `env:"CONSUMER_SUBJECT_STUDENT_WITHDRAWN" envDefault:"enrollment.student_withdrawn"`
+		StaffTerminatedSubject  string `env:"CONSUMER_SUBJECT_STAFF_TERMINATED" envDefault:"hr.staff_terminated"`
+		UserEventsSubject       string `env:"CONSUMER_SUBJECT_USER_EVENTS" envDefault:"user.>"`            // fanned out to webhooks
+		WebhookDurable          string `env:"CONSUMER_WEBHOOK_DURABLE" envDefault:"auth-service-webhooks"` // consumer of the user events stream
+		RelationsSubject        string `env:"CONSUMER_SUBJECT_RELATIONS" envDefault:"course.relations"`
+	}
+
+	// ------------ Webhooks ------------
+	Webhook struct {
+		PollInterval time.Duration `env:"WEBHOOK_POLL_INTERVAL" envDefault:"1s"`
+		Timeout      time.Duration `env:"WEBHOOK_TIMEOUT" envDefault:"10s"` // per HTTP request
+		Lease        time.Duration `env:"WEBHOOK_LEASE" envDefault:"30s"`
+		MaxAttempts  int           `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"8"`
+		Backoff      time.Duration `env:"WEBHOOK_BACKOFF" envDefault:"10s"`
+		MaxBackoff   time.Duration `env:"WEBHOOK_MAX_BACKOFF" envDefault:"1h"`
+		DisableAfter int           `env:"WEBHOOK_DISABLE_AFTER" envDefault:"20"` // consecutive failures
+		Retention    time.Duration `env:"WEBHOOK_RETENTION" envDefault:"720h"`   // delivery log kept for
+		// Private networks endpoints may still use (CIDRs), every other non-public address is refused
+		AllowedNetworks []string `env:"WEBHOOK_ALLOWED_NETWORKS" envSeparator:","`
+	}
+
+	// ------------ Account erasure ------------
+	Erasure struct {
+		GracePeriod time.Duration `env:"ERASURE_GRACE_PERIOD" envDefault:"720h"` // deleted accounts can be restored for
+		Interval    time.Duration `env:"ERASURE_INTERVAL" envDefault:"1h"`
+		BatchSize   int           `env:"ERASURE_BATCH_SIZE" envDefault:"100"`
+	}
+
+	// ------------ Data export ------------
+	Export struct {
+		InlineMaxEntries int           `env:"EXPORT_INLINE_MAX_ENTRIES" envDefault:"1000"` // larger exports run as a job
+		TTL              time.Duration `env:"EXPORT_TTL" envDefault:"72h"`                 // bundles downloadable for
+		PollInterval     time.Duration `env:"EXPORT_POLL_INTERVAL" envDefault:"5s"`
+		Lease            time.Duration `env:"EXPORT_LEASE" envDefault:"5m"`
+	}
+
+	// ------------ Bulk import ------------
+	Import struct {
+		Dir          string        `env:"IMPORT_DIR"`                         // StartImport reads files under it, empty disables it
+		BatchSize    int           `env:"IMPORT_BATCH_SIZE" envDefault:"200"` // rows per transaction
+		PollInterval time.Duration `env:"IMPORT_POLL_INTERVAL" envDefault:"5s"`
+		Lease        time.Duration `env:"IMPORT_LEASE" envDefault:"5m"`         // renewed every batch
+		MaxUploadMB  int64         `env:"IMPORT_MAX_UPLOAD_MB" envDefault:"50"` // per file
+		ResultTTL    time.Duration `env:"IMPORT_RESULT_TTL" envDefault:"720h"`  // jobs, files and row results kept for
+	}
+
+	// ------------ SCIM ------------
+	Scim struct {
+		Addr            string        `env:"SCIM_ADDR"`      // HTTP server of /scim/v2, empty disables it
+		BaseURL         string        `env:"SCIM_BASE_URL"`  // public URL of /scim/v2, for resource locations
+		CertFile        string        `env:"SCIM_CERT_FILE"` // TLS when both cert and key are set
+		KeyFile         string        `env:"SCIM_KEY_FILE"`
+		DefaultRole     string        `env:"SCIM_DEFAULT_ROLE" envDefault:"student"` // users provisioned without roles
+		MaxResults      int           `env:"SCIM_MAX_RESULTS" envDefault:"200"`      // page size cap of list requests
+		RequestTimeout  time.Duration `env:"SCIM_REQUEST_TIMEOUT" envDefault:"30s"`  // read and write timeout
+		ShutdownTimeout time.Duration `env:"SCIM_SHUTDOWN_TIMEOUT" envDefault:"10s"`
+	}
+
+	// ------------ Audit ------------
+	Audit struct {
+		Retention time.Duration `env:"AUDIT_RETENTION" envDefault:"8760h"` // entries expire after (1 year)
+	}
+
+	// ------------ RBAC ------------
+	RBAC struct {
+		ChangesSubject string        `env:"RBAC_CHANGES_SUBJECT" envDefault:"auth.rbac.changed"` // core NATS, outside the user stream
+		CacheTTL       time.Duration `env:"RBAC_CACHE_TTL" envDefault:"5m"`                      // reload even without a notice
+	}
+
+	// ------------ Policy ------------
+	Policy struct {
+		File        string `env:"POLICY_FILE"`                           // empty uses the built-in policy.json
+		DecisionLog string `env:"POLICY_DECISION_LOG" envDefault:"deny"` // all, deny or off
+
+		CheckCacheTTL  time.Duration `env:"POLICY_CHECK_CACHE_TTL" envDefault:"5s"` // CheckPermission decisions, 0 disables
+		CheckCacheSize int           `env:"POLICY_CHECK_CACHE_SIZE" envDefault:"10000"`
+	}
+
+	// ------------ Tenant ------------
+	Tenant struct {
+		CacheTTL time.Duration `env:"TENANT_CACHE_TTL" envDefault:"30s"` // config changes reach other replicas after this
+
+		// Seeded on first start, existing users belong to it
+		DefaultName              string `env:"TENANT_DEFAULT_NAME" envDefault:"Default"`
+		DefaultMinPasswordLength int    `env:"TENANT_DEFAULT_MIN_PASSWORD_LENGTH" envDefault:"8"`
 	}
 
 	// ------------ Redis ------------
@@ -81,6 +246,31 @@ type (
 		Expiration time.Duration `env:"JWT_EXPIRATION"` // token ttl
 	}
 
+	// ------------ Email ---------
+	Email struct {
+		Transport string `env:"EMAIL_TRANSPORT" envDefault:"smtp"`      // "smtp", "file" or "log"
+		FileDir   string `env:"EMAIL_FILE_DIR" envDefault:"./tmp/mail"` // maildir root for "file"
+		LogBody   bool   `env:"EMAIL_LOG_BODY" envDefault:"false"`      // "log" also logs bodies, development only
+
+		FoldAliases bool `env:"EMAIL_FOLD_ALIASES" envDefault:"false"` // gmail dots, +tags etc. match one account
+	}
+
+	// ------------ Email change ---------
+	EmailChange struct {
+		CodeTTL time.Duration `env:"EMAIL_CHANGE_CODE_TTL" envDefault:"15m"`
+		UndoTTL time.Duration `env:"EMAIL_CHANGE_UNDO_TTL" envDefault:"168h"` // old address can revert for
+		UndoURL string        `env:"EMAIL_CHANGE_UNDO_URL" envDefault:"http://localhost:3000/email-change/undo?token="`
+	}
+
+	// ------------ Registration and invitations ---------
+	Invitation struct {
+		RegistrationMode string        `env:"REGISTRATION_MODE" envDefault:"student"` // "student" or "disabled"
+		Secret           string        `env:"INVITE_SECRET,notEmpty"`                 // HMAC key for invite codes
+		TTL              time.Duration `env:"INVITE_TTL" envDefault:"168h"`
+		AcceptURL        string        `env:"INVITE_ACCEPT_URL" envDefault:"http://localhost:3000/invite?code="`
+		BootstrapAdmin   string        `env:"INVITE_BOOTSTRAP_ADMIN"` // invited as admin of the default tenant until the account exists
+	}
+
 	// ------------ Gomail ---------
 	Gomail struct {
 		From         string `env:"GOMAIL_FROM"`
@@ -88,6 +278,19 @@ type (
 		Port         int    `env:"GOMAIL_PORT"`
 		SMTPUsername string `env:"GOMAIL_SMTP_USERNAME"`
 		SMTPPassword string `env:"GOMAIL_SMTP_PASSWORD"`
+		TLSMode      string `env:"GOMAIL_TLS_MODE" envDefault:"starttls"` // "starttls", "implicit" or "none"
+		PoolSize     int    `env:"GOMAIL_POOL_SIZE" envDefault:"2"`       // idle SMTP conns kept open, 0 disables
+	}
+
+	// ------------ SMS ------------
+	SMS struct {
+		Transport          string        `env:"SMS_TRANSPORT" envDefault:"log"` // "log" or "http"
+		HTTPURL            string        `env:"SMS_HTTP_URL"`                   // provider gateway endpoint
+		HTTPToken          string        `env:"SMS_HTTP_TOKEN"`
+		From               string        `env:"SMS_FROM"`
+		Timeout            time.Duration `env:"SMS_TIMEOUT" envDefault:"10s"`
+		DefaultCountryCode string        `env:"SMS_DEFAULT_COUNTRY_CODE"`           // for numbers entered without one, e.g. "7"
+		SendCooldown       time.Duration `env:"SMS_SEND_COOLDOWN" envDefault:"60s"` // between codes to the same user or phone
 	}
 
 	// ------------ Log ------------
@@ -98,6 +301,11 @@ type (
 	}
 )
 
+// Authorization rules used when POLICY_FILE is not set
+//
+//go:embed policy.json
+var DefaultPolicy []byte
+
 func New() (*Config, error) {
 	var cfg Config
 	err := env.Parse(&cfg)
@@ -0,0 +1,85 @@
+package authz
+
+import (
+	"context"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/Neroframe/AuthService/pkg/policy"
+)
+
+// Decision log modes
+const (
+	LogAll  = "all"
+	LogDeny = "deny"
+	LogOff  = "off"
+)
+
+// PolicyAuthorizer checks requests against the rules in the policy file
+type PolicyAuthorizer struct {
+	engine  *policy.Engine
+	log     *logger.Logger
+	logMode string
+}
+
+var _ domain.Authorizer = (*PolicyAuthorizer)(nil)
+
+func NewPolicyAuthorizer(engine *policy.Engine, log *logger.Logger, logMode string) *PolicyAuthorizer {
+	return &PolicyAuthorizer{engine: engine, log: log, logMode: logMode}
+}
+
+func (a *PolicyAuthorizer) Authorize(ctx context.Context, sub domain.AuthzSubject, action string, res domain.AuthzResource) (*domain.AuthzDecision, error) {
+	d := a.engine.Evaluate(subjectInput(sub), action, resourceInput(res))
+	a.logDecision(ctx, sub, action, res, d)
+
+	return &domain.AuthzDecision{Allowed: d.Allowed, Rule: d.Rule, Reason: d.Reason}, nil
+}
+
+func (a *PolicyAuthorizer) logDecision(ctx context.Context, sub domain.AuthzSubject, action string, res domain.AuthzResource, d policy.Decision) {
+	for _, e := range d.Errors {
+		a.log.Error("policy rule failed", "action", action, "err", e)
+	}
+	if a.logMode == LogOff || (a.logMode == LogDeny && d.Allowed) {
+		return
+	}
+
+	var requestID string
+	if meta, ok := ctx.Value(middleware.RequestMetaCtxKey).(*domain.RequestMeta); ok {
+		requestID = meta.RequestID
+	}
+	a.log.Info("policy decision",
+		"request_id", requestID,
+		"subject_id", sub.ID,
+		"subject_role", sub.Role,
+		"action", action,
+		"resource_type", res.Type,
+		"resource_id", res.ID,
+		"allowed", d.Allowed,
+		"rule", d.Rule,
+		"reason", d.Reason,
+		"duration", d.Duration,
+	)
+}
+
+// Field names here are what rules see, keep them in sync with the policy file
+func subjectInput(s domain.AuthzSubject) map[string]any {
+	return map[string]any{
+		"id":          s.ID,
+		"tenant":      s.Tenant,
+		"role":        string(s.Role),
+		"permissions": s.Permissions,
+		"groups":      s.Groups,
+	}
+}
+
+func resourceInput(r domain.AuthzResource) map[string]any {
+	return map[string]any{
+		"type":     r.Type,
+		"id":       r.ID,
+		"tenant":   r.Tenant,
+		"owner_id": r.OwnerID,
+		"role":     string(r.Role),
+		"attrs":    r.Attrs,
+	}
+}
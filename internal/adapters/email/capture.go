@@ -0,0 +1,111 @@
+package email
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+)
+
+type Message struct {
+	To       string
+	Subject  string
+	HTMLBody string
+	SentAt   time.Time
+}
+
+// CaptureSender keeps sent messages in memory, for tests
+type CaptureSender struct {
+	mu       sync.Mutex
+	messages []Message
+	notify   chan struct{} // closed and replaced on every Send
+}
+
+var _ domain.EmailSender = (*CaptureSender)(nil)
+
+func NewCaptureSender() *CaptureSender {
+	return &CaptureSender{notify: make(chan struct{})}
+}
+
+func (s *CaptureSender) Send(to, subject, htmlBody string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = append(s.messages, Message{
+		To:       to,
+		Subject:  subject,
+		HTMLBody: htmlBody,
+		SentAt:   time.Now().UTC(),
+	})
+
+	// Wake up WaitFor callers
+	close(s.notify)
+	s.notify = make(chan struct{})
+
+	return nil
+}
+
+// All captured messages in send order
+func (s *CaptureSender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Message, len(s.messages))
+	copy(out, s.messages)
+	return out
+}
+
+// All messages sent to a recipient
+func (s *CaptureSender) To(to string) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []Message
+	for _, m := range s.messages {
+		if m.To == to {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Most recent message sent to a recipient
+func (s *CaptureSender) Last(to string) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.messages[i].To == to {
+			return s.messages[i], true
+		}
+	}
+	return Message{}, false
+}
+
+// Block until a message to the recipient arrives or ctx is done
+func (s *CaptureSender) WaitFor(ctx context.Context, to string) (Message, error) {
+	for {
+		s.mu.Lock()
+		notify := s.notify
+		s.mu.Unlock()
+
+		if m, ok := s.Last(to); ok {
+			return m, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+// Drop all captured messages
+func (s *CaptureSender) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = nil
+}
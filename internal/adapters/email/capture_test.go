@@ -0,0 +1,57 @@
+package email
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCaptureSender(t *testing.T) {
+	s := NewCaptureSender()
+	s.Send("a@example.com", "first", "<p>1</p>")
+	s.Send("b@example.com", "other", "<p>2</p>")
+	s.Send("a@example.com", "second", "<p>3</p>")
+
+	if got := len(s.Messages()); got != 3 {
+		t.Fatalf("Messages = %d, want 3", got)
+	}
+	if got := len(s.To("a@example.com")); got != 2 {
+		t.Errorf("To = %d, want 2", got)
+	}
+	if m, ok := s.Last("a@example.com"); !ok || m.Subject != "second" {
+		t.Errorf("Last = %q, %t, want second", m.Subject, ok)
+	}
+	if _, ok := s.Last("c@example.com"); ok {
+		t.Error("Last found a message for an unknown recipient")
+	}
+
+	s.Reset()
+	if got := len(s.Messages()); got != 0 {
+		t.Errorf("Messages after Reset = %d, want 0", got)
+	}
+}
+
+func TestCaptureSenderWaitFor(t *testing.T) {
+	s := NewCaptureSender()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.Send("b@example.com", "noise", "")
+		s.Send("a@example.com", "code", "123456")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m, err := s.WaitFor(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.HTMLBody != "123456" {
+		t.Errorf("WaitFor body = %q", m.HTMLBody)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.WaitFor(ctx, "c@example.com"); err == nil {
+		t.Error("WaitFor returned without a message")
+	}
+}
@@ -0,0 +1,63 @@
+package email
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/google/uuid"
+)
+
+// FileSender writes every message into a local maildir (tmp/, new/, cur/)
+// so it can be opened with any mail client during development.
+type FileSender struct {
+	from string
+	dir  string
+}
+
+var _ domain.EmailSender = (*FileSender)(nil)
+
+func NewFileSender(dir, from string) (*FileSender, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("create maildir %s: %w", sub, err)
+		}
+	}
+	return &FileSender{from: from, dir: dir}, nil
+}
+
+func (s *FileSender) Send(to, subject, htmlBody string) error {
+	now := time.Now().UTC()
+	name := fmt.Sprintf("%d.%s.authservice", now.UnixNano(), uuid.NewString())
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(s.from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(to))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(htmlBody)
+
+	// Write into tmp and rename into new, so readers never see partial files
+	tmpPath := filepath.Join(s.dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("maildir write: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, "new", name)); err != nil {
+		return fmt.Errorf("maildir deliver: %w", err)
+	}
+
+	return nil
+}
+
+// Line breaks would end the header and inject new ones
+var headerBreaks = strings.NewReplacer("\r", "", "\n", "")
+
+func headerValue(v string) string {
+	return headerBreaks.Replace(v)
+}
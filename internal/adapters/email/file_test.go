@@ -0,0 +1,69 @@
+package email
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSender(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileSender(dir, "noreply@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send("a@example.com", "Hello", "<p>hi</p>"); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := onlyMessage(t, dir)
+	for _, want := range []string{
+		"From: noreply@example.com\r\n",
+		"To: a@example.com\r\n",
+		"Subject: Hello\r\n",
+		"Content-Type: text/html; charset=UTF-8\r\n",
+		"\r\n\r\n<p>hi</p>",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message lacks %q:\n%s", want, msg)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(entries) != 0 {
+		t.Errorf("tmp holds %d files after delivery", len(entries))
+	}
+}
+
+func TestFileSenderStripsHeaderBreaks(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileSender(dir, "noreply@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send("a@example.com\r\nBcc: evil@example.com", "Hi\nX-Injected: 1", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := onlyMessage(t, dir)
+	for _, line := range strings.Split(msg, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") || strings.HasPrefix(line, "X-Injected:") {
+			t.Errorf("injected header %q", line)
+		}
+	}
+}
+
+func onlyMessage(t *testing.T, dir string) string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(dir, "new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("new holds %d messages, want 1", len(entries))
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "new", entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
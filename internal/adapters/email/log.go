@@ -0,0 +1,28 @@
+package email
+
+import (
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/pkg/logger"
+)
+
+// LogSender only logs outgoing messages, nothing is delivered. Bodies hold
+// codes and links, so they are only logged with logBody, for development.
+type LogSender struct {
+	log     *logger.Logger
+	logBody bool
+}
+
+var _ domain.EmailSender = (*LogSender)(nil)
+
+func NewLogSender(log *logger.Logger, logBody bool) *LogSender {
+	return &LogSender{log: log, logBody: logBody}
+}
+
+func (s *LogSender) Send(to, subject, htmlBody string) error {
+	if s.logBody {
+		s.log.Info("email sent (log transport)", "to", to, "subject", subject, "body", htmlBody)
+		return nil
+	}
+	s.log.Info("email sent (log transport)", "to", to, "subject", subject)
+	return nil
+}
@@ -0,0 +1,55 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) DeleteMyAccount(ctx context.Context, req *authpb.DeleteMyAccountRequest) (*authpb.DeleteMyAccountResponse, error) {
+	if req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "password required")
+	}
+
+	purgeAt, err := h.uc.DeleteMyAccount(ctx, req.Password)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCredentials) {
+			return nil, status.Error(codes.Unauthenticated, "invalid password")
+		}
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		h.log.Error("DeleteMyAccount failed", "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &authpb.DeleteMyAccountResponse{Success: true, PurgeAt: purgeAt.Unix()}, nil
+}
+
+func (h *AuthHandler) RestoreAccount(ctx context.Context, req *authpb.RestoreAccountRequest) (*authpb.RestoreAccountResponse, error) {
+	// Older clients only send email
+	identifier := req.Identifier
+	if identifier == "" {
+		identifier = req.Email
+	}
+	if identifier == "" || req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "identifier and password required")
+	}
+
+	if err := h.uc.RestoreAccount(ctx, identifier, req.Password); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) || errors.Is(err, domain.ErrInvalidCredentials) {
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		if errors.Is(err, domain.ErrNotRestorable) {
+			return nil, status.Error(codes.FailedPrecondition, "account is not awaiting deletion")
+		}
+		h.log.Error("RestoreAccount failed", "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &authpb.RestoreAccountResponse{Success: true}, nil
+}
@@ -0,0 +1,163 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) ListUsers(ctx context.Context, req *authpb.ListUsersRequest) (*authpb.ListUsersResponse, error) {
+	f := domain.UserFilter{
+		Query:  req.Query,
+		Cursor: req.PageToken,
+		Limit:  int(req.PageSize),
+	}
+	if req.RoleName != "" {
+		f.Role = domain.Role(req.RoleName)
+	} else if req.Role != authpb.Role_UNSPECIFIED {
+		role, err := convertProtoRole(req.Role)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid role")
+		}
+		f.Role = role
+	}
+	if req.Verified != nil {
+		v := req.GetVerified()
+		f.Verified = &v
+	}
+	if req.CreatedFrom > 0 {
+		f.CreatedFrom = time.Unix(req.CreatedFrom, 0).UTC()
+	}
+	if req.CreatedTo > 0 {
+		f.CreatedTo = time.Unix(req.CreatedTo, 0).UTC()
+	}
+
+	users, next, err := h.uc.ListUsers(ctx, f)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCursor) {
+			return nil, status.Error(codes.InvalidArgument, "invalid page token")
+		}
+		if errors.Is(err, domain.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
+		h.log.Error("failed to list users", "err", err)
+		return nil, status.Error(codes.Internal, "failed to list users")
+	}
+
+	resp := &authpb.ListUsersResponse{
+		Users:         make([]*authpb.UserSummary, 0, len(users)),
+		NextPageToken: next,
+	}
+	for _, u := range users {
+		resp.Users = append(resp.Users, toUserSummary(u))
+	}
+
+	return resp, nil
+}
+
+func (h *AuthHandler) DeleteUser(ctx context.Context, req *authpb.DeleteUserRequest) (*authpb.DeleteUserResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id required")
+	}
+
+	if err := h.uc.DeleteUser(ctx, req.UserId); err != nil {
+		return nil, h.adminError(err, "failed to delete user")
+	}
+	return &authpb.DeleteUserResponse{Success: true}, nil
+}
+
+func (h *AuthHandler) SetUserRole(ctx context.Context, req *authpb.SetUserRoleRequest) (*authpb.SetUserRoleResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id required")
+	}
+	role := domain.Role(req.RoleName)
+	if role == domain.UNSPECIFIED {
+		var err error
+		if role, err = convertProtoRole(req.Role); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid role")
+		}
+	}
+
+	usr, err := h.uc.SetUserRole(ctx, req.UserId, role)
+	if err != nil {
+		return nil, h.adminError(err, "failed to set user role")
+	}
+	return &authpb.SetUserRoleResponse{User: toUserSummary(usr)}, nil
+}
+
+func (h *AuthHandler) SetUserVerified(ctx context.Context, req *authpb.SetUserVerifiedRequest) (*authpb.SetUserVerifiedResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id required")
+	}
+
+	usr, err := h.uc.SetUserVerified(ctx, req.UserId, req.Verified)
+	if err != nil {
+		return nil, h.adminError(err, "failed to set user verified")
+	}
+	return &authpb.SetUserVerifiedResponse{User: toUserSummary(usr)}, nil
+}
+
+func (h *AuthHandler) SetAccountStatus(ctx context.Context, req *authpb.SetAccountStatusRequest) (*authpb.SetAccountStatusResponse, error) {
+	if req.UserId == "" || req.Status == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id and status required")
+	}
+
+	p := domain.SetStatusParams{
+		UserID: req.UserId,
+		Status: domain.UserStatus(req.Status),
+		Reason: req.Reason,
+	}
+	if req.SuspendedUntil > 0 {
+		until := time.Unix(req.SuspendedUntil, 0).UTC()
+		p.Until = &until
+	}
+
+	usr, err := h.uc.SetAccountStatus(ctx, p)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidStatus) {
+			return nil, status.Error(codes.InvalidArgument, "invalid status or suspension expiry")
+		}
+		return nil, h.adminError(err, "failed to set account status")
+	}
+	return &authpb.SetAccountStatusResponse{User: toUserSummary(usr)}, nil
+}
+
+func (h *AuthHandler) adminError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, domain.ErrRoleNotFound):
+		return status.Error(codes.InvalidArgument, "unknown role")
+	}
+	h.log.Error(msg, "err", err)
+	return status.Error(codes.Internal, msg)
+}
+
+func toUserSummary(u *domain.User) *authpb.UserSummary {
+	s := &authpb.UserSummary{
+		UserId:    u.ID,
+		Email:     u.Email,
+		Username:  u.Username,
+		Role:      convertRole(u.Role),
+		RoleName:  string(u.Role),
+		Phone:     u.Phone,
+		Verified:  u.Verified,
+		Status:    string(u.Status),
+		CreatedAt: u.CreatedAt.Unix(),
+	}
+	if !u.UpdatedAt.IsZero() {
+		s.UpdatedAt = u.UpdatedAt.Unix()
+	}
+	s.StatusReason = u.StatusReason
+	if u.StatusUntil != nil {
+		s.StatusUntil = u.StatusUntil.Unix()
+	}
+	return s
+}
@@ -0,0 +1,63 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) ListAuditEvents(ctx context.Context, req *authpb.ListAuditEventsRequest) (*authpb.ListAuditEventsResponse, error) {
+	f := domain.AuditFilter{
+		ActorID:  req.ActorId,
+		TargetID: req.TargetId,
+		Action:   domain.AuditAction(req.Action),
+		Outcome:  domain.AuditOutcome(req.Outcome),
+		Cursor:   req.PageToken,
+		Limit:    int(req.PageSize),
+	}
+	if req.From > 0 {
+		f.From = time.Unix(req.From, 0).UTC()
+	}
+	if req.To > 0 {
+		f.To = time.Unix(req.To, 0).UTC()
+	}
+
+	events, next, err := h.uc.ListAuditEvents(ctx, f)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCursor) {
+			return nil, status.Error(codes.InvalidArgument, "invalid page token")
+		}
+		if errors.Is(err, domain.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
+		h.log.Error("failed to list audit events", "err", err)
+		return nil, status.Error(codes.Internal, "failed to list audit events")
+	}
+
+	resp := &authpb.ListAuditEventsResponse{
+		Events:        make([]*authpb.AuditEvent, 0, len(events)),
+		NextPageToken: next,
+	}
+	for _, e := range events {
+		resp.Events = append(resp.Events, &authpb.AuditEvent{
+			Id:        e.ID,
+			Action:    string(e.Action),
+			ActorId:   e.ActorID,
+			ActorRole: convertRole(e.ActorRole),
+			TargetId:  e.TargetID,
+			Ip:        e.IP,
+			UserAgent: e.UserAgent,
+			RequestId: e.RequestID,
+			Outcome:   string(e.Outcome),
+			Details:   e.Details,
+			CreatedAt: e.CreatedAt.Unix(),
+		})
+	}
+
+	return resp, nil
+}
@@ -16,11 +16,21 @@ import (
 type AuthHandler struct {
 	authpb.UnimplementedAuthServiceServer
 	uc  usecase.UserUsecase
+	wh  usecase.WebhookUsecase
+	ex  usecase.ExportUsecase
+	rb  usecase.RBACUsecase
+	ck  usecase.CheckUsecase
+	rl  usecase.RelationUsecase
+	tn  usecase.TenantUsecase
+	gr  usecase.GroupUsecase
+	iv  usecase.InvitationUsecase
+	im  usecase.ImportUsecase
+	sc  usecase.ScimUsecase
 	log *logger.Logger
 }
 
-func NewHandler(uc usecase.UserUsecase, log *logger.Logger) *AuthHandler {
-	return &AuthHandler{uc: uc, log: log}
+func NewHandler(uc usecase.UserUsecase, wh usecase.WebhookUsecase, ex usecase.ExportUsecase, rb usecase.RBACUsecase, ck usecase.CheckUsecase, rl usecase.RelationUsecase, tn usecase.TenantUsecase, gr usecase.GroupUsecase, iv usecase.InvitationUsecase, im usecase.ImportUsecase, sc usecase.ScimUsecase, log *logger.Logger) *AuthHandler {
+	return &AuthHandler{uc: uc, wh: wh, ex: ex, rb: rb, ck: ck, rl: rl, tn: tn, gr: gr, iv: iv, im: im, sc: sc, log: log}
 }
 
 func (h *AuthHandler) Register(ctx context.Context, req *authpb.RegisterRequest) (*authpb.RegisterResponse, error) {
@@ -28,17 +38,29 @@ func (h *AuthHandler) Register(ctx context.Context, req *authpb.RegisterRequest)
 		return nil, status.Error(codes.InvalidArgument, "Email and password required")
 	}
 
-	// Validate and convert into domain.Role
-	role, err := convertProtoRole(req.Role)
-	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid role")
+	// Validate and convert into domain.Role, no role means student
+	role := domain.STUDENT
+	if req.Role != authpb.Role_UNSPECIFIED {
+		var err error
+		if role, err = convertProtoRole(req.Role); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid role")
+		}
 	}
 
 	usr, err := h.uc.Register(ctx, req.Email, req.Password, role)
 	if err != nil {
+		if errors.Is(err, domain.ErrRegistrationOff) || errors.Is(err, domain.ErrRoleNotAllowed) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
 		if errors.Is(err, domain.ErrEmailAlreadyExists) {
 			return nil, status.Error(codes.AlreadyExists, "email already in use")
 		}
+		if errors.Is(err, domain.ErrInvalidEmail) {
+			return nil, status.Error(codes.InvalidArgument, "invalid email")
+		}
+		if errors.Is(err, domain.ErrWeakPassword) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		h.log.Error("Register failed", "err", err)
 		return nil, status.Error(codes.Internal, "internal error")
 	}
@@ -52,11 +74,65 @@ func (h *AuthHandler) Register(ctx context.Context, req *authpb.RegisterRequest)
 	}, nil
 }
 
+func (h *AuthHandler) CreateStudentAccount(ctx context.Context, req *authpb.CreateStudentAccountRequest) (*authpb.CreateStudentAccountResponse, error) {
+	if req.Username == "" || req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "username and password required")
+	}
+
+	usr, err := h.uc.CreateStudentAccount(ctx, req.Username, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidUsername):
+			return nil, status.Error(codes.InvalidArgument, "invalid username")
+		case errors.Is(err, domain.ErrUsernameAlreadyExists):
+			return nil, status.Error(codes.AlreadyExists, "username already in use")
+		case errors.Is(err, domain.ErrWeakPassword):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, domain.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
+		h.log.Error("CreateStudentAccount failed", "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &authpb.CreateStudentAccountResponse{UserId: usr.ID, Username: usr.Username}, nil
+}
+
 func (h *AuthHandler) Login(ctx context.Context, req *authpb.LoginRequest) (*authpb.LoginResponse, error) {
-	token, payload, err := h.uc.Login(ctx, req.Email, req.Password)
+	// Older clients only send email
+	identifier := req.Identifier
+	if identifier == "" {
+		identifier = req.Email
+	}
+	if identifier == "" || req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "identifier and password required")
+	}
+
+	token, payload, err := h.uc.Login(ctx, identifier, req.Password, req.NewPassword)
 	if err != nil {
 		if errors.Is(err, domain.ErrUserNotFound) || errors.Is(err, domain.ErrInvalidCredentials) {
-			return nil, status.Error(codes.Unauthenticated, "invalid email or password")
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		if errors.Is(err, domain.ErrAccountDisabled) {
+			return nil, status.Error(codes.PermissionDenied, "account disabled")
+		}
+		if errors.Is(err, domain.ErrAccountSuspended) {
+			return nil, status.Error(codes.PermissionDenied, "account suspended")
+		}
+		if errors.Is(err, domain.ErrAccountNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, "account pending verification")
+		}
+		if errors.Is(err, domain.ErrAccountDeleted) {
+			return nil, status.Error(codes.PermissionDenied, "account deleted")
+		}
+		if errors.Is(err, domain.ErrIdPNotAllowed) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		if errors.Is(err, domain.ErrPasswordChangeRequired) {
+			return nil, status.Error(codes.FailedPrecondition, "password change required, send new_password")
+		}
+		if errors.Is(err, domain.ErrWeakPassword) || errors.Is(err, domain.ErrPasswordUnchanged) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
 		}
 		h.log.Error("Login failed", "err", err)
 		return nil, status.Error(codes.Internal, "internal error")
@@ -69,9 +145,19 @@ func (h *AuthHandler) Login(ctx context.Context, req *authpb.LoginRequest) (*aut
 		RefreshToken: "", // TODO ?
 		ExpiresAt:    payload.ExpiresAt,
 		TokenType:    "Bearer",
+		TenantId:     payload.TenantID,
 	}, nil
 }
 
+func (h *AuthHandler) Logout(ctx context.Context, req *authpb.LogoutRequest) (*authpb.LogoutResponse, error) {
+	if err := h.uc.Logout(ctx, req.AllSessions); err != nil {
+		h.log.Error("Logout failed", "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &authpb.LogoutResponse{Success: true}, nil
+}
+
 func (h *AuthHandler) ValidateToken(ctx context.Context, req *authpb.ValidateTokenRequest) (*authpb.ValidateTokenResponse, error) {
 	payload, err := h.uc.ValidateToken(ctx, req.Jwt)
 	if err != nil {
@@ -86,6 +172,7 @@ func (h *AuthHandler) ValidateToken(ctx context.Context, req *authpb.ValidateTok
 		UserId:    payload.UserID,
 		Role:      convertRole(payload.Role), // map domain.Role to authpb.Role
 		ExpiresAt: payload.ExpiresAt,
+		TenantId:  payload.TenantID,
 	}, nil
 }
 
@@ -0,0 +1,76 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) CheckPermission(ctx context.Context, req *authpb.CheckPermissionRequest) (*authpb.CheckPermissionResponse, error) {
+	d, err := h.ck.CheckPermission(ctx, req.GetSubject().GetUserId(), domain.AuthzCheck{
+		Action:   req.Action,
+		Resource: toDomainResource(req.Resource),
+	})
+	if err != nil {
+		return nil, h.checkError(err, "failed to check permission")
+	}
+	return &authpb.CheckPermissionResponse{Decision: toPbDecision(d)}, nil
+}
+
+func (h *AuthHandler) BatchCheck(ctx context.Context, req *authpb.BatchCheckRequest) (*authpb.BatchCheckResponse, error) {
+	checks := make([]domain.AuthzCheck, 0, len(req.Checks))
+	for _, c := range req.Checks {
+		checks = append(checks, domain.AuthzCheck{Action: c.GetAction(), Resource: toDomainResource(c.GetResource())})
+	}
+
+	ds, err := h.ck.BatchCheck(ctx, req.GetSubject().GetUserId(), checks)
+	if err != nil {
+		return nil, h.checkError(err, "failed to check permissions")
+	}
+
+	resp := &authpb.BatchCheckResponse{Decisions: make([]*authpb.CheckDecision, 0, len(ds))}
+	for _, d := range ds {
+		resp.Decisions = append(resp.Decisions, toPbDecision(d))
+	}
+	return resp, nil
+}
+
+func (h *AuthHandler) checkError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidCheck):
+		return status.Error(codes.InvalidArgument, "every check needs an action")
+	case errors.Is(err, domain.ErrTooManyChecks):
+		return status.Error(codes.InvalidArgument, "at most 100 checks per batch")
+	case errors.Is(err, domain.ErrUserNotFound):
+		return status.Error(codes.NotFound, "subject not found")
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "checking other subjects needs authz.check")
+	}
+	h.log.Error(msg, "err", err)
+	return status.Error(codes.Internal, msg)
+}
+
+func toDomainResource(r *authpb.CheckResource) domain.AuthzResource {
+	return domain.AuthzResource{
+		Type:    r.GetType(),
+		ID:      r.GetId(),
+		OwnerID: r.GetOwnerId(),
+		Role:    domain.Role(r.GetRole()),
+		Attrs:   r.GetAttrs(),
+	}
+}
+
+func toPbDecision(d *domain.AuthzDecision) *authpb.CheckDecision {
+	return &authpb.CheckDecision{
+		Allowed:            d.Allowed,
+		Rule:               d.Rule,
+		Reason:             d.Reason,
+		SubjectRole:        string(d.SubjectRole),
+		SubjectPermissions: d.SubjectPermissions,
+		Cached:             d.Cached,
+	}
+}
@@ -0,0 +1,93 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) RequestEmailChange(ctx context.Context, req *authpb.RequestEmailChangeRequest) (*authpb.RequestEmailChangeResponse, error) {
+	if req.NewEmail == "" || req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "new email and password required")
+	}
+
+	if err := h.uc.RequestEmailChange(ctx, req.NewEmail, req.Password); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidCredentials):
+			return nil, status.Error(codes.Unauthenticated, "invalid password")
+		case errors.Is(err, domain.ErrInvalidEmail):
+			return nil, status.Error(codes.InvalidArgument, "invalid email")
+		case errors.Is(err, domain.ErrEmailUnchanged):
+			return nil, status.Error(codes.InvalidArgument, "new email is the current email")
+		case errors.Is(err, domain.ErrEmailAlreadyExists):
+			return nil, status.Error(codes.AlreadyExists, "email already in use")
+		case errors.Is(err, domain.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		h.log.Error("RequestEmailChange failed", "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &authpb.RequestEmailChangeResponse{Success: true}, nil
+}
+
+func (h *AuthHandler) ConfirmEmailChange(ctx context.Context, req *authpb.ConfirmEmailChangeRequest) (*authpb.ConfirmEmailChangeResponse, error) {
+	if req.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code required")
+	}
+
+	usr, err := h.uc.ConfirmEmailChange(ctx, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNoPendingEmailChange):
+			return nil, status.Error(codes.FailedPrecondition, "no pending email change")
+		case errors.Is(err, domain.ErrCodeExpired):
+			return nil, status.Error(codes.InvalidArgument, "expired code")
+		case errors.Is(err, domain.ErrCodeInvalid):
+			return nil, status.Error(codes.InvalidArgument, "invalid code")
+		case errors.Is(err, domain.ErrEmailAlreadyExists):
+			return nil, status.Error(codes.AlreadyExists, "email already in use")
+		case errors.Is(err, domain.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		h.log.Error("ConfirmEmailChange failed", "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &authpb.ConfirmEmailChangeResponse{
+		Success: true,
+		User: &authpb.User{
+			UserId:        usr.ID,
+			Email:         usr.Email,
+			Username:      usr.Username,
+			Role:          convertRole(usr.Role),
+			Phone:         usr.Phone,
+			PhoneVerified: usr.PhoneVerified,
+			RoleName:      string(usr.Role),
+			TenantId:      usr.TenantID,
+		},
+	}, nil
+}
+
+func (h *AuthHandler) UndoEmailChange(ctx context.Context, req *authpb.UndoEmailChangeRequest) (*authpb.UndoEmailChangeResponse, error) {
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token required")
+	}
+
+	if err := h.uc.UndoEmailChange(ctx, req.Token); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUndoTokenInvalid):
+			return nil, status.Error(codes.InvalidArgument, "invalid or expired link")
+		case errors.Is(err, domain.ErrEmailAlreadyExists):
+			return nil, status.Error(codes.AlreadyExists, "previous email is now used by another account")
+		}
+		h.log.Error("UndoEmailChange failed", "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &authpb.UndoEmailChangeResponse{Success: true}, nil
+}
@@ -0,0 +1,139 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const exportChunkSize = 64 << 10
+
+func (h *AuthHandler) ExportMyData(ctx context.Context, req *authpb.ExportMyDataRequest) (*authpb.ExportDataResponse, error) {
+	format := exportFormat(req.Format)
+	res, err := h.ex.ExportMyData(ctx, format)
+	if err != nil {
+		return nil, h.exportError(err, "failed to export data")
+	}
+	return toExportResponse(res, format), nil
+}
+
+func (h *AuthHandler) ExportUserData(ctx context.Context, req *authpb.ExportUserDataRequest) (*authpb.ExportDataResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id required")
+	}
+
+	format := exportFormat(req.Format)
+	res, err := h.ex.ExportUserData(ctx, req.UserId, format)
+	if err != nil {
+		return nil, h.exportError(err, "failed to export user data")
+	}
+	return toExportResponse(res, format), nil
+}
+
+func (h *AuthHandler) GetDataExport(ctx context.Context, req *authpb.GetDataExportRequest) (*authpb.DataExport, error) {
+	if req.ExportId == "" {
+		return nil, status.Error(codes.InvalidArgument, "export_id required")
+	}
+
+	exp, err := h.ex.GetDataExport(ctx, req.ExportId)
+	if err != nil {
+		return nil, h.exportError(err, "failed to get data export")
+	}
+	return toProtoExport(exp), nil
+}
+
+func (h *AuthHandler) DownloadDataExport(req *authpb.DownloadDataExportRequest, stream grpc.ServerStreamingServer[authpb.DataExportChunk]) error {
+	if req.DownloadToken == "" {
+		return status.Error(codes.InvalidArgument, "download_token required")
+	}
+
+	exp, bundle, err := h.ex.OpenDataExport(stream.Context(), req.DownloadToken)
+	if err != nil {
+		return h.exportError(err, "failed to open data export")
+	}
+	defer bundle.Close()
+
+	buf := make([]byte, exportChunkSize)
+	first := true
+	for {
+		n, err := bundle.Read(buf)
+		if n > 0 {
+			chunk := &authpb.DataExportChunk{Data: buf[:n]}
+			if first {
+				chunk.ContentType = contentType(exp.Format)
+				first = false
+			}
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			h.log.Error("failed to read data export", "export_id", exp.ID, "err", err)
+			return status.Error(codes.Internal, "failed to read data export")
+		}
+	}
+}
+
+func (h *AuthHandler) exportError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidExportType):
+		return status.Error(codes.InvalidArgument, "format must be json or zip")
+	case errors.Is(err, domain.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, domain.ErrExportNotFound):
+		return status.Error(codes.NotFound, "data export not found")
+	case errors.Is(err, domain.ErrExportNotReady):
+		return status.Error(codes.FailedPrecondition, "data export not ready")
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	}
+	h.log.Error(msg, "err", err)
+	return status.Error(codes.Internal, msg)
+}
+
+func exportFormat(f string) domain.ExportFormat {
+	if f == "" {
+		return domain.ExportJSON
+	}
+	return domain.ExportFormat(f)
+}
+
+func contentType(f domain.ExportFormat) string {
+	if f == domain.ExportZIP {
+		return "application/zip"
+	}
+	return "application/json"
+}
+
+func toExportResponse(res *domain.ExportResult, format domain.ExportFormat) *authpb.ExportDataResponse {
+	if res.Export != nil {
+		return &authpb.ExportDataResponse{Export: toProtoExport(res.Export), DownloadToken: res.DownloadToken}
+	}
+	return &authpb.ExportDataResponse{Bundle: res.Bundle, ContentType: contentType(format)}
+}
+
+func toProtoExport(e *domain.DataExport) *authpb.DataExport {
+	pe := &authpb.DataExport{
+		ExportId:  e.ID,
+		UserId:    e.UserID,
+		Format:    string(e.Format),
+		Status:    string(e.Status),
+		Error:     e.Error,
+		Size:      e.Size,
+		CreatedAt: e.CreatedAt.Unix(),
+		ExpiresAt: e.ExpiresAt.Unix(),
+	}
+	if e.CompletedAt != nil {
+		pe.CompletedAt = e.CompletedAt.Unix()
+	}
+	return pe
+}
@@ -0,0 +1,108 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) CreateGroup(ctx context.Context, req *authpb.CreateGroupRequest) (*authpb.Group, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	grp, err := h.gr.CreateGroup(ctx, req.Name, domain.GroupKind(req.Kind), req.OwnerIds)
+	if err != nil {
+		return nil, h.groupError(err, "failed to create group")
+	}
+	return toProtoGroup(grp), nil
+}
+
+func (h *AuthHandler) AddGroupMembers(ctx context.Context, req *authpb.GroupMembersRequest) (*authpb.GroupMembersResponse, error) {
+	if req.GroupId == "" || len(req.UserIds) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "group_id and user_ids are required")
+	}
+
+	res, err := h.gr.AddGroupMembers(ctx, req.GroupId, req.Relation, req.UserIds)
+	if err != nil {
+		return nil, h.groupError(err, "failed to add group members")
+	}
+	return &authpb.GroupMembersResponse{Changed: res.Changed, NotFound: res.NotFound}, nil
+}
+
+func (h *AuthHandler) RemoveGroupMembers(ctx context.Context, req *authpb.GroupMembersRequest) (*authpb.GroupMembersResponse, error) {
+	if req.GroupId == "" || len(req.UserIds) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "group_id and user_ids are required")
+	}
+
+	res, err := h.gr.RemoveGroupMembers(ctx, req.GroupId, req.Relation, req.UserIds)
+	if err != nil {
+		return nil, h.groupError(err, "failed to remove group members")
+	}
+	return &authpb.GroupMembersResponse{Changed: res.Changed, NotFound: res.NotFound}, nil
+}
+
+func (h *AuthHandler) ListUserGroups(ctx context.Context, req *authpb.ListUserGroupsRequest) (*authpb.ListUserGroupsResponse, error) {
+	memberships, err := h.gr.ListUserGroups(ctx, req.UserId)
+	if err != nil {
+		return nil, h.groupError(err, "failed to list user groups")
+	}
+
+	out := make([]*authpb.UserGroup, 0, len(memberships))
+	for _, m := range memberships {
+		out = append(out, &authpb.UserGroup{Group: toProtoGroup(m.Group), Relation: m.Relation})
+	}
+	return &authpb.ListUserGroupsResponse{Groups: out}, nil
+}
+
+func (h *AuthHandler) ListGroupMembers(ctx context.Context, req *authpb.ListGroupMembersRequest) (*authpb.ListGroupMembersResponse, error) {
+	if req.GroupId == "" {
+		return nil, status.Error(codes.InvalidArgument, "group_id is required")
+	}
+
+	members, err := h.gr.ListGroupMembers(ctx, req.GroupId)
+	if err != nil {
+		return nil, h.groupError(err, "failed to list group members")
+	}
+
+	out := make([]*authpb.GroupMember, 0, len(members))
+	for _, m := range members {
+		pm := &authpb.GroupMember{UserId: m.UserID, Relation: m.Relation}
+		if m.User != nil {
+			pm.Username, pm.Email, pm.RoleName = m.User.Username, m.User.Email, string(m.User.Role)
+		}
+		out = append(out, pm)
+	}
+	return &authpb.ListGroupMembersResponse{Members: out}, nil
+}
+
+func (h *AuthHandler) groupError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrGroupNotFound):
+		return status.Error(codes.NotFound, "group not found")
+	case errors.Is(err, domain.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrInvalidGroup):
+		return status.Error(codes.InvalidArgument, "invalid group, relation or user list")
+	case errors.Is(err, domain.ErrTooManyMembers):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	}
+	h.log.Error(msg, "err", err)
+	return status.Error(codes.Internal, msg)
+}
+
+func toProtoGroup(g *domain.Group) *authpb.Group {
+	return &authpb.Group{
+		Id:        g.ID,
+		Name:      g.Name,
+		Kind:      string(g.Kind),
+		CreatedBy: g.CreatedBy,
+		CreatedAt: g.CreatedAt.Unix(),
+	}
+}
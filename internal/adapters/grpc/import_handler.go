@@ -0,0 +1,250 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) UploadImport(stream grpc.ClientStreamingServer[authpb.UploadImportRequest, authpb.ImportJob]) error {
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return status.Error(codes.InvalidArgument, "options required")
+	}
+	if err != nil {
+		return err
+	}
+	if first.GetOptions() == nil {
+		return status.Error(codes.InvalidArgument, "first message must hold the options")
+	}
+
+	job, err := h.im.UploadImport(stream.Context(), importOptions(first.GetOptions()), &uploadStream{stream: stream})
+	if err != nil {
+		return h.importError(err, "failed to upload import")
+	}
+	return stream.SendAndClose(importJobToProto(job))
+}
+
+func (h *AuthHandler) StartImport(ctx context.Context, req *authpb.StartImportRequest) (*authpb.ImportJob, error) {
+	if len(req.Paths) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "paths required")
+	}
+
+	job, err := h.im.StartImport(ctx, importOptions(req.Options), req.Paths)
+	if err != nil {
+		return nil, h.importError(err, "failed to start import")
+	}
+	return importJobToProto(job), nil
+}
+
+func (h *AuthHandler) GetImport(ctx context.Context, req *authpb.GetImportRequest) (*authpb.ImportJob, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	job, err := h.im.GetImport(ctx, req.Id)
+	if err != nil {
+		return nil, h.importError(err, "failed to get import")
+	}
+	return importJobToProto(job), nil
+}
+
+func (h *AuthHandler) ListImportResults(ctx context.Context, req *authpb.ListImportResultsRequest) (*authpb.ListImportResultsResponse, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	rows, next, err := h.im.ListImportResults(ctx, req.Id, domain.ImportOutcome(req.Outcome), req.PageToken, int(req.PageSize))
+	if err != nil {
+		return nil, h.importError(err, "failed to list import results")
+	}
+
+	resp := &authpb.ListImportResultsResponse{NextPageToken: next}
+	for _, r := range rows {
+		resp.Rows = append(resp.Rows, &authpb.ImportRowResult{
+			File:          r.File,
+			Line:          int32(r.Line),
+			Outcome:       string(r.Outcome),
+			Error:         r.Error,
+			UserId:        r.UserID,
+			ExternalId:    r.ExternalID,
+			Email:         r.Email,
+			Username:      r.Username,
+			GroupId:       r.GroupID,
+			Fields:        r.Fields,
+			Password:      r.Password,
+			InvitePending: r.InvitePending,
+		})
+	}
+	return resp, nil
+}
+
+func (h *AuthHandler) ResumeImport(ctx context.Context, req *authpb.ResumeImportRequest) (*authpb.ImportJob, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	job, err := h.im.ResumeImport(ctx, req.Id)
+	if err != nil {
+		return nil, h.importError(err, "failed to resume import")
+	}
+	return importJobToProto(job), nil
+}
+
+// Defaults: csv files, new accounts invited
+func importOptions(o *authpb.ImportOptions) domain.ImportOptions {
+	opts := domain.ImportOptions{
+		Format:      domain.ImportFormat(o.GetFormat()),
+		Credentials: domain.ImportCredentials(o.GetCredentials()),
+		DefaultRole: domain.Role(o.GetDefaultRole()),
+		DryRun:      o.GetDryRun(),
+	}
+	if opts.Format == "" {
+		opts.Format = domain.ImportCSV
+	}
+	if opts.Credentials == "" {
+		opts.Credentials = domain.CredentialsInvite
+	}
+	return opts
+}
+
+func importJobToProto(j *domain.ImportJob) *authpb.ImportJob {
+	pb := &authpb.ImportJob{
+		Id:     j.ID,
+		Status: string(j.Status),
+		Error:  j.Error,
+		Options: &authpb.ImportOptions{
+			Format:      string(j.Format),
+			Credentials: string(j.Credentials),
+			DefaultRole: string(j.DefaultRole),
+			DryRun:      j.DryRun,
+		},
+		Counts: &authpb.ImportCounts{
+			Created:   int32(j.Counts.Created),
+			Updated:   int32(j.Counts.Updated),
+			Unchanged: int32(j.Counts.Unchanged),
+			Removed:   int32(j.Counts.Removed),
+			Skipped:   int32(j.Counts.Skipped),
+			Invalid:   int32(j.Counts.Invalid),
+			Failed:    int32(j.Counts.Failed),
+		},
+		CreatedBy: j.CreatedBy,
+		Attempts:  int32(j.Attempts),
+		CreatedAt: j.CreatedAt.Unix(),
+		ExpiresAt: j.ExpiresAt.Unix(),
+	}
+	for _, f := range j.Files {
+		pb.Files = append(pb.Files, &authpb.ImportFile{Name: f.Name, Path: f.Path, Rows: int32(f.Rows), Done: f.Done})
+	}
+	if j.CompletedAt != nil {
+		pb.CompletedAt = j.CompletedAt.Unix()
+	}
+	return pb
+}
+
+func (h *AuthHandler) importError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidImport), errors.Is(err, domain.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrImportFileTooLarge):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, domain.ErrImportNotFound), errors.Is(err, domain.ErrRoleNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrImportPathsOff), errors.Is(err, domain.ErrImportNotResumable):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	// Broken or cancelled upload stream
+	if s, ok := status.FromError(err); ok {
+		return s.Err()
+	}
+	h.log.Error(msg, "err", err)
+	return status.Error(codes.Internal, msg)
+}
+
+// Files of an UploadImport stream. A chunk with a name starts the next file,
+// what the reader left of the current one is skipped.
+type uploadStream struct {
+	stream grpc.ClientStreamingServer[authpb.UploadImportRequest, authpb.ImportJob]
+	cur    *chunkReader
+	next   *authpb.ImportFileChunk // read ahead, first chunk of the next file
+	done   bool
+}
+
+func (u *uploadStream) Next() (string, io.Reader, error) {
+	if u.cur != nil {
+		if _, err := io.Copy(io.Discard, u.cur); err != nil {
+			return "", nil, err
+		}
+	}
+	if u.next == nil {
+		if u.done {
+			return "", nil, io.EOF
+		}
+		chunk, err := u.recv()
+		if err != nil {
+			return "", nil, err
+		}
+		u.next = chunk
+	}
+	if u.next.Name == "" {
+		return "", nil, status.Error(codes.InvalidArgument, "first chunk of a file needs its name")
+	}
+
+	name := u.next.Name
+	u.cur = &chunkReader{u: u, buf: u.next.Data}
+	u.next = nil
+	return name, u.cur, nil
+}
+
+func (u *uploadStream) recv() (*authpb.ImportFileChunk, error) {
+	req, err := u.stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			u.done = true
+		}
+		return nil, err
+	}
+	if req.GetChunk() == nil {
+		return nil, status.Error(codes.InvalidArgument, "options must only be in the first message")
+	}
+	return req.GetChunk(), nil
+}
+
+type chunkReader struct {
+	u   *uploadStream
+	buf []byte
+	eof bool
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		chunk, err := r.u.recv()
+		if errors.Is(err, io.EOF) {
+			r.eof = true
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if chunk.Name != "" {
+			r.u.next = chunk
+			r.eof = true
+			continue
+		}
+		r.buf = chunk.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,81 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) CreateInvitation(ctx context.Context, req *authpb.CreateInvitationRequest) (*authpb.Invitation, error) {
+	if req.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	role := domain.Role(req.RoleName)
+	if role == "" {
+		var err error
+		if role, err = convertProtoRole(req.Role); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "role or role_name is required")
+		}
+	}
+
+	inv, err := h.iv.CreateInvitation(ctx, req.Email, role, req.GroupId)
+	if err != nil {
+		return nil, h.invitationError(err, "failed to create invitation")
+	}
+	return &authpb.Invitation{
+		Id:        inv.ID,
+		Email:     inv.Email,
+		RoleName:  string(inv.Role),
+		GroupId:   inv.GroupID,
+		InvitedBy: inv.InvitedBy,
+		ExpiresAt: inv.ExpiresAt.Unix(),
+		CreatedAt: inv.CreatedAt.Unix(),
+	}, nil
+}
+
+func (h *AuthHandler) RevokeInvitation(ctx context.Context, req *authpb.RevokeInvitationRequest) (*authpb.RevokeInvitationResponse, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	if err := h.iv.RevokeInvitation(ctx, req.Id); err != nil {
+		return nil, h.invitationError(err, "failed to revoke invitation")
+	}
+	return &authpb.RevokeInvitationResponse{Success: true}, nil
+}
+
+func (h *AuthHandler) AcceptInvitation(ctx context.Context, req *authpb.AcceptInvitationRequest) (*authpb.AcceptInvitationResponse, error) {
+	if req.Code == "" || req.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "code and password required")
+	}
+
+	usr, err := h.iv.AcceptInvitation(ctx, req.Code, req.Password)
+	if err != nil {
+		return nil, h.invitationError(err, "failed to accept invitation")
+	}
+	return &authpb.AcceptInvitationResponse{UserId: usr.ID, TenantId: usr.TenantID, RoleName: string(usr.Role)}, nil
+}
+
+func (h *AuthHandler) invitationError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrInvitationInvalid):
+		return status.Error(codes.InvalidArgument, "invalid invitation code")
+	case errors.Is(err, domain.ErrInvitationExpired), errors.Is(err, domain.ErrInvitationUsed):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, domain.ErrEmailAlreadyExists):
+		return status.Error(codes.AlreadyExists, "email already in use")
+	case errors.Is(err, domain.ErrInvalidEmail), errors.Is(err, domain.ErrWeakPassword):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrRoleNotFound), errors.Is(err, domain.ErrGroupNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrTenantDisabled), errors.Is(err, domain.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	h.log.Error(msg, "err", err)
+	return status.Error(codes.Internal, msg)
+}
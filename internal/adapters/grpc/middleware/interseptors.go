@@ -2,34 +2,51 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"time"
 
 	"github.com/Neroframe/AuthService/internal/domain"
 	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/Neroframe/AuthService/pkg/realip"
 	authpb "github.com/Neroframe/AuthService/proto"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
-const UserCtxKey string = "userClaims"
+const (
+	UserCtxKey        string = "userClaims"
+	RequestMetaCtxKey string = "requestMeta"
+)
+
+// Decides whether a role may call a method
+type MethodAuthorizer interface {
+	AllowsMethod(ctx context.Context, role domain.Role, method string) (bool, error)
+}
 
 type AuthInterceptor struct {
 	publicMethods map[string]struct{}
-	permissions   map[string][]domain.Role
+	authz         MethodAuthorizer
+	tenants       domain.TenantDirectory
 	authClient    authpb.AuthServiceClient
 	jwtSvc        domain.JWTService
+	revoker       domain.SessionRevoker
+	proxies       *realip.Resolver
 	log           *logger.Logger
 }
 
 func NewAuthInterceptor(
 	publicMethods []string,
-	permissions map[string][]domain.Role,
+	authz MethodAuthorizer,
+	tenants domain.TenantDirectory,
 	client authpb.AuthServiceClient,
 	jwt domain.JWTService,
+	revoker domain.SessionRevoker,
+	proxies *realip.Resolver,
 	log *logger.Logger,
 ) *AuthInterceptor {
 	// build a set for quick public-check
@@ -40,9 +57,12 @@ func NewAuthInterceptor(
 
 	return &AuthInterceptor{
 		publicMethods: publicSet, // store the map
-		permissions:   permissions,
+		authz:         authz,
+		tenants:       tenants,
 		authClient:    client,
 		jwtSvc:        jwt,
+		revoker:       revoker,
+		proxies:       proxies,
 		log:           log,
 	}
 }
@@ -55,64 +75,112 @@ func (i *AuthInterceptor) UnaryAuthentificate() grpc.UnaryServerInterceptor {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (any, error) {
-		// Skip public
-		if _, ok := i.publicMethods[info.FullMethod]; ok {
-			return handler(ctx, req)
+		ctx, err := i.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
 		}
+		return handler(ctx, req)
+	}
+}
 
-		// Extract token
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Error(codes.Unauthenticated, "missing metadata")
+// Same checks for streaming methods, e.g. UploadImport
+func (i *AuthInterceptor) StreamAuthentificate() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := i.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
 		}
+		return handler(srv, &ctxStream{ServerStream: ss, ctx: ctx})
+	}
+}
 
-		authHeaders := md["authorization"]
-		if len(authHeaders) == 0 {
-			return nil, status.Error(codes.Unauthenticated, "authorization header not supplied")
+func (i *AuthInterceptor) authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+
+	// Skip public, the caller names its tenant
+	if _, public := i.publicMethods[method]; public {
+		tenantID := firstValue(md, "x-tenant-id")
+		if tenantID == "" {
+			tenantID = domain.DefaultTenantID
 		}
+		return i.withTenant(ctx, tenantID)
+	}
 
-		token := strings.TrimSpace(strings.TrimPrefix(authHeaders[0], "Bearer "))
+	// Extract token
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing metadata")
+	}
 
-		// Validate token
-		claims, err := i.jwtSvc.Validate(ctx, token)
-		if err != nil {
-			return nil, status.Error(codes.Unauthenticated, "invalid token")
-		}
+	authHeaders := md["authorization"]
+	if len(authHeaders) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization header not supplied")
+	}
 
-		// Role check
-		if allowedRoles, ok := i.permissions[info.FullMethod]; ok {
-			var found bool
-			for _, role := range allowedRoles {
-				if role == claims.Role {
-					found = true
-					break
-				}
-			}
-			if !found {
-				i.log.Warn("role is not authorized to pass", "role", claims.Role)
-				return nil, status.Error(codes.PermissionDenied, "role not authorized to pass")
-			}
-		}
+	token := strings.TrimSpace(strings.TrimPrefix(authHeaders[0], "Bearer "))
 
-		// Inject token payload into ctx
-		ctx = context.WithValue(ctx, UserCtxKey, claims)
-		return handler(ctx, req)
+	// Validate token
+	claims, err := i.jwtSvc.Validate(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	// Reject tokens of revoked sessions
+	revoked, err := i.revoker.IsRevoked(ctx, claims.UserID, claims.IssuedAt)
+	if err != nil {
+		i.log.Error("revocation check failed", "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	if revoked {
+		return nil, status.Error(codes.Unauthenticated, "token revoked")
+	}
+
+	// The token's tenant is the only one the caller can reach
+	if hdr := firstValue(md, "x-tenant-id"); hdr != "" && hdr != claims.TenantID {
+		return nil, status.Error(codes.PermissionDenied, "token belongs to another tenant")
+	}
+	ctx, err = i.withTenant(ctx, claims.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Role check
+	allowed, err := i.authz.AllowsMethod(ctx, claims.Role, method)
+	if err != nil {
+		i.log.Error("permission check failed", "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	if !allowed {
+		i.log.Warn("role is not authorized to pass", "role", claims.Role)
+		return nil, status.Error(codes.PermissionDenied, "role not authorized to pass")
+	}
+
+	// Inject token payload into ctx
+	return context.WithValue(ctx, UserCtxKey, claims), nil
+}
+
+// Scope ctx to an active tenant
+func (i *AuthInterceptor) withTenant(ctx context.Context, tenantID string) (context.Context, error) {
+	if !domain.ValidTenantID(tenantID) {
+		return nil, status.Error(codes.InvalidArgument, "invalid tenant")
+	}
+	tenant, err := i.tenants.Tenant(ctx, tenantID)
+	if err != nil {
+		if errors.Is(err, domain.ErrTenantNotFound) {
+			return nil, status.Error(codes.NotFound, "tenant not found")
+		}
+		i.log.Error("tenant lookup failed", "tenant", tenantID, "err", err)
+		return nil, status.Error(codes.Internal, "internal error")
 	}
+	if !tenant.IsActive() {
+		return nil, status.Error(codes.PermissionDenied, "tenant disabled")
+	}
+	return domain.WithTenant(ctx, tenantID), nil
 }
 
 // Logging incoming req
 func (i *AuthInterceptor) UnaryLoggingInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
-		// Extract or generate request ID
-		md, _ := metadata.FromIncomingContext(ctx)
-		var reqID string
-		if vals := md.Get("x-request-id"); len(vals) > 0 {
-			reqID = vals[0]
-		} else {
-			// Append reqID to ctx
-			reqID = uuid.New().String()
-			ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", reqID)
-		}
+		ctx, reqID := i.withRequestMeta(ctx)
 
 		// Start timer for req
 		start := time.Now()
@@ -145,4 +213,66 @@ func (i *AuthInterceptor) UnaryLoggingInterceptor() grpc.UnaryServerInterceptor
 	}
 }
 
+func (i *AuthInterceptor) StreamLoggingInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, reqID := i.withRequestMeta(ss.Context())
+
+		start := time.Now()
+		i.log.Info("incoming gRPC stream", "method", info.FullMethod, "request_id", reqID)
+
+		err := handler(srv, &ctxStream{ServerStream: ss, ctx: ctx})
+		if err != nil {
+			i.log.Error("gRPC stream FAILED", "method", info.FullMethod, "request_id", reqID, "duration", time.Since(start), "error", err)
+		} else {
+			i.log.Info("gRPC stream SUCCEDED", "method", info.FullMethod, "request_id", reqID, "duration", time.Since(start))
+		}
+		return err
+	}
+}
+
+// Request ID, from the caller or generated, and caller info for audit entries
+func (i *AuthInterceptor) withRequestMeta(ctx context.Context) (context.Context, string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var reqID string
+	if vals := md.Get("x-request-id"); len(vals) > 0 {
+		reqID = vals[0]
+	} else {
+		// Append reqID to ctx
+		reqID = uuid.New().String()
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", reqID)
+	}
+
+	// Inject caller info into ctx
+	ctx = context.WithValue(ctx, RequestMetaCtxKey, &domain.RequestMeta{
+		RequestID: reqID,
+		IP:        i.clientIP(ctx, md),
+		UserAgent: firstValue(md, "user-agent"),
+	})
+	return ctx, reqID
+}
+
+// Server stream with the ctx set by an interceptor
+type ctxStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *ctxStream) Context() context.Context { return s.ctx }
+
+// Peer addr, the proxy header only counts when the peer is a trusted proxy
+func (i *AuthInterceptor) clientIP(ctx context.Context, md metadata.MD) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	return i.proxies.ClientIP(p.Addr.String(), md.Get("x-forwarded-for"))
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if vals := md.Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
 // TODO: rate limiting
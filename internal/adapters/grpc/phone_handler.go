@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) SendPhoneVerificationCode(ctx context.Context, req *authpb.SendPhoneVerificationCodeRequest) (*authpb.SendPhoneVerificationCodeResponse, error) {
+	if err := h.uc.SendPhoneVerificationCode(ctx); err != nil {
+		if errors.Is(err, domain.ErrPhoneNotSet) {
+			return nil, status.Error(codes.FailedPrecondition, "no phone number on the profile")
+		}
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		if errors.Is(err, domain.ErrCodeSentRecently) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		h.log.Error("SendPhoneVerificationCode failed", "err", err)
+		return nil, status.Error(codes.Internal, "failed to send code")
+	}
+
+	return &authpb.SendPhoneVerificationCodeResponse{Success: true}, nil
+}
+
+func (h *AuthHandler) VerifyPhone(ctx context.Context, req *authpb.VerifyPhoneRequest) (*authpb.VerifyPhoneResponse, error) {
+	if req.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code required")
+	}
+
+	if err := h.uc.VerifyPhone(ctx, req.Code); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrCodeExpired):
+			return nil, status.Error(codes.InvalidArgument, "expired code")
+		case errors.Is(err, domain.ErrCodeInvalid), errors.Is(err, domain.ErrInvalidPurpose):
+			return nil, status.Error(codes.InvalidArgument, "invalid code")
+		case errors.Is(err, domain.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		h.log.Error("VerifyPhone failed", "err", err)
+		return nil, status.Error(codes.Internal, "failed to verify phone")
+	}
+
+	return &authpb.VerifyPhoneResponse{Success: true}, nil
+}
@@ -0,0 +1,175 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) ListRoles(ctx context.Context, req *authpb.ListRolesRequest) (*authpb.ListRolesResponse, error) {
+	roles, err := h.rb.ListRoles(ctx)
+	if err != nil {
+		return nil, h.rbacError(err, "failed to list roles")
+	}
+
+	resp := &authpb.ListRolesResponse{Roles: make([]*authpb.RoleDefinition, 0, len(roles))}
+	for _, r := range roles {
+		resp.Roles = append(resp.Roles, toPbRole(r))
+	}
+	return resp, nil
+}
+
+func (h *AuthHandler) CreateRole(ctx context.Context, req *authpb.CreateRoleRequest) (*authpb.RoleDefinition, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name required")
+	}
+
+	role := &domain.RoleDefinition{
+		Name:        domain.Role(req.Name),
+		Description: req.Description,
+		Permissions: nonNil(req.Permissions),
+	}
+	if err := h.rb.CreateRole(ctx, role); err != nil {
+		return nil, h.rbacError(err, "failed to create role")
+	}
+	return toPbRole(role), nil
+}
+
+func (h *AuthHandler) UpdateRole(ctx context.Context, req *authpb.UpdateRoleRequest) (*authpb.RoleDefinition, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name required")
+	}
+
+	role := &domain.RoleDefinition{
+		Name:        domain.Role(req.Name),
+		Description: req.Description,
+		Permissions: nonNil(req.Permissions),
+	}
+	if err := h.rb.UpdateRole(ctx, role); err != nil {
+		return nil, h.rbacError(err, "failed to update role")
+	}
+	return toPbRole(role), nil
+}
+
+func (h *AuthHandler) DeleteRole(ctx context.Context, req *authpb.DeleteRoleRequest) (*authpb.DeleteRoleResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name required")
+	}
+
+	if err := h.rb.DeleteRole(ctx, domain.Role(req.Name)); err != nil {
+		return nil, h.rbacError(err, "failed to delete role")
+	}
+	return &authpb.DeleteRoleResponse{Success: true}, nil
+}
+
+func (h *AuthHandler) GrantPermission(ctx context.Context, req *authpb.RolePermissionRequest) (*authpb.RolePermissionResponse, error) {
+	if req.Role == "" || req.Permission == "" {
+		return nil, status.Error(codes.InvalidArgument, "role and permission required")
+	}
+
+	if err := h.rb.GrantPermission(ctx, domain.Role(req.Role), req.Permission); err != nil {
+		return nil, h.rbacError(err, "failed to grant permission")
+	}
+	return &authpb.RolePermissionResponse{Success: true}, nil
+}
+
+func (h *AuthHandler) RevokePermission(ctx context.Context, req *authpb.RolePermissionRequest) (*authpb.RolePermissionResponse, error) {
+	if req.Role == "" || req.Permission == "" {
+		return nil, status.Error(codes.InvalidArgument, "role and permission required")
+	}
+
+	if err := h.rb.RevokePermission(ctx, domain.Role(req.Role), req.Permission); err != nil {
+		return nil, h.rbacError(err, "failed to revoke permission")
+	}
+	return &authpb.RolePermissionResponse{Success: true}, nil
+}
+
+func (h *AuthHandler) ListPermissions(ctx context.Context, req *authpb.ListPermissionsRequest) (*authpb.ListPermissionsResponse, error) {
+	perms, err := h.rb.ListPermissions(ctx)
+	if err != nil {
+		return nil, h.rbacError(err, "failed to list permissions")
+	}
+
+	resp := &authpb.ListPermissionsResponse{Permissions: make([]*authpb.PermissionDefinition, 0, len(perms))}
+	for _, p := range perms {
+		resp.Permissions = append(resp.Permissions, toPbPermission(p))
+	}
+	return resp, nil
+}
+
+func (h *AuthHandler) CreatePermission(ctx context.Context, req *authpb.CreatePermissionRequest) (*authpb.PermissionDefinition, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name required")
+	}
+
+	perm := &domain.Permission{
+		Name:        req.Name,
+		Description: req.Description,
+		Methods:     req.Methods,
+	}
+	if err := h.rb.CreatePermission(ctx, perm); err != nil {
+		return nil, h.rbacError(err, "failed to create permission")
+	}
+	return toPbPermission(perm), nil
+}
+
+func (h *AuthHandler) DeletePermission(ctx context.Context, req *authpb.DeletePermissionRequest) (*authpb.DeletePermissionResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name required")
+	}
+
+	if err := h.rb.DeletePermission(ctx, req.Name); err != nil {
+		return nil, h.rbacError(err, "failed to delete permission")
+	}
+	return &authpb.DeletePermissionResponse{Success: true}, nil
+}
+
+func (h *AuthHandler) rbacError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrRoleNotFound), errors.Is(err, domain.ErrPermNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrRoleExists), errors.Is(err, domain.ErrPermExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, domain.ErrInvalidRoleName), errors.Is(err, domain.ErrInvalidPermName), errors.Is(err, domain.ErrInvalidPermMethods):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrBuiltinRole), errors.Is(err, domain.ErrBuiltinPerm),
+		errors.Is(err, domain.ErrRoleInUse), errors.Is(err, domain.ErrAdminLockout):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	}
+	h.log.Error(msg, "err", err)
+	return status.Error(codes.Internal, msg)
+}
+
+func toPbRole(r *domain.RoleDefinition) *authpb.RoleDefinition {
+	return &authpb.RoleDefinition{
+		Name:        string(r.Name),
+		Description: r.Description,
+		Permissions: r.Permissions,
+		Builtin:     r.Builtin,
+		CreatedAt:   r.CreatedAt.Unix(),
+		UpdatedAt:   r.UpdatedAt.Unix(),
+	}
+}
+
+func toPbPermission(p *domain.Permission) *authpb.PermissionDefinition {
+	return &authpb.PermissionDefinition{
+		Name:        p.Name,
+		Description: p.Description,
+		Methods:     p.Methods,
+		Builtin:     p.Builtin,
+	}
+}
+
+// Stored as an empty array rather than null
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) CheckRelation(ctx context.Context, req *authpb.CheckRelationRequest) (*authpb.CheckRelationResponse, error) {
+	typ, id, err := domain.ParseObject(req.Object)
+	if err != nil || req.Relation == "" {
+		return nil, status.Error(codes.InvalidArgument, "object must look like course:101 and relation is required")
+	}
+	sub, err := parseSubject(req.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := h.rl.CheckRelation(ctx, typ, id, req.Relation, sub)
+	if err != nil {
+		return nil, h.relationError(err, "failed to check relation")
+	}
+	return &authpb.CheckRelationResponse{Allowed: ok}, nil
+}
+
+func (h *AuthHandler) ListObjects(ctx context.Context, req *authpb.ListObjectsRequest) (*authpb.ListObjectsResponse, error) {
+	if req.ObjectType == "" || req.Relation == "" {
+		return nil, status.Error(codes.InvalidArgument, "object_type and relation are required")
+	}
+	sub, err := parseSubject(req.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := h.rl.ListObjects(ctx, req.ObjectType, req.Relation, sub)
+	if err != nil {
+		return nil, h.relationError(err, "failed to list objects")
+	}
+	return &authpb.ListObjectsResponse{ObjectIds: ids}, nil
+}
+
+func (h *AuthHandler) WriteRelations(ctx context.Context, req *authpb.WriteRelationsRequest) (*authpb.WriteRelationsResponse, error) {
+	change, err := domain.ParseRelationChange(req.Writes, req.Deletes)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := h.rl.WriteRelations(ctx, change); err != nil {
+		return nil, h.relationError(err, "failed to write relations")
+	}
+	return &authpb.WriteRelationsResponse{Success: true}, nil
+}
+
+// Empty subject is the caller
+func parseSubject(raw string) (*domain.SubjectRef, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	sub, err := domain.ParseSubject(raw)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "subject must look like user:x or course:101#student")
+	}
+	return &sub, nil
+}
+
+func (h *AuthHandler) relationError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidTuple):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrEmptyRelations):
+		return status.Error(codes.InvalidArgument, "nothing to write or delete")
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	}
+	h.log.Error(msg, "err", err)
+	return status.Error(codes.Internal, msg)
+}
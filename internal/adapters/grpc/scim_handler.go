@@ -0,0 +1,73 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) CreateScimClient(ctx context.Context, req *authpb.CreateScimClientRequest) (*authpb.CreateScimClientResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name required")
+	}
+
+	client, token, err := h.sc.CreateScimClient(ctx, req.Name)
+	if err != nil {
+		return nil, h.scimError(err, "failed to create scim client")
+	}
+	return &authpb.CreateScimClientResponse{Client: toProtoScimClient(client), Token: token}, nil
+}
+
+func (h *AuthHandler) ListScimClients(ctx context.Context, req *authpb.ListScimClientsRequest) (*authpb.ListScimClientsResponse, error) {
+	clients, err := h.sc.ListScimClients(ctx)
+	if err != nil {
+		return nil, h.scimError(err, "failed to list scim clients")
+	}
+
+	resp := &authpb.ListScimClientsResponse{Clients: make([]*authpb.ScimClient, 0, len(clients))}
+	for _, c := range clients {
+		resp.Clients = append(resp.Clients, toProtoScimClient(c))
+	}
+	return resp, nil
+}
+
+func (h *AuthHandler) RevokeScimClient(ctx context.Context, req *authpb.RevokeScimClientRequest) (*authpb.RevokeScimClientResponse, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id required")
+	}
+
+	if err := h.sc.RevokeScimClient(ctx, req.Id); err != nil {
+		return nil, h.scimError(err, "failed to revoke scim client")
+	}
+	return &authpb.RevokeScimClientResponse{Success: true}, nil
+}
+
+func (h *AuthHandler) scimError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidScimClient):
+		return status.Error(codes.InvalidArgument, "name must be 1 to 128 characters")
+	case errors.Is(err, domain.ErrScimClientNotFound):
+		return status.Error(codes.NotFound, "scim client not found or already revoked")
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	}
+	h.log.Error(msg, "err", err)
+	return status.Error(codes.Internal, msg)
+}
+
+func toProtoScimClient(c *domain.ScimClient) *authpb.ScimClient {
+	pb := &authpb.ScimClient{
+		Id:        c.ID,
+		Name:      c.Name,
+		CreatedBy: c.CreatedBy,
+		CreatedAt: c.CreatedAt.Unix(),
+	}
+	if c.RevokedAt != nil {
+		pb.RevokedAt = c.RevokedAt.Unix()
+	}
+	return pb
+}
@@ -0,0 +1,133 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) CreateTenant(ctx context.Context, req *authpb.CreateTenantRequest) (*authpb.Tenant, error) {
+	if req.Id == "" || req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "id and name are required")
+	}
+
+	tn, err := h.tn.CreateTenant(ctx, &domain.Tenant{
+		ID:     req.Id,
+		Name:   req.Name,
+		Config: fromProtoTenantConfig(req.Config),
+	})
+	if err != nil {
+		return nil, h.tenantError(err, "failed to create tenant")
+	}
+	return toProtoTenant(tn), nil
+}
+
+func (h *AuthHandler) GetTenant(ctx context.Context, req *authpb.GetTenantRequest) (*authpb.Tenant, error) {
+	tn, err := h.tn.GetTenant(ctx, req.Id)
+	if err != nil {
+		return nil, h.tenantError(err, "failed to get tenant")
+	}
+	return toProtoTenant(tn), nil
+}
+
+func (h *AuthHandler) ListTenants(ctx context.Context, _ *authpb.ListTenantsRequest) (*authpb.ListTenantsResponse, error) {
+	tenants, err := h.tn.ListTenants(ctx)
+	if err != nil {
+		return nil, h.tenantError(err, "failed to list tenants")
+	}
+
+	out := make([]*authpb.Tenant, 0, len(tenants))
+	for _, tn := range tenants {
+		out = append(out, toProtoTenant(tn))
+	}
+	return &authpb.ListTenantsResponse{Tenants: out}, nil
+}
+
+func (h *AuthHandler) UpdateTenant(ctx context.Context, req *authpb.UpdateTenantRequest) (*authpb.Tenant, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	upd := domain.TenantUpdate{ID: req.Id, Name: req.Name}
+	if req.Status != nil {
+		s := domain.TenantStatus(*req.Status)
+		upd.Status = &s
+	}
+	if req.Config != nil {
+		cfg := fromProtoTenantConfig(req.Config)
+		upd.Config = &cfg
+	}
+
+	tn, err := h.tn.UpdateTenant(ctx, upd)
+	if err != nil {
+		return nil, h.tenantError(err, "failed to update tenant")
+	}
+	return toProtoTenant(tn), nil
+}
+
+func (h *AuthHandler) tenantError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrTenantNotFound):
+		return status.Error(codes.NotFound, "tenant not found")
+	case errors.Is(err, domain.ErrTenantExists):
+		return status.Error(codes.AlreadyExists, "tenant already exists")
+	case errors.Is(err, domain.ErrInvalidTenant):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	}
+	h.log.Error(msg, "err", err)
+	return status.Error(codes.Internal, msg)
+}
+
+func toProtoTenant(tn *domain.Tenant) *authpb.Tenant {
+	c := tn.Config
+	return &authpb.Tenant{
+		Id:     tn.ID,
+		Name:   tn.Name,
+		Status: string(tn.Status),
+		Config: &authpb.TenantConfig{
+			Password: &authpb.PasswordPolicy{
+				MinLength:     int32(c.Password.MinLength),
+				RequireUpper:  c.Password.RequireUpper,
+				RequireLower:  c.Password.RequireLower,
+				RequireDigit:  c.Password.RequireDigit,
+				RequireSymbol: c.Password.RequireSymbol,
+			},
+			AllowedIdps: c.AllowedIdPs,
+			Branding: &authpb.EmailBranding{
+				ProductName:  c.Branding.ProductName,
+				LogoUrl:      c.Branding.LogoURL,
+				PrimaryColor: c.Branding.PrimaryColor,
+				FooterText:   c.Branding.FooterText,
+			},
+		},
+		CreatedAt: tn.CreatedAt.Unix(),
+		UpdatedAt: tn.UpdatedAt.Unix(),
+	}
+}
+
+// Getters are nil safe, a missing config is the zero config
+func fromProtoTenantConfig(c *authpb.TenantConfig) domain.TenantConfig {
+	pw, br := c.GetPassword(), c.GetBranding()
+	return domain.TenantConfig{
+		Password: domain.PasswordPolicy{
+			MinLength:     int(pw.GetMinLength()),
+			RequireUpper:  pw.GetRequireUpper(),
+			RequireLower:  pw.GetRequireLower(),
+			RequireDigit:  pw.GetRequireDigit(),
+			RequireSymbol: pw.GetRequireSymbol(),
+		},
+		AllowedIdPs: c.GetAllowedIdps(),
+		Branding: domain.EmailBranding{
+			ProductName:  br.GetProductName(),
+			LogoURL:      br.GetLogoUrl(),
+			PrimaryColor: br.GetPrimaryColor(),
+			FooterText:   br.GetFooterText(),
+		},
+	}
+}
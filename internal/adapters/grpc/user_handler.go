@@ -14,20 +14,28 @@ func (h *AuthHandler) GetUserByID(ctx context.Context, req *authpb.GetUserByIDRe
 	// Get user
 	usr, err := h.uc.GetUserByID(ctx, req.UserId)
 	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		case errors.Is(err, domain.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
 		h.log.Error("failed to find by ID", "err", err)
-		return nil, status.Error(codes.NotFound, "failed to find by ID")
+		return nil, status.Error(codes.Internal, "failed to find by ID")
 	}
 
 	return &authpb.GetUserByIDResponse{
 		Success: true,
 		Message: "user found",
 		User: &authpb.User{
-			UserId:   usr.ID,
-			Email:    usr.Email,
-			Username: usr.Username,
-			Password: usr.Password,
-			Role:     convertRole(usr.Role), // ? panics if no user found
-			Phone:    usr.Phone,
+			UserId:        usr.ID,
+			Email:         usr.Email,
+			Username:      usr.Username,
+			Role:          convertRole(usr.Role), // ? panics if no user found
+			Phone:         usr.Phone,
+			PhoneVerified: usr.PhoneVerified,
+			RoleName:      string(usr.Role),
+			TenantId:      usr.TenantID,
 		},
 	}, nil
 }
@@ -42,6 +50,21 @@ func (h *AuthHandler) UpdateUserProfile(ctx context.Context, req *authpb.UpdateU
 
 	usr, err := h.uc.UpdateProfile(ctx, params)
 	if err != nil {
+		if errors.Is(err, domain.ErrEmailChangeNeedsConfirm) {
+			return nil, status.Error(codes.FailedPrecondition, "use RequestEmailChange to change the email")
+		}
+		if errors.Is(err, domain.ErrInvalidPhone) {
+			return nil, status.Error(codes.InvalidArgument, "invalid phone number")
+		}
+		if errors.Is(err, domain.ErrInvalidUsername) {
+			return nil, status.Error(codes.InvalidArgument, "invalid username")
+		}
+		if errors.Is(err, domain.ErrUsernameAlreadyExists) {
+			return nil, status.Error(codes.AlreadyExists, "username already in use")
+		}
+		if errors.Is(err, domain.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
 		h.log.Error("failed to update user profile", "err", err)
 		return nil, status.Error(codes.Internal, "failed to update user")
 	}
@@ -50,12 +73,14 @@ func (h *AuthHandler) UpdateUserProfile(ctx context.Context, req *authpb.UpdateU
 		Success: true,
 		Message: "user updated",
 		User: &authpb.User{
-			UserId:   usr.ID,
-			Email:    usr.Email,
-			Username: usr.Username,
-			Password: usr.Password,
-			Role:     convertRole(usr.Role),
-			Phone:    usr.Phone,
+			UserId:        usr.ID,
+			Email:         usr.Email,
+			Username:      usr.Username,
+			Role:          convertRole(usr.Role),
+			Phone:         usr.Phone,
+			PhoneVerified: usr.PhoneVerified,
+			RoleName:      string(usr.Role),
+			TenantId:      usr.TenantID,
 		},
 	}, nil
 }
@@ -63,6 +88,16 @@ func (h *AuthHandler) UpdateUserProfile(ctx context.Context, req *authpb.UpdateU
 func (h *AuthHandler) ChangePassword(ctx context.Context, req *authpb.ChangePasswordRequest) (*authpb.ChangePasswordResponse, error) {
 	err := h.uc.ChangePassword(ctx, req.UserId, req.OldPassword, req.NewPassword)
 	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrWeakPassword), errors.Is(err, domain.ErrPasswordUnchanged):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, domain.ErrInvalidCredentials):
+			return nil, status.Error(codes.InvalidArgument, "old password is incorrect")
+		case errors.Is(err, domain.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		case errors.Is(err, domain.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
 		h.log.Error("failed to change password", "err", err)
 		return nil, status.Error(codes.Internal, "failed to change password")
 	}
@@ -100,14 +135,7 @@ func (h *AuthHandler) VerifyAccount(ctx context.Context, req *authpb.VerifyAccou
 		return nil, status.Errorf(codes.Internal, "failed to verify code: %v", err)
 	}
 
-	// Fetch user
-	usr, err := h.uc.GetUserByEmail(ctx, req.Email)
-	if err != nil {
-		h.log.Error("Failed to get user", "err", err)
-		return nil, status.Errorf(codes.Internal, "failed to fetch user detail: %v", err)
-	}
-
-	if err := h.uc.VerifyAccount(ctx, usr.ID); err != nil {
+	if err := h.uc.VerifyAccount(ctx, req.Email); err != nil {
 		h.log.Error("Failed to verify user", "err", err)
 		return nil, status.Error(codes.Internal, "failed to verify user")
 	}
@@ -116,6 +144,19 @@ func (h *AuthHandler) VerifyAccount(ctx context.Context, req *authpb.VerifyAccou
 }
 
 func (h *AuthHandler) ResetPassword(ctx context.Context, req *authpb.ResetPasswordRequest) (*authpb.ResetPasswordResponse, error) {
+	// Send reset code to a verified phone
+	if req.GetChannel() == "sms" {
+		if err := h.uc.SendRecoverySMS(ctx, req.GetEmail()); err != nil {
+			h.log.Error("failed to send reset code", "err", err)
+			return nil, status.Error(codes.Internal, "failed to send reset code")
+		}
+
+		return &authpb.ResetPasswordResponse{
+			Success: true,
+			Message: "If the account has a verified phone, a reset code was sent to it",
+		}, nil
+	}
+
 	// Send reset code to email
 	err := h.uc.SendVerificationCode(ctx, req.GetEmail(), domain.PurposeResetPassword)
 	if err != nil {
@@ -142,15 +183,11 @@ func (h *AuthHandler) ConfirmResetPassword(ctx context.Context, req *authpb.Conf
 		return nil, status.Error(codes.Internal, "failed to verify code")
 	}
 
-	// Fetch user
-	usr, err := h.uc.GetUserByEmail(ctx, req.Email)
-	if err != nil {
-		h.log.Error("Failed to get user", "err", err)
-		return nil, status.Error(codes.Internal, "failed to fetch user detail")
-	}
-
 	// Change password
-	if err := h.uc.ChangePassword(ctx, usr.ID, usr.Password, req.NewPassword); err != nil {
+	if err := h.uc.ResetPassword(ctx, req.Email, req.NewPassword); err != nil {
+		if errors.Is(err, domain.ErrWeakPassword) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		h.log.Error("Failed to change password", "err", err)
 		return nil, status.Error(codes.Internal, "failed to reset password")
 	}
@@ -0,0 +1,159 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *AuthHandler) CreateWebhook(ctx context.Context, req *authpb.CreateWebhookRequest) (*authpb.CreateWebhookResponse, error) {
+	if req.Url == "" {
+		return nil, status.Error(codes.InvalidArgument, "url required")
+	}
+
+	sub, err := h.wh.CreateWebhook(ctx, req.Url, toEventTypes(req.EventTypes))
+	if err != nil {
+		return nil, h.webhookError(err, "failed to create webhook")
+	}
+
+	return &authpb.CreateWebhookResponse{Webhook: toProtoWebhook(sub), Secret: sub.Secret}, nil
+}
+
+func (h *AuthHandler) ListWebhooks(ctx context.Context, req *authpb.ListWebhooksRequest) (*authpb.ListWebhooksResponse, error) {
+	subs, err := h.wh.ListWebhooks(ctx)
+	if err != nil {
+		return nil, h.webhookError(err, "failed to list webhooks")
+	}
+
+	resp := &authpb.ListWebhooksResponse{Webhooks: make([]*authpb.Webhook, 0, len(subs))}
+	for _, s := range subs {
+		resp.Webhooks = append(resp.Webhooks, toProtoWebhook(s))
+	}
+	return resp, nil
+}
+
+func (h *AuthHandler) UpdateWebhook(ctx context.Context, req *authpb.UpdateWebhookRequest) (*authpb.UpdateWebhookResponse, error) {
+	if req.Id == "" || req.Url == "" {
+		return nil, status.Error(codes.InvalidArgument, "id and url required")
+	}
+
+	sub, err := h.wh.UpdateWebhook(ctx, domain.UpdateWebhookParams{
+		ID:         req.Id,
+		URL:        req.Url,
+		EventTypes: toEventTypes(req.EventTypes),
+		Enabled:    req.Enabled, // unset keeps the current state
+	})
+	if err != nil {
+		return nil, h.webhookError(err, "failed to update webhook")
+	}
+
+	return &authpb.UpdateWebhookResponse{Webhook: toProtoWebhook(sub)}, nil
+}
+
+func (h *AuthHandler) DeleteWebhook(ctx context.Context, req *authpb.DeleteWebhookRequest) (*authpb.DeleteWebhookResponse, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id required")
+	}
+
+	if err := h.wh.DeleteWebhook(ctx, req.Id); err != nil {
+		return nil, h.webhookError(err, "failed to delete webhook")
+	}
+	return &authpb.DeleteWebhookResponse{Success: true}, nil
+}
+
+func (h *AuthHandler) ListWebhookDeliveries(ctx context.Context, req *authpb.ListWebhookDeliveriesRequest) (*authpb.ListWebhookDeliveriesResponse, error) {
+	deliveries, next, err := h.wh.ListWebhookDeliveries(ctx, domain.WebhookDeliveryFilter{
+		SubscriptionID: req.WebhookId,
+		Status:         domain.DeliveryStatus(req.Status),
+		Cursor:         req.PageToken,
+		Limit:          int(req.PageSize),
+	})
+	if err != nil {
+		return nil, h.webhookError(err, "failed to list webhook deliveries")
+	}
+
+	resp := &authpb.ListWebhookDeliveriesResponse{
+		Deliveries:    make([]*authpb.WebhookDelivery, 0, len(deliveries)),
+		NextPageToken: next,
+	}
+	for _, d := range deliveries {
+		pd := &authpb.WebhookDelivery{
+			Id:             d.ID,
+			WebhookId:      d.SubscriptionID,
+			EventId:        d.EventID,
+			EventType:      string(d.EventType),
+			Status:         string(d.Status),
+			Attempts:       int32(d.Attempts),
+			LastStatusCode: int32(d.LastStatusCode),
+			LastError:      d.LastError,
+			NextAttemptAt:  d.NextAttemptAt.Unix(),
+			CreatedAt:      d.CreatedAt.Unix(),
+		}
+		if d.DeliveredAt != nil {
+			pd.DeliveredAt = d.DeliveredAt.Unix()
+		}
+		resp.Deliveries = append(resp.Deliveries, pd)
+	}
+
+	return resp, nil
+}
+
+func (h *AuthHandler) ReplayWebhookDelivery(ctx context.Context, req *authpb.ReplayWebhookDeliveryRequest) (*authpb.ReplayWebhookDeliveryResponse, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id required")
+	}
+
+	if err := h.wh.ReplayWebhookDelivery(ctx, req.Id); err != nil {
+		return nil, h.webhookError(err, "failed to replay webhook delivery")
+	}
+	return &authpb.ReplayWebhookDeliveryResponse{Success: true}, nil
+}
+
+func (h *AuthHandler) webhookError(err error, msg string) error {
+	switch {
+	case errors.Is(err, domain.ErrWebhookNotFound):
+		return status.Error(codes.NotFound, "webhook not found")
+	case errors.Is(err, domain.ErrDeliveryNotFound):
+		return status.Error(codes.NotFound, "webhook delivery not found")
+	case errors.Is(err, domain.ErrInvalidWebhookURL):
+		return status.Error(codes.InvalidArgument, "url must be an absolute http(s) url")
+	case errors.Is(err, domain.ErrWebhookTarget):
+		return status.Error(codes.InvalidArgument, "url must point at a public address")
+	case errors.Is(err, domain.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, "invalid page token")
+	case errors.Is(err, domain.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	}
+	h.log.Error(msg, "err", err)
+	return status.Error(codes.Internal, msg)
+}
+
+// Secret is never included, it's only returned by CreateWebhook
+func toProtoWebhook(s *domain.WebhookSubscription) *authpb.Webhook {
+	types := make([]string, 0, len(s.EventTypes))
+	for _, t := range s.EventTypes {
+		types = append(types, string(t))
+	}
+	return &authpb.Webhook{
+		Id:             s.ID,
+		Url:            s.URL,
+		EventTypes:     types,
+		Enabled:        s.Enabled,
+		FailureCount:   int32(s.FailureCount),
+		DisabledReason: s.DisabledReason,
+		CreatedAt:      s.CreatedAt.Unix(),
+		UpdatedAt:      s.UpdatedAt.Unix(),
+	}
+}
+
+func toEventTypes(in []string) []domain.EventType {
+	out := make([]domain.EventType, 0, len(in))
+	for _, t := range in {
+		out = append(out, domain.EventType(t))
+	}
+	return out
+}
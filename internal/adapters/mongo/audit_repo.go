@@ -0,0 +1,163 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var auditCollectionName = "audit_log"
+
+type AuditRepository struct {
+	collection *mongo.Collection
+}
+
+var _ repository.AuditRepository = (*AuditRepository)(nil)
+
+// Entries expire after retention through a TTL index
+func NewAuditRepository(ctx context.Context, db *mongo.Database, retention time.Duration) (*AuditRepository, error) {
+	if err := backfillTenant(ctx, db.Collection(auditCollectionName)); err != nil {
+		return nil, err
+	}
+	if err := ensureAuditIndexes(ctx, db.Collection(auditCollectionName), retention); err != nil {
+		return nil, fmt.Errorf("repo error in defining audit indexes: %w", err)
+	}
+	return &AuditRepository{collection: db.Collection(auditCollectionName)}, nil
+}
+
+func (r *AuditRepository) Append(ctx context.Context, e *domain.AuditEvent) error {
+	if _, err := r.collection.InsertOne(ctx, e); err != nil {
+		return fmt.Errorf("repo audit Append: %w", err)
+	}
+	return nil
+}
+
+func (r *AuditRepository) List(ctx context.Context, f domain.AuditFilter) ([]*domain.AuditEvent, string, error) {
+	and := bson.A{}
+	if f.UserID != "" {
+		and = append(and, bson.M{"$or": bson.A{
+			bson.M{"actor_id": f.UserID},
+			bson.M{"target_id": f.UserID},
+		}})
+	}
+	if f.ActorID != "" {
+		and = append(and, bson.M{"actor_id": f.ActorID})
+	}
+	if f.TargetID != "" {
+		and = append(and, bson.M{"target_id": f.TargetID})
+	}
+	if f.Action != "" {
+		and = append(and, bson.M{"action": f.Action})
+	}
+	if f.Outcome != "" {
+		and = append(and, bson.M{"outcome": f.Outcome})
+	}
+	if !f.From.IsZero() {
+		and = append(and, bson.M{"created_at": bson.M{"$gte": f.From}})
+	}
+	if !f.To.IsZero() {
+		and = append(and, bson.M{"created_at": bson.M{"$lt": f.To}})
+	}
+	if f.Cursor != "" {
+		after, err := afterCursor("created_at", f.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		and = append(and, after)
+	}
+
+	filter := bson.M{}
+	if len(and) > 0 {
+		filter = bson.M{"$and": and}
+	}
+	filter, err := scoped(ctx, filter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Fetch one extra to know if there is a next page
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(f.Limit + 1))
+
+	cur, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("repo audit Find: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var events []*domain.AuditEvent
+	if err := cur.All(ctx, &events); err != nil {
+		return nil, "", fmt.Errorf("repo audit decode: %w", err)
+	}
+
+	var next string
+	if len(events) > f.Limit {
+		events = events[:f.Limit]
+		last := events[len(events)-1]
+		next = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	return events, next, nil
+}
+
+// Detail keys without personal data, the rest (emails, usernames,
+// identifiers, free text reasons) is dropped when a user is erased
+var auditKeptDetails = bson.A{
+	"error", "op", "kind", "status", "verified", "new_role", "old_role", "all_sessions",
+	"dry_run", "format", "source", "fields", "changed", "until", "created", "updated",
+	"added", "removed",
+}
+
+func (r *AuditRepository) ScrubDetails(ctx context.Context, userID string) error {
+	filter, err := scoped(ctx, bson.M{"$or": bson.A{
+		bson.M{"actor_id": userID},
+		bson.M{"target_id": userID},
+	}})
+	if err != nil {
+		return err
+	}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"details": bson.M{"$arrayToObject": bson.M{"$filter": bson.M{
+			"input": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$details", bson.M{}}}},
+			"cond":  bson.M{"$in": bson.A{"$$this.k", auditKeptDetails}},
+		}}}}}},
+	}
+	if _, err := r.collection.UpdateMany(ctx, filter, update); err != nil {
+		return fmt.Errorf("repo audit ScrubDetails: %w", err)
+	}
+
+	// Address and client are the actor's, keep those of admins who acted on the user
+	filter, err = scoped(ctx, bson.M{"actor_id": userID})
+	if err != nil {
+		return err
+	}
+	if _, err := r.collection.UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"ip": "", "user_agent": ""}}); err != nil {
+		return fmt.Errorf("repo audit ScrubDetails: %w", err)
+	}
+	return nil
+}
+
+func ensureAuditIndexes(ctx context.Context, col *mongo.Collection, retention time.Duration) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().
+				SetName("created_at_ttl").
+				SetExpireAfterSeconds(int32(retention.Seconds())),
+		},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "action", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	}
+
+	_, err := col.Indexes().CreateMany(ctx, indexes)
+	return err
+}
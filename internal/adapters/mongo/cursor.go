@@ -0,0 +1,48 @@
+package mongo
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Opaque page cursor, position of the last item on the page (time desc, _id desc)
+func encodeCursor(t time.Time, id string) string {
+	raw := strconv.FormatInt(t.UnixNano(), 10) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", repository.ErrInvalidCursor
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return time.Time{}, "", repository.ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, "", repository.ErrInvalidCursor
+	}
+
+	return time.Unix(0, n).UTC(), id, nil
+}
+
+// Filter for items after the cursor in (field desc, _id desc) order
+func afterCursor(field, cursor string) (bson.M, error) {
+	t, id, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{"$lt": t}},
+		bson.M{field: t, "_id": bson.M{"$lt": id}},
+	}}, nil
+}
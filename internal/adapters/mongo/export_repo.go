@@ -0,0 +1,232 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	exportCollectionName = "data_exports"
+	exportBucketName     = "export_bundles" // GridFS, file id = export id
+)
+
+type ExportRepository struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+}
+
+var _ repository.ExportRepository = (*ExportRepository)(nil)
+
+func NewExportRepository(ctx context.Context, db *mongo.Database) (*ExportRepository, error) {
+	col := db.Collection(exportCollectionName)
+	if err := backfillTenant(ctx, col); err != nil {
+		return nil, err
+	}
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+	if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
+		return nil, fmt.Errorf("repo error in defining export indexes: %w", err)
+	}
+
+	return &ExportRepository{db: db, collection: col}, nil
+}
+
+func (r *ExportRepository) Create(ctx context.Context, e *domain.DataExport) error {
+	tenant, err := insertTenant(ctx, e.TenantID)
+	if err != nil {
+		return err
+	}
+	e.TenantID = tenant
+
+	if _, err := r.collection.InsertOne(ctx, e); err != nil {
+		return fmt.Errorf("repo export Create: %w", err)
+	}
+	return nil
+}
+
+func (r *ExportRepository) GetByID(ctx context.Context, id string) (*domain.DataExport, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *ExportRepository) GetByTokenHash(ctx context.Context, hash string) (*domain.DataExport, error) {
+	return r.findOne(ctx, bson.M{"token_hash": hash})
+}
+
+func (r *ExportRepository) ClaimPending(ctx context.Context, now time.Time, lease time.Duration) (*domain.DataExport, error) {
+	filter, err := scoped(ctx, bson.M{
+		"status":       domain.ExportPending,
+		"locked_until": bson.M{"$lte": now},
+	})
+	if err != nil {
+		return nil, err
+	}
+	update := bson.M{
+		"$set": bson.M{"locked_until": now.Add(lease)},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var e domain.DataExport
+	err = r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&e)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("repo export ClaimPending: %w", err)
+	}
+	return &e, nil
+}
+
+func (r *ExportRepository) SaveBundle(ctx context.Context, id string, bundle []byte) error {
+	bucket, err := r.bucket(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A retried job may have uploaded before failing
+	if err := bucket.DeleteContext(ctx, id); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return fmt.Errorf("repo export delete old bundle: %w", err)
+	}
+	if err := bucket.UploadFromStreamWithID(id, id, bytes.NewReader(bundle)); err != nil {
+		return fmt.Errorf("repo export upload: %w", err)
+	}
+
+	now := time.Now().UTC()
+	return r.update(ctx, id, bson.M{"$set": bson.M{
+		"status":       domain.ExportReady,
+		"size":         int64(len(bundle)),
+		"completed_at": now,
+		"locked_until": time.Time{},
+	}})
+}
+
+func (r *ExportRepository) OpenBundle(ctx context.Context, id string) (io.ReadCloser, error) {
+	bucket, err := r.bucket(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := bucket.OpenDownloadStream(id)
+	if err != nil {
+		if errors.Is(err, gridfs.ErrFileNotFound) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("repo export download: %w", err)
+	}
+	return stream, nil
+}
+
+func (r *ExportRepository) MarkFailed(ctx context.Context, id string, cause error) error {
+	now := time.Now().UTC()
+	return r.update(ctx, id, bson.M{"$set": bson.M{
+		"status":       domain.ExportFailed,
+		"error":        cause.Error(),
+		"completed_at": now,
+		"locked_until": time.Time{},
+	}})
+}
+
+func (r *ExportRepository) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	filter, err := scoped(ctx, bson.M{"expires_at": bson.M{"$lte": now}})
+	if err != nil {
+		return 0, err
+	}
+	cur, err := r.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("repo export find expired: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var expired []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cur.All(ctx, &expired); err != nil {
+		return 0, fmt.Errorf("repo export decode expired: %w", err)
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	bucket, err := r.bucket(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int
+	for _, e := range expired {
+		// Bundle first, a leftover export row is retried next time
+		if err := bucket.DeleteContext(ctx, e.ID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return deleted, fmt.Errorf("repo export delete bundle: %w", err)
+		}
+		if _, err := r.collection.DeleteOne(ctx, bson.M{"_id": e.ID}); err != nil {
+			return deleted, fmt.Errorf("repo export delete: %w", err)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+func (r *ExportRepository) findOne(ctx context.Context, filter bson.M) (*domain.DataExport, error) {
+	filter, err := scoped(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var e domain.DataExport
+	err = r.collection.FindOne(ctx, filter).Decode(&e)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("repo export FindOne: %w", err)
+	}
+	return &e, nil
+}
+
+func (r *ExportRepository) update(ctx context.Context, id string, update bson.M) error {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("repo export Update: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// Bucket deadlines aren't goroutine safe, so one bucket per call
+func (r *ExportRepository) bucket(ctx context.Context) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(r.db, options.GridFSBucket().SetName(exportBucketName))
+	if err != nil {
+		return nil, fmt.Errorf("repo export bucket: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		bucket.SetWriteDeadline(deadline)
+		bucket.SetReadDeadline(deadline)
+	}
+	return bucket, nil
+}
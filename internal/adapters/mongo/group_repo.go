@@ -0,0 +1,153 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var groupCollectionName = "groups"
+
+type GroupRepository struct {
+	collection *mongo.Collection
+}
+
+var _ repository.GroupRepository = (*GroupRepository)(nil)
+
+func NewGroupRepository(ctx context.Context, db *mongo.Database) (*GroupRepository, error) {
+	col := db.Collection(groupCollectionName)
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "name", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "sourced_id", Value: 1}},
+			Options: options.Index().
+				SetName("tenant_sourced_id_unique").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"sourced_id": bson.M{"$gt": ""}}),
+		},
+	}
+	if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
+		return nil, fmt.Errorf("repo error in defining group indexes: %w", err)
+	}
+
+	return &GroupRepository{collection: col}, nil
+}
+
+func (r *GroupRepository) Create(ctx context.Context, g *domain.Group) error {
+	tenant, err := insertTenant(ctx, g.TenantID)
+	if err != nil {
+		return fmt.Errorf("repo group Create: %w", err)
+	}
+	g.TenantID = tenant
+
+	if _, err := r.collection.InsertOne(ctx, g); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return repository.ErrAlreadyExists
+		}
+		return fmt.Errorf("repo group Create: %w", err)
+	}
+	return nil
+}
+
+func (r *GroupRepository) GetByID(ctx context.Context, id string) (*domain.Group, error) {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return nil, fmt.Errorf("repo group GetByID: %w", err)
+	}
+
+	var g domain.Group
+	if err := r.collection.FindOne(ctx, filter).Decode(&g); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("repo group GetByID: %w", err)
+	}
+	return &g, nil
+}
+
+func (r *GroupRepository) ListByIDs(ctx context.Context, ids []string) ([]*domain.Group, error) {
+	return r.find(ctx, "ListByIDs", bson.M{"_id": bson.M{"$in": ids}})
+}
+
+func (r *GroupRepository) ListBySourcedIDs(ctx context.Context, ids []string) ([]*domain.Group, error) {
+	return r.find(ctx, "ListBySourcedIDs", bson.M{"sourced_id": bson.M{"$in": ids}})
+}
+
+func (r *GroupRepository) List(ctx context.Context, afterID string, limit int) ([]*domain.Group, error) {
+	filter := bson.M{}
+	if afterID != "" {
+		filter["_id"] = bson.M{"$gt": afterID}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	return r.find(ctx, "List", filter, opts)
+}
+
+func (r *GroupRepository) Update(ctx context.Context, g *domain.Group) error {
+	filter, err := scoped(ctx, bson.M{"_id": g.ID})
+	if err != nil {
+		return fmt.Errorf("repo group Update: %w", err)
+	}
+	set := bson.M{"name": g.Name, "updated_at": g.UpdatedAt}
+	update := bson.M{"$set": set}
+	// Unset, the unique index only skips missing sourced IDs
+	if g.SourcedID != "" {
+		set["sourced_id"] = g.SourcedID
+	} else {
+		update["$unset"] = bson.M{"sourced_id": ""}
+	}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return repository.ErrAlreadyExists
+		}
+		return fmt.Errorf("repo group Update: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *GroupRepository) Delete(ctx context.Context, id string) error {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("repo group Delete: %w", err)
+	}
+	res, err := r.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("repo group Delete: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *GroupRepository) find(ctx context.Context, op string, filter bson.M, opts ...*options.FindOptions) ([]*domain.Group, error) {
+	filter, err := scoped(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("repo group %s: %w", op, err)
+	}
+	if len(opts) == 0 {
+		opts = append(opts, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	}
+	cur, err := r.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("repo group %s: %w", op, err)
+	}
+	defer cur.Close(ctx)
+
+	var groups []*domain.Group
+	if err := cur.All(ctx, &groups); err != nil {
+		return nil, fmt.Errorf("repo group %s decode: %w", op, err)
+	}
+	return groups, nil
+}
@@ -0,0 +1,439 @@
+package mongo
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	importCollectionName    = "imports"
+	importRowCollectionName = "import_rows"
+	importBucketName        = "import_files" // GridFS, file id = "<job id>/<file name>"
+)
+
+type ImportRepository struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+	rows       *mongo.Collection
+}
+
+var _ repository.ImportRepository = (*ImportRepository)(nil)
+
+func NewImportRepository(ctx context.Context, db *mongo.Database) (*ImportRepository, error) {
+	col := db.Collection(importCollectionName)
+	rows := db.Collection(importRowCollectionName)
+
+	jobIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}},
+	}
+	if _, err := col.Indexes().CreateMany(ctx, jobIndexes); err != nil {
+		return nil, fmt.Errorf("repo error in defining import indexes: %w", err)
+	}
+
+	rowIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "job_id", Value: 1}, {Key: "file", Value: 1}, {Key: "line", Value: 1}}},
+		{Keys: bson.D{{Key: "job_id", Value: 1}, {Key: "outcome", Value: 1}, {Key: "file", Value: 1}, {Key: "line", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"user_id": bson.M{"$gt": ""}}),
+		},
+		{
+			Keys:    bson.D{{Key: "job_id", Value: 1}},
+			Options: options.Index().SetName("pending_invites").SetPartialFilterExpression(bson.M{"invite_pending": true}),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	if _, err := rows.Indexes().CreateMany(ctx, rowIndexes); err != nil {
+		return nil, fmt.Errorf("repo error in defining import row indexes: %w", err)
+	}
+
+	return &ImportRepository{db: db, collection: col, rows: rows}, nil
+}
+
+func (r *ImportRepository) Create(ctx context.Context, j *domain.ImportJob) error {
+	tenant, err := insertTenant(ctx, j.TenantID)
+	if err != nil {
+		return fmt.Errorf("repo import Create: %w", err)
+	}
+	j.TenantID = tenant
+
+	if _, err := r.collection.InsertOne(ctx, j); err != nil {
+		return fmt.Errorf("repo import Create: %w", err)
+	}
+	return nil
+}
+
+func (r *ImportRepository) GetByID(ctx context.Context, id string) (*domain.ImportJob, error) {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return nil, fmt.Errorf("repo import GetByID: %w", err)
+	}
+
+	var j domain.ImportJob
+	if err := r.collection.FindOne(ctx, filter).Decode(&j); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("repo import GetByID: %w", err)
+	}
+	return &j, nil
+}
+
+func (r *ImportRepository) SaveFile(ctx context.Context, jobID, name string, data io.Reader) error {
+	if _, err := r.GetByID(ctx, jobID); err != nil {
+		return fmt.Errorf("repo import SaveFile: %w", err)
+	}
+	bucket, err := r.bucket(ctx)
+	if err != nil {
+		return err
+	}
+	if err := bucket.UploadFromStreamWithID(importFileID(jobID, name), name, data); err != nil {
+		return fmt.Errorf("repo import upload: %w", err)
+	}
+	return nil
+}
+
+func (r *ImportRepository) OpenFile(ctx context.Context, jobID, name string) (io.ReadCloser, error) {
+	if _, err := r.GetByID(ctx, jobID); err != nil {
+		return nil, fmt.Errorf("repo import OpenFile: %w", err)
+	}
+	bucket, err := r.bucket(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := bucket.OpenDownloadStream(importFileID(jobID, name))
+	if err != nil {
+		if errors.Is(err, gridfs.ErrFileNotFound) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("repo import download: %w", err)
+	}
+	return stream, nil
+}
+
+func (r *ImportRepository) Queue(ctx context.Context, id string, files []domain.ImportFile) error {
+	return r.update(ctx, "Queue", bson.M{"_id": id, "status": domain.ImportUploading}, bson.M{"$set": bson.M{
+		"status":     domain.ImportPending,
+		"files":      files,
+		"updated_at": time.Now().UTC(),
+	}})
+}
+
+func (r *ImportRepository) ClaimPending(ctx context.Context, now time.Time, lease time.Duration) (*domain.ImportJob, error) {
+	filter, err := scoped(ctx, bson.M{
+		"status":       domain.ImportPending,
+		"locked_until": bson.M{"$lte": now},
+	})
+	if err != nil {
+		return nil, err
+	}
+	update := bson.M{
+		"$set": bson.M{"locked_until": now.Add(lease)},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var j domain.ImportJob
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&j); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("repo import ClaimPending: %w", err)
+	}
+	return &j, nil
+}
+
+func (r *ImportRepository) SaveProgress(ctx context.Context, jobID string, file, rows int, done bool, results []*domain.ImportRow, lockedUntil time.Time) error {
+	var counts domain.ImportCounts
+	if len(results) > 0 {
+		models := make([]mongo.WriteModel, 0, len(results))
+		for _, row := range results {
+			tenant, err := insertTenant(ctx, row.TenantID)
+			if err != nil {
+				return fmt.Errorf("repo import SaveProgress: %w", err)
+			}
+			row.TenantID = tenant
+			models = append(models, mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": row.ID}).SetReplacement(row).SetUpsert(true))
+			counts.Add(row.Outcome)
+		}
+		if _, err := r.rows.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+			return fmt.Errorf("repo import save rows: %w", err)
+		}
+	}
+
+	prefix := "files." + strconv.Itoa(file) + "."
+	return r.update(ctx, "SaveProgress", bson.M{"_id": jobID}, bson.M{
+		"$set": bson.M{
+			prefix + "rows": rows,
+			prefix + "done": done,
+			"locked_until":  lockedUntil,
+			"updated_at":    time.Now().UTC(),
+		},
+		"$inc": bson.M{
+			"counts.created":   counts.Created,
+			"counts.updated":   counts.Updated,
+			"counts.unchanged": counts.Unchanged,
+			"counts.removed":   counts.Removed,
+			"counts.skipped":   counts.Skipped,
+			"counts.invalid":   counts.Invalid,
+			"counts.failed":    counts.Failed,
+		},
+	})
+}
+
+func (r *ImportRepository) ListPendingInvites(ctx context.Context, jobID string, limit int) ([]*domain.ImportRow, error) {
+	filter, err := scoped(ctx, bson.M{"job_id": jobID, "invite_pending": true})
+	if err != nil {
+		return nil, fmt.Errorf("repo import ListPendingInvites: %w", err)
+	}
+	return r.findRows(ctx, filter, options.Find().SetLimit(int64(limit)))
+}
+
+func (r *ImportRepository) ClearInvitePending(ctx context.Context, rowID string) error {
+	filter, err := scoped(ctx, bson.M{"_id": rowID})
+	if err != nil {
+		return fmt.Errorf("repo import ClearInvitePending: %w", err)
+	}
+	if _, err := r.rows.UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"invite_pending": ""}}); err != nil {
+		return fmt.Errorf("repo import ClearInvitePending: %w", err)
+	}
+	return nil
+}
+
+func (r *ImportRepository) TakePasswords(ctx context.Context, jobID string, rowIDs []string) (map[string]string, error) {
+	out := make(map[string]string)
+	opts := options.FindOneAndUpdate().
+		SetProjection(bson.M{"password": 1}).
+		SetReturnDocument(options.Before)
+	for _, id := range rowIDs {
+		filter, err := scoped(ctx, bson.M{"_id": id, "job_id": jobID, "password": bson.M{"$exists": true}})
+		if err != nil {
+			return nil, fmt.Errorf("repo import TakePasswords: %w", err)
+		}
+		var row struct {
+			Password string `bson:"password"`
+		}
+		err = r.rows.FindOneAndUpdate(ctx, filter, bson.M{"$unset": bson.M{"password": ""}}, opts).Decode(&row)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				continue
+			}
+			return nil, fmt.Errorf("repo import TakePasswords: %w", err)
+		}
+		out[id] = row.Password
+	}
+	return out, nil
+}
+
+func (r *ImportRepository) ScrubUser(ctx context.Context, userID string) error {
+	filter, err := scoped(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return fmt.Errorf("repo import ScrubUser: %w", err)
+	}
+	update := bson.M{"$unset": bson.M{"email": "", "username": "", "external_id": "", "password": ""}}
+	if _, err := r.rows.UpdateMany(ctx, filter, update); err != nil {
+		return fmt.Errorf("repo import ScrubUser: %w", err)
+	}
+	return nil
+}
+
+func (r *ImportRepository) MarkDone(ctx context.Context, id string) error {
+	now := time.Now().UTC()
+	return r.update(ctx, "MarkDone", bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"status":       domain.ImportDone,
+		"completed_at": now,
+		"updated_at":   now,
+		"locked_until": time.Time{},
+	}})
+}
+
+func (r *ImportRepository) MarkFailed(ctx context.Context, id string, cause error) error {
+	now := time.Now().UTC()
+	return r.update(ctx, "MarkFailed", bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"status":       domain.ImportFailed,
+		"error":        cause.Error(),
+		"completed_at": now,
+		"updated_at":   now,
+		"locked_until": time.Time{},
+	}})
+}
+
+func (r *ImportRepository) Resume(ctx context.Context, id string) error {
+	return r.update(ctx, "Resume", bson.M{"_id": id, "status": domain.ImportFailed}, bson.M{
+		"$set": bson.M{
+			"status":       domain.ImportPending,
+			"attempts":     0,
+			"locked_until": time.Time{},
+			"updated_at":   time.Now().UTC(),
+		},
+		"$unset": bson.M{"error": "", "completed_at": ""},
+	})
+}
+
+func (r *ImportRepository) ListRows(ctx context.Context, jobID string, outcome domain.ImportOutcome, cursor string, limit int) ([]*domain.ImportRow, string, error) {
+	query := bson.M{"job_id": jobID}
+	if outcome != "" {
+		query["outcome"] = outcome
+	}
+	if cursor != "" {
+		file, line, err := decodeRowCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query["$or"] = bson.A{
+			bson.M{"file": file, "line": bson.M{"$gt": line}},
+			bson.M{"file": bson.M{"$gt": file}},
+		}
+	}
+	filter, err := scoped(ctx, query)
+	if err != nil {
+		return nil, "", fmt.Errorf("repo import ListRows: %w", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "file", Value: 1}, {Key: "line", Value: 1}}).
+		SetProjection(bson.M{"password": 0}).
+		SetLimit(int64(limit + 1)) // one extra to know if there's a next page
+	rows, err := r.findRows(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		next = encodeRowCursor(last.File, last.Line)
+	}
+	return rows, next, nil
+}
+
+func (r *ImportRepository) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	filter, err := scoped(ctx, bson.M{"expires_at": bson.M{"$lte": now}})
+	if err != nil {
+		return 0, err
+	}
+	cur, err := r.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1, "files": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("repo import find expired: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var expired []*domain.ImportJob
+	if err := cur.All(ctx, &expired); err != nil {
+		return 0, fmt.Errorf("repo import decode expired: %w", err)
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	bucket, err := r.bucket(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int
+	for _, j := range expired {
+		// Files first, a leftover job row is retried next time
+		for _, f := range j.Files {
+			if f.Path != "" {
+				continue
+			}
+			if err := bucket.DeleteContext(ctx, importFileID(j.ID, f.Name)); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+				return deleted, fmt.Errorf("repo import delete file: %w", err)
+			}
+		}
+		if _, err := r.collection.DeleteOne(ctx, bson.M{"_id": j.ID}); err != nil {
+			return deleted, fmt.Errorf("repo import delete: %w", err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (r *ImportRepository) findRows(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*domain.ImportRow, error) {
+	cur, err := r.rows.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("repo import rows Find: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var rows []*domain.ImportRow
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("repo import rows decode: %w", err)
+	}
+	return rows, nil
+}
+
+func (r *ImportRepository) update(ctx context.Context, op string, query, update bson.M) error {
+	filter, err := scoped(ctx, query)
+	if err != nil {
+		return fmt.Errorf("repo import %s: %w", op, err)
+	}
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("repo import %s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// Bucket deadlines aren't goroutine safe, so one bucket per call
+func (r *ImportRepository) bucket(ctx context.Context) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(r.db, options.GridFSBucket().SetName(importBucketName))
+	if err != nil {
+		return nil, fmt.Errorf("repo import bucket: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		bucket.SetWriteDeadline(deadline)
+		bucket.SetReadDeadline(deadline)
+	}
+	return bucket, nil
+}
+
+func importFileID(jobID, name string) string {
+	return jobID + "/" + name
+}
+
+// Position of the last row on the page, "<file>|<line>"
+func encodeRowCursor(file string, line int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(file + "|" + strconv.Itoa(line)))
+}
+
+func decodeRowCursor(cursor string) (string, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, repository.ErrInvalidCursor
+	}
+	file, lineStr, ok := strings.Cut(string(raw), "|")
+	if !ok || file == "" {
+		return "", 0, repository.ErrInvalidCursor
+	}
+	line, err := strconv.Atoi(lineStr)
+	if err != nil {
+		return "", 0, repository.ErrInvalidCursor
+	}
+	return file, line, nil
+}
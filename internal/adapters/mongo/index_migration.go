@@ -0,0 +1,54 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Global unique indexes replaced by per-tenant ones. Left in place they
+// still reject the same email, username or tuple in another tenant.
+var legacyIndexes = map[string][]string{
+	collectionName:         {"email_1", "email_unique", "email_key_unique", "username_unique_ci"},
+	relationCollectionName: {"tuple_unique"},
+}
+
+// Builds the per-tenant indexes first, so uniqueness is enforced throughout,
+// then drops the legacy ones still present. Returns them as collection.index,
+// a dry run only lists them.
+func MigrateTenantIndexes(ctx context.Context, db *mongo.Database, dryRun bool) ([]string, error) {
+	if _, err := NewUserRepository(ctx, db); err != nil {
+		return nil, err
+	}
+	if _, err := NewRelationRepository(ctx, db); err != nil {
+		return nil, err
+	}
+
+	var found []string
+	for _, colName := range []string{collectionName, relationCollectionName} {
+		col := db.Collection(colName)
+		specs, err := col.Indexes().ListSpecifications(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list %s indexes: %w", colName, err)
+		}
+		present := make(map[string]bool, len(specs))
+		for _, s := range specs {
+			present[s.Name] = true
+		}
+
+		for _, name := range legacyIndexes[colName] {
+			if !present[name] {
+				continue
+			}
+			found = append(found, colName+"."+name)
+			if dryRun {
+				continue
+			}
+			if err := dropIndexIfExists(ctx, col, name); err != nil {
+				return found, err
+			}
+		}
+	}
+	return found, nil
+}
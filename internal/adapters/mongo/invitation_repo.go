@@ -0,0 +1,117 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var invitationCollectionName = "invitations"
+
+type InvitationRepository struct {
+	collection *mongo.Collection
+}
+
+var _ repository.InvitationRepository = (*InvitationRepository)(nil)
+
+func NewInvitationRepository(ctx context.Context, db *mongo.Database) (*InvitationRepository, error) {
+	col := db.Collection(invitationCollectionName)
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "email_key", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "accepted_by", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"accepted_by": bson.M{"$gt": ""}}),
+		},
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"user_id": bson.M{"$gt": ""}}),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
+		return nil, fmt.Errorf("repo error in defining invitation indexes: %w", err)
+	}
+
+	return &InvitationRepository{collection: col}, nil
+}
+
+func (r *InvitationRepository) Create(ctx context.Context, inv *domain.Invitation) error {
+	tenant, err := insertTenant(ctx, inv.TenantID)
+	if err != nil {
+		return fmt.Errorf("repo invitation Create: %w", err)
+	}
+	inv.TenantID = tenant
+
+	if _, err := r.collection.InsertOne(ctx, inv); err != nil {
+		return fmt.Errorf("repo invitation Create: %w", err)
+	}
+	return nil
+}
+
+func (r *InvitationRepository) GetByID(ctx context.Context, id string) (*domain.Invitation, error) {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return nil, fmt.Errorf("repo invitation GetByID: %w", err)
+	}
+
+	var inv domain.Invitation
+	if err := r.collection.FindOne(ctx, filter).Decode(&inv); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("repo invitation GetByID: %w", err)
+	}
+	return &inv, nil
+}
+
+func (r *InvitationRepository) Accept(ctx context.Context, id, userID string, at time.Time) error {
+	return r.close(ctx, "Accept", id, at, bson.M{"accepted_at": at, "accepted_by": userID})
+}
+
+func (r *InvitationRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	return r.close(ctx, "Revoke", id, at, bson.M{"revoked_at": at})
+}
+
+// Only an open invite matches, so the update doubles as the single-use check
+func (r *InvitationRepository) ScrubUser(ctx context.Context, userID string) error {
+	filter, err := scoped(ctx, bson.M{"$or": bson.A{bson.M{"accepted_by": userID}, bson.M{"user_id": userID}}})
+	if err != nil {
+		return fmt.Errorf("repo invitation ScrubUser: %w", err)
+	}
+	if _, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"email": "", "email_key": ""}}); err != nil {
+		return fmt.Errorf("repo invitation ScrubUser: %w", err)
+	}
+	return nil
+}
+
+func (r *InvitationRepository) close(ctx context.Context, op, id string, at time.Time, set bson.M) error {
+	filter, err := scoped(ctx, bson.M{
+		"_id":         id,
+		"accepted_at": bson.M{"$exists": false},
+		"revoked_at":  bson.M{"$exists": false},
+		"expires_at":  bson.M{"$gt": at},
+	})
+	if err != nil {
+		return fmt.Errorf("repo invitation %s: %w", op, err)
+	}
+
+	res, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return fmt.Errorf("repo invitation %s: %w", op, err)
+	}
+	if res.MatchedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	outboxCollectionName = "outbox"
+	outboxSeqCollection  = "outbox_seq"
+	locksCollectionName  = "locks"
+	relayLockID          = "outbox_relay"
+)
+
+type OutboxRepository struct {
+	collection *mongo.Collection
+	seqs       *mongo.Collection // last seq per aggregate
+	locks      *mongo.Collection
+}
+
+var _ repository.OutboxRepository = (*OutboxRepository)(nil)
+
+// Sent records are removed by a TTL index after retention
+func NewOutboxRepository(ctx context.Context, db *mongo.Database, retention time.Duration) (*OutboxRepository, error) {
+	if err := ensureOutboxIndexes(ctx, db.Collection(outboxCollectionName), retention); err != nil {
+		return nil, fmt.Errorf("repo error in defining outbox indexes: %w", err)
+	}
+	return &OutboxRepository{
+		collection: db.Collection(outboxCollectionName),
+		seqs:       db.Collection(outboxSeqCollection),
+		locks:      db.Collection(locksCollectionName),
+	}, nil
+}
+
+func (r *OutboxRepository) Add(ctx context.Context, rec *domain.OutboxRecord) error {
+	seq, err := r.nextSeq(ctx, rec.AggregateID, rec.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("repo outbox Add: %w", err)
+	}
+	rec.Seq = seq
+	if rec.NextAttemptAt.IsZero() {
+		rec.NextAttemptAt = rec.CreatedAt
+	}
+
+	if _, err := r.collection.InsertOne(ctx, rec); err != nil {
+		return fmt.Errorf("repo outbox Add: %w", err)
+	}
+	return nil
+}
+
+// Counter per aggregate. Concurrent transactions on one aggregate conflict on
+// it and retry, so seqs follow commit order. A new counter starts at the
+// clock, above the seqs of records written before counters existed.
+func (r *OutboxRepository) nextSeq(ctx context.Context, aggregateID string, now time.Time) (int64, error) {
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"seq": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$seq", now.UnixNano()}}, 1}},
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var doc struct {
+		Seq int64 `bson:"seq"`
+	}
+	if err := r.seqs.FindOneAndUpdate(ctx, bson.M{"_id": aggregateID}, update, opts).Decode(&doc); err != nil {
+		return 0, fmt.Errorf("next seq: %w", err)
+	}
+	return doc.Seq, nil
+}
+
+func (r *OutboxRepository) FetchPending(ctx context.Context, now time.Time, limit int) ([]*domain.OutboxRecord, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"sent_at": nil, "dead_at": nil}}},
+		{{Key: "$sort", Value: bson.D{{Key: "aggregate_id", Value: 1}, {Key: "seq", Value: 1}}}},
+		// Only the head of each aggregate, the rest wait for it
+		{{Key: "$group", Value: bson.M{"_id": "$aggregate_id", "head": bson.M{"$first": "$$ROOT"}}}},
+		{{Key: "$replaceWith", Value: "$head"}},
+		{{Key: "$match", Value: bson.M{"next_attempt_at": bson.M{"$not": bson.M{"$gt": now}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: int64(limit)}},
+	}
+
+	cur, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("repo outbox Find: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var recs []*domain.OutboxRecord
+	if err := cur.All(ctx, &recs); err != nil {
+		return nil, fmt.Errorf("repo outbox decode: %w", err)
+	}
+
+	return recs, nil
+}
+
+func (r *OutboxRepository) MarkSent(ctx context.Context, id string) error {
+	update := bson.M{
+		"$set": bson.M{"sent_at": time.Now().UTC()},
+		"$inc": bson.M{"attempts": 1},
+	}
+	if _, err := r.collection.UpdateByID(ctx, id, update); err != nil {
+		return fmt.Errorf("repo outbox MarkSent: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id string, cause error, next time.Time) error {
+	update := bson.M{
+		"$set": bson.M{"last_error": cause.Error(), "next_attempt_at": next},
+		"$inc": bson.M{"attempts": 1},
+	}
+	if _, err := r.collection.UpdateByID(ctx, id, update); err != nil {
+		return fmt.Errorf("repo outbox MarkFailed: %w", err)
+	}
+	return nil
+}
+
+// Dead records are kept, not relayed and not expired by the TTL index
+func (r *OutboxRepository) MarkDead(ctx context.Context, id string, cause error) error {
+	update := bson.M{
+		"$set": bson.M{"last_error": cause.Error(), "dead_at": time.Now().UTC()},
+		"$inc": bson.M{"attempts": 1},
+	}
+	if _, err := r.collection.UpdateByID(ctx, id, update); err != nil {
+		return fmt.Errorf("repo outbox MarkDead: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepository) ScrubUser(ctx context.Context, userID string) error {
+	filter := bson.M{"aggregate_id": userID, "event_type": bson.M{"$ne": domain.EventUserErased}}
+	if _, err := r.collection.DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("repo outbox ScrubUser: %w", err)
+	}
+	return nil
+}
+
+// Lease based lock, so only one replica relays and per aggregate order holds
+func (r *OutboxRepository) AcquireRelayLock(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"_id": relayLockID,
+		"$or": bson.A{
+			bson.M{"owner": owner},
+			bson.M{"expires_at": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"owner": owner, "expires_at": now.Add(ttl)}}
+
+	_, err := r.locks.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		// Upsert collides with a live lock held by someone else
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("repo outbox lock: %w", err)
+	}
+	return true, nil
+}
+
+func ensureOutboxIndexes(ctx context.Context, col *mongo.Collection, retention time.Duration) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "sent_at", Value: 1}, {Key: "aggregate_id", Value: 1}, {Key: "seq", Value: 1}},
+		},
+		{Keys: bson.D{{Key: "aggregate_id", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "sent_at", Value: 1}},
+			Options: options.Index().
+				SetName("sent_at_ttl").
+				SetExpireAfterSeconds(int32(retention.Seconds())),
+		},
+	}
+
+	_, err := col.Indexes().CreateMany(ctx, indexes)
+	return err
+}
@@ -0,0 +1,65 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var processedCollectionName = "processed_messages"
+
+type processedMessage struct {
+	ID          string    `bson:"_id"`
+	Subject     string    `bson:"subject"`
+	ProcessedAt time.Time `bson:"processed_at"`
+}
+
+type ProcessedMessageRepository struct {
+	collection *mongo.Collection
+}
+
+var _ repository.ProcessedMessageRepository = (*ProcessedMessageRepository)(nil)
+
+// IDs are forgotten after retention, redeliveries older than that are processed again
+func NewProcessedMessageRepository(ctx context.Context, db *mongo.Database, retention time.Duration) (*ProcessedMessageRepository, error) {
+	col := db.Collection(processedCollectionName)
+
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "processed_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(retention.Seconds())),
+	}
+	if _, err := col.Indexes().CreateOne(ctx, index); err != nil {
+		return nil, fmt.Errorf("repo error in defining processed_at ttl index: %w", err)
+	}
+
+	return &ProcessedMessageRepository{collection: col}, nil
+}
+
+func (r *ProcessedMessageRepository) IsProcessed(ctx context.Context, msgID string) (bool, error) {
+	err := r.collection.FindOne(ctx, bson.M{"_id": msgID}).Err()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return false, fmt.Errorf("repo IsProcessed: %w", err)
+	}
+	return true, nil
+}
+
+func (r *ProcessedMessageRepository) MarkProcessed(ctx context.Context, msgID, subject string) error {
+	_, err := r.collection.InsertOne(ctx, &processedMessage{
+		ID:          msgID,
+		Subject:     subject,
+		ProcessedAt: time.Now().UTC(),
+	})
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("repo MarkProcessed: %w", err)
+	}
+	return nil
+}
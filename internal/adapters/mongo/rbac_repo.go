@@ -0,0 +1,201 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	roleCollectionName       = "roles"
+	permissionCollectionName = "permissions"
+)
+
+type RBACRepository struct {
+	roles *mongo.Collection
+	perms *mongo.Collection
+}
+
+var _ repository.RBACRepository = (*RBACRepository)(nil)
+
+func NewRBACRepository(db *mongo.Database) *RBACRepository {
+	return &RBACRepository{
+		roles: db.Collection(roleCollectionName),
+		perms: db.Collection(permissionCollectionName),
+	}
+}
+
+func (r *RBACRepository) ListRoles(ctx context.Context) ([]*domain.RoleDefinition, error) {
+	cur, err := r.roles.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("repo rbac ListRoles: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var roles []*domain.RoleDefinition
+	if err := cur.All(ctx, &roles); err != nil {
+		return nil, fmt.Errorf("repo rbac ListRoles decode: %w", err)
+	}
+	return roles, nil
+}
+
+func (r *RBACRepository) GetRole(ctx context.Context, name domain.Role) (*domain.RoleDefinition, error) {
+	var role domain.RoleDefinition
+	if err := r.roles.FindOne(ctx, bson.M{"_id": name}).Decode(&role); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("repo rbac GetRole: %w", err)
+	}
+	return &role, nil
+}
+
+func (r *RBACRepository) CreateRole(ctx context.Context, role *domain.RoleDefinition) error {
+	if _, err := r.roles.InsertOne(ctx, role); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return repository.ErrAlreadyExists
+		}
+		return fmt.Errorf("repo rbac CreateRole: %w", err)
+	}
+	return nil
+}
+
+func (r *RBACRepository) UpdateRole(ctx context.Context, role *domain.RoleDefinition) error {
+	return r.updateRole(ctx, role.Name, bson.M{"$set": bson.M{
+		"description": role.Description,
+		"permissions": role.Permissions,
+		"updated_at":  role.UpdatedAt,
+	}})
+}
+
+func (r *RBACRepository) DeleteRole(ctx context.Context, name domain.Role) error {
+	res, err := r.roles.DeleteOne(ctx, bson.M{"_id": name})
+	if err != nil {
+		return fmt.Errorf("repo rbac DeleteRole: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *RBACRepository) AddRolePermissions(ctx context.Context, name domain.Role, perms ...string) error {
+	return r.updateRole(ctx, name, bson.M{
+		"$addToSet": bson.M{"permissions": bson.M{"$each": perms}},
+		"$set":      bson.M{"updated_at": time.Now().UTC()},
+	})
+}
+
+func (r *RBACRepository) RemoveRolePermissions(ctx context.Context, name domain.Role, perms ...string) error {
+	return r.updateRole(ctx, name, bson.M{
+		"$pull": bson.M{"permissions": bson.M{"$in": perms}},
+		"$set":  bson.M{"updated_at": time.Now().UTC()},
+	})
+}
+
+func (r *RBACRepository) UnbindPermission(ctx context.Context, perm string) error {
+	update := bson.M{
+		"$pull": bson.M{"permissions": perm},
+		"$set":  bson.M{"updated_at": time.Now().UTC()},
+	}
+	if _, err := r.roles.UpdateMany(ctx, bson.M{"permissions": perm}, update); err != nil {
+		return fmt.Errorf("repo rbac UnbindPermission: %w", err)
+	}
+	return nil
+}
+
+func (r *RBACRepository) ListPermissions(ctx context.Context) ([]*domain.Permission, error) {
+	cur, err := r.perms.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("repo rbac ListPermissions: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var perms []*domain.Permission
+	if err := cur.All(ctx, &perms); err != nil {
+		return nil, fmt.Errorf("repo rbac ListPermissions decode: %w", err)
+	}
+	return perms, nil
+}
+
+func (r *RBACRepository) GetPermission(ctx context.Context, name string) (*domain.Permission, error) {
+	var perm domain.Permission
+	if err := r.perms.FindOne(ctx, bson.M{"_id": name}).Decode(&perm); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("repo rbac GetPermission: %w", err)
+	}
+	return &perm, nil
+}
+
+func (r *RBACRepository) CreatePermission(ctx context.Context, p *domain.Permission) error {
+	if _, err := r.perms.InsertOne(ctx, p); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return repository.ErrAlreadyExists
+		}
+		return fmt.Errorf("repo rbac CreatePermission: %w", err)
+	}
+	return nil
+}
+
+func (r *RBACRepository) DeletePermission(ctx context.Context, name string) error {
+	res, err := r.perms.DeleteOne(ctx, bson.M{"_id": name})
+	if err != nil {
+		return fmt.Errorf("repo rbac DeletePermission: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// Insert only, so admin changes to a built-in role survive restarts
+func (r *RBACRepository) EnsureRole(ctx context.Context, role *domain.RoleDefinition) error {
+	update := bson.M{"$setOnInsert": bson.M{
+		"description": role.Description,
+		"permissions": role.Permissions,
+		"builtin":     role.Builtin,
+		"created_at":  role.CreatedAt,
+		"updated_at":  role.UpdatedAt,
+	}}
+	if _, err := r.roles.UpdateByID(ctx, role.Name, update, options.Update().SetUpsert(true)); err != nil {
+		return fmt.Errorf("repo rbac EnsureRole: %w", err)
+	}
+	return nil
+}
+
+// Built-in permissions follow the code, new RPCs are picked up on deploy
+func (r *RBACRepository) EnsurePermission(ctx context.Context, p *domain.Permission) error {
+	update := bson.M{
+		"$set": bson.M{
+			"description": p.Description,
+			"methods":     p.Methods,
+			"builtin":     p.Builtin,
+			"updated_at":  p.UpdatedAt,
+		},
+		"$setOnInsert": bson.M{"created_at": p.CreatedAt},
+	}
+	if _, err := r.perms.UpdateByID(ctx, p.Name, update, options.Update().SetUpsert(true)); err != nil {
+		return fmt.Errorf("repo rbac EnsurePermission: %w", err)
+	}
+	return nil
+}
+
+func (r *RBACRepository) updateRole(ctx context.Context, name domain.Role, update bson.M) error {
+	res, err := r.roles.UpdateByID(ctx, name, update)
+	if err != nil {
+		return fmt.Errorf("repo rbac updateRole: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var relationCollectionName = "relation_tuples"
+
+// Stored tuple, subject_key is the subject's string form for $in lookups
+type relationDoc struct {
+	domain.RelationTuple `bson:",inline"`
+	TenantID             string `bson:"tenant_id"`
+	SubjectKey           string `bson:"subject_key"`
+}
+
+type RelationRepository struct {
+	collection *mongo.Collection
+}
+
+var _ repository.RelationRepository = (*RelationRepository)(nil)
+
+func NewRelationRepository(ctx context.Context, db *mongo.Database) (*RelationRepository, error) {
+	col := db.Collection(relationCollectionName)
+	if err := backfillTenant(ctx, col); err != nil {
+		return nil, fmt.Errorf("repo relation: %w", err)
+	}
+	// The same tuple can exist in two tenants, MigrateTenantIndexes drops
+	// the global tuple_unique this replaces
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "tenant_id", Value: 1},
+				{Key: "object_type", Value: 1},
+				{Key: "object_id", Value: 1},
+				{Key: "relation", Value: 1},
+				{Key: "subject_key", Value: 1},
+			},
+			Options: options.Index().SetUnique(true).SetName("tenant_tuple_unique"),
+		},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "subject_key", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "object_type", Value: 1}, {Key: "relation", Value: 1}, {Key: "subject_key", Value: 1}}},
+	}
+	if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
+		return nil, fmt.Errorf("repo error in defining relation indexes: %w", err)
+	}
+
+	return &RelationRepository{collection: col}, nil
+}
+
+func (r *RelationRepository) Write(ctx context.Context, tuples []domain.RelationTuple) error {
+	tenant, err := insertTenant(ctx, "")
+	if err != nil {
+		return fmt.Errorf("repo relation Write: %w", err)
+	}
+
+	models := make([]mongo.WriteModel, 0, len(tuples))
+	for _, t := range tuples {
+		// The filter fields, tenant_id included, are copied into inserted documents
+		filter := tupleFilter(t)
+		filter["tenant_id"] = tenant
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.M{"$setOnInsert": bson.M{"subject": t.Subject}}).
+			SetUpsert(true))
+	}
+	// A concurrent upsert of the same tuple loses on the unique index, the tuple exists either way
+	if _, err := r.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil && !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("repo relation Write: %w", err)
+	}
+	return nil
+}
+
+func (r *RelationRepository) Delete(ctx context.Context, tuples []domain.RelationTuple) error {
+	models := make([]mongo.WriteModel, 0, len(tuples))
+	for _, t := range tuples {
+		filter, err := scoped(ctx, tupleFilter(t))
+		if err != nil {
+			return fmt.Errorf("repo relation Delete: %w", err)
+		}
+		models = append(models, mongo.NewDeleteOneModel().SetFilter(filter))
+	}
+	if _, err := r.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("repo relation Delete: %w", err)
+	}
+	return nil
+}
+
+func (r *RelationRepository) ListBySubjects(ctx context.Context, subjects []string) ([]domain.RelationTuple, error) {
+	filter, err := scoped(ctx, bson.M{"subject_key": bson.M{"$in": subjects}})
+	if err != nil {
+		return nil, fmt.Errorf("repo relation ListBySubjects: %w", err)
+	}
+	cur, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("repo relation ListBySubjects: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var docs []relationDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("repo relation ListBySubjects decode: %w", err)
+	}
+	tuples := make([]domain.RelationTuple, 0, len(docs))
+	for _, d := range docs {
+		tuples = append(tuples, d.RelationTuple)
+	}
+	return tuples, nil
+}
+
+func (r *RelationRepository) ExistsAny(ctx context.Context, objectType string, objectIDs []string, relation string, subjects []string) (bool, error) {
+	filter, err := scoped(ctx, bson.M{
+		"object_type": objectType,
+		"object_id":   bson.M{"$in": objectIDs},
+		"relation":    relation,
+		"subject_key": bson.M{"$in": subjects},
+	})
+	if err != nil {
+		return false, fmt.Errorf("repo relation ExistsAny: %w", err)
+	}
+	n, err := r.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, fmt.Errorf("repo relation ExistsAny: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *RelationRepository) ListObjects(ctx context.Context, objectType, relation string, subjects []string) ([]string, error) {
+	filter, err := scoped(ctx, bson.M{
+		"object_type": objectType,
+		"relation":    relation,
+		"subject_key": bson.M{"$in": subjects},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repo relation ListObjects: %w", err)
+	}
+	ids, err := r.collection.Distinct(ctx, "object_id", filter)
+	if err != nil {
+		return nil, fmt.Errorf("repo relation ListObjects: %w", err)
+	}
+
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if s, ok := id.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (r *RelationRepository) ListByObject(ctx context.Context, objectType, objectID string) ([]domain.RelationTuple, error) {
+	filter, err := scoped(ctx, bson.M{"object_type": objectType, "object_id": objectID})
+	if err != nil {
+		return nil, fmt.Errorf("repo relation ListByObject: %w", err)
+	}
+	cur, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("repo relation ListByObject: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var docs []relationDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("repo relation ListByObject decode: %w", err)
+	}
+	tuples := make([]domain.RelationTuple, 0, len(docs))
+	for _, d := range docs {
+		tuples = append(tuples, d.RelationTuple)
+	}
+	return tuples, nil
+}
+
+func tupleFilter(t domain.RelationTuple) bson.M {
+	return bson.M{
+		"object_type": t.ObjectType,
+		"object_id":   t.ObjectID,
+		"relation":    t.Relation,
+		"subject_key": t.Subject.String(),
+	}
+}
@@ -0,0 +1,101 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var scimClientCollectionName = "scim_clients"
+
+type ScimClientRepository struct {
+	collection *mongo.Collection
+}
+
+var _ repository.ScimClientRepository = (*ScimClientRepository)(nil)
+
+func NewScimClientRepository(ctx context.Context, db *mongo.Database) (*ScimClientRepository, error) {
+	col := db.Collection(scimClientCollectionName)
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+	if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
+		return nil, fmt.Errorf("repo error in defining scim client indexes: %w", err)
+	}
+
+	return &ScimClientRepository{collection: col}, nil
+}
+
+func (r *ScimClientRepository) Create(ctx context.Context, c *domain.ScimClient) error {
+	tenant, err := insertTenant(ctx, c.TenantID)
+	if err != nil {
+		return fmt.Errorf("repo scim client Create: %w", err)
+	}
+	c.TenantID = tenant
+
+	if _, err := r.collection.InsertOne(ctx, c); err != nil {
+		return fmt.Errorf("repo scim client Create: %w", err)
+	}
+	return nil
+}
+
+func (r *ScimClientRepository) GetByTokenHash(ctx context.Context, hash string) (*domain.ScimClient, error) {
+	filter, err := scoped(ctx, bson.M{"token_hash": hash})
+	if err != nil {
+		return nil, fmt.Errorf("repo scim client GetByTokenHash: %w", err)
+	}
+
+	var c domain.ScimClient
+	if err := r.collection.FindOne(ctx, filter).Decode(&c); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("repo scim client GetByTokenHash: %w", err)
+	}
+	return &c, nil
+}
+
+func (r *ScimClientRepository) List(ctx context.Context) ([]*domain.ScimClient, error) {
+	filter, err := scoped(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("repo scim client List: %w", err)
+	}
+	cur, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("repo scim client List: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var clients []*domain.ScimClient
+	if err := cur.All(ctx, &clients); err != nil {
+		return nil, fmt.Errorf("repo scim client List decode: %w", err)
+	}
+	return clients, nil
+}
+
+func (r *ScimClientRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	filter, err := scoped(ctx, bson.M{"_id": id, "revoked_at": bson.M{"$exists": false}})
+	if err != nil {
+		return fmt.Errorf("repo scim client Revoke: %w", err)
+	}
+	res, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked_at": at}})
+	if err != nil {
+		return fmt.Errorf("repo scim client Revoke: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var tenantCollectionName = "tenants"
+
+type TenantRepository struct {
+	collection *mongo.Collection
+}
+
+var _ repository.TenantRepository = (*TenantRepository)(nil)
+
+func NewTenantRepository(db *mongo.Database) *TenantRepository {
+	return &TenantRepository{collection: db.Collection(tenantCollectionName)}
+}
+
+func (r *TenantRepository) Create(ctx context.Context, t *domain.Tenant) error {
+	if _, err := r.collection.InsertOne(ctx, t); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return repository.ErrAlreadyExists
+		}
+		return fmt.Errorf("repo tenant Create: %w", err)
+	}
+	return nil
+}
+
+func (r *TenantRepository) Ensure(ctx context.Context, t *domain.Tenant) error {
+	update := bson.M{"$setOnInsert": bson.M{
+		"name":       t.Name,
+		"status":     t.Status,
+		"config":     t.Config,
+		"created_at": t.CreatedAt,
+		"updated_at": t.UpdatedAt,
+	}}
+	if _, err := r.collection.UpdateByID(ctx, t.ID, update, options.Update().SetUpsert(true)); err != nil {
+		return fmt.Errorf("repo tenant Ensure: %w", err)
+	}
+	return nil
+}
+
+func (r *TenantRepository) GetByID(ctx context.Context, id string) (*domain.Tenant, error) {
+	var t domain.Tenant
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&t); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("repo tenant GetByID: %w", err)
+	}
+	return &t, nil
+}
+
+func (r *TenantRepository) List(ctx context.Context) ([]*domain.Tenant, error) {
+	cur, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("repo tenant List: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var tenants []*domain.Tenant
+	if err := cur.All(ctx, &tenants); err != nil {
+		return nil, fmt.Errorf("repo tenant List decode: %w", err)
+	}
+	return tenants, nil
+}
+
+func (r *TenantRepository) Update(ctx context.Context, t *domain.Tenant) error {
+	res, err := r.collection.UpdateByID(ctx, t.ID, bson.M{"$set": bson.M{
+		"name":       t.Name,
+		"status":     t.Status,
+		"config":     t.Config,
+		"updated_at": t.UpdatedAt,
+	}})
+	if err != nil {
+		return fmt.Errorf("repo tenant Update: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// Limit a filter to the tenant of ctx. Repositories holding tenant data
+// build every filter through this, so there is no unscoped query to forget.
+func scoped(ctx context.Context, filter bson.M) (bson.M, error) {
+	tenant, all, err := domain.TenantScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !all {
+		filter["tenant_id"] = tenant
+	}
+	return filter, nil
+}
+
+// Tenant for a new document, an insert always needs a single tenant
+func insertTenant(ctx context.Context, current string) (string, error) {
+	tenant, all, err := domain.TenantScope(ctx)
+	switch {
+	case err != nil:
+		return "", err
+	case all:
+		if current == "" {
+			return "", domain.ErrNoTenant
+		}
+		return current, nil
+	case current != "" && current != tenant:
+		return "", repository.ErrOtherTenant
+	}
+	return tenant, nil
+}
+
+// Documents stored before tenants existed belong to the default tenant
+func backfillTenant(ctx context.Context, col *mongo.Collection) error {
+	_, err := col.UpdateMany(ctx,
+		bson.M{"tenant_id": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"tenant_id": domain.DefaultTenantID}},
+	)
+	if err != nil {
+		return fmt.Errorf("backfill tenant of %s: %w", col.Name(), err)
+	}
+	return nil
+}
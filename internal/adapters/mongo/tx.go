@@ -0,0 +1,38 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Requires MongoDB running as a replica set
+type TxManager struct {
+	client *mongo.Client
+}
+
+var _ repository.TxManager = (*TxManager)(nil)
+
+func NewTxManager(client *mongo.Client) *TxManager {
+	return &TxManager{client: client}
+}
+
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	// Already inside a transaction, join it
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	sess, err := m.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("start session: %w", err)
+	}
+	defer sess.EndSession(ctx)
+
+	_, err = sess.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
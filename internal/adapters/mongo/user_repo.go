@@ -4,16 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/Neroframe/AuthService/internal/domain"
 	"github.com/Neroframe/AuthService/internal/repository"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var collectionName = "users"
 
+// Case-insensitive comparison, queries on username must use it to hit the unique index
+var usernameCollation = &options.Collation{Locale: "en", Strength: 2}
+
 type UserRepository struct {
 	collection *mongo.Collection
 }
@@ -21,7 +29,10 @@ type UserRepository struct {
 var _ repository.UserRepository = (*UserRepository)(nil)
 
 func NewUserRepository(ctx context.Context, db *mongo.Database) (*UserRepository, error) {
-	// Set email as unique key
+	if err := backfillTenant(ctx, db.Collection(collectionName)); err != nil {
+		return nil, err
+	}
+	// Set email as unique key per tenant
 	if err := ensureUserIndexes(ctx, db.Collection(collectionName)); err != nil {
 		return nil, fmt.Errorf("repo error in defining unique email index: %w", err)
 	}
@@ -29,10 +40,16 @@ func NewUserRepository(ctx context.Context, db *mongo.Database) (*UserRepository
 }
 
 func (r *UserRepository) Create(ctx context.Context, u *domain.User) error {
-	_, err := r.collection.InsertOne(ctx, u)
+	tenant, err := insertTenant(ctx, u.TenantID)
+	if err != nil {
+		return err
+	}
+	u.TenantID = tenant
+
+	_, err = r.collection.InsertOne(ctx, u)
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
-			return repository.ErrEmailAlreadyUsed
+			return duplicateKeyErr(err)
 		}
 		return fmt.Errorf("repo Create: %w", err)
 	}
@@ -40,10 +57,14 @@ func (r *UserRepository) Create(ctx context.Context, u *domain.User) error {
 	return nil
 }
 
-func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, emailKey string) (*domain.User, error) {
 	var u domain.User
 
-	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&u)
+	filter, err := scoped(ctx, bson.M{"email_key": emailKey})
+	if err != nil {
+		return nil, err
+	}
+	err = r.collection.FindOne(ctx, filter).Decode(&u)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, repository.ErrNotFound
@@ -54,10 +75,33 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.
 	return &u, nil
 }
 
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*domain.User, error) {
+	var u domain.User
+
+	filter, err := scoped(ctx, bson.M{"username": username})
+	if err != nil {
+		return nil, err
+	}
+	opts := options.FindOne().SetCollation(usernameCollation)
+	err = r.collection.FindOne(ctx, filter, opts).Decode(&u)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("repo FindByUsername: %w", err)
+	}
+
+	return &u, nil
+}
+
 func (r *UserRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
 	var u domain.User
 
-	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&u)
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return nil, err
+	}
+	err = r.collection.FindOne(ctx, filter).Decode(&u)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, repository.ErrNotFound
@@ -73,25 +117,35 @@ func (r *UserRepository) Update(ctx context.Context, u *domain.User, fields ...s
 		return nil, repository.ErrNothingToUpdate
 	}
 
-	filter := bson.M{"_id": u.ID}
+	filter, err := scoped(ctx, bson.M{"_id": u.ID})
+	if err != nil {
+		return nil, err
+	}
 	update := buildUpdateFields(u, fields...)
 
 	opts := options.FindOneAndUpdate().
 		SetReturnDocument(options.After). // to decode result
 		SetUpsert(false)                  // no insert
 
-	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(u)
+	err = r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(u)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, repository.ErrNotFound
 		}
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, duplicateKeyErr(err)
+		}
 		return nil, fmt.Errorf("repo Update: %w", err)
 	}
 	return u, nil
 }
 
 func (r *UserRepository) Delete(ctx context.Context, id string) error {
-	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	res, err := r.collection.DeleteOne(ctx, filter)
 	if err != nil {
 		return fmt.Errorf("repo Delete: %w", err)
 	}
@@ -103,30 +157,318 @@ func (r *UserRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// Newest first, same cursor format as the audit log
+func (r *UserRepository) List(ctx context.Context, f domain.UserFilter) ([]*domain.User, string, error) {
+	and := bson.A{}
+	if f.Role != domain.UNSPECIFIED {
+		and = append(and, bson.M{"role": f.Role})
+	}
+	if f.Verified != nil {
+		and = append(and, bson.M{"verified": *f.Verified})
+	}
+	if !f.CreatedFrom.IsZero() {
+		and = append(and, bson.M{"created_at": bson.M{"$gte": f.CreatedFrom}})
+	}
+	if !f.CreatedTo.IsZero() {
+		and = append(and, bson.M{"created_at": bson.M{"$lt": f.CreatedTo}})
+	}
+	if f.Cursor != "" {
+		after, err := afterCursor("created_at", f.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		and = append(and, after)
+	}
+
+	// Fetch one extra to know if there is a next page
+	var users []*domain.User
+	var err error
+	if f.Query == "" {
+		users, err = r.listPage(ctx, and, f.Limit+1, nil)
+	} else {
+		users, err = r.listPrefix(ctx, and, f.Query, f.Limit+1)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(users) > f.Limit {
+		users = users[:f.Limit]
+		last := users[len(users)-1]
+		next = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	return users, next, nil
+}
+
+// Email key or username starting with query. The two prefixes need different
+// collations to use their unique indexes, so each is its own page and the
+// pages are merged in listing order.
+func (r *UserRepository) listPrefix(ctx context.Context, and bson.A, query string, limit int) ([]*domain.User, error) {
+	byEmail := append(bson.A{bson.M{"email_key": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(strings.ToLower(query))}}}, and...)
+	users, err := r.listPage(ctx, byEmail, limit, nil)
+	if err != nil {
+		return nil, err
+	}
+	// U+FFFF sorts after every other character under the collation
+	byUsername := append(bson.A{bson.M{"username": bson.M{"$gte": query, "$lt": query + "\uffff"}}}, and...)
+	more, err := r.listPage(ctx, byUsername, limit, usernameCollation)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(users))
+	for _, u := range users {
+		seen[u.ID] = true
+	}
+	for _, u := range more {
+		if !seen[u.ID] {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.After(users[j].CreatedAt)
+		}
+		return users[i].ID > users[j].ID
+	})
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+// Newest first, as the admin listing pages
+func (r *UserRepository) listPage(ctx context.Context, and bson.A, limit int, collation *options.Collation) ([]*domain.User, error) {
+	filter := bson.M{}
+	if len(and) > 0 {
+		filter = bson.M{"$and": and}
+	}
+	filter, err := scoped(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+	if collation != nil {
+		opts.SetCollation(collation)
+	}
+
+	cur, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("repo List: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var users []*domain.User
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, fmt.Errorf("repo List decode: %w", err)
+	}
+	return users, nil
+}
+
+// Soft deleted users whose grace period is over
+func (r *UserRepository) ListDueForErasure(ctx context.Context, now time.Time, limit int) ([]*domain.User, error) {
+	filter := bson.M{"status": domain.StatusDeleted, "purge_at": bson.M{"$lte": now}}
+	opts := options.Find().SetSort(bson.D{{Key: "purge_at", Value: 1}}).SetLimit(int64(limit))
+
+	return r.find(ctx, filter, opts)
+}
+
+func (r *UserRepository) ListMissingEmailKey(ctx context.Context, afterID string, limit int) ([]*domain.User, error) {
+	filter := bson.M{"email_key": bson.M{"$exists": false}}
+	if afterID != "" {
+		filter["_id"] = bson.M{"$gt": afterID}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+
+	return r.find(ctx, filter, opts)
+}
+
+func (r *UserRepository) ListByEmailKeys(ctx context.Context, keys []string) ([]*domain.User, error) {
+	return r.find(ctx, bson.M{"email_key": bson.M{"$in": keys}})
+}
+
+func (r *UserRepository) ListByIDs(ctx context.Context, ids []string) ([]*domain.User, error) {
+	return r.find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+}
+
+func (r *UserRepository) ListByExternalIDs(ctx context.Context, ids []string) ([]*domain.User, error) {
+	return r.find(ctx, bson.M{"external_id": bson.M{"$in": ids}})
+}
+
+func (r *UserRepository) ListByUsernames(ctx context.Context, usernames []string) ([]*domain.User, error) {
+	return r.find(ctx, bson.M{"username": bson.M{"$in": usernames}}, options.Find().SetCollation(usernameCollation))
+}
+
+func (r *UserRepository) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]*domain.User, error) {
+	filter, err := scoped(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := r.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("repo Find: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var users []*domain.User
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, fmt.Errorf("repo Find decode: %w", err)
+	}
+	return users, nil
+}
+
+func (r *UserRepository) Erase(ctx context.Context, u *domain.User) error {
+	// Only a still deleted user, so a restore racing the job wins
+	if _, err := insertTenant(ctx, u.TenantID); err != nil {
+		return err
+	}
+	filter, err := scoped(ctx, bson.M{"_id": u.ID, "status": domain.StatusDeleted})
+	if err != nil {
+		return err
+	}
+	res, err := r.collection.ReplaceOne(ctx, filter, u)
+	if err != nil {
+		return fmt.Errorf("repo Erase: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// Unique per tenant and partial, username-only accounts have no email.
+// The global indexes these replace are dropped by MigrateTenantIndexes.
 func ensureUserIndexes(ctx context.Context, col *mongo.Collection) error {
-	index := mongo.IndexModel{
-		Keys:    bson.D{{Key: "email", Value: 1}},
-		Options: options.Index().SetUnique(true),
+	indexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "email", Value: 1}},
+			Options: options.Index().
+				SetName("tenant_email_unique").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"email": bson.M{"$gt": ""}}),
+		},
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "email_key", Value: 1}},
+			Options: options.Index().
+				SetName("tenant_email_key_unique").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"email_key": bson.M{"$gt": ""}}),
+		},
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "username", Value: 1}},
+			Options: options.Index().
+				SetName("tenant_username_unique_ci").
+				SetUnique(true).
+				SetCollation(usernameCollation).
+				SetPartialFilterExpression(bson.M{"username": bson.M{"$gt": ""}}),
+		},
+		// Rostering, the SIS id
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "external_id", Value: 1}},
+			Options: options.Index().
+				SetName("tenant_external_id_unique").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"external_id": bson.M{"$gt": ""}}),
+		},
+		// Admin listing, filters + created_at desc
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "role", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "verified", Value: 1}, {Key: "created_at", Value: -1}}},
+		// Erasure job
+		{
+			Keys:    bson.D{{Key: "purge_at", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"status": domain.StatusDeleted}),
+		},
 	}
 
-	_, err := col.Indexes().CreateOne(ctx, index)
+	_, err := col.Indexes().CreateMany(ctx, indexes)
 	return err
 }
 
+func dropIndexIfExists(ctx context.Context, col *mongo.Collection, name string) error {
+	_, err := col.Indexes().DropOne(ctx, name)
+	var cmdErr mongo.CommandError
+	if err != nil && !(errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27)) { // NamespaceNotFound, IndexNotFound
+		return fmt.Errorf("drop index %s: %w", name, err)
+	}
+	return nil
+}
+
+// Tell which unique index a duplicate key error came from, by the key
+// pattern the server reports with it
+func duplicateKeyErr(err error) error {
+	var raws []bson.Raw
+	var we mongo.WriteException
+	var ce mongo.CommandError
+	switch {
+	case errors.As(err, &we):
+		for _, e := range we.WriteErrors {
+			if e.Code == 11000 {
+				raws = append(raws, e.Raw)
+			}
+		}
+	case errors.As(err, &ce):
+		raws = append(raws, ce.Raw)
+	}
+
+	for _, raw := range raws {
+		pattern, ok := raw.Lookup("keyPattern").DocumentOK()
+		if !ok {
+			continue
+		}
+		if _, err := pattern.LookupErr("username"); err == nil {
+			return repository.ErrUsernameTaken
+		}
+		if _, err := pattern.LookupErr("external_id"); err == nil {
+			return repository.ErrAlreadyExists
+		}
+	}
+	return repository.ErrEmailAlreadyUsed
+}
+
 func buildUpdateFields(u *domain.User, allowedFields ...string) bson.M {
 	set := bson.M{}
 	for _, field := range allowedFields {
 		switch field {
 		case "username":
 			set["username"] = u.Username
+		case "external_id":
+			set["external_id"] = u.ExternalID
 		case "phone":
 			set["phone"] = u.Phone
 		case "password":
 			set["password"] = u.Password
+		case "must_change_password":
+			set["must_change_password"] = u.MustChangePassword
 		case "email":
 			set["email"] = u.Email
+		case "email_key":
+			set["email_key"] = u.EmailKey
 		case "updated_at":
 			set["updated_at"] = u.UpdatedAt
+		case "role":
+			set["role"] = u.Role
+		case "verified":
+			set["verified"] = u.Verified
+		case "phone_verified":
+			set["phone_verified"] = u.PhoneVerified
+		case "status":
+			set["status"] = u.Status
+		case "status_reason":
+			set["status_reason"] = u.StatusReason
+		case "status_until":
+			set["status_until"] = u.StatusUntil
+		case "deleted_at":
+			set["deleted_at"] = u.DeletedAt
+		case "purge_at":
+			set["purge_at"] = u.PurgeAt
+		case "restore_status":
+			set["restore_status"] = u.RestoreStatus
 		}
 	}
 	return bson.M{"$set": set}
@@ -0,0 +1,395 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	webhookCollectionName  = "webhooks"
+	deliveryCollectionName = "webhook_deliveries"
+)
+
+type WebhookRepository struct {
+	collection *mongo.Collection
+}
+
+var _ repository.WebhookRepository = (*WebhookRepository)(nil)
+
+func NewWebhookRepository(ctx context.Context, db *mongo.Database) (*WebhookRepository, error) {
+	col := db.Collection(webhookCollectionName)
+	if err := backfillTenant(ctx, col); err != nil {
+		return nil, fmt.Errorf("repo webhook: %w", err)
+	}
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "enabled", Value: 1}}},
+	}
+	if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
+		return nil, fmt.Errorf("repo error in defining webhook indexes: %w", err)
+	}
+
+	return &WebhookRepository{collection: col}, nil
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, s *domain.WebhookSubscription) error {
+	tenant, err := insertTenant(ctx, s.TenantID)
+	if err != nil {
+		return fmt.Errorf("repo webhook Create: %w", err)
+	}
+	s.TenantID = tenant
+
+	if _, err := r.collection.InsertOne(ctx, s); err != nil {
+		return fmt.Errorf("repo webhook Create: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) GetByID(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return nil, fmt.Errorf("repo webhook FindOne: %w", err)
+	}
+
+	var s domain.WebhookSubscription
+	if err := r.collection.FindOne(ctx, filter).Decode(&s); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("repo webhook FindOne: %w", err)
+	}
+	return &s, nil
+}
+
+func (r *WebhookRepository) List(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	return r.find(ctx, bson.M{})
+}
+
+func (r *WebhookRepository) ListEnabled(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	return r.find(ctx, bson.M{"enabled": true})
+}
+
+func (r *WebhookRepository) Update(ctx context.Context, s *domain.WebhookSubscription) error {
+	update := bson.M{"$set": bson.M{
+		"url":             s.URL,
+		"event_types":     s.EventTypes,
+		"enabled":         s.Enabled,
+		"failure_count":   s.FailureCount,
+		"disabled_reason": s.DisabledReason,
+		"updated_at":      s.UpdatedAt,
+	}}
+
+	filter, err := scoped(ctx, bson.M{"_id": s.ID})
+	if err != nil {
+		return fmt.Errorf("repo webhook Update: %w", err)
+	}
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("repo webhook Update: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *WebhookRepository) Delete(ctx context.Context, id string) error {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("repo webhook Delete: %w", err)
+	}
+	res, err := r.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("repo webhook Delete: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// Count a failed delivery, disables the subscription once the limit is hit
+func (r *WebhookRepository) RecordFailure(ctx context.Context, id string, disableAfter int) (bool, error) {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, fmt.Errorf("repo webhook RecordFailure: %w", err)
+	}
+
+	var s domain.WebhookSubscription
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"failure_count": 1}}, opts).Decode(&s)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, repository.ErrNotFound
+		}
+		return false, fmt.Errorf("repo webhook RecordFailure: %w", err)
+	}
+
+	if !s.Enabled || s.FailureCount < disableAfter {
+		return false, nil
+	}
+
+	update := bson.M{"$set": bson.M{
+		"enabled":         false,
+		"disabled_reason": fmt.Sprintf("%d consecutive failed deliveries", s.FailureCount),
+		"updated_at":      time.Now().UTC(),
+	}}
+	if _, err := r.collection.UpdateOne(ctx, filter, update); err != nil {
+		return false, fmt.Errorf("repo webhook disable: %w", err)
+	}
+	return true, nil
+}
+
+func (r *WebhookRepository) ResetFailures(ctx context.Context, id string) error {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("repo webhook ResetFailures: %w", err)
+	}
+	if _, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"failure_count": 0}}); err != nil {
+		return fmt.Errorf("repo webhook ResetFailures: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) find(ctx context.Context, filter bson.M) ([]*domain.WebhookSubscription, error) {
+	filter, err := scoped(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("repo webhook Find: %w", err)
+	}
+	cur, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("repo webhook Find: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var subs []*domain.WebhookSubscription
+	if err := cur.All(ctx, &subs); err != nil {
+		return nil, fmt.Errorf("repo webhook decode: %w", err)
+	}
+	return subs, nil
+}
+
+type WebhookDeliveryRepository struct {
+	collection *mongo.Collection
+}
+
+var _ repository.WebhookDeliveryRepository = (*WebhookDeliveryRepository)(nil)
+
+// Finished deliveries are removed after retention
+func NewWebhookDeliveryRepository(ctx context.Context, db *mongo.Database, retention time.Duration) (*WebhookDeliveryRepository, error) {
+	col := db.Collection(deliveryCollectionName)
+	if err := backfillTenant(ctx, col); err != nil {
+		return nil, fmt.Errorf("repo delivery: %w", err)
+	}
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "subscription_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "subject", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"subject": bson.M{"$gt": ""}}),
+		},
+		{
+			Keys: bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().
+				SetName("created_at_ttl").
+				SetExpireAfterSeconds(int32(retention.Seconds())),
+		},
+	}
+	if _, err := col.Indexes().CreateMany(ctx, indexes); err != nil {
+		return nil, fmt.Errorf("repo error in defining delivery indexes: %w", err)
+	}
+
+	return &WebhookDeliveryRepository{collection: col}, nil
+}
+
+func (r *WebhookDeliveryRepository) Create(ctx context.Context, d *domain.WebhookDelivery) error {
+	tenant, err := insertTenant(ctx, d.TenantID)
+	if err != nil {
+		return fmt.Errorf("repo delivery Create: %w", err)
+	}
+	d.TenantID = tenant
+
+	if _, err := r.collection.InsertOne(ctx, d); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return repository.ErrAlreadyExists
+		}
+		return fmt.Errorf("repo delivery Create: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookDeliveryRepository) GetByID(ctx context.Context, id string) (*domain.WebhookDelivery, error) {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return nil, fmt.Errorf("repo delivery FindOne: %w", err)
+	}
+
+	var d domain.WebhookDelivery
+	if err := r.collection.FindOne(ctx, filter).Decode(&d); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("repo delivery FindOne: %w", err)
+	}
+	return &d, nil
+}
+
+func (r *WebhookDeliveryRepository) List(ctx context.Context, f domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, string, error) {
+	and := bson.A{}
+	if f.SubscriptionID != "" {
+		and = append(and, bson.M{"subscription_id": f.SubscriptionID})
+	}
+	if f.Status != "" {
+		and = append(and, bson.M{"status": f.Status})
+	}
+	if f.Cursor != "" {
+		after, err := afterCursor("created_at", f.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		and = append(and, after)
+	}
+
+	filter := bson.M{}
+	if len(and) > 0 {
+		filter = bson.M{"$and": and}
+	}
+	filter, err := scoped(ctx, filter)
+	if err != nil {
+		return nil, "", fmt.Errorf("repo delivery List: %w", err)
+	}
+
+	// Fetch one extra to know if there is a next page
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(f.Limit + 1))
+
+	cur, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("repo delivery Find: %w", err)
+	}
+	defer cur.Close(ctx)
+
+	var deliveries []*domain.WebhookDelivery
+	if err := cur.All(ctx, &deliveries); err != nil {
+		return nil, "", fmt.Errorf("repo delivery decode: %w", err)
+	}
+
+	var next string
+	if len(deliveries) > f.Limit {
+		deliveries = deliveries[:f.Limit]
+		last := deliveries[len(deliveries)-1]
+		next = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	return deliveries, next, nil
+}
+
+// Lock the oldest due delivery for lease, so replicas don't send it twice
+func (r *WebhookDeliveryRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*domain.WebhookDelivery, error) {
+	filter, err := scoped(ctx, bson.M{
+		"status":          domain.DeliveryPending,
+		"next_attempt_at": bson.M{"$lte": now},
+		"locked_until":    bson.M{"$lte": now},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repo delivery ClaimDue: %w", err)
+	}
+	update := bson.M{"$set": bson.M{"locked_until": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var d domain.WebhookDelivery
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&d); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("repo delivery ClaimDue: %w", err)
+	}
+	return &d, nil
+}
+
+func (r *WebhookDeliveryRepository) MarkSucceeded(ctx context.Context, id string, statusCode int) error {
+	now := time.Now().UTC()
+	return r.update(ctx, id, bson.M{
+		"$set": bson.M{
+			"status":           domain.DeliverySucceeded,
+			"last_status_code": statusCode,
+			"last_error":       "",
+			"delivered_at":     now,
+			"locked_until":     time.Time{},
+		},
+		"$inc": bson.M{"attempts": 1},
+	})
+}
+
+func (r *WebhookDeliveryRepository) MarkRetry(ctx context.Context, id string, statusCode int, cause error, next time.Time) error {
+	return r.update(ctx, id, bson.M{
+		"$set": bson.M{
+			"last_status_code": statusCode,
+			"last_error":       cause.Error(),
+			"next_attempt_at":  next,
+			"locked_until":     time.Time{},
+		},
+		"$inc": bson.M{"attempts": 1},
+	})
+}
+
+func (r *WebhookDeliveryRepository) MarkFailed(ctx context.Context, id string, statusCode int, cause error) error {
+	return r.update(ctx, id, bson.M{
+		"$set": bson.M{
+			"status":           domain.DeliveryFailed,
+			"last_status_code": statusCode,
+			"last_error":       cause.Error(),
+			"locked_until":     time.Time{},
+		},
+		"$inc": bson.M{"attempts": 1},
+	})
+}
+
+// Send the delivery again from scratch
+func (r *WebhookDeliveryRepository) Requeue(ctx context.Context, id string) error {
+	return r.update(ctx, id, bson.M{"$set": bson.M{
+		"status":          domain.DeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now().UTC(),
+		"locked_until":    time.Time{},
+	}})
+}
+
+func (r *WebhookDeliveryRepository) ScrubUser(ctx context.Context, userID string) error {
+	filter, err := scoped(ctx, bson.M{"subject": userID})
+	if err != nil {
+		return fmt.Errorf("repo delivery ScrubUser: %w", err)
+	}
+	if _, err := r.collection.DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("repo delivery ScrubUser: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookDeliveryRepository) update(ctx context.Context, id string, update bson.M) error {
+	filter, err := scoped(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("repo delivery update: %w", err)
+	}
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("repo delivery update: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
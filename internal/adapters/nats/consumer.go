@@ -0,0 +1,270 @@
+package nats
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/Neroframe/AuthService/pkg/nats"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Handlers wrap errors with ErrPermanent to skip retries (e.g. malformed payload)
+var ErrPermanent = errors.New("permanent failure")
+
+type Message struct {
+	ID      string // Nats-Msg-Id, CloudEvent id or a hash of the body
+	Subject string
+	Data    []byte
+	Headers map[string]string // first value of each NATS header
+}
+
+type MessageHandler func(ctx context.Context, msg *Message) error
+
+type ConsumerConfig struct {
+	Stream         string // holds the inbound subjects registered with Handle
+	DurablePrefix  string // durable of an inbound subject is <prefix>-<subject>
+	DLQSubject     string // given up messages go to <DLQSubject>.<subject>
+	MaxRetries     int
+	Backoff        time.Duration // first retry delay, doubled on every attempt
+	MaxBackoff     time.Duration
+	HandlerTimeout time.Duration
+	AckWait        time.Duration // redelivered when a handler outlives it
+}
+
+// Consumer runs registered handlers on durable JetStream pull consumers, so
+// replicas share the work and nothing is lost while the service is down
+type Consumer struct {
+	conn      *nats.Client
+	processed repository.ProcessedMessageRepository
+	log       *logger.Logger
+	cfg       ConsumerConfig
+
+	streams  []streamHandler
+	consumes []jetstream.ConsumeContext
+	inflight sync.WaitGroup
+
+	ctx    context.Context // canceled when draining times out
+	cancel context.CancelFunc
+}
+
+func NewConsumer(c *nats.Client, processed repository.ProcessedMessageRepository, log *logger.Logger, cfg ConsumerConfig) *Consumer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Consumer{
+		conn:      c,
+		processed: processed,
+		log:       log,
+		cfg:       cfg,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Register a handler for an inbound subject of the consumer's Stream, must be
+// called before Start
+func (c *Consumer) Handle(subject string, h MessageHandler) {
+	c.HandleStream(c.cfg.Stream, durableName(c.cfg.DurablePrefix, subject), subject, h)
+}
+
+type streamHandler struct {
+	cfg nats.ConsumerConfig
+	h   MessageHandler
+}
+
+// Register a handler on a durable consumer of a JetStream stream, so messages
+// published while the service is down are handled once it's back. Replicas
+// share the durable. Must be called before Start.
+func (c *Consumer) HandleStream(stream, durable, subject string, h MessageHandler) {
+	c.streams = append(c.streams, streamHandler{
+		cfg: nats.ConsumerConfig{
+			Stream:        stream,
+			Durable:       durable,
+			FilterSubject: subject,
+			AckWait:       c.cfg.AckWait,
+			// No MaxDeliver, the handler gives up itself once the message is in the DLQ
+		},
+		h: h,
+	})
+}
+
+func (c *Consumer) Start() error {
+	for _, s := range c.streams {
+		ctx, cancel := context.WithTimeout(c.ctx, c.cfg.HandlerTimeout)
+		cc, err := c.conn.Consume(ctx, s.cfg, c.dispatchStream(s.h))
+		cancel()
+		if err != nil {
+			c.stopAll()
+			return err
+		}
+		c.consumes = append(c.consumes, cc)
+		c.log.Info("consumer attached", "stream", s.cfg.Stream, "durable", s.cfg.Durable, "subject", s.cfg.FilterSubject)
+	}
+	return nil
+}
+
+// Stop receiving new messages and wait for in-flight handlers
+func (c *Consumer) Drain(ctx context.Context) error {
+	var drainErr error
+	for _, cc := range c.consumes {
+		cc.Drain()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		// Wait until buffered messages are delivered
+		for _, cc := range c.consumes {
+			<-cc.Closed()
+		}
+		c.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// Abort handlers still retrying
+		c.cancel()
+		drainErr = errors.Join(drainErr, ctx.Err())
+	}
+
+	return drainErr
+}
+
+// Messages are retried by redelivery: a failure is nacked with the backoff
+// delay. A permanent failure, or one past MaxRetries, is moved to the DLQ.
+func (c *Consumer) dispatchStream(h MessageHandler) jetstream.MessageHandler {
+	return func(m jetstream.Msg) {
+		c.inflight.Add(1)
+		defer c.inflight.Done()
+
+		msg := &Message{ID: messageID(m.Subject(), m.Data(), m.Headers()), Subject: m.Subject(), Data: m.Data(), Headers: firstHeaders(m.Headers())}
+		attempt := 1
+		if meta, err := m.Metadata(); err == nil {
+			attempt = int(meta.NumDelivered)
+		}
+
+		done, err := c.processed.IsProcessed(c.ctx, msg.ID)
+		if err != nil {
+			c.log.Error("consumer dedup check failed", "subject", msg.Subject, "id", msg.ID, "err", err)
+		}
+		if done {
+			c.log.Debug("consumer skipped duplicate", "subject", msg.Subject, "id", msg.ID)
+			c.ack(m, msg)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.ctx, c.cfg.HandlerTimeout)
+		err = h(ctx, msg)
+		cancel()
+		switch {
+		case err == nil:
+			if err := c.processed.MarkProcessed(c.ctx, msg.ID, msg.Subject); err != nil {
+				c.log.Error("consumer MarkProcessed failed", "subject", msg.Subject, "id", msg.ID, "err", err)
+			}
+			c.ack(m, msg)
+		case errors.Is(err, ErrPermanent) || attempt > c.cfg.MaxRetries:
+			c.log.Error("consumer gave up on message", "subject", msg.Subject, "id", msg.ID, "attempt", attempt, "err", err)
+			c.deadLetter(m, msg, attempt, err)
+		default:
+			c.log.Warn("consumer retrying message", "subject", msg.Subject, "id", msg.ID, "attempt", attempt, "err", err)
+			if err := m.NakWithDelay(c.backoff(attempt)); err != nil {
+				c.log.Error("consumer nak failed", "subject", msg.Subject, "id", msg.ID, "err", err)
+			}
+		}
+	}
+}
+
+// Republish to the DLQ, then drop it from the consumer. Kept for redelivery
+// while the DLQ can't take it.
+func (c *Consumer) deadLetter(m jetstream.Msg, msg *Message, attempt int, cause error) {
+	headers := make(map[string]string, len(msg.Headers)+3)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers["Dlq-Subject"] = msg.Subject
+	headers["Dlq-Attempts"] = strconv.Itoa(attempt)
+	headers["Dlq-Error"] = cause.Error()
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.cfg.HandlerTimeout)
+	defer cancel()
+	if err := c.conn.PublishAck(ctx, c.cfg.DLQSubject+"."+msg.Subject, msg.Data, headers, "dlq-"+msg.ID); err != nil {
+		c.log.Error("consumer DLQ publish failed", "subject", msg.Subject, "id", msg.ID, "err", err)
+		if err := m.NakWithDelay(c.backoff(attempt)); err != nil {
+			c.log.Error("consumer nak failed", "subject", msg.Subject, "id", msg.ID, "err", err)
+		}
+		return
+	}
+
+	if err := m.Term(); err != nil {
+		c.log.Error("consumer term failed", "subject", msg.Subject, "id", msg.ID, "err", err)
+	}
+}
+
+func (c *Consumer) ack(m jetstream.Msg, msg *Message) {
+	if err := m.Ack(); err != nil {
+		c.log.Error("consumer ack failed", "subject", msg.Subject, "id", msg.ID, "err", err)
+	}
+}
+
+// Delay before the retry after attempt, doubled from Backoff up to MaxBackoff
+func (c *Consumer) backoff(attempt int) time.Duration {
+	delay := c.cfg.Backoff
+	for i := 1; i < attempt && delay < c.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, c.cfg.MaxBackoff)
+}
+
+func (c *Consumer) stopAll() {
+	for _, cc := range c.consumes {
+		cc.Stop()
+	}
+	c.consumes = nil
+}
+
+// Durable names can't hold subject tokens like "." or wildcards
+func durableName(prefix, subject string) string {
+	return prefix + "-" + strings.NewReplacer(".", "_", "*", "any", ">", "all").Replace(subject)
+}
+
+func messageID(subject string, data []byte, header natsgo.Header) string {
+	if id := header.Get(natsgo.MsgIdHdr); id != "" {
+		return id
+	}
+	if id := header.Get("ce-id"); id != "" {
+		return id
+	}
+
+	// Structured CloudEvent
+	var ce struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(data, &ce) == nil && ce.ID != "" {
+		return ce.ID
+	}
+
+	sum := sha256.Sum256(append([]byte(subject+"\n"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+func firstHeaders(h natsgo.Header) map[string]string {
+	if len(h) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if len(v) > 0 {
+			out[k] = v[0]
+		}
+	}
+	return out
+}
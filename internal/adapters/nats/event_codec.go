@@ -0,0 +1,176 @@
+package nats
+
+import (
+	"fmt"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/pkg/cloudevents"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Event data encodings
+const (
+	EncodingJSON     = "json"     // structured CloudEvent, protojson data
+	EncodingProtobuf = "protobuf" // binary CloudEvent, attributes in headers
+)
+
+// Encode the event as a CloudEvent, returns message body and headers
+func encodeEvent(encoding string, ce cloudevents.Event, payload any) ([]byte, map[string]string, error) {
+	msg, err := toProto(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	ce.DataSchema = "urn:proto:" + string(msg.ProtoReflect().Descriptor().FullName())
+
+	switch encoding {
+	case EncodingJSON, "":
+		ce.DataContentType = cloudevents.ContentTypeJSON
+		data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("protojson marshal: %w", err)
+		}
+		body, err := cloudevents.MarshalStructured(ce, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		return body, map[string]string{"content-type": cloudevents.ContentTypeStructured}, nil
+	case EncodingProtobuf:
+		ce.DataContentType = cloudevents.ContentTypeProtobuf
+		body, err := proto.Marshal(msg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("proto marshal: %w", err)
+		}
+		return body, cloudevents.BinaryHeaders(ce), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown event encoding: %q", encoding)
+	}
+}
+
+// Map domain events to their schema in proto/events.proto
+func toProto(payload any) (proto.Message, error) {
+	switch e := payload.(type) {
+	case *domain.UserRegisteredEvent:
+		return &authpb.UserRegistered{
+			UserId:    e.UserID,
+			Email:     e.Email,
+			Role:      string(e.Role),
+			CreatedAt: timestamppb.New(e.CreatedAt),
+			Username:  e.Username,
+			CreatedBy: e.CreatedBy,
+		}, nil
+	case *domain.UserLoggedInEvent:
+		return &authpb.UserLoggedIn{
+			UserId:    e.UserID,
+			Ip:        e.IP,
+			UserAgent: e.UserAgent,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	case *domain.UserLoggedOutEvent:
+		return &authpb.UserLoggedOut{
+			UserId:    e.UserID,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	case *domain.UserProfileUpdatedEvent:
+		return &authpb.UserProfileUpdated{
+			UserId:    e.UserID,
+			UpdatedBy: e.UpdatedBy,
+			Fields:    e.Fields,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	case *domain.UserEmailVerifiedEvent:
+		return &authpb.UserEmailVerified{
+			UserId:    e.UserID,
+			Email:     e.Email,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	case *domain.UserRoleChangedEvent:
+		return &authpb.UserRoleChanged{
+			UserId:    e.UserID,
+			OldRole:   string(e.OldRole),
+			NewRole:   string(e.NewRole),
+			ChangedBy: e.ChangedBy,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	case *domain.PasswordChangedEvent:
+		return &authpb.PasswordChanged{
+			UserId:    e.UserID,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	case *domain.PasswordResetEvent:
+		return &authpb.PasswordReset{
+			UserId:    e.UserID,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	case *domain.UserDeletedEvent:
+		return &authpb.UserDeleted{
+			UserId:    e.UserID,
+			DeletedBy: e.DeletedBy,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+			PurgeAt:   timestamppb.New(e.PurgeAt),
+		}, nil
+	case *domain.UserVerifiedSetEvent:
+		return &authpb.UserVerifiedSet{
+			UserId:    e.UserID,
+			Verified:  e.Verified,
+			ChangedBy: e.ChangedBy,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	case *domain.UserRestoredEvent:
+		return &authpb.UserRestored{
+			UserId:    e.UserID,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	case *domain.UserErasedEvent:
+		return &authpb.UserErased{
+			UserId:    e.UserID,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	case *domain.UserEmailChangedEvent:
+		return &authpb.UserEmailChanged{
+			UserId:    e.UserID,
+			OldEmail:  e.OldEmail,
+			NewEmail:  e.NewEmail,
+			Reverted:  e.Reverted,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	case *domain.UserPhoneVerifiedEvent:
+		return &authpb.UserPhoneVerified{
+			UserId:    e.UserID,
+			Phone:     e.Phone,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	case *domain.UserStatusChangedEvent:
+		msg := &authpb.UserStatusChanged{
+			UserId:    e.UserID,
+			Status:    string(e.Status),
+			Reason:    e.Reason,
+			ChangedBy: e.ChangedBy,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}
+		if e.Until != nil {
+			msg.Until = timestamppb.New(*e.Until)
+		}
+		return msg, nil
+	case *domain.GroupMembersAddedEvent:
+		return &authpb.GroupMembersAdded{
+			GroupId:   e.GroupID,
+			Relation:  e.Relation,
+			UserIds:   e.UserIDs,
+			ChangedBy: e.ChangedBy,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	case *domain.GroupMembersRemovedEvent:
+		return &authpb.GroupMembersRemoved{
+			GroupId:   e.GroupID,
+			Relation:  e.Relation,
+			UserIds:   e.UserIDs,
+			ChangedBy: e.ChangedBy,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		}, nil
+	default:
+		return nil, fmt.Errorf("no proto schema for event %T", payload)
+	}
+}
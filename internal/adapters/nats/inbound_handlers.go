@@ -0,0 +1,124 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/usecase"
+)
+
+// Reactions to events published by other services
+type InboundHandlers struct {
+	uc        usecase.UserUsecase
+	relations usecase.RelationUsecase
+}
+
+func NewInboundHandlers(uc usecase.UserUsecase, relations usecase.RelationUsecase) *InboundHandlers {
+	return &InboundHandlers{uc: uc, relations: relations}
+}
+
+// enrollment.student_withdrawn: disable the student account
+func (h *InboundHandlers) StudentWithdrawn(ctx context.Context, msg *Message) error {
+	userID, tenant, err := decodeUserID(msg.Data)
+	if err != nil {
+		return err
+	}
+
+	err = h.uc.DisableAccount(domain.WithTenant(ctx, tenant), userID, "student withdrawn", domain.STUDENT)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) || errors.Is(err, domain.ErrUnexpectedRole) {
+			return fmt.Errorf("%w: %v", ErrPermanent, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// hr.staff_terminated: disable the teacher or admin account, which also
+// revokes its sessions
+func (h *InboundHandlers) StaffTerminated(ctx context.Context, msg *Message) error {
+	userID, tenant, err := decodeUserID(msg.Data)
+	if err != nil {
+		return err
+	}
+
+	err = h.uc.DisableAccount(domain.WithTenant(ctx, tenant), userID, "staff terminated", domain.TEACHER, domain.ADMIN)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) || errors.Is(err, domain.ErrUnexpectedRole) {
+			return fmt.Errorf("%w: %v", ErrPermanent, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// course.relations: tuple writes and deletes from the course service
+func (h *InboundHandlers) RelationsChanged(ctx context.Context, msg *Message) error {
+	type body struct {
+		TenantID string   `json:"tenant_id"` // the default tenant when missing
+		Writes   []string `json:"writes"`
+		Deletes  []string `json:"deletes"`
+	}
+	var in struct {
+		body
+		Data *body `json:"data"`
+	}
+	if err := json.Unmarshal(msg.Data, &in); err != nil {
+		return fmt.Errorf("%w: decode: %v", ErrPermanent, err)
+	}
+	if in.Data != nil {
+		in.body = *in.Data
+	}
+	if in.TenantID == "" {
+		in.TenantID = domain.DefaultTenantID
+	}
+	if !domain.ValidTenantID(in.TenantID) {
+		return fmt.Errorf("%w: invalid tenant_id", ErrPermanent)
+	}
+
+	change, err := domain.ParseRelationChange(in.Writes, in.Deletes)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPermanent, err)
+	}
+	if err := h.relations.ApplyRelationChange(domain.WithTenant(ctx, in.TenantID), change); err != nil {
+		if errors.Is(err, domain.ErrInvalidTuple) || errors.Is(err, domain.ErrEmptyRelations) {
+			return fmt.Errorf("%w: %v", ErrPermanent, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// Accepts a plain JSON body or a structured CloudEvent with the body in data,
+// tenant_id is required
+func decodeUserID(body []byte) (userID, tenantID string, err error) {
+	type ref struct {
+		UserID   string `json:"user_id"`
+		TenantID string `json:"tenant_id"`
+	}
+	var msg struct {
+		ref
+		Data *ref `json:"data"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return "", "", fmt.Errorf("%w: decode: %v", ErrPermanent, err)
+	}
+
+	if msg.Data != nil && msg.Data.UserID != "" {
+		msg.ref = *msg.Data
+	}
+	if msg.UserID == "" {
+		return "", "", fmt.Errorf("%w: missing user_id", ErrPermanent)
+	}
+	if msg.TenantID == "" {
+		return "", "", fmt.Errorf("%w: missing tenant_id", ErrPermanent)
+	}
+	if !domain.ValidTenantID(msg.TenantID) {
+		return "", "", fmt.Errorf("%w: invalid tenant_id", ErrPermanent)
+	}
+
+	return msg.UserID, msg.TenantID, nil
+}
@@ -0,0 +1,117 @@
+package nats
+
+import (
+	"context"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/Neroframe/AuthService/pkg/nats"
+	"github.com/google/uuid"
+)
+
+type RelayConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	LockTTL      time.Duration
+	MaxAttempts  int           // a record failing this often is dead-lettered
+	Backoff      time.Duration // first retry delay, doubled on every attempt
+	MaxBackoff   time.Duration
+}
+
+// Relay moves outbox records to NATS with at-least-once delivery
+type Relay struct {
+	outbox repository.OutboxRepository
+	conn   *nats.Client
+	log    *logger.Logger
+	cfg    RelayConfig
+	owner  string // relay lock holder id
+}
+
+func NewRelay(outbox repository.OutboxRepository, c *nats.Client, log *logger.Logger, cfg RelayConfig) *Relay {
+	return &Relay{outbox: outbox, conn: c, log: log, cfg: cfg, owner: uuid.NewString()}
+}
+
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			r.flush(ctx)
+		}
+	}
+}
+
+// Publish pending records until none is due. Every pass takes the head of
+// each aggregate, so it keeps going while heads get sent.
+func (r *Relay) flush(ctx context.Context) {
+	for ctx.Err() == nil {
+		// Only one replica relays at a time, the lease is renewed every pass
+		ok, err := r.outbox.AcquireRelayLock(ctx, r.owner, r.cfg.LockTTL)
+		if err != nil {
+			r.log.Error("outbox relay lock failed", "err", err)
+			return
+		}
+		if !ok || r.relayBatch(ctx) == 0 {
+			return
+		}
+	}
+}
+
+// Publish one head record per aggregate, returns how many were sent
+func (r *Relay) relayBatch(ctx context.Context) int {
+	recs, err := r.outbox.FetchPending(ctx, time.Now().UTC(), r.cfg.BatchSize)
+	if err != nil {
+		r.log.Error("outbox fetch failed", "err", err)
+		return 0
+	}
+
+	sent := 0
+	for _, rec := range recs {
+		// Record ID doubles as Nats-Msg-Id, so re-sends within the dedup window are dropped
+		if err := r.conn.PublishAck(ctx, rec.Subject, rec.Payload, rec.Headers, rec.ID); err != nil {
+			r.failed(ctx, rec, err)
+			continue
+		}
+
+		// Crash before this point re-sends the record, dedup by Nats-Msg-Id or CloudEvent ID
+		if err := r.outbox.MarkSent(ctx, rec.ID); err != nil {
+			r.log.Error("outbox MarkSent failed", "id", rec.ID, "err", err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+// Retry with exponential backoff, the rest of the aggregate waits meanwhile.
+// After MaxAttempts the record is dead-lettered and the aggregate moves on.
+func (r *Relay) failed(ctx context.Context, rec *domain.OutboxRecord, cause error) {
+	attempts := rec.Attempts + 1
+	if r.cfg.MaxAttempts > 0 && attempts >= r.cfg.MaxAttempts {
+		r.log.Error("outbox record dead-lettered", "id", rec.ID, "subject", rec.Subject, "aggregate_id", rec.AggregateID, "attempts", attempts, "err", cause)
+		if err := r.outbox.MarkDead(ctx, rec.ID, cause); err != nil {
+			r.log.Error("outbox MarkDead failed", "id", rec.ID, "err", err)
+		}
+		return
+	}
+
+	next := time.Now().UTC().Add(r.backoff(rec.Attempts))
+	r.log.Warn("outbox publish failed", "id", rec.ID, "subject", rec.Subject, "attempts", attempts, "retry_at", next, "err", cause)
+	if err := r.outbox.MarkFailed(ctx, rec.ID, cause, next); err != nil {
+		r.log.Error("outbox MarkFailed failed", "id", rec.ID, "err", err)
+	}
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.cfg.Backoff
+	for i := 0; i < attempts && delay < r.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.cfg.MaxBackoff)
+}
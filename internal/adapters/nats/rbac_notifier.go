@@ -0,0 +1,31 @@
+package nats
+
+import (
+	"context"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/pkg/nats"
+	natsgo "github.com/nats-io/nats.go"
+)
+
+// Broadcasts RBAC changes over core NATS, no queue group so every replica hears it
+type RBACNotifier struct {
+	conn    *nats.Client
+	subject string
+}
+
+var _ domain.RBACNotifier = (*RBACNotifier)(nil)
+
+func NewRBACNotifier(c *nats.Client, subject string) *RBACNotifier {
+	return &RBACNotifier{conn: c, subject: subject}
+}
+
+func (n *RBACNotifier) PublishRBACChanged(ctx context.Context) error {
+	return n.conn.Publish(n.subject, []byte(time.Now().UTC().Format(time.RFC3339Nano)))
+}
+
+// Call reload on every change notice, including our own
+func (n *RBACNotifier) Subscribe(reload func()) (*natsgo.Subscription, error) {
+	return n.conn.Subscribe(n.subject, func(*natsgo.Msg) { reload() })
+}
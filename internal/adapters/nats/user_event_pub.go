@@ -2,19 +2,148 @@ package nats
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/Neroframe/AuthService/internal/domain"
-	"github.com/Neroframe/AuthService/pkg/nats"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/cloudevents"
+	"github.com/google/uuid"
 )
 
+// NATS subject per event type
+type Subjects struct {
+	UserRegistered      string
+	UserLoggedIn        string
+	UserLoggedOut       string
+	UserProfileUpdated  string
+	UserEmailVerified   string
+	UserRoleChanged     string
+	PasswordChanged     string
+	PasswordReset       string
+	UserDeleted         string
+	UserVerifiedSet     string
+	UserRestored        string
+	UserErased          string
+	UserEmailChanged    string
+	UserPhoneVerified   string
+	UserStatusChanged   string
+	GroupMembersAdded   string
+	GroupMembersRemoved string
+}
+
+// Writes events into the outbox, the Relay delivers them to NATS.
+// Call inside TxManager.WithinTx to save the event atomically with the change.
 type AuthPublisher struct {
-	conn *nats.Client
+	outbox   repository.OutboxRepository
+	subjects Subjects
+	source   string // CloudEvents source
+	encoding string // EncodingJSON or EncodingProtobuf
 }
 
-func NewAuthPublisher(c *nats.Client) *AuthPublisher {
-	return &AuthPublisher{conn: c}
+var _ domain.UserEventPublisher = (*AuthPublisher)(nil)
+
+func NewAuthPublisher(outbox repository.OutboxRepository, subjects Subjects, source, encoding string) *AuthPublisher {
+	return &AuthPublisher{outbox: outbox, subjects: subjects, source: source, encoding: encoding}
 }
 
 func (p *AuthPublisher) PublishUserRegistered(ctx context.Context, evt *domain.UserRegisteredEvent) error {
-	return p.conn.PublishJSON("user.registered", evt)
+	return p.publish(ctx, evt.UserID, p.subjects.UserRegistered, domain.EventUserRegistered, evt)
+}
+
+func (p *AuthPublisher) PublishUserLoggedIn(ctx context.Context, evt *domain.UserLoggedInEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.UserLoggedIn, domain.EventUserLoggedIn, evt)
+}
+
+func (p *AuthPublisher) PublishUserLoggedOut(ctx context.Context, evt *domain.UserLoggedOutEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.UserLoggedOut, domain.EventUserLoggedOut, evt)
+}
+
+func (p *AuthPublisher) PublishUserProfileUpdated(ctx context.Context, evt *domain.UserProfileUpdatedEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.UserProfileUpdated, domain.EventUserProfileUpdated, evt)
+}
+
+func (p *AuthPublisher) PublishUserEmailVerified(ctx context.Context, evt *domain.UserEmailVerifiedEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.UserEmailVerified, domain.EventUserEmailVerified, evt)
+}
+
+func (p *AuthPublisher) PublishUserRoleChanged(ctx context.Context, evt *domain.UserRoleChangedEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.UserRoleChanged, domain.EventUserRoleChanged, evt)
+}
+
+func (p *AuthPublisher) PublishPasswordChanged(ctx context.Context, evt *domain.PasswordChangedEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.PasswordChanged, domain.EventPasswordChanged, evt)
+}
+
+func (p *AuthPublisher) PublishPasswordReset(ctx context.Context, evt *domain.PasswordResetEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.PasswordReset, domain.EventPasswordReset, evt)
+}
+
+func (p *AuthPublisher) PublishUserDeleted(ctx context.Context, evt *domain.UserDeletedEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.UserDeleted, domain.EventUserDeleted, evt)
+}
+
+func (p *AuthPublisher) PublishUserVerifiedSet(ctx context.Context, evt *domain.UserVerifiedSetEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.UserVerifiedSet, domain.EventUserVerifiedSet, evt)
+}
+
+func (p *AuthPublisher) PublishUserRestored(ctx context.Context, evt *domain.UserRestoredEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.UserRestored, domain.EventUserRestored, evt)
+}
+
+func (p *AuthPublisher) PublishUserErased(ctx context.Context, evt *domain.UserErasedEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.UserErased, domain.EventUserErased, evt)
+}
+
+func (p *AuthPublisher) PublishUserEmailChanged(ctx context.Context, evt *domain.UserEmailChangedEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.UserEmailChanged, domain.EventUserEmailChanged, evt)
+}
+
+func (p *AuthPublisher) PublishUserPhoneVerified(ctx context.Context, evt *domain.UserPhoneVerifiedEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.UserPhoneVerified, domain.EventUserPhoneVerified, evt)
+}
+
+func (p *AuthPublisher) PublishUserStatusChanged(ctx context.Context, evt *domain.UserStatusChangedEvent) error {
+	return p.publish(ctx, evt.UserID, p.subjects.UserStatusChanged, domain.EventUserStatusChanged, evt)
+}
+
+func (p *AuthPublisher) PublishGroupMembersAdded(ctx context.Context, evt *domain.GroupMembersAddedEvent) error {
+	return p.publish(ctx, evt.GroupID, p.subjects.GroupMembersAdded, domain.EventGroupMembersAdded, evt)
+}
+
+func (p *AuthPublisher) PublishGroupMembersRemoved(ctx context.Context, evt *domain.GroupMembersRemovedEvent) error {
+	return p.publish(ctx, evt.GroupID, p.subjects.GroupMembersRemoved, domain.EventGroupMembersRemoved, evt)
+}
+
+// Encode payload as a CloudEvent and store it in the outbox
+func (p *AuthPublisher) publish(ctx context.Context, aggregateID, subject string, typ domain.EventType, payload any) error {
+	// Fall back to event type if subject isn't configured
+	if subject == "" {
+		subject = string(typ)
+	}
+
+	now := time.Now().UTC()
+	ce := cloudevents.Event{
+		ID:       uuid.NewString(),
+		Source:   p.source,
+		Type:     fmt.Sprintf("%s.v%d", typ, domain.EventSchemaVersion),
+		Subject:  aggregateID,
+		Time:     now,
+		TenantID: domain.TenantOf(ctx),
+	}
+
+	body, headers, err := encodeEvent(p.encoding, ce, payload)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", typ, err)
+	}
+
+	return p.outbox.Add(ctx, &domain.OutboxRecord{
+		ID:          ce.ID,
+		AggregateID: aggregateID,
+		Subject:     subject,
+		EventType:   typ,
+		Payload:     body,
+		Headers:     headers,
+		CreatedAt:   now,
+	})
 }
@@ -0,0 +1,68 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/usecase"
+)
+
+// Strips the schema version suffix, "user.registered.v1" -> "user.registered"
+var typeVersion = regexp.MustCompile(`\.v[0-9]+$`)
+
+// Fans our own user events out to webhook subscriptions
+type WebhookFanout struct {
+	uc usecase.WebhookUsecase
+}
+
+func NewWebhookFanout(uc usecase.WebhookUsecase) *WebhookFanout {
+	return &WebhookFanout{uc: uc}
+}
+
+func (h *WebhookFanout) UserEvent(ctx context.Context, msg *Message) error {
+	typ, tenant, subject := msg.Headers["ce-type"], msg.Headers["ce-tenantid"], msg.Headers["ce-subject"]
+	if typ == "" {
+		// Structured CloudEvent
+		var ce struct {
+			Type     string `json:"type"`
+			TenantID string `json:"tenantid"`
+			Subject  string `json:"subject"`
+		}
+		if err := json.Unmarshal(msg.Data, &ce); err != nil {
+			return fmt.Errorf("%w: decode: %v", ErrPermanent, err)
+		}
+		typ, tenant, subject = ce.Type, ce.TenantID, ce.Subject
+	}
+	if typ == "" {
+		return fmt.Errorf("%w: missing event type", ErrPermanent)
+	}
+	// Events published before tenants existed
+	if tenant == "" {
+		tenant = domain.DefaultTenantID
+	}
+
+	// Only the event's own tenant's subscriptions get it
+	return h.uc.EnqueueWebhookEvent(domain.WithTenant(ctx, tenant), &domain.WebhookEvent{
+		ID:      msg.ID,
+		Type:    domain.EventType(typeVersion.ReplaceAllString(typ, "")),
+		Subject: subject,
+		Body:    msg.Data,
+		Headers: cloudEventHeaders(msg.Headers),
+	})
+}
+
+// Keep only headers meaningful to HTTP receivers (binary mode CloudEvents)
+func cloudEventHeaders(h map[string]string) map[string]string {
+	out := make(map[string]string)
+	for k, v := range h {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || strings.HasPrefix(lk, "ce-") {
+			out[k] = v
+		}
+	}
+	return out
+}
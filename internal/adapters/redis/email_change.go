@@ -0,0 +1,125 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+const (
+	emailChangePrefix = "email_change:"
+	emailUndoPrefix   = "email_change_undo:"
+	emailUndosPrefix  = "email_change_undos:" // set of a user's undo token hashes
+)
+
+type EmailChangeStore struct {
+	client *redisv9.Client
+}
+
+var _ domain.EmailChangeStore = (*EmailChangeStore)(nil)
+
+func NewEmailChangeStore(client *redisv9.Client) *EmailChangeStore {
+	return &EmailChangeStore{client: client}
+}
+
+func (s *EmailChangeStore) SavePending(ctx context.Context, c *domain.EmailChange, ttl time.Duration) error {
+	return s.set(ctx, emailChangePrefix+c.UserID, c, ttl)
+}
+
+func (s *EmailChangeStore) GetPending(ctx context.Context, userID string) (*domain.EmailChange, error) {
+	var c domain.EmailChange
+	if err := s.get(ctx, emailChangePrefix+userID, &c); err != nil {
+		if errors.Is(err, redisv9.Nil) {
+			return nil, domain.ErrNoPendingEmailChange
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *EmailChangeStore) DeletePending(ctx context.Context, userID string) error {
+	if err := s.client.Del(ctx, emailChangePrefix+userID).Err(); err != nil {
+		return fmt.Errorf("redis Del: %w", err)
+	}
+	return nil
+}
+
+func (s *EmailChangeStore) SaveUndo(ctx context.Context, tokenHash string, u *domain.EmailChangeUndo, ttl time.Duration) error {
+	if err := s.set(ctx, emailUndoPrefix+tokenHash, u, ttl); err != nil {
+		return err
+	}
+	// The undo TTL is fixed, so the latest save outlives the earlier tokens
+	_, err := s.client.TxPipelined(ctx, func(p redisv9.Pipeliner) error {
+		p.SAdd(ctx, emailUndosPrefix+u.UserID, tokenHash)
+		p.Expire(ctx, emailUndosPrefix+u.UserID, ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis SAdd: %w", err)
+	}
+	return nil
+}
+
+func (s *EmailChangeStore) GetUndo(ctx context.Context, tokenHash string) (*domain.EmailChangeUndo, error) {
+	var u domain.EmailChangeUndo
+	if err := s.get(ctx, emailUndoPrefix+tokenHash, &u); err != nil {
+		if errors.Is(err, redisv9.Nil) {
+			return nil, domain.ErrUndoTokenInvalid
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *EmailChangeStore) DeleteUndo(ctx context.Context, tokenHash string) error {
+	if err := s.client.Del(ctx, emailUndoPrefix+tokenHash).Err(); err != nil {
+		return fmt.Errorf("redis Del: %w", err)
+	}
+	return nil
+}
+
+func (s *EmailChangeStore) DeleteUser(ctx context.Context, userID string) error {
+	hashes, err := s.client.SMembers(ctx, emailUndosPrefix+userID).Result()
+	if err != nil {
+		return fmt.Errorf("redis SMembers: %w", err)
+	}
+	keys := []string{emailChangePrefix + userID, emailUndosPrefix + userID}
+	for _, h := range hashes {
+		keys = append(keys, emailUndoPrefix+h)
+	}
+	if err := s.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("redis Del: %w", err)
+	}
+	return nil
+}
+
+func (s *EmailChangeStore) set(ctx context.Context, key string, v any, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+	if err := s.client.Set(ctx, key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("redis Set: %w", err)
+	}
+	return nil
+}
+
+// Returns redisv9.Nil unwrapped for a missing key
+func (s *EmailChangeStore) get(ctx context.Context, key string, v any) error {
+	data, err := s.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redisv9.Nil) {
+			return err
+		}
+		return fmt.Errorf("redis Get: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+const sendLimitPrefix = "send_limit:"
+
+// One send per key and window, the key expires with the window
+type SendLimiter struct {
+	client *redisv9.Client
+}
+
+var _ domain.SendLimiter = (*SendLimiter)(nil)
+
+func NewSendLimiter(client *redisv9.Client) *SendLimiter {
+	return &SendLimiter{client: client}
+}
+
+func (l *SendLimiter) Allow(ctx context.Context, key string, window time.Duration) (bool, error) {
+	ok, err := l.client.SetNX(ctx, sendLimitPrefix+key, 1, window).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis SetNX: %w", err)
+	}
+	return ok, nil
+}
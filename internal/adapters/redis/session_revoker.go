@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+const (
+	revokedPrefix      = "revoked_before:"
+	revokedTokenPrefix = "revoked_token:"
+)
+
+// Stores per user revocation time, tokens issued at or before it are rejected
+type SessionRevoker struct {
+	client *redisv9.Client
+	ttl    time.Duration // access token lifetime, older tokens expire anyway
+}
+
+var _ domain.SessionRevoker = (*SessionRevoker)(nil)
+
+func NewSessionRevoker(client *redisv9.Client, tokenTTL time.Duration) *SessionRevoker {
+	return &SessionRevoker{client: client, ttl: tokenTTL}
+}
+
+func (r *SessionRevoker) RevokeAll(ctx context.Context, userID string) error {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := r.client.Set(ctx, revokedPrefix+userID, now, r.ttl).Err(); err != nil {
+		return fmt.Errorf("redis Set: %w", err)
+	}
+	return nil
+}
+
+// Tokens carry no ID, a single one is keyed by user and issue second, so a
+// logout also ends other sessions opened in that same second
+func (r *SessionRevoker) Revoke(ctx context.Context, userID string, issuedAt, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil // already expired
+	}
+	if err := r.client.Set(ctx, tokenKey(userID, issuedAt), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("redis Set: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRevoker) IsRevoked(ctx context.Context, userID string, issuedAt time.Time) (bool, error) {
+	vals, err := r.client.MGet(ctx, revokedPrefix+userID, tokenKey(userID, issuedAt)).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis MGet: %w", err)
+	}
+	if vals[1] != nil {
+		return true, nil
+	}
+	s, ok := vals[0].(string)
+	if !ok {
+		return false, nil
+	}
+	before, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("parse %s: %w", revokedPrefix+userID, err)
+	}
+
+	return issuedAt.Unix() <= before, nil
+}
+
+func (r *SessionRevoker) RevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	val, err := r.client.Get(ctx, revokedPrefix+userID).Int64()
+	if err != nil {
+		if errors.Is(err, redisv9.Nil) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("redis Get: %w", err)
+	}
+
+	return time.Unix(val, 0).UTC(), nil
+}
+
+func tokenKey(userID string, issuedAt time.Time) string {
+	return revokedTokenPrefix + userID + ":" + strconv.FormatInt(issuedAt.Unix(), 10)
+}
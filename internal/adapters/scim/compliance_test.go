@@ -0,0 +1,762 @@
+package scim_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Protocol checks of RFC 7644 against a running endpoint. Cases run in
+// order and share the resources they create, names get a random suffix so
+// a live server's data isn't touched.
+type suite struct {
+	base   string // .../scim/v2
+	token  string
+	client *http.Client
+	suffix string
+
+	// Set for the in-process server only
+	revokedToken  string
+	disabledToken string
+
+	// Created by earlier cases
+	userID, userETag string
+	emailUserID      string
+	groupID          string
+}
+
+type testCase struct {
+	name string
+	run  func(s *suite) error
+}
+
+type response struct {
+	status int
+	header http.Header
+	doc    map[string]any
+}
+
+func (s *suite) do(method, path string, body any, header map[string]string) (*response, error) {
+	var rd io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rd = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, s.base+path, rd)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Content-Type", "application/scim+json")
+	for k, v := range header {
+		if v == "" {
+			req.Header.Del(k)
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	out := &response{status: resp.StatusCode, header: resp.Header}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(raw)) > 0 {
+		if err := json.Unmarshal(raw, &out.doc); err != nil {
+			return nil, fmt.Errorf("%s %s: body is not a JSON object: %.200s", method, path, raw)
+		}
+	}
+	return out, nil
+}
+
+// Fails unless the status is want, with the error detail of the response
+func (r *response) expect(want int) error {
+	if r.status == want {
+		return nil
+	}
+	return fmt.Errorf("status %d, want %d: %v", r.status, want, r.doc["detail"])
+}
+
+// A SCIM error response with the status and scimType
+func (r *response) expectError(status int, scimType string) error {
+	if err := r.expect(status); err != nil {
+		return err
+	}
+	if got := fmt.Sprint(r.doc["status"]); got != fmt.Sprint(status) {
+		return fmt.Errorf("error status %q, want %q", got, fmt.Sprint(status))
+	}
+	if !hasSchema(r.doc, "urn:ietf:params:scim:api:messages:2.0:Error") {
+		return fmt.Errorf("error response without the Error schema")
+	}
+	if scimType != "" && r.doc["scimType"] != scimType {
+		return fmt.Errorf("scimType %v, want %s", r.doc["scimType"], scimType)
+	}
+	return nil
+}
+
+func hasSchema(doc map[string]any, schema string) bool {
+	list, _ := doc["schemas"].([]any)
+	for _, s := range list {
+		if s == schema {
+			return true
+		}
+	}
+	return false
+}
+
+// Dotted path into a document, list indexes included: "emails.0.value"
+func get(doc map[string]any, path string) any {
+	var cur any = doc
+	for _, part := range strings.Split(path, ".") {
+		switch x := cur.(type) {
+		case map[string]any:
+			cur = x[part]
+		case []any:
+			var i int
+			if _, err := fmt.Sscan(part, &i); err != nil || i >= len(x) {
+				return nil
+			}
+			cur = x[i]
+		default:
+			return nil
+		}
+	}
+	return cur
+}
+
+func expectValue(doc map[string]any, path string, want any) error {
+	if got := get(doc, path); fmt.Sprint(got) != fmt.Sprint(want) {
+		return fmt.Errorf("%s is %v, want %v", path, got, want)
+	}
+	return nil
+}
+
+func (s *suite) list(path, filter, extra string) (*response, error) {
+	q := url.Values{}
+	if filter != "" {
+		q.Set("filter", filter)
+	}
+	p := path + "?" + q.Encode()
+	if extra != "" {
+		p += "&" + extra
+	}
+	return s.do(http.MethodGet, p, nil, nil)
+}
+
+// totalResults of a filtered list
+func (s *suite) count(path, filter string) (int, error) {
+	resp, err := s.list(path, filter, "")
+	if err != nil {
+		return 0, err
+	}
+	if err := resp.expect(http.StatusOK); err != nil {
+		return 0, err
+	}
+	n, _ := resp.doc["totalResults"].(float64)
+	return int(n), nil
+}
+
+func expectCount(s *suite, path, filter string, want int) error {
+	n, err := s.count(path, filter)
+	if err != nil {
+		return fmt.Errorf("filter %s: %w", filter, err)
+	}
+	if n != want {
+		return fmt.Errorf("filter %s: %d results, want %d", filter, n, want)
+	}
+	return nil
+}
+
+func patchOp(ops ...map[string]any) map[string]any {
+	list := make([]any, len(ops))
+	for i, op := range ops {
+		list[i] = op
+	}
+	return map[string]any{
+		"schemas":    []any{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+		"Operations": list,
+	}
+}
+
+func (s *suite) cases() []testCase {
+	userName := "bjensen" + s.suffix
+	email := "jsmith" + s.suffix + "@example.com"
+	externalID := "ext-" + s.suffix
+	groupName := "Tour Guides " + s.suffix
+
+	return []testCase{
+		// ------------ discovery and auth ------------
+		{"service provider config", func(s *suite) error {
+			resp, err := s.do(http.MethodGet, "/ServiceProviderConfig", nil, nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusOK); err != nil {
+				return err
+			}
+			if err := expectValue(resp.doc, "patch.supported", true); err != nil {
+				return err
+			}
+			if ct := resp.header.Get("Content-Type"); !strings.HasPrefix(ct, "application/scim+json") {
+				return fmt.Errorf("content type %q", ct)
+			}
+			return expectValue(resp.doc, "etag.supported", true)
+		}},
+		{"schemas and resource types", func(s *suite) error {
+			resp, err := s.do(http.MethodGet, "/Schemas", nil, nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusOK); err != nil {
+				return err
+			}
+			if err := expectValue(resp.doc, "totalResults", 2); err != nil {
+				return err
+			}
+			resp, err = s.do(http.MethodGet, "/Schemas/urn:ietf:params:scim:schemas:core:2.0:User", nil, nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusOK); err != nil {
+				return err
+			}
+			resp, err = s.do(http.MethodGet, "/ResourceTypes", nil, nil)
+			if err != nil {
+				return err
+			}
+			return expectValue(resp.doc, "Resources.1.endpoint", "/Groups")
+		}},
+		{"missing token", func(s *suite) error {
+			resp, err := s.do(http.MethodGet, "/Users", nil, map[string]string{"Authorization": ""})
+			if err != nil {
+				return err
+			}
+			if resp.header.Get("WWW-Authenticate") == "" {
+				return fmt.Errorf("no WWW-Authenticate header")
+			}
+			return resp.expectError(http.StatusUnauthorized, "")
+		}},
+		{"unknown token", func(s *suite) error {
+			resp, err := s.do(http.MethodGet, "/Users", nil, map[string]string{"Authorization": "Bearer scim_0000"})
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusUnauthorized, "")
+		}},
+		{"revoked token", func(s *suite) error {
+			if s.revokedToken == "" {
+				return nil
+			}
+			resp, err := s.do(http.MethodGet, "/Users", nil, map[string]string{"Authorization": "Bearer " + s.revokedToken})
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusUnauthorized, "")
+		}},
+		{"token of a disabled tenant", func(s *suite) error {
+			if s.disabledToken == "" {
+				return nil
+			}
+			resp, err := s.do(http.MethodGet, "/Users", nil, map[string]string{"Authorization": "Bearer " + s.disabledToken})
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusForbidden, "")
+		}},
+
+		// ------------ users ------------
+		{"create user", func(s *suite) error {
+			resp, err := s.do(http.MethodPost, "/Users", map[string]any{
+				"schemas":    []any{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"userName":   userName,
+				"externalId": externalID,
+				"name":       map[string]any{"givenName": "Barbara", "familyName": "Jensen"},
+				"emails": []any{
+					map[string]any{"value": "bjensen" + s.suffix + "@example.com", "type": "work", "primary": true},
+				},
+				"phoneNumbers": []any{map[string]any{"value": "+1 555 555 5555", "type": "work"}},
+				"active":       true,
+				"password":     "t1meMa$heen",
+			}, nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusCreated); err != nil {
+				return err
+			}
+			s.userID, _ = resp.doc["id"].(string)
+			s.userETag = resp.header.Get("ETag")
+			switch {
+			case s.userID == "":
+				return fmt.Errorf("no id")
+			case s.userETag == "":
+				return fmt.Errorf("no ETag header")
+			case !strings.HasSuffix(resp.header.Get("Location"), "/Users/"+s.userID):
+				return fmt.Errorf("location %q", resp.header.Get("Location"))
+			case resp.doc["password"] != nil:
+				return fmt.Errorf("password returned")
+			}
+			if err := expectValue(resp.doc, "meta.resourceType", "User"); err != nil {
+				return err
+			}
+			if err := expectValue(resp.doc, "meta.version", s.userETag); err != nil {
+				return err
+			}
+			return expectValue(resp.doc, "userName", userName)
+		}},
+		{"create user with an email as userName", func(s *suite) error {
+			resp, err := s.do(http.MethodPost, "/Users", map[string]any{
+				"schemas":  []any{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"userName": email,
+				"roles":    []any{map[string]any{"value": "teacher", "primary": true}},
+			}, nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusCreated); err != nil {
+				return err
+			}
+			s.emailUserID, _ = resp.doc["id"].(string)
+			if err := expectValue(resp.doc, "emails.0.value", email); err != nil {
+				return err
+			}
+			return expectValue(resp.doc, "roles.0.value", "teacher")
+		}},
+		{"create duplicate userName", func(s *suite) error {
+			resp, err := s.do(http.MethodPost, "/Users", map[string]any{
+				"schemas":  []any{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"userName": strings.ToUpper(userName),
+			}, nil)
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusConflict, "uniqueness")
+		}},
+		{"create duplicate externalId", func(s *suite) error {
+			resp, err := s.do(http.MethodPost, "/Users", map[string]any{
+				"schemas":    []any{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"userName":   "other" + s.suffix,
+				"externalId": externalID,
+			}, nil)
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusConflict, "uniqueness")
+		}},
+		{"create user without userName", func(s *suite) error {
+			resp, err := s.do(http.MethodPost, "/Users", map[string]any{
+				"schemas": []any{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"emails":  []any{map[string]any{"value": "x@example.com"}},
+			}, nil)
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusBadRequest, "invalidValue")
+		}},
+		{"create user with an unknown role", func(s *suite) error {
+			resp, err := s.do(http.MethodPost, "/Users", map[string]any{
+				"schemas":  []any{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"userName": "role" + s.suffix,
+				"roles":    []any{map[string]any{"value": "wizard"}},
+			}, nil)
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusBadRequest, "invalidValue")
+		}},
+		{"create user with a malformed body", func(s *suite) error {
+			req, err := http.NewRequest(http.MethodPost, s.base+"/Users", strings.NewReader("{not json"))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+s.token)
+			resp, err := s.client.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				return fmt.Errorf("status %d, want 400", resp.StatusCode)
+			}
+			return nil
+		}},
+		{"get user", func(s *suite) error {
+			resp, err := s.do(http.MethodGet, "/Users/"+s.userID, nil, nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusOK); err != nil {
+				return err
+			}
+			if resp.header.Get("ETag") != s.userETag {
+				return fmt.Errorf("ETag %q, want %q", resp.header.Get("ETag"), s.userETag)
+			}
+			if err := expectValue(resp.doc, "externalId", externalID); err != nil {
+				return err
+			}
+			return expectValue(resp.doc, "active", true)
+		}},
+		{"get user not modified", func(s *suite) error {
+			resp, err := s.do(http.MethodGet, "/Users/"+s.userID, nil, map[string]string{"If-None-Match": s.userETag})
+			if err != nil {
+				return err
+			}
+			return resp.expect(http.StatusNotModified)
+		}},
+		{"get unknown user", func(s *suite) error {
+			resp, err := s.do(http.MethodGet, "/Users/does-not-exist", nil, nil)
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusNotFound, "")
+		}},
+		{"get user attributes", func(s *suite) error {
+			resp, err := s.do(http.MethodGet, "/Users/"+s.userID+"?attributes=userName,emails.value", nil, nil)
+			if err != nil {
+				return err
+			}
+			switch {
+			case resp.doc["id"] == nil:
+				return fmt.Errorf("id dropped, it is always returned")
+			case resp.doc["phoneNumbers"] != nil || resp.doc["meta"] != nil:
+				return fmt.Errorf("attributes not requested returned")
+			case get(resp.doc, "emails.0.type") != nil:
+				return fmt.Errorf("emails.type returned")
+			}
+			return expectValue(resp.doc, "emails.0.value", "bjensen"+s.suffix+"@example.com")
+		}},
+		{"list users excludedAttributes", func(s *suite) error {
+			resp, err := s.list("/Users", `userName eq "`+userName+`"`, "excludedAttributes=emails,phoneNumbers")
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusOK); err != nil {
+				return err
+			}
+			if get(resp.doc, "Resources.0.emails") != nil || get(resp.doc, "Resources.0.phoneNumbers") != nil {
+				return fmt.Errorf("excluded attributes returned")
+			}
+			return expectValue(resp.doc, "Resources.0.userName", userName)
+		}},
+
+		// ------------ filters ------------
+		{"filter userName eq, case-insensitive", func(s *suite) error {
+			return expectCount(s, "/Users", `userName eq "`+strings.ToUpper(userName)+`"`, 1)
+		}},
+		{"filter externalId eq, case-exact", func(s *suite) error {
+			if err := expectCount(s, "/Users", `externalId eq "`+externalID+`"`, 1); err != nil {
+				return err
+			}
+			return expectCount(s, "/Users", `externalId eq "`+strings.ToUpper(externalID)+`"`, 0)
+		}},
+		{"filter id eq", func(s *suite) error {
+			return expectCount(s, "/Users", `id eq "`+s.userID+`"`, 1)
+		}},
+		{"filter by email", func(s *suite) error {
+			if err := expectCount(s, "/Users", `emails.value eq "`+email+`"`, 1); err != nil {
+				return err
+			}
+			return expectCount(s, "/Users", `emails[type eq "work" and value eq "bjensen`+s.suffix+`@example.com"]`, 1)
+		}},
+		{"filter and, or, not, pr, sw, co", func(s *suite) error {
+			both := `(userName eq "` + userName + `" or userName eq "` + email + `")`
+			for filter, want := range map[string]int{
+				both:                                2,
+				both + ` and externalId pr`:         1,
+				both + ` and not (externalId pr)`:   1,
+				both + ` and userName sw "BJENSEN"`: 1,
+				both + ` and emails.value co "` + s.suffix + `@"`:    2,
+				both + ` and emails.value ew "@example.com"`:         2,
+				both + ` and active eq true and roles pr`:            2,
+				both + ` and meta.created ge "2000-01-01T00:00:00Z"`: 2,
+				both + ` and meta.created lt "2000-01-01T00:00:00Z"`: 0,
+			} {
+				if err := expectCount(s, "/Users", filter, want); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"invalid filter", func(s *suite) error {
+			for _, filter := range []string{`userName eq`, `userName xx "a"`, `(userName eq "a"`, `userName eq "a" and`} {
+				resp, err := s.list("/Users", filter, "")
+				if err != nil {
+					return err
+				}
+				if err := resp.expectError(http.StatusBadRequest, "invalidFilter"); err != nil {
+					return fmt.Errorf("filter %s: %w", filter, err)
+				}
+			}
+			return nil
+		}},
+		{"pagination", func(s *suite) error {
+			filter := `userName eq "` + userName + `" or userName eq "` + email + `"`
+			resp, err := s.list("/Users", filter, "startIndex=2&count=1")
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusOK); err != nil {
+				return err
+			}
+			for path, want := range map[string]any{"totalResults": 2, "itemsPerPage": 1, "startIndex": 2} {
+				if err := expectValue(resp.doc, path, want); err != nil {
+					return err
+				}
+			}
+			resp, err = s.list("/Users", filter, "count=0")
+			if err != nil {
+				return err
+			}
+			if err := expectValue(resp.doc, "itemsPerPage", 0); err != nil {
+				return err
+			}
+			return expectValue(resp.doc, "totalResults", 2)
+		}},
+
+		// ------------ replace and patch ------------
+		{"replace user", func(s *suite) error {
+			resp, err := s.do(http.MethodPut, "/Users/"+s.userID, map[string]any{
+				"schemas":      []any{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"userName":     userName,
+				"emails":       []any{map[string]any{"value": "barbara" + s.suffix + "@example.com", "primary": true}},
+				"phoneNumbers": []any{map[string]any{"value": "+1 555 555 0000"}},
+			}, map[string]string{"If-Match": s.userETag})
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusOK); err != nil {
+				return err
+			}
+			if resp.doc["externalId"] != nil {
+				return fmt.Errorf("externalId kept by a replace without it")
+			}
+			etag := resp.header.Get("ETag")
+			if etag == "" || etag == s.userETag {
+				return fmt.Errorf("ETag %q not changed", etag)
+			}
+			s.userETag = etag
+			return expectValue(resp.doc, "emails.0.value", "barbara"+s.suffix+"@example.com")
+		}},
+		{"replace user with a stale ETag", func(s *suite) error {
+			resp, err := s.do(http.MethodPut, "/Users/"+s.userID, map[string]any{
+				"schemas":  []any{"urn:ietf:params:scim:schemas:core:2.0:User"},
+				"userName": userName,
+			}, map[string]string{"If-Match": `W/"stale"`})
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusPreconditionFailed, "")
+		}},
+		{"patch replace active", func(s *suite) error {
+			resp, err := s.do(http.MethodPatch, "/Users/"+s.userID, patchOp(
+				map[string]any{"op": "Replace", "path": "active", "value": false},
+			), map[string]string{"If-Match": s.userETag})
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusOK); err != nil {
+				return err
+			}
+			s.userETag = resp.header.Get("ETag")
+			if err := expectValue(resp.doc, "active", false); err != nil {
+				return err
+			}
+			return expectCount(s, "/Users", `userName eq "`+userName+`" and active eq false`, 1)
+		}},
+		{"patch without a path", func(s *suite) error {
+			resp, err := s.do(http.MethodPatch, "/Users/"+s.userID, patchOp(
+				map[string]any{"op": "replace", "value": map[string]any{"active": true, "externalId": externalID}},
+			), nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusOK); err != nil {
+				return err
+			}
+			if err := expectValue(resp.doc, "active", true); err != nil {
+				return err
+			}
+			return expectValue(resp.doc, "externalId", externalID)
+		}},
+		{"patch value filter path", func(s *suite) error {
+			resp, err := s.do(http.MethodPatch, "/Users/"+s.userID, patchOp(
+				map[string]any{"op": "replace", "path": `emails[primary eq true].value`, "value": "bj" + s.suffix + "@example.com"},
+				map[string]any{"op": "remove", "path": "phoneNumbers"},
+			), nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusOK); err != nil {
+				return err
+			}
+			if resp.doc["phoneNumbers"] != nil {
+				return fmt.Errorf("phoneNumbers not removed")
+			}
+			return expectValue(resp.doc, "emails.0.value", "bj"+s.suffix+"@example.com")
+		}},
+		{"patch read-only attribute", func(s *suite) error {
+			resp, err := s.do(http.MethodPatch, "/Users/"+s.userID, patchOp(
+				map[string]any{"op": "replace", "path": "id", "value": "x"},
+			), nil)
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusBadRequest, "mutability")
+		}},
+		{"patch without the PatchOp schema", func(s *suite) error {
+			resp, err := s.do(http.MethodPatch, "/Users/"+s.userID, map[string]any{
+				"Operations": []any{map[string]any{"op": "replace", "path": "active", "value": true}},
+			}, nil)
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusBadRequest, "invalidSyntax")
+		}},
+		{"patch bad path", func(s *suite) error {
+			resp, err := s.do(http.MethodPatch, "/Users/"+s.userID, patchOp(
+				map[string]any{"op": "replace", "path": `emails[type eq "work"`, "value": "x"},
+			), nil)
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusBadRequest, "invalidPath")
+		}},
+
+		// ------------ groups ------------
+		{"create group", func(s *suite) error {
+			resp, err := s.do(http.MethodPost, "/Groups", map[string]any{
+				"schemas":     []any{"urn:ietf:params:scim:schemas:core:2.0:Group"},
+				"displayName": groupName,
+				"externalId":  "grp-" + s.suffix,
+				"members":     []any{map[string]any{"value": s.userID}},
+			}, nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusCreated); err != nil {
+				return err
+			}
+			s.groupID, _ = resp.doc["id"].(string)
+			if s.groupID == "" {
+				return fmt.Errorf("no id")
+			}
+			return expectValue(resp.doc, "members.0.value", s.userID)
+		}},
+		{"create group with an unknown member", func(s *suite) error {
+			resp, err := s.do(http.MethodPost, "/Groups", map[string]any{
+				"schemas":     []any{"urn:ietf:params:scim:schemas:core:2.0:Group"},
+				"displayName": "Nobody " + s.suffix,
+				"members":     []any{map[string]any{"value": "does-not-exist"}},
+			}, nil)
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusBadRequest, "invalidValue")
+		}},
+		{"filter groups", func(s *suite) error {
+			if err := expectCount(s, "/Groups", `displayName eq "`+strings.ToLower(groupName)+`"`, 1); err != nil {
+				return err
+			}
+			if err := expectCount(s, "/Groups", `externalId eq "grp-`+s.suffix+`"`, 1); err != nil {
+				return err
+			}
+			return expectCount(s, "/Groups", `members[value eq "`+s.userID+`"] and displayName eq "`+groupName+`"`, 1)
+		}},
+		{"patch group add and remove members", func(s *suite) error {
+			resp, err := s.do(http.MethodPatch, "/Groups/"+s.groupID, patchOp(
+				map[string]any{"op": "add", "path": "members", "value": []any{map[string]any{"value": s.emailUserID}}},
+			), nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusOK); err != nil {
+				return err
+			}
+			if n := len(get(resp.doc, "members").([]any)); n != 2 {
+				return fmt.Errorf("%d members after add, want 2", n)
+			}
+
+			resp, err = s.do(http.MethodPatch, "/Groups/"+s.groupID, patchOp(
+				map[string]any{"op": "remove", "path": `members[value eq "` + s.userID + `"]`},
+			), nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusOK); err != nil {
+				return err
+			}
+			return expectValue(resp.doc, "members.0.value", s.emailUserID)
+		}},
+		{"replace group", func(s *suite) error {
+			resp, err := s.do(http.MethodPut, "/Groups/"+s.groupID, map[string]any{
+				"schemas":     []any{"urn:ietf:params:scim:schemas:core:2.0:Group"},
+				"displayName": groupName + " 2",
+			}, nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusOK); err != nil {
+				return err
+			}
+			if resp.doc["members"] != nil {
+				return fmt.Errorf("members kept by a replace without them")
+			}
+			return expectValue(resp.doc, "displayName", groupName+" 2")
+		}},
+		{"delete group", func(s *suite) error {
+			resp, err := s.do(http.MethodDelete, "/Groups/"+s.groupID, nil, nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expect(http.StatusNoContent); err != nil {
+				return err
+			}
+			resp, err = s.do(http.MethodGet, "/Groups/"+s.groupID, nil, nil)
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusNotFound, "")
+		}},
+
+		// ------------ delete ------------
+		{"delete user with a stale ETag", func(s *suite) error {
+			resp, err := s.do(http.MethodDelete, "/Users/"+s.userID, nil, map[string]string{"If-Match": `W/"stale"`})
+			if err != nil {
+				return err
+			}
+			return resp.expectError(http.StatusPreconditionFailed, "")
+		}},
+		{"delete users", func(s *suite) error {
+			for _, id := range []string{s.userID, s.emailUserID} {
+				resp, err := s.do(http.MethodDelete, "/Users/"+id, nil, nil)
+				if err != nil {
+					return err
+				}
+				if err := resp.expect(http.StatusNoContent); err != nil {
+					return err
+				}
+			}
+			resp, err := s.do(http.MethodGet, "/Users/"+s.userID, nil, nil)
+			if err != nil {
+				return err
+			}
+			if err := resp.expectError(http.StatusNotFound, ""); err != nil {
+				return err
+			}
+			return expectCount(s, "/Users", `userName eq "`+userName+`"`, 0)
+		}},
+	}
+}
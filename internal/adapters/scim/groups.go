@@ -0,0 +1,210 @@
+package scim
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/pkg/scim"
+)
+
+func (h *Handler) getGroup(w http.ResponseWriter, r *http.Request) {
+	g, err := h.uc.GetScimGroup(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	version := domain.ScimVersion(g.Group.UpdatedAt)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagVersion(inm) == version {
+		w.Header().Set("ETag", etag(version))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	attributes, excluded := projection(r)
+	h.writeResource(w, http.StatusOK, scim.Project(groupSchema, h.groupDoc(g), attributes, excluded), version)
+}
+
+func (h *Handler) listGroups(w http.ResponseWriter, r *http.Request) {
+	lq, err := h.parseList(r)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	q := domain.ScimGroupQuery{StartIndex: lq.startIndex, Count: lq.count}
+	if lq.filter != nil {
+		if v, ok := lq.filter.Equality(groupSchema, "id"); ok {
+			q.Lookup, q.Value = domain.LookupID, v
+		} else if v, ok := lq.filter.Equality(groupSchema, "externalId"); ok {
+			q.Lookup, q.Value = domain.LookupExternalID, v
+		}
+		q.Match = func(g *domain.ScimGroup) bool { return lq.filter.Match(groupSchema, h.groupDoc(g)) }
+	}
+	groups, total, err := h.uc.ListScimGroups(r.Context(), q)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	docs := make([]map[string]any, 0, len(groups))
+	for _, g := range groups {
+		docs = append(docs, scim.Project(groupSchema, h.groupDoc(g), lq.attributes, lq.excluded))
+	}
+	h.write(w, http.StatusOK, scim.NewListResponse(total, lq.startIndex, docs))
+}
+
+func (h *Handler) createGroup(w http.ResponseWriter, r *http.Request) {
+	doc, err := h.decode(r)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	in, err := groupFromDoc(doc)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	g, err := h.uc.CreateScimGroup(r.Context(), in)
+	if err != nil {
+		h.fail(w, memberError(err))
+		return
+	}
+	h.writeResource(w, http.StatusCreated, h.groupDoc(g), domain.ScimVersion(g.Group.UpdatedAt))
+}
+
+func (h *Handler) replaceGroup(w http.ResponseWriter, r *http.Request) {
+	doc, err := h.decode(r)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	in, err := groupFromDoc(doc)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	h.saveGroup(w, r, ifMatch(r), in)
+}
+
+// As for users, the patched resource is saved conditional on the version read
+func (h *Handler) patchGroup(w http.ResponseWriter, r *http.Request) {
+	ops, err := h.decodePatch(r)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	g, err := h.uc.GetScimGroup(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	version := domain.ScimVersion(g.Group.UpdatedAt)
+	if want := ifMatch(r); want != "" && want != version {
+		h.fail(w, domain.ErrVersionMismatch)
+		return
+	}
+
+	doc := h.groupDoc(g)
+	if err := scim.Patch(groupSchema, doc, ops); err != nil {
+		h.fail(w, err)
+		return
+	}
+	in, err := groupFromDoc(doc)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	h.saveGroup(w, r, version, in)
+}
+
+func (h *Handler) saveGroup(w http.ResponseWriter, r *http.Request, version string, in *domain.ScimGroup) {
+	g, err := h.uc.ReplaceScimGroup(r.Context(), r.PathValue("id"), version, in)
+	if err != nil {
+		h.fail(w, memberError(err))
+		return
+	}
+	attributes, excluded := projection(r)
+	h.writeResource(w, http.StatusOK, scim.Project(groupSchema, h.groupDoc(g), attributes, excluded), domain.ScimVersion(g.Group.UpdatedAt))
+}
+
+func (h *Handler) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	if err := h.uc.DeleteScimGroup(r.Context(), r.PathValue("id"), ifMatch(r)); err != nil {
+		h.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Unknown members are a bad value of the group, not a missing resource
+func memberError(err error) error {
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return scim.BadRequest(scim.ErrInvalidValue, "unknown members: %s", err.Error())
+	}
+	return err
+}
+
+// ------------ mapping ------------
+
+func (h *Handler) groupDoc(g *domain.ScimGroup) map[string]any {
+	grp := g.Group
+	doc := map[string]any{
+		"schemas":     []any{scim.GroupSchema},
+		"id":          grp.ID,
+		"displayName": grp.Name,
+		"meta":        h.meta("Group", "/Groups/"+grp.ID, grp.CreatedAt, grp.UpdatedAt),
+	}
+	if grp.SourcedID != "" {
+		doc["externalId"] = grp.SourcedID
+	}
+	if len(g.Members) > 0 {
+		members := make([]any, 0, len(g.Members))
+		for _, id := range g.Members {
+			members = append(members, map[string]any{"value": id, "$ref": h.baseURL + "/Users/" + id, "type": "User"})
+		}
+		doc["members"] = members
+	}
+	return doc
+}
+
+func groupFromDoc(doc map[string]any) (*domain.ScimGroup, error) {
+	name, err := stringAttr(doc, "displayName")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, scim.BadRequest(scim.ErrInvalidValue, "displayName is required")
+	}
+	externalID, err := stringAttr(doc, "externalId")
+	if err != nil {
+		return nil, err
+	}
+	in := &domain.ScimGroup{Group: &domain.Group{Name: name, SourcedID: externalID}}
+
+	v, _ := scim.Get(doc, "members")
+	if v == nil {
+		return in, nil
+	}
+	list, ok := v.([]any)
+	if !ok {
+		return nil, scim.BadRequest(scim.ErrInvalidValue, "members must be a list")
+	}
+	for _, it := range list {
+		m, ok := it.(map[string]any)
+		if !ok {
+			return nil, scim.BadRequest(scim.ErrInvalidValue, "members must be objects with a value")
+		}
+		if typ, _ := scim.Get(m, "type"); typ != nil {
+			if s, _ := typ.(string); !strings.EqualFold(s, "User") {
+				return nil, scim.BadRequest(scim.ErrInvalidValue, "only users can be members")
+			}
+		}
+		id, _ := scim.Get(m, "value")
+		s, ok := id.(string)
+		if !ok || s == "" {
+			return nil, scim.BadRequest(scim.ErrInvalidValue, "members must be objects with a value")
+		}
+		in.Members = append(in.Members, s)
+	}
+	return in, nil
+}
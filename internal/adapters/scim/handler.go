@@ -0,0 +1,265 @@
+// Package scim serves SCIM 2.0 /Users and /Groups (RFC 7644) over HTTP for
+// identity providers and SIS exports. Resources map to domain users and
+// groups, the protocol parts are in pkg/scim.
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/usecase"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/Neroframe/AuthService/pkg/realip"
+	"github.com/Neroframe/AuthService/pkg/scim"
+	"github.com/google/uuid"
+)
+
+const maxBodySize = 1 << 20
+
+type Handler struct {
+	uc         usecase.ScimUsecase
+	baseURL    string // e.g. https://auth.example.com/scim/v2, for meta.location
+	maxResults int    // page size cap of list requests
+	proxies    *realip.Resolver
+	log        *logger.Logger
+}
+
+func NewHandler(uc usecase.ScimUsecase, baseURL string, maxResults int, proxies *realip.Resolver, log *logger.Logger) *Handler {
+	return &Handler{uc: uc, baseURL: strings.TrimSuffix(baseURL, "/"), maxResults: maxResults, proxies: proxies, log: log}
+}
+
+// Endpoints under /scim/v2, every one needs a client token
+func (h *Handler) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /Users", h.listUsers)
+	mux.HandleFunc("POST /Users", h.createUser)
+	mux.HandleFunc("GET /Users/{id}", h.getUser)
+	mux.HandleFunc("PUT /Users/{id}", h.replaceUser)
+	mux.HandleFunc("PATCH /Users/{id}", h.patchUser)
+	mux.HandleFunc("DELETE /Users/{id}", h.deleteUser)
+
+	mux.HandleFunc("GET /Groups", h.listGroups)
+	mux.HandleFunc("POST /Groups", h.createGroup)
+	mux.HandleFunc("GET /Groups/{id}", h.getGroup)
+	mux.HandleFunc("PUT /Groups/{id}", h.replaceGroup)
+	mux.HandleFunc("PATCH /Groups/{id}", h.patchGroup)
+	mux.HandleFunc("DELETE /Groups/{id}", h.deleteGroup)
+
+	mux.HandleFunc("GET /ServiceProviderConfig", func(w http.ResponseWriter, r *http.Request) {
+		h.write(w, http.StatusOK, h.serviceProviderConfig())
+	})
+	mux.HandleFunc("GET /ResourceTypes", func(w http.ResponseWriter, r *http.Request) {
+		types := h.resourceTypes()
+		h.write(w, http.StatusOK, scim.NewListResponse(len(types), 1, types))
+	})
+	mux.HandleFunc("GET /Schemas", func(w http.ResponseWriter, r *http.Request) {
+		docs := []map[string]any{h.schemaDoc(userSchema), h.schemaDoc(groupSchema)}
+		h.write(w, http.StatusOK, scim.NewListResponse(len(docs), 1, docs))
+	})
+	mux.HandleFunc("GET /Schemas/{id}", func(w http.ResponseWriter, r *http.Request) {
+		for _, s := range []*scim.Schema{userSchema, groupSchema} {
+			if s.ID == r.PathValue("id") {
+				h.write(w, http.StatusOK, h.schemaDoc(s))
+				return
+			}
+		}
+		h.fail(w, &scim.Error{Status: http.StatusNotFound, Detail: "schema not found"})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		h.fail(w, &scim.Error{Status: http.StatusNotFound, Detail: "no such endpoint"})
+	})
+
+	return http.StripPrefix("/scim/v2", h.authenticate(mux))
+}
+
+// Client token to tenant scope and caller, plus the request meta audit entries read
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqID := r.Header.Get("X-Request-Id")
+		if reqID == "" {
+			reqID = uuid.New().String()
+		}
+		w.Header().Set("X-Request-Id", reqID)
+		ctx := context.WithValue(r.Context(), middleware.RequestMetaCtxKey, &domain.RequestMeta{
+			RequestID: reqID,
+			IP:        h.proxies.ClientIP(r.RemoteAddr, r.Header.Values("X-Forwarded-For")),
+			UserAgent: r.UserAgent(),
+		})
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			h.fail(w, &scim.Error{Status: http.StatusUnauthorized, Detail: "bearer token required"})
+			return
+		}
+		ctx, err := h.uc.AuthenticateScim(ctx, strings.TrimSpace(token))
+		if err != nil {
+			if errors.Is(err, domain.ErrScimUnauthorized) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="scim", error="invalid_token"`)
+			}
+			h.fail(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ------------ requests ------------
+
+func (h *Handler) decode(r *http.Request) (map[string]any, error) {
+	var doc map[string]any
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize)).Decode(&doc); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, &scim.Error{Status: http.StatusRequestEntityTooLarge, Detail: "request body too large"}
+		}
+		return nil, scim.BadRequest(scim.ErrInvalidSyntax, "body is not a JSON object")
+	}
+	if doc == nil {
+		return nil, scim.BadRequest(scim.ErrInvalidSyntax, "body is not a JSON object")
+	}
+	return doc, nil
+}
+
+func (h *Handler) decodePatch(r *http.Request) ([]scim.PatchOp, error) {
+	var req scim.PatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize)).Decode(&req); err != nil {
+		return nil, scim.BadRequest(scim.ErrInvalidSyntax, "body is not a PatchOp request")
+	}
+	for _, s := range req.Schemas {
+		if s == scim.PatchOpSchema {
+			return req.Operations, nil
+		}
+	}
+	return nil, scim.BadRequest(scim.ErrInvalidSyntax, "schemas must list %s", scim.PatchOpSchema)
+}
+
+// Query of a list request: filter, startIndex, count, attributes and excludedAttributes
+type listQuery struct {
+	filter     *scim.Filter
+	startIndex int
+	count      int
+	attributes []string
+	excluded   []string
+}
+
+func (h *Handler) parseList(r *http.Request) (*listQuery, error) {
+	q := r.URL.Query()
+	lq := &listQuery{startIndex: 1, count: h.maxResults}
+	if raw := q.Get("filter"); raw != "" {
+		f, err := scim.ParseFilter(raw)
+		if err != nil {
+			return nil, err
+		}
+		lq.filter = f
+	}
+	// Out of range values are clamped (RFC 7644 3.4.2.4)
+	if raw := q.Get("startIndex"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, scim.BadRequest(scim.ErrInvalidValue, "startIndex must be an integer")
+		}
+		lq.startIndex = max(n, 1)
+	}
+	if raw := q.Get("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, scim.BadRequest(scim.ErrInvalidValue, "count must be an integer")
+		}
+		lq.count = min(max(n, 0), h.maxResults)
+	}
+	lq.attributes, lq.excluded = splitList(q.Get("attributes")), splitList(q.Get("excludedAttributes"))
+	return lq, nil
+}
+
+// Attribute projection of single resources
+func projection(r *http.Request) (attributes, excluded []string) {
+	q := r.URL.Query()
+	return splitList(q.Get("attributes")), splitList(q.Get("excludedAttributes"))
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Version of If-Match, empty for none or "*"
+func ifMatch(r *http.Request) string {
+	return etagVersion(r.Header.Get("If-Match"))
+}
+
+func etagVersion(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "*" {
+		return ""
+	}
+	return strings.Trim(strings.TrimPrefix(raw, "W/"), `"`)
+}
+
+func etag(version string) string {
+	return `W/"` + version + `"`
+}
+
+// ------------ responses ------------
+
+func (h *Handler) write(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", scim.ContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.log.Error("scim write response failed", "err", err)
+	}
+}
+
+// A resource with its ETag and Location
+func (h *Handler) writeResource(w http.ResponseWriter, status int, doc map[string]any, version string) {
+	w.Header().Set("ETag", etag(version))
+	if meta, ok := doc["meta"].(map[string]any); ok && status == http.StatusCreated {
+		w.Header().Set("Location", fmt.Sprint(meta["location"]))
+	}
+	h.write(w, status, doc)
+}
+
+func (h *Handler) fail(w http.ResponseWriter, err error) {
+	e := scimError(err)
+	if e.Status == http.StatusInternalServerError {
+		h.log.Error("scim request failed", "err", err)
+	}
+	h.write(w, e.Status, e)
+}
+
+func scimError(err error) *scim.Error {
+	var e *scim.Error
+	switch {
+	case errors.As(err, &e):
+		return e
+	case errors.Is(err, domain.ErrScimUnauthorized):
+		return &scim.Error{Status: http.StatusUnauthorized, Detail: err.Error()}
+	case errors.Is(err, domain.ErrTenantDisabled):
+		return &scim.Error{Status: http.StatusForbidden, Detail: err.Error()}
+	case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrGroupNotFound):
+		return &scim.Error{Status: http.StatusNotFound, Detail: err.Error()}
+	case errors.Is(err, domain.ErrVersionMismatch):
+		return &scim.Error{Status: http.StatusPreconditionFailed, Detail: err.Error()}
+	case errors.Is(err, domain.ErrEmailAlreadyExists), errors.Is(err, domain.ErrUsernameAlreadyExists),
+		errors.Is(err, domain.ErrExternalIDExists):
+		return &scim.Error{Status: http.StatusConflict, ScimType: scim.ErrUniqueness, Detail: err.Error()}
+	case errors.Is(err, domain.ErrInvalidEmail), errors.Is(err, domain.ErrInvalidUsername),
+		errors.Is(err, domain.ErrInvalidPhone), errors.Is(err, domain.ErrNoLoginIdentifier),
+		errors.Is(err, domain.ErrRoleNotFound), errors.Is(err, domain.ErrWeakPassword),
+		errors.Is(err, domain.ErrInvalidGroup), errors.Is(err, domain.ErrTooManyMembers):
+		return scim.BadRequest(scim.ErrInvalidValue, "%s", err.Error())
+	}
+	return &scim.Error{Status: http.StatusInternalServerError, Detail: "internal error"}
+}
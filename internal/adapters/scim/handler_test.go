@@ -0,0 +1,116 @@
+package scim_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Neroframe/AuthService/config"
+	"github.com/Neroframe/AuthService/internal/adapters/authz"
+	"github.com/Neroframe/AuthService/internal/adapters/bcrypt"
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	natsadapter "github.com/Neroframe/AuthService/internal/adapters/nats"
+	scimadapter "github.com/Neroframe/AuthService/internal/adapters/scim"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/usecase"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/Neroframe/AuthService/pkg/policy"
+)
+
+// RFC 7644 compliance suite. It runs against the handler served in-process
+// over in-memory stores, or against a live server when SCIM_CHECK_URL and
+// SCIM_CHECK_TOKEN are set.
+func TestCompliance(t *testing.T) {
+	s := &suite{client: &http.Client{Timeout: 30 * time.Second}, suffix: randomSuffix(t)}
+	if base := os.Getenv("SCIM_CHECK_URL"); base != "" {
+		s.base, s.token = strings.TrimSuffix(base, "/"), os.Getenv("SCIM_CHECK_TOKEN")
+		if s.token == "" {
+			t.Fatal("SCIM_CHECK_TOKEN is required with SCIM_CHECK_URL")
+		}
+	} else {
+		serve(t, s)
+	}
+
+	// Cases build on each other, so the first failure stops the run
+	for _, c := range s.cases() {
+		if !t.Run(c.name, func(t *testing.T) {
+			if err := c.run(s); err != nil {
+				t.Fatal(err)
+			}
+		}) {
+			return
+		}
+	}
+}
+
+// Wires the usecase and handler as the app does, over in-memory stores, and
+// issues the tokens of the suite: a working one, a revoked one and one of a
+// tenant that is then disabled.
+func serve(t *testing.T, s *suite) {
+	t.Helper()
+	log := logger.New(logger.Config{Level: "error"})
+	engine, err := policy.Load(config.DefaultPolicy)
+	if err != nil {
+		t.Fatalf("policy: %v", err)
+	}
+
+	st := newStore()
+	tenants := tenantDirectory{
+		domain.DefaultTenantID: {ID: domain.DefaultTenantID, Status: domain.TenantActive, Config: domain.TenantConfig{Password: domain.PasswordPolicy{MinLength: 8}}},
+		"closed":               {ID: "closed", Status: domain.TenantActive},
+	}
+	uc := usecase.NewScimUsecase(
+		clientStore{st}, userStore{st}, groupStore{st}, relationStore{st}, st,
+		bcrypt.NewHasher(),
+		natsadapter.NewAuthPublisher(outboxStore{st}, natsadapter.Subjects{}, "scim-test", natsadapter.EncodingJSON),
+		sessionRevoker{}, domain.EmailNormalizer{}, tenants,
+		usecase.ScimConfig{DefaultRole: domain.STUDENT, ErasureGrace: 30 * 24 * time.Hour},
+		auditStore{st}, log,
+		authz.NewPolicyAuthorizer(engine, log, authz.LogOff),
+		roleCatalog{},
+	)
+
+	s.token = issue(t, uc, domain.DefaultTenantID, false)
+	s.revokedToken = issue(t, uc, domain.DefaultTenantID, true)
+	s.disabledToken = issue(t, uc, "closed", false)
+	tenants["closed"].Status = domain.TenantDisabled
+
+	srv := httptest.NewServer(nil)
+	t.Cleanup(srv.Close)
+	mux := http.NewServeMux()
+	mux.Handle("/scim/v2/", scimadapter.NewHandler(uc, srv.URL+"/scim/v2", 100, nil, log).Routes())
+	srv.Config.Handler = mux
+	s.base = srv.URL + "/scim/v2"
+}
+
+// Client token created by an admin of the tenant
+func issue(t *testing.T, uc usecase.ScimUsecase, tenant string, revoke bool) string {
+	t.Helper()
+	ctx := domain.WithTenant(context.Background(), tenant)
+	ctx = context.WithValue(ctx, middleware.UserCtxKey, &domain.TokenPayload{UserID: "scim-test", TenantID: tenant, Role: domain.ADMIN})
+
+	client, token, err := uc.CreateScimClient(ctx, "scim-test")
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	if revoke {
+		if err := uc.RevokeScimClient(ctx, client.ID); err != nil {
+			t.Fatalf("revoke client: %v", err)
+		}
+	}
+	return token
+}
+
+func randomSuffix(t *testing.T) string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,575 @@
+package scim_test
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+)
+
+// In-memory stores behind the usecase, so the suite runs without Mongo.
+// They keep the unique indexes and tenant scoping of the Mongo repositories
+// and hand out copies, like documents decoded from a query.
+type store struct {
+	mu      sync.Mutex
+	txMu    sync.Mutex
+	users   map[string]domain.User
+	groups  map[string]domain.Group
+	tuples  map[string]domain.RelationTuple // by String()
+	clients map[string]domain.ScimClient
+	events  int // outbox records written
+	audits  int
+}
+
+func newStore() *store {
+	return &store{
+		users:   map[string]domain.User{},
+		groups:  map[string]domain.Group{},
+		tuples:  map[string]domain.RelationTuple{},
+		clients: map[string]domain.ScimClient{},
+	}
+}
+
+// Tenant of ctx, empty in the all tenants scope
+func scope(ctx context.Context) (string, error) {
+	tenant, _, err := domain.TenantScope(ctx)
+	return tenant, err
+}
+
+func inScope(tenant, docTenant string) bool {
+	return tenant == "" || tenant == docTenant
+}
+
+// ------------ tx ------------
+
+// Restores the stores when fn fails, transactions run one at a time
+func (s *store) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.Lock()
+	users, groups, tuples := maps.Clone(s.users), maps.Clone(s.groups), maps.Clone(s.tuples)
+	s.mu.Unlock()
+
+	err := fn(ctx)
+	if err != nil {
+		s.mu.Lock()
+		s.users, s.groups, s.tuples = users, groups, tuples
+		s.mu.Unlock()
+	}
+	return err
+}
+
+var _ repository.TxManager = (*store)(nil)
+
+// ------------ users ------------
+
+type userStore struct{ *store }
+
+var _ repository.UserRepository = userStore{}
+
+func (s userStore) Create(ctx context.Context, u *domain.User) error {
+	tenant, err := scope(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u.TenantID == "" {
+		u.TenantID = tenant
+	}
+	if err := s.unique(u); err != nil {
+		return err
+	}
+	s.users[u.ID] = *u
+	return nil
+}
+
+// Unique indexes of the users collection, empty values are not indexed
+func (s userStore) unique(u *domain.User) error {
+	for id, o := range s.users {
+		if id == u.ID || o.TenantID != u.TenantID {
+			continue
+		}
+		switch {
+		case u.EmailKey != "" && o.EmailKey == u.EmailKey:
+			return repository.ErrEmailAlreadyUsed
+		case u.Username != "" && strings.EqualFold(o.Username, u.Username):
+			return repository.ErrUsernameTaken
+		case u.ExternalID != "" && o.ExternalID == u.ExternalID:
+			return repository.ErrAlreadyExists
+		}
+	}
+	return nil
+}
+
+func (s userStore) get(ctx context.Context, match func(*domain.User) bool) ([]*domain.User, error) {
+	tenant, err := scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []*domain.User
+	for _, u := range s.users {
+		if inScope(tenant, u.TenantID) && match(&u) {
+			c := u
+			out = append(out, &c)
+		}
+	}
+	// Newest first, as List sorts
+	slices.SortFunc(out, func(a, b *domain.User) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	return out, nil
+}
+
+func (s userStore) one(ctx context.Context, match func(*domain.User) bool) (*domain.User, error) {
+	users, err := s.get(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return users[0], nil
+}
+
+func (s userStore) GetByEmail(ctx context.Context, emailKey string) (*domain.User, error) {
+	return s.one(ctx, func(u *domain.User) bool { return u.EmailKey == emailKey })
+}
+
+func (s userStore) GetByUsername(ctx context.Context, username string) (*domain.User, error) {
+	return s.one(ctx, func(u *domain.User) bool { return strings.EqualFold(u.Username, username) })
+}
+
+func (s userStore) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	return s.one(ctx, func(u *domain.User) bool { return u.ID == id })
+}
+
+// The whole document is written, fields only matter to Mongo
+func (s userStore) Update(ctx context.Context, u *domain.User, fields ...string) (*domain.User, error) {
+	tenant, err := scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.users[u.ID]
+	if !ok || !inScope(tenant, cur.TenantID) {
+		return nil, repository.ErrNotFound
+	}
+	next := *u
+	next.TenantID = cur.TenantID
+	if err := s.unique(&next); err != nil {
+		return nil, err
+	}
+	s.users[u.ID] = next
+	return &next, nil
+}
+
+func (s userStore) Delete(ctx context.Context, id string) error {
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users, id)
+	return nil
+}
+
+// Only the cursor and limit of the filter, the cursor is the last ID
+func (s userStore) List(ctx context.Context, f domain.UserFilter) ([]*domain.User, string, error) {
+	users, err := s.get(ctx, func(*domain.User) bool { return true })
+	if err != nil {
+		return nil, "", err
+	}
+	if f.Cursor != "" {
+		i := slices.IndexFunc(users, func(u *domain.User) bool { return u.ID == f.Cursor })
+		if i < 0 {
+			return nil, "", domain.ErrInvalidCursor
+		}
+		users = users[i+1:]
+	}
+	if f.Limit <= 0 || len(users) <= f.Limit {
+		return users, "", nil
+	}
+	return users[:f.Limit], users[f.Limit-1].ID, nil
+}
+
+func (s userStore) ListDueForErasure(ctx context.Context, now time.Time, limit int) ([]*domain.User, error) {
+	users, err := s.get(ctx, func(u *domain.User) bool {
+		return u.Status == domain.StatusDeleted && u.PurgeAt != nil && !u.PurgeAt.After(now)
+	})
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, err
+}
+
+func (s userStore) Erase(ctx context.Context, u *domain.User) error {
+	_, err := s.Update(ctx, u)
+	return err
+}
+
+func (s userStore) ListMissingEmailKey(ctx context.Context, afterID string, limit int) ([]*domain.User, error) {
+	return nil, nil
+}
+
+func (s userStore) ListByEmailKeys(ctx context.Context, keys []string) ([]*domain.User, error) {
+	return s.get(ctx, func(u *domain.User) bool { return u.EmailKey != "" && slices.Contains(keys, u.EmailKey) })
+}
+
+func (s userStore) ListByIDs(ctx context.Context, ids []string) ([]*domain.User, error) {
+	return s.get(ctx, func(u *domain.User) bool { return slices.Contains(ids, u.ID) })
+}
+
+func (s userStore) ListByExternalIDs(ctx context.Context, ids []string) ([]*domain.User, error) {
+	return s.get(ctx, func(u *domain.User) bool { return u.ExternalID != "" && slices.Contains(ids, u.ExternalID) })
+}
+
+func (s userStore) ListByUsernames(ctx context.Context, usernames []string) ([]*domain.User, error) {
+	return s.get(ctx, func(u *domain.User) bool {
+		return u.Username != "" && slices.ContainsFunc(usernames, func(n string) bool { return strings.EqualFold(n, u.Username) })
+	})
+}
+
+// ------------ groups ------------
+
+type groupStore struct{ *store }
+
+var _ repository.GroupRepository = groupStore{}
+
+func (s groupStore) Create(ctx context.Context, g *domain.Group) error {
+	tenant, err := scope(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if g.TenantID == "" {
+		g.TenantID = tenant
+	}
+	if err := s.unique(g); err != nil {
+		return err
+	}
+	s.groups[g.ID] = *g
+	return nil
+}
+
+func (s groupStore) unique(g *domain.Group) error {
+	for id, o := range s.groups {
+		if id != g.ID && o.TenantID == g.TenantID && g.SourcedID != "" && o.SourcedID == g.SourcedID {
+			return repository.ErrAlreadyExists
+		}
+	}
+	return nil
+}
+
+// By ID
+func (s groupStore) get(ctx context.Context, match func(*domain.Group) bool) ([]*domain.Group, error) {
+	tenant, err := scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []*domain.Group
+	for _, g := range s.groups {
+		if inScope(tenant, g.TenantID) && match(&g) {
+			c := g
+			out = append(out, &c)
+		}
+	}
+	slices.SortFunc(out, func(a, b *domain.Group) int { return strings.Compare(a.ID, b.ID) })
+	return out, nil
+}
+
+func (s groupStore) GetByID(ctx context.Context, id string) (*domain.Group, error) {
+	groups, err := s.get(ctx, func(g *domain.Group) bool { return g.ID == id })
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return groups[0], nil
+}
+
+func (s groupStore) ListByIDs(ctx context.Context, ids []string) ([]*domain.Group, error) {
+	return s.get(ctx, func(g *domain.Group) bool { return slices.Contains(ids, g.ID) })
+}
+
+func (s groupStore) ListBySourcedIDs(ctx context.Context, ids []string) ([]*domain.Group, error) {
+	return s.get(ctx, func(g *domain.Group) bool { return g.SourcedID != "" && slices.Contains(ids, g.SourcedID) })
+}
+
+func (s groupStore) List(ctx context.Context, afterID string, limit int) ([]*domain.Group, error) {
+	groups, err := s.get(ctx, func(g *domain.Group) bool { return g.ID > afterID })
+	if len(groups) > limit {
+		groups = groups[:limit]
+	}
+	return groups, err
+}
+
+func (s groupStore) Update(ctx context.Context, g *domain.Group) error {
+	cur, err := s.GetByID(ctx, g.ID)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur.Name, cur.SourcedID, cur.UpdatedAt = g.Name, g.SourcedID, g.UpdatedAt
+	if err := s.unique(cur); err != nil {
+		return err
+	}
+	s.groups[g.ID] = *cur
+	return nil
+}
+
+func (s groupStore) Delete(ctx context.Context, id string) error {
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.groups, id)
+	return nil
+}
+
+// ------------ relations ------------
+
+// One tenant per run, so tuples aren't scoped
+type relationStore struct{ *store }
+
+var _ repository.RelationRepository = relationStore{}
+
+func (s relationStore) Write(ctx context.Context, tuples []domain.RelationTuple) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range tuples {
+		s.tuples[t.String()] = t
+	}
+	return nil
+}
+
+func (s relationStore) Delete(ctx context.Context, tuples []domain.RelationTuple) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range tuples {
+		delete(s.tuples, t.String())
+	}
+	return nil
+}
+
+func (s relationStore) find(match func(domain.RelationTuple) bool) []domain.RelationTuple {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []domain.RelationTuple
+	for _, t := range s.tuples {
+		if match(t) {
+			out = append(out, t)
+		}
+	}
+	slices.SortFunc(out, func(a, b domain.RelationTuple) int { return strings.Compare(a.String(), b.String()) })
+	return out
+}
+
+func (s relationStore) ListBySubjects(ctx context.Context, subjects []string) ([]domain.RelationTuple, error) {
+	return s.find(func(t domain.RelationTuple) bool { return slices.Contains(subjects, t.Subject.String()) }), nil
+}
+
+func (s relationStore) ExistsAny(ctx context.Context, objectType string, objectIDs []string, relation string, subjects []string) (bool, error) {
+	return len(s.find(func(t domain.RelationTuple) bool {
+		return t.ObjectType == objectType && slices.Contains(objectIDs, t.ObjectID) && t.Relation == relation && slices.Contains(subjects, t.Subject.String())
+	})) > 0, nil
+}
+
+func (s relationStore) ListObjects(ctx context.Context, objectType, relation string, subjects []string) ([]string, error) {
+	var ids []string
+	for _, t := range s.find(func(t domain.RelationTuple) bool {
+		return t.ObjectType == objectType && t.Relation == relation && slices.Contains(subjects, t.Subject.String())
+	}) {
+		ids = append(ids, t.ObjectID)
+	}
+	return ids, nil
+}
+
+func (s relationStore) ListByObject(ctx context.Context, objectType, objectID string) ([]domain.RelationTuple, error) {
+	return s.find(func(t domain.RelationTuple) bool { return t.ObjectType == objectType && t.ObjectID == objectID }), nil
+}
+
+// ------------ scim clients ------------
+
+type clientStore struct{ *store }
+
+var _ repository.ScimClientRepository = clientStore{}
+
+func (s clientStore) Create(ctx context.Context, c *domain.ScimClient) error {
+	tenant, err := scope(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.TenantID == "" {
+		c.TenantID = tenant
+	}
+	s.clients[c.ID] = *c
+	return nil
+}
+
+func (s clientStore) GetByTokenHash(ctx context.Context, hash string) (*domain.ScimClient, error) {
+	tenant, err := scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.clients {
+		if c.TokenHash == hash && inScope(tenant, c.TenantID) {
+			return &c, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (s clientStore) List(ctx context.Context) ([]*domain.ScimClient, error) {
+	tenant, err := scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*domain.ScimClient
+	for _, c := range s.clients {
+		if inScope(tenant, c.TenantID) {
+			out = append(out, &c)
+		}
+	}
+	slices.SortFunc(out, func(a, b *domain.ScimClient) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return out, nil
+}
+
+func (s clientStore) Revoke(ctx context.Context, id string, at time.Time) error {
+	tenant, err := scope(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.clients[id]
+	if !ok || !inScope(tenant, c.TenantID) || c.RevokedAt != nil {
+		return repository.ErrNotFound
+	}
+	c.RevokedAt = &at
+	s.clients[id] = c
+	return nil
+}
+
+// ------------ events and audit ------------
+
+// Outbox of the real publisher, records are only counted
+type outboxStore struct{ *store }
+
+var _ repository.OutboxRepository = outboxStore{}
+
+func (s outboxStore) Add(ctx context.Context, rec *domain.OutboxRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events++
+	return nil
+}
+
+func (s outboxStore) FetchPending(ctx context.Context, now time.Time, limit int) ([]*domain.OutboxRecord, error) {
+	return nil, nil
+}
+
+func (s outboxStore) MarkSent(ctx context.Context, id string) error { return nil }
+
+func (s outboxStore) MarkFailed(ctx context.Context, id string, cause error, next time.Time) error {
+	return nil
+}
+
+func (s outboxStore) MarkDead(ctx context.Context, id string, cause error) error { return nil }
+
+func (s outboxStore) AcquireRelayLock(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	return false, nil
+}
+
+func (s outboxStore) ScrubUser(ctx context.Context, userID string) error { return nil }
+
+type auditStore struct{ *store }
+
+var _ repository.AuditRepository = auditStore{}
+
+func (s auditStore) Append(ctx context.Context, e *domain.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audits++
+	return nil
+}
+
+func (s auditStore) List(ctx context.Context, f domain.AuditFilter) ([]*domain.AuditEvent, string, error) {
+	return nil, "", nil
+}
+
+func (s auditStore) ScrubDetails(ctx context.Context, userID string) error { return nil }
+
+// ------------ tenants, roles and sessions ------------
+
+// The tenant the suite provisions, and a disabled one
+type tenantDirectory map[string]*domain.Tenant
+
+func (d tenantDirectory) Tenant(ctx context.Context, id string) (*domain.Tenant, error) {
+	t, ok := d[id]
+	if !ok {
+		return nil, domain.ErrTenantNotFound
+	}
+	return t, nil
+}
+
+// Built-in roles, admins can manage SCIM clients
+type roleCatalog struct{}
+
+func (roleCatalog) RoleExists(ctx context.Context, role domain.Role) (bool, error) {
+	return role == domain.ADMIN || role == domain.TEACHER || role == domain.STUDENT, nil
+}
+
+func (roleCatalog) Permissions(ctx context.Context, role domain.Role) ([]string, error) {
+	if role == domain.ADMIN {
+		return []string{"scim.manage"}, nil
+	}
+	return nil, nil
+}
+
+type sessionRevoker struct{}
+
+func (sessionRevoker) RevokeAll(ctx context.Context, userID string) error { return nil }
+
+func (sessionRevoker) Revoke(ctx context.Context, userID string, issuedAt, expiresAt time.Time) error {
+	return nil
+}
+
+func (sessionRevoker) IsRevoked(ctx context.Context, userID string, issuedAt time.Time) (bool, error) {
+	return false, nil
+}
+
+func (sessionRevoker) RevokedBefore(ctx context.Context, userID string) (time.Time, error) {
+	return time.Time{}, nil
+}
@@ -0,0 +1,100 @@
+package scim
+
+import (
+	"github.com/Neroframe/AuthService/pkg/scim"
+)
+
+// Attributes of the core schemas this server stores, others are accepted
+// and dropped. name is not stored, so it isn't listed.
+var userSchema = &scim.Schema{
+	ID:          scim.UserSchema,
+	Name:        "User",
+	Description: "User Account",
+	Attributes: []scim.Attribute{
+		{Name: "userName", Type: scim.TypeString, Required: true, Mutability: scim.ReadWrite, Returned: scim.ReturnedDefault, Uniqueness: "server",
+			Description: "Username, or the email when it has an @"},
+		{Name: "active", Type: scim.TypeBoolean, Mutability: scim.ReadWrite, Returned: scim.ReturnedDefault, Uniqueness: "none"},
+		{Name: "password", Type: scim.TypeString, Mutability: scim.WriteOnly, Returned: scim.ReturnedNever, Uniqueness: "none"},
+		{Name: "emails", Type: scim.TypeComplex, MultiValued: true, Mutability: scim.ReadWrite, Returned: scim.ReturnedDefault, Uniqueness: "none",
+			Description: "The primary one is the account email when userName isn't one", SubAttributes: valueAttributes(scim.TypeString)},
+		{Name: "phoneNumbers", Type: scim.TypeComplex, MultiValued: true, Mutability: scim.ReadWrite, Returned: scim.ReturnedDefault, Uniqueness: "none",
+			SubAttributes: valueAttributes(scim.TypeString)},
+		{Name: "roles", Type: scim.TypeComplex, MultiValued: true, Mutability: scim.ReadWrite, Returned: scim.ReturnedDefault, Uniqueness: "none",
+			Description: "One role, the primary one is kept", SubAttributes: valueAttributes(scim.TypeString)},
+	},
+}
+
+var groupSchema = &scim.Schema{
+	ID:          scim.GroupSchema,
+	Name:        "Group",
+	Description: "Group",
+	Attributes: []scim.Attribute{
+		{Name: "displayName", Type: scim.TypeString, Required: true, Mutability: scim.ReadWrite, Returned: scim.ReturnedDefault, Uniqueness: "none"},
+		{Name: "members", Type: scim.TypeComplex, MultiValued: true, Mutability: scim.ReadWrite, Returned: scim.ReturnedDefault, Uniqueness: "none",
+			Description: "Users only, groups can't be nested", SubAttributes: []scim.Attribute{
+				{Name: "value", Type: scim.TypeString, CaseExact: true, Mutability: scim.Immutable, Returned: scim.ReturnedDefault, Uniqueness: "none"},
+				{Name: "$ref", Type: scim.TypeRef, CaseExact: true, Mutability: scim.Immutable, Returned: scim.ReturnedDefault, Uniqueness: "none"},
+				{Name: "type", Type: scim.TypeString, Mutability: scim.Immutable, Returned: scim.ReturnedDefault, Uniqueness: "none"},
+				{Name: "display", Type: scim.TypeString, Mutability: scim.ReadOnly, Returned: scim.ReturnedDefault, Uniqueness: "none"},
+			}},
+	},
+}
+
+// Sub-attributes of emails, phoneNumbers and roles
+func valueAttributes(valueType string) []scim.Attribute {
+	return []scim.Attribute{
+		{Name: "value", Type: valueType, Mutability: scim.ReadWrite, Returned: scim.ReturnedDefault, Uniqueness: "none"},
+		{Name: "type", Type: scim.TypeString, Mutability: scim.ReadWrite, Returned: scim.ReturnedDefault, Uniqueness: "none"},
+		{Name: "primary", Type: scim.TypeBoolean, Mutability: scim.ReadWrite, Returned: scim.ReturnedDefault, Uniqueness: "none"},
+		{Name: "display", Type: scim.TypeString, Mutability: scim.ReadWrite, Returned: scim.ReturnedDefault, Uniqueness: "none"},
+	}
+}
+
+func (h *Handler) serviceProviderConfig() map[string]any {
+	return map[string]any{
+		"schemas":          []string{scim.ServiceConfigSchema},
+		"documentationUri": "",
+		"patch":            map[string]any{"supported": true},
+		"bulk":             map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           map[string]any{"supported": true, "maxResults": h.maxResults},
+		"changePassword":   map[string]any{"supported": true},
+		"sort":             map[string]any{"supported": false},
+		"etag":             map[string]any{"supported": true},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "Token of a SCIM client, created with the CreateScimClient RPC",
+			"primary":     true,
+		}},
+		"meta": map[string]any{"resourceType": "ServiceProviderConfig", "location": h.baseURL + "/ServiceProviderConfig"},
+	}
+}
+
+func (h *Handler) resourceTypes() []map[string]any {
+	return []map[string]any{
+		h.resourceType("User", "/Users", scim.UserSchema),
+		h.resourceType("Group", "/Groups", scim.GroupSchema),
+	}
+}
+
+func (h *Handler) resourceType(name, endpoint, schema string) map[string]any {
+	return map[string]any{
+		"schemas":  []string{scim.ResourceTypeSchema},
+		"id":       name,
+		"name":     name,
+		"endpoint": endpoint,
+		"schema":   schema,
+		"meta":     map[string]any{"resourceType": "ResourceType", "location": h.baseURL + "/ResourceTypes/" + name},
+	}
+}
+
+func (h *Handler) schemaDoc(s *scim.Schema) map[string]any {
+	return map[string]any{
+		"schemas":     []string{scim.SchemaSchema},
+		"id":          s.ID,
+		"name":        s.Name,
+		"description": s.Description,
+		"attributes":  s.Attributes,
+		"meta":        map[string]any{"resourceType": "Schema", "location": h.baseURL + "/Schemas/" + s.ID},
+	}
+}
@@ -0,0 +1,300 @@
+package scim
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/pkg/scim"
+)
+
+func (h *Handler) getUser(w http.ResponseWriter, r *http.Request) {
+	usr, err := h.uc.GetScimUser(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	version := domain.ScimVersion(usr.UpdatedAt)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagVersion(inm) == version {
+		w.Header().Set("ETag", etag(version))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	attributes, excluded := projection(r)
+	h.writeResource(w, http.StatusOK, scim.Project(userSchema, h.userDoc(usr), attributes, excluded), version)
+}
+
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	lq, err := h.parseList(r)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	q := domain.ScimUserQuery{StartIndex: lq.startIndex, Count: lq.count}
+	if lq.filter != nil {
+		q.Lookup, q.Value = userLookup(lq.filter)
+		q.Match = func(u *domain.User) bool { return lq.filter.Match(userSchema, h.userDoc(u)) }
+	}
+	users, total, err := h.uc.ListScimUsers(r.Context(), q)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	docs := make([]map[string]any, 0, len(users))
+	for _, u := range users {
+		docs = append(docs, scim.Project(userSchema, h.userDoc(u), lq.attributes, lq.excluded))
+	}
+	h.write(w, http.StatusOK, scim.NewListResponse(total, lq.startIndex, docs))
+}
+
+// Indexed attribute the filter pins, the most selective first
+func userLookup(f *scim.Filter) (domain.ScimLookup, string) {
+	for _, l := range []struct {
+		attr   string
+		lookup domain.ScimLookup
+	}{
+		{"id", domain.LookupID},
+		{"externalId", domain.LookupExternalID},
+		{"userName", domain.LookupUserName},
+		{"emails.value", domain.LookupEmail},
+	} {
+		if v, ok := f.Equality(userSchema, l.attr); ok {
+			return l.lookup, v
+		}
+	}
+	return domain.LookupNone, ""
+}
+
+func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
+	doc, err := h.decode(r)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	in, password, err := userFromDoc(doc)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	usr, err := h.uc.CreateScimUser(r.Context(), in, password)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	h.writeResource(w, http.StatusCreated, h.userDoc(usr), domain.ScimVersion(usr.UpdatedAt))
+}
+
+func (h *Handler) replaceUser(w http.ResponseWriter, r *http.Request) {
+	doc, err := h.decode(r)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	in, password, err := userFromDoc(doc)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	// Not given is active, as a full replace
+	if in.Status == "" {
+		in.Status = domain.StatusActive
+	}
+	h.saveUser(w, r, ifMatch(r), in, password)
+}
+
+// Applied to the current resource, which is then saved as a replace
+// conditional on the version read, so a concurrent change fails with 412
+func (h *Handler) patchUser(w http.ResponseWriter, r *http.Request) {
+	ops, err := h.decodePatch(r)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	usr, err := h.uc.GetScimUser(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	version := domain.ScimVersion(usr.UpdatedAt)
+	if want := ifMatch(r); want != "" && want != version {
+		h.fail(w, domain.ErrVersionMismatch)
+		return
+	}
+
+	doc := h.userDoc(usr)
+	if err := scim.Patch(userSchema, doc, ops); err != nil {
+		h.fail(w, err)
+		return
+	}
+	in, password, err := userFromDoc(doc)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	h.saveUser(w, r, version, in, password)
+}
+
+func (h *Handler) saveUser(w http.ResponseWriter, r *http.Request, version string, in *domain.User, password string) {
+	usr, err := h.uc.ReplaceScimUser(r.Context(), r.PathValue("id"), version, in, password)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	attributes, excluded := projection(r)
+	h.writeResource(w, http.StatusOK, scim.Project(userSchema, h.userDoc(usr), attributes, excluded), domain.ScimVersion(usr.UpdatedAt))
+}
+
+func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := h.uc.DeleteScimUser(r.Context(), r.PathValue("id"), ifMatch(r)); err != nil {
+		h.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ------------ mapping ------------
+
+// userName is the username, or the email for accounts without one. Suspensions
+// are moderation, not provisioning, so only a disabled account is inactive.
+func (h *Handler) userDoc(u *domain.User) map[string]any {
+	userName := u.Username
+	if userName == "" {
+		userName = u.Email
+	}
+	doc := map[string]any{
+		"schemas":  []any{scim.UserSchema},
+		"id":       u.ID,
+		"userName": userName,
+		"active":   u.Status != domain.StatusDisabled,
+		"meta":     h.meta("User", "/Users/"+u.ID, u.CreatedAt, u.UpdatedAt),
+	}
+	if u.ExternalID != "" {
+		doc["externalId"] = u.ExternalID
+	}
+	if u.Email != "" {
+		doc["emails"] = []any{map[string]any{"value": u.Email, "type": "work", "primary": true}}
+	}
+	if u.Phone != "" {
+		doc["phoneNumbers"] = []any{map[string]any{"value": u.Phone, "type": "work", "primary": true}}
+	}
+	if u.Role != "" {
+		doc["roles"] = []any{map[string]any{"value": string(u.Role), "primary": true}}
+	}
+	return doc
+}
+
+func (h *Handler) meta(resourceType, path string, created, updated time.Time) map[string]any {
+	return map[string]any{
+		"resourceType": resourceType,
+		"created":      created.UTC().Format(time.RFC3339),
+		"lastModified": updated.UTC().Format(time.RFC3339),
+		"location":     h.baseURL + path,
+		"version":      etag(domain.ScimVersion(updated)),
+	}
+}
+
+// The attributes a SCIM user sets, see userDoc. A userName with an "@" is
+// the account email and emails are ignored, otherwise the primary email is.
+// Status is empty when active isn't given.
+func userFromDoc(doc map[string]any) (*domain.User, string, error) {
+	userName, err := stringAttr(doc, "userName")
+	if err != nil {
+		return nil, "", err
+	}
+	if userName == "" {
+		return nil, "", scim.BadRequest(scim.ErrInvalidValue, "userName is required")
+	}
+
+	in := &domain.User{}
+	if domain.IsEmailIdentifier(userName) {
+		in.Email = userName
+	} else {
+		in.Username = userName
+		if in.Email, err = primaryValue(doc, "emails"); err != nil {
+			return nil, "", err
+		}
+	}
+	if in.Phone, err = primaryValue(doc, "phoneNumbers"); err != nil {
+		return nil, "", err
+	}
+	role, err := primaryValue(doc, "roles")
+	if err != nil {
+		return nil, "", err
+	}
+	in.Role = domain.Role(role)
+	if in.ExternalID, err = stringAttr(doc, "externalId"); err != nil {
+		return nil, "", err
+	}
+
+	if v, ok := scim.Get(doc, "active"); ok && v != nil {
+		active, ok := v.(bool)
+		if !ok {
+			return nil, "", scim.BadRequest(scim.ErrInvalidValue, "active must be a boolean")
+		}
+		in.Status = domain.StatusDisabled
+		if active {
+			in.Status = domain.StatusActive
+		}
+	}
+	password, err := stringAttr(doc, "password")
+	if err != nil {
+		return nil, "", err
+	}
+	return in, password, nil
+}
+
+func stringAttr(doc map[string]any, name string) (string, error) {
+	v, ok := scim.Get(doc, name)
+	if !ok || v == nil {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", scim.BadRequest(scim.ErrInvalidValue, "%s must be a string", name)
+	}
+	return s, nil
+}
+
+// Value of the primary entry of a multi-valued attribute, else of the first.
+// Plain strings are taken as values.
+func primaryValue(doc map[string]any, name string) (string, error) {
+	v, ok := scim.Get(doc, name)
+	if !ok || v == nil {
+		return "", nil
+	}
+	list, ok := v.([]any)
+	if !ok {
+		return "", scim.BadRequest(scim.ErrInvalidValue, "%s must be a list", name)
+	}
+
+	var first, primary any
+	for i, it := range list {
+		m, ok := it.(map[string]any)
+		if !ok {
+			if i == 0 {
+				first = it
+			}
+			continue
+		}
+		val, _ := scim.Get(m, "value")
+		if i == 0 {
+			first = val
+		}
+		if p, _ := scim.Get(m, "primary"); p == true {
+			primary = val
+		}
+	}
+	if primary == nil {
+		primary = first
+	}
+	if primary == nil {
+		return "", nil
+	}
+	s, ok := primary.(string)
+	if !ok {
+		return "", scim.BadRequest(scim.ErrInvalidValue, "%s value must be a string", name)
+	}
+	return s, nil
+}
@@ -0,0 +1,59 @@
+package sms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+)
+
+// HTTPSender posts messages as JSON to an SMS provider gateway
+type HTTPSender struct {
+	client *http.Client
+	url    string
+	token  string // sent as a bearer token
+	from   string // sender ID or number
+}
+
+var _ domain.SMSSender = (*HTTPSender)(nil)
+
+func NewHTTPSender(client *http.Client, url, token, from string) *HTTPSender {
+	return &HTTPSender{client: client, url: url, token: token, from: from}
+}
+
+type sendRequest struct {
+	To      string `json:"to"`
+	From    string `json:"from,omitempty"`
+	Message string `json:"message"`
+}
+
+func (s *HTTPSender) Send(to, message string) error {
+	body, err := json.Marshal(sendRequest{To: to, From: s.from, Message: message})
+	if err != nil {
+		return fmt.Errorf("sms marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("sms request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sms send: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("sms provider responded %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
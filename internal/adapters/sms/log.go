@@ -0,0 +1,22 @@
+package sms
+
+import (
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/pkg/logger"
+)
+
+// LogSender only logs outgoing messages, nothing is delivered
+type LogSender struct {
+	log *logger.Logger
+}
+
+var _ domain.SMSSender = (*LogSender)(nil)
+
+func NewLogSender(log *logger.Logger) *LogSender {
+	return &LogSender{log: log}
+}
+
+func (s *LogSender) Send(to, message string) error {
+	s.log.Info("sms sent (log transport)", "to", to, "message", message)
+	return nil
+}
@@ -0,0 +1,63 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+)
+
+var b64 = base64.RawURLEncoding
+
+// Invite codes are "<invite id>.<tenant>.<expiry>.<hmac>", base64url encoded
+type inviteSigner struct {
+	key []byte
+}
+
+func NewInviteSigner(secret string) domain.InviteSigner {
+	return &inviteSigner{key: []byte(secret)}
+}
+
+func (s *inviteSigner) Sign(inviteID, tenantID string, expiresAt time.Time) string {
+	payload := b64.EncodeToString([]byte(inviteID)) + "." +
+		b64.EncodeToString([]byte(tenantID)) + "." +
+		strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + b64.EncodeToString(s.mac(payload))
+}
+
+func (s *inviteSigner) Verify(code string) (string, string, error) {
+	i := strings.LastIndexByte(code, '.')
+	if i < 0 {
+		return "", "", domain.ErrInvitationInvalid
+	}
+	payload := code[:i]
+	sig, err := b64.DecodeString(code[i+1:])
+	if err != nil || !hmac.Equal(sig, s.mac(payload)) {
+		return "", "", domain.ErrInvitationInvalid
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 {
+		return "", "", domain.ErrInvitationInvalid
+	}
+	inviteID, err1 := b64.DecodeString(parts[0])
+	tenantID, err2 := b64.DecodeString(parts[1])
+	exp, err3 := strconv.ParseInt(parts[2], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return "", "", domain.ErrInvitationInvalid
+	}
+	if !time.Now().Before(time.Unix(exp, 0)) {
+		return "", "", domain.ErrInvitationExpired
+	}
+	return string(inviteID), string(tenantID), nil
+}
+
+func (s *inviteSigner) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
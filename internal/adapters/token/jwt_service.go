@@ -33,22 +33,32 @@ func (s *service) Validate(ctx context.Context, tokenStr string) (*domain.TokenP
 		return nil, err
 	}
 
-	return &domain.TokenPayload{
+	payload := &domain.TokenPayload{
 		UserID:    claims["sub"].(string),
 		Role:      domain.Role(claims["role"].(string)),
 		ExpiresAt: int64(claims["exp"].(float64)),
-	}, nil
+		TenantID:  domain.DefaultTenantID, // tokens issued before tenants
+	}
+	if tid, ok := claims["tid"].(string); ok && tid != "" {
+		payload.TenantID = tid
+	}
+	if iat, ok := claims["iat"].(float64); ok {
+		payload.IssuedAt = time.Unix(int64(iat), 0).UTC()
+	}
+
+	return payload, nil
 }
 
-func (s *service) Generate(userID string, role domain.Role) (string, time.Time, int64, error) {
+func (s *service) Generate(userID, tenantID string, role domain.Role) (string, time.Time, int64, error) {
 	exp := time.Now().Add(s.accessTTL).Unix()
 	iat := time.Now().UTC()
 
 	claims := jwt.MapClaims{
 		"sub":  userID,
+		"tid":  tenantID,
 		"role": role,
 		"exp":  exp,
-		"iat":  iat,
+		"iat":  iat.Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -0,0 +1,169 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/Neroframe/AuthService/pkg/webhook"
+)
+
+type Config struct {
+	PollInterval time.Duration
+	Lease        time.Duration // how long a claimed delivery is locked
+	MaxAttempts  int
+	Backoff      time.Duration // first retry delay, doubled on every attempt
+	MaxBackoff   time.Duration
+	DisableAfter int // consecutive failed deliveries before the subscription is disabled
+}
+
+// Dispatcher POSTs pending deliveries to subscriber endpoints
+type Dispatcher struct {
+	subs       repository.WebhookRepository
+	deliveries repository.WebhookDeliveryRepository
+	client     *http.Client
+	log        *logger.Logger
+	cfg        Config
+}
+
+func NewDispatcher(
+	subs repository.WebhookRepository,
+	deliveries repository.WebhookDeliveryRepository,
+	client *http.Client,
+	log *logger.Logger,
+	cfg Config,
+) *Dispatcher {
+	return &Dispatcher{subs: subs, deliveries: deliveries, client: client, log: log, cfg: cfg}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			d.DeliverDue(ctx)
+		}
+	}
+}
+
+// Send every delivery that is due now, of any tenant
+func (d *Dispatcher) DeliverDue(ctx context.Context) {
+	all := domain.WithAllTenants(ctx)
+	for ctx.Err() == nil {
+		del, err := d.deliveries.ClaimDue(all, time.Now().UTC(), d.cfg.Lease)
+		if err != nil {
+			d.log.Error("webhook claim failed", "err", err)
+			return
+		}
+		if del == nil {
+			return
+		}
+		d.deliver(domain.WithTenant(ctx, del.TenantID), del)
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, del *domain.WebhookDelivery) {
+	sub, err := d.subs.GetByID(ctx, del.SubscriptionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			d.finish(ctx, del, 0, errors.New("subscription deleted"))
+			return
+		}
+		d.log.Error("webhook subscription fetch failed", "delivery_id", del.ID, "err", err)
+		return
+	}
+	if !sub.Enabled {
+		d.finish(ctx, del, 0, errors.New("subscription disabled"))
+		return
+	}
+
+	code, sendErr := d.Send(ctx, sub, del)
+	if sendErr == nil {
+		if err := d.deliveries.MarkSucceeded(ctx, del.ID, code); err != nil {
+			d.log.Error("webhook MarkSucceeded failed", "delivery_id", del.ID, "err", err)
+		}
+		if sub.FailureCount > 0 {
+			if err := d.subs.ResetFailures(ctx, sub.ID); err != nil {
+				d.log.Error("webhook ResetFailures failed", "webhook_id", sub.ID, "err", err)
+			}
+		}
+		return
+	}
+
+	d.log.Warn("webhook delivery failed", "delivery_id", del.ID, "webhook_id", sub.ID, "attempt", del.Attempts+1, "status", code, "err", sendErr)
+
+	// Retry with exponential backoff
+	if del.Attempts+1 < d.cfg.MaxAttempts {
+		next := time.Now().UTC().Add(d.backoff(del.Attempts))
+		if err := d.deliveries.MarkRetry(ctx, del.ID, code, sendErr, next); err != nil {
+			d.log.Error("webhook MarkRetry failed", "delivery_id", del.ID, "err", err)
+		}
+		return
+	}
+
+	d.finish(ctx, del, code, sendErr)
+
+	disabled, err := d.subs.RecordFailure(ctx, sub.ID, d.cfg.DisableAfter)
+	if err != nil {
+		d.log.Error("webhook RecordFailure failed", "webhook_id", sub.ID, "err", err)
+	}
+	if disabled {
+		d.log.Warn("webhook disabled after repeated failures", "webhook_id", sub.ID, "url", sub.URL)
+	}
+}
+
+// Sign and POST one delivery, non-2xx responses are errors
+func (d *Dispatcher) Send(ctx context.Context, sub *domain.WebhookSubscription, del *domain.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(del.Body))
+	if err != nil {
+		return 0, fmt.Errorf("build request: %w", err)
+	}
+
+	// CloudEvent headers (content-type, ce-*) are forwarded as is
+	for k, v := range del.Headers {
+		req.Header.Set(k, v)
+	}
+	ts := time.Now().Unix()
+	req.Header.Set(webhook.HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(sub.Secret, ts, del.Body))
+	req.Header.Set(webhook.HeaderDelivery, del.ID)
+	req.Header.Set(webhook.HeaderEvent, string(del.EventType))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("post: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) finish(ctx context.Context, del *domain.WebhookDelivery, code int, cause error) {
+	if err := d.deliveries.MarkFailed(ctx, del.ID, code, cause); err != nil {
+		d.log.Error("webhook MarkFailed failed", "delivery_id", del.ID, "err", err)
+	}
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.Backoff
+	for i := 0; i < attempts && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxBackoff)
+}
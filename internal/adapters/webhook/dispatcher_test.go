@@ -0,0 +1,265 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/Neroframe/AuthService/pkg/webhook"
+)
+
+// Subscriptions by ID, failures counted
+type subStore struct {
+	repository.WebhookRepository
+	mu       sync.Mutex
+	subs     map[string]*domain.WebhookSubscription
+	failures int
+	resets   int
+}
+
+func (s *subStore) GetByID(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	c := *sub
+	return &c, nil
+}
+
+func (s *subStore) RecordFailure(ctx context.Context, id string, disableAfter int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures++
+	return s.failures >= disableAfter, nil
+}
+
+func (s *subStore) ResetFailures(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resets++
+	return nil
+}
+
+// Hands out the queued deliveries once and records their outcome
+type deliveryStore struct {
+	repository.WebhookDeliveryRepository
+	mu        sync.Mutex
+	due       []*domain.WebhookDelivery
+	succeeded []string
+	failed    []string
+	retries   map[string]time.Time
+	codes     map[string]int
+}
+
+func (s *deliveryStore) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*domain.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.due) == 0 {
+		return nil, nil
+	}
+	d := s.due[0]
+	s.due = s.due[1:]
+	return d, nil
+}
+
+func (s *deliveryStore) MarkSucceeded(ctx context.Context, id string, statusCode int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.succeeded = append(s.succeeded, id)
+	s.codes[id] = statusCode
+	return nil
+}
+
+func (s *deliveryStore) MarkRetry(ctx context.Context, id string, statusCode int, cause error, next time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retries[id] = next
+	s.codes[id] = statusCode
+	return nil
+}
+
+func (s *deliveryStore) MarkFailed(ctx context.Context, id string, statusCode int, cause error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = append(s.failed, id)
+	s.codes[id] = statusCode
+	return nil
+}
+
+const testSecret = "whsec_test"
+
+var testConfig = Config{
+	PollInterval: time.Second,
+	Lease:        time.Minute,
+	MaxAttempts:  4,
+	Backoff:      time.Second,
+	MaxBackoff:   3 * time.Second,
+	DisableAfter: 2,
+}
+
+// Dispatcher posting to a local receiver answering with status
+func newTestDispatcher(t *testing.T, status int, handler func(r *http.Request, body []byte)) (*Dispatcher, *subStore, *deliveryStore) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		if handler != nil {
+			handler(r, body)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	subs := &subStore{subs: map[string]*domain.WebhookSubscription{
+		"sub-1": {ID: "sub-1", TenantID: domain.DefaultTenantID, URL: srv.URL, Secret: testSecret, Enabled: true, FailureCount: 1},
+	}}
+	deliveries := &deliveryStore{retries: map[string]time.Time{}, codes: map[string]int{}}
+	d := NewDispatcher(subs, deliveries, srv.Client(), logger.New(logger.Config{Level: "error"}), testConfig)
+	return d, subs, deliveries
+}
+
+func testDelivery(id string, attempts int) *domain.WebhookDelivery {
+	return &domain.WebhookDelivery{
+		ID:             id,
+		TenantID:       domain.DefaultTenantID,
+		SubscriptionID: "sub-1",
+		EventID:        "evt-" + id,
+		EventType:      domain.EventType("user.registered"),
+		Body:           []byte(`{"user_id":"u1"}`),
+		Headers:        map[string]string{"Content-Type": "application/json", "ce-type": "user.registered.v1"},
+		Attempts:       attempts,
+	}
+}
+
+func TestSendSignsAndForwardsHeaders(t *testing.T) {
+	var got *http.Request
+	var gotBody []byte
+	d, _, _ := newTestDispatcher(t, http.StatusNoContent, func(r *http.Request, body []byte) {
+		got, gotBody = r, body
+	})
+	sub, _ := d.subs.GetByID(context.Background(), "sub-1")
+	del := testDelivery("d1", 0)
+
+	code, err := d.Send(context.Background(), sub, del)
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("Send = %d, %v, want 204", code, err)
+	}
+
+	if string(gotBody) != string(del.Body) {
+		t.Errorf("body %q, want %q", gotBody, del.Body)
+	}
+	for header, want := range map[string]string{
+		webhook.HeaderDelivery: "d1",
+		webhook.HeaderEvent:    "user.registered",
+		"Content-Type":         "application/json",
+		"Ce-Type":              "user.registered.v1",
+	} {
+		if v := got.Header.Get(header); v != want {
+			t.Errorf("%s = %q, want %q", header, v, want)
+		}
+	}
+
+	ts, sig := got.Header.Get(webhook.HeaderTimestamp), got.Header.Get(webhook.HeaderSignature)
+	if err := webhook.Verify(testSecret, ts, sig, gotBody, time.Minute, time.Now()); err != nil {
+		t.Errorf("signature doesn't verify: %v", err)
+	}
+	if err := webhook.Verify("other", ts, sig, gotBody, time.Minute, time.Now()); err == nil {
+		t.Error("signature verifies with another secret")
+	}
+	if err := webhook.Verify(testSecret, ts, sig, []byte(`{"user_id":"u2"}`), time.Minute, time.Now()); err == nil {
+		t.Error("signature verifies for another body")
+	}
+}
+
+func TestSendRejectsNon2xx(t *testing.T) {
+	d, _, _ := newTestDispatcher(t, http.StatusBadGateway, nil)
+	sub, _ := d.subs.GetByID(context.Background(), "sub-1")
+
+	code, err := d.Send(context.Background(), sub, testDelivery("d1", 0))
+	if err == nil || code != http.StatusBadGateway {
+		t.Fatalf("Send = %d, %v, want 502 and an error", code, err)
+	}
+}
+
+func TestDeliverSuccessResetsFailures(t *testing.T) {
+	d, subs, deliveries := newTestDispatcher(t, http.StatusOK, nil)
+	deliveries.due = []*domain.WebhookDelivery{testDelivery("d1", 0)}
+
+	d.DeliverDue(context.Background())
+
+	if len(deliveries.succeeded) != 1 || deliveries.codes["d1"] != http.StatusOK {
+		t.Fatalf("succeeded %v codes %v, want d1 with 200", deliveries.succeeded, deliveries.codes)
+	}
+	if subs.resets != 1 {
+		t.Errorf("failures reset %d times, want 1", subs.resets)
+	}
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	d, subs, deliveries := newTestDispatcher(t, http.StatusInternalServerError, nil)
+	// Delay doubles from Backoff and is capped at MaxBackoff
+	want := map[string]time.Duration{"a0": time.Second, "a1": 2 * time.Second, "a2": 3 * time.Second}
+	deliveries.due = []*domain.WebhookDelivery{testDelivery("a0", 0), testDelivery("a1", 1), testDelivery("a2", 2)}
+
+	start := time.Now().UTC()
+	d.DeliverDue(context.Background())
+	end := time.Now().UTC()
+
+	for id, delay := range want {
+		next, ok := deliveries.retries[id]
+		if !ok {
+			t.Errorf("%s not retried", id)
+			continue
+		}
+		if next.Before(start.Add(delay)) || next.After(end.Add(delay)) {
+			t.Errorf("%s retried at +%s, want +%s", id, next.Sub(start), delay)
+		}
+		if deliveries.codes[id] != http.StatusInternalServerError {
+			t.Errorf("%s status %d, want 500", id, deliveries.codes[id])
+		}
+	}
+	if len(deliveries.failed) != 0 || subs.failures != 0 {
+		t.Errorf("failed %v with %d subscription failures before the last attempt", deliveries.failed, subs.failures)
+	}
+}
+
+func TestDeliverGivesUpAfterMaxAttempts(t *testing.T) {
+	d, subs, deliveries := newTestDispatcher(t, http.StatusInternalServerError, nil)
+	deliveries.due = []*domain.WebhookDelivery{testDelivery("d1", testConfig.MaxAttempts-1)}
+
+	d.DeliverDue(context.Background())
+
+	if len(deliveries.retries) != 0 {
+		t.Errorf("retried %v after the last attempt", deliveries.retries)
+	}
+	if len(deliveries.failed) != 1 || subs.failures != 1 {
+		t.Fatalf("failed %v with %d subscription failures, want d1 and 1", deliveries.failed, subs.failures)
+	}
+}
+
+func TestDeliverSkipsDisabledSubscription(t *testing.T) {
+	calls := 0
+	d, subs, deliveries := newTestDispatcher(t, http.StatusOK, func(*http.Request, []byte) { calls++ })
+	subs.subs["sub-1"].Enabled = false
+	deliveries.due = []*domain.WebhookDelivery{testDelivery("d1", 0)}
+
+	d.DeliverDue(context.Background())
+
+	if calls != 0 {
+		t.Errorf("disabled subscription got %d requests", calls)
+	}
+	if len(deliveries.failed) != 1 {
+		t.Errorf("failed %v, want d1", deliveries.failed)
+	}
+}
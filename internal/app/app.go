@@ -4,26 +4,37 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"time"
 
 	gomailpkg "github.com/Neroframe/AuthService/pkg/gomail"
 
 	"github.com/Neroframe/AuthService/config"
+	"github.com/Neroframe/AuthService/internal/adapters/authz"
 	"github.com/Neroframe/AuthService/internal/adapters/bcrypt"
+	"github.com/Neroframe/AuthService/internal/adapters/email"
 	"github.com/Neroframe/AuthService/internal/adapters/gomail"
 	grpcadapter "github.com/Neroframe/AuthService/internal/adapters/grpc"
 	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
 	mongoadapter "github.com/Neroframe/AuthService/internal/adapters/mongo"
 	natsadapter "github.com/Neroframe/AuthService/internal/adapters/nats"
 	redisadapter "github.com/Neroframe/AuthService/internal/adapters/redis"
+	scimadapter "github.com/Neroframe/AuthService/internal/adapters/scim"
+	"github.com/Neroframe/AuthService/internal/adapters/sms"
 	"github.com/Neroframe/AuthService/internal/adapters/token"
+	webhookadapter "github.com/Neroframe/AuthService/internal/adapters/webhook"
 	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
 	"github.com/Neroframe/AuthService/internal/usecase"
 	grpcpkg "github.com/Neroframe/AuthService/pkg/grpc"
+	"github.com/Neroframe/AuthService/pkg/httpserver"
 	"github.com/Neroframe/AuthService/pkg/logger"
 	mongopkg "github.com/Neroframe/AuthService/pkg/mongo"
 	natspkg "github.com/Neroframe/AuthService/pkg/nats"
+	"github.com/Neroframe/AuthService/pkg/realip"
 	redispkg "github.com/Neroframe/AuthService/pkg/redis"
+	webhookpkg "github.com/Neroframe/AuthService/pkg/webhook"
 	authpb "github.com/Neroframe/AuthService/proto"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
@@ -38,10 +49,17 @@ type App struct {
 	redis *redispkg.Client
 
 	grpc       *grpcpkg.Server
+	scim       *httpserver.Server // nil unless SCIM_ADDR is set
+	relay      *natsadapter.Relay
+	consumer   *natsadapter.Consumer
+	dispatcher *webhookadapter.Dispatcher
+	users      usecase.UserUsecase
+	exports    usecase.ExportUsecase
+	imports    usecase.ImportUsecase
 	authClient authpb.AuthServiceClient
 	authConn   *grpc.ClientConn
 
-	emailSender *gomailpkg.Sender
+	smtpSender *gomailpkg.Sender // nil unless EMAIL_TRANSPORT=smtp
 }
 
 func New(ctx context.Context, cfg *config.Config, log *logger.Logger) (*App, error) {
@@ -63,12 +81,37 @@ func New(ctx context.Context, cfg *config.Config, log *logger.Logger) (*App, err
 		Name:          cfg.Nats.Name,
 		MaxReconnects: cfg.Nats.MaxReconnects,
 		ReconnectWait: cfg.Nats.ReconnectWait,
+		AckTimeout:    cfg.Nats.AckTimeout,
 	})
 	if err != nil {
 		mongoClient.Disconnect(ctx)
 		return nil, fmt.Errorf("nats connect: %w", err)
 	}
 
+	// JetStream stream for user events
+	if err := natsClient.EnsureStream(ctx, natspkg.StreamConfig(cfg.Nats.Stream)); err != nil {
+		mongoClient.Disconnect(ctx)
+		natsClient.Disconnect()
+		return nil, fmt.Errorf("nats stream: %w", err)
+	}
+	// Inbound events from other services, and messages the consumers gave up on
+	if err := natsClient.EnsureStream(ctx, natspkg.StreamConfig{
+		Name: cfg.Consumer.Stream,
+		Subjects: []string{
+			cfg.Consumer.StudentWithdrawnSubject,
+			cfg.Consumer.StaffTerminatedSubject,
+			cfg.Consumer.RelationsSubject,
+			cfg.Consumer.DLQSubject + ".>",
+		},
+		MaxAge:          cfg.Consumer.StreamMaxAge,
+		Replicas:        cfg.Nats.Stream.Replicas,
+		DuplicateWindow: cfg.Nats.Stream.DuplicateWindow,
+	}); err != nil {
+		mongoClient.Disconnect(ctx)
+		natsClient.Disconnect()
+		return nil, fmt.Errorf("nats inbound stream: %w", err)
+	}
+
 	// Redis
 	redisClient, err := redispkg.NewClient(ctx, redispkg.Config(cfg.Redis))
 	if err != nil {
@@ -82,19 +125,239 @@ func New(ctx context.Context, cfg *config.Config, log *logger.Logger) (*App, err
 	if err != nil {
 		return nil, fmt.Errorf("mongo repo init: %w", err)
 	}
-	publisher := natsadapter.NewAuthPublisher(natsClient)
+	txManager := mongoadapter.NewTxManager(mongoClient.Client)
+
+	// Users stored before email normalisation, duplicates are left for an admin.
+	// They all predate tenants, so they are in the default one.
+	emails := domain.EmailNormalizer{FoldAliases: cfg.Email.FoldAliases}
+	keyReport, err := usecase.MigrateEmailKeys(domain.WithTenant(ctx, domain.DefaultTenantID), repo, emails, false)
+	if err != nil {
+		return nil, fmt.Errorf("email key migration: %w", err)
+	}
+	if keyReport.Scanned > 0 {
+		log.Info("email keys migrated", "scanned", keyReport.Scanned, "keyed", keyReport.Keyed, "invalid", len(keyReport.Invalid))
+	}
+	for key, ids := range keyReport.Duplicates {
+		log.Warn("duplicate accounts for email", "email_key", key, "kept_by", keyReport.Owners[key], "user_ids", ids)
+	}
+	outboxRepo, err := mongoadapter.NewOutboxRepository(ctx, mongoClient.DB, cfg.Outbox.Retention)
+	if err != nil {
+		return nil, fmt.Errorf("mongo outbox repo init: %w", err)
+	}
+	publisher := natsadapter.NewAuthPublisher(
+		outboxRepo,
+		natsadapter.Subjects(cfg.Nats.NatsSubjects),
+		cfg.Events.Source,
+		cfg.Events.Encoding,
+	)
+	relay := natsadapter.NewRelay(outboxRepo, natsClient, log, natsadapter.RelayConfig{
+		PollInterval: cfg.Outbox.PollInterval,
+		BatchSize:    cfg.Outbox.BatchSize,
+		LockTTL:      cfg.Outbox.LockTTL,
+		MaxAttempts:  cfg.Outbox.MaxAttempts,
+		Backoff:      cfg.Outbox.Backoff,
+		MaxBackoff:   cfg.Outbox.MaxBackoff,
+	})
 	redisCache := redisadapter.NewCodeCache(redisClient.Client, cfg.Redis.DialTimeout)
+	auditRepo, err := mongoadapter.NewAuditRepository(ctx, mongoClient.DB, cfg.Audit.Retention)
+	if err != nil {
+		return nil, fmt.Errorf("mongo audit repo init: %w", err)
+	}
+	revoker := redisadapter.NewSessionRevoker(redisClient.Client, cfg.JWT.Expiration)
+	emailChanges := redisadapter.NewEmailChangeStore(redisClient.Client)
+	sendLimiter := redisadapter.NewSendLimiter(redisClient.Client)
+	processedRepo, err := mongoadapter.NewProcessedMessageRepository(ctx, mongoClient.DB, cfg.Consumer.ProcessedTTL)
+	if err != nil {
+		return nil, fmt.Errorf("mongo processed repo init: %w", err)
+	}
+	exportRepo, err := mongoadapter.NewExportRepository(ctx, mongoClient.DB)
+	if err != nil {
+		return nil, fmt.Errorf("mongo export repo init: %w", err)
+	}
+	relationRepo, err := mongoadapter.NewRelationRepository(ctx, mongoClient.DB)
+	if err != nil {
+		return nil, fmt.Errorf("mongo relation repo init: %w", err)
+	}
+	groupRepo, err := mongoadapter.NewGroupRepository(ctx, mongoClient.DB)
+	if err != nil {
+		return nil, fmt.Errorf("mongo group repo init: %w", err)
+	}
+	invitationRepo, err := mongoadapter.NewInvitationRepository(ctx, mongoClient.DB)
+	if err != nil {
+		return nil, fmt.Errorf("mongo invitation repo init: %w", err)
+	}
+	importRepo, err := mongoadapter.NewImportRepository(ctx, mongoClient.DB)
+	if err != nil {
+		return nil, fmt.Errorf("mongo import repo init: %w", err)
+	}
+	scimClientRepo, err := mongoadapter.NewScimClientRepository(ctx, mongoClient.DB)
+	if err != nil {
+		return nil, fmt.Errorf("mongo scim client repo init: %w", err)
+	}
+	webhookRepo, err := mongoadapter.NewWebhookRepository(ctx, mongoClient.DB)
+	if err != nil {
+		return nil, fmt.Errorf("mongo webhook repo init: %w", err)
+	}
+	deliveryRepo, err := mongoadapter.NewWebhookDeliveryRepository(ctx, mongoClient.DB, cfg.Webhook.Retention)
+	if err != nil {
+		return nil, fmt.Errorf("mongo webhook delivery repo init: %w", err)
+	}
+	// Endpoints on internal addresses are refused when registered and when dialled
+	webhookGuard, err := webhookpkg.NewGuard(cfg.Webhook.AllowedNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("webhook guard init: %w", err)
+	}
+	webhookTransport := http.DefaultTransport.(*http.Transport).Clone()
+	webhookTransport.Proxy = nil
+	webhookTransport.DialContext = (&net.Dialer{Timeout: cfg.Webhook.Timeout, Control: webhookGuard.Control}).DialContext
+	dispatcher := webhookadapter.NewDispatcher(
+		webhookRepo,
+		deliveryRepo,
+		&http.Client{Timeout: cfg.Webhook.Timeout, Transport: webhookTransport},
+		log,
+		webhookadapter.Config{
+			PollInterval: cfg.Webhook.PollInterval,
+			Lease:        cfg.Webhook.Lease,
+			MaxAttempts:  cfg.Webhook.MaxAttempts,
+			Backoff:      cfg.Webhook.Backoff,
+			MaxBackoff:   cfg.Webhook.MaxBackoff,
+			DisableAfter: cfg.Webhook.DisableAfter,
+		},
+	)
 
 	// Init jwt and bcrypt helper services
 	jwtSvc := token.NewJWTService(cfg.JWT.Secret, cfg.JWT.Expiration)
+	inviteSigner := token.NewInviteSigner(cfg.Invitation.Secret)
+	registration := domain.RegistrationMode(cfg.Invitation.RegistrationMode)
+	if !registration.Valid() {
+		return nil, fmt.Errorf("unknown REGISTRATION_MODE %q", cfg.Invitation.RegistrationMode)
+	}
 	hasher := bcrypt.NewHasher()
 
 	// Init email sender
-	gomailSender := gomailpkg.New(gomailpkg.Config(cfg.Gomail))
-	emailSender := gomail.NewGomailService(gomailSender)
+	emailSender, smtpSender, err := newEmailSender(cfg, log)
+	if err != nil {
+		return nil, fmt.Errorf("email sender init: %w", err)
+	}
+
+	smsSender, err := newSMSSender(cfg, log)
+	if err != nil {
+		return nil, fmt.Errorf("sms sender init: %w", err)
+	}
+
+	// Authorization rules checked by every usecase
+	policyEngine, err := loadPolicy(cfg.Policy)
+	if err != nil {
+		return nil, fmt.Errorf("policy load: %w", err)
+	}
+	authorizer := authz.NewPolicyAuthorizer(policyEngine, log, cfg.Policy.DecisionLog)
+
+	// Roles and permissions, every replica reloads on change notices
+	rbacNotifier := natsadapter.NewRBACNotifier(natsClient, cfg.RBAC.ChangesSubject)
+	rbacUC := usecase.NewRBACUsecase(mongoadapter.NewRBACRepository(mongoClient.DB), repo, rbacNotifier, auditRepo, log, cfg.RBAC.CacheTTL, authorizer)
+	if err := rbacUC.Seed(ctx, defaultRoles(), defaultPermissions()); err != nil {
+		return nil, fmt.Errorf("rbac seed: %w", err)
+	}
+	if _, err := rbacNotifier.Subscribe(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := rbacUC.Reload(ctx); err != nil {
+			log.Error("rbac reload failed", "err", err)
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("rbac subscribe: %w", err)
+	}
+
+	// Tenants, the default one holds every user stored before tenants existed
+	tenantUC := usecase.NewTenantUsecase(mongoadapter.NewTenantRepository(mongoClient.DB), auditRepo, log, authorizer, rbacUC, cfg.Tenant.CacheTTL)
+	if err := tenantUC.Seed(ctx, cfg.Tenant.DefaultName, domain.TenantConfig{
+		Password: domain.PasswordPolicy{MinLength: cfg.Tenant.DefaultMinPasswordLength},
+	}); err != nil {
+		return nil, fmt.Errorf("tenant seed: %w", err)
+	}
 
 	// Usecase
-	userUC := usecase.NewUserUsecase(repo, hasher, publisher, redisCache, log, jwtSvc, emailSender)
+	relationUC := usecase.NewRelationUsecase(relationRepo, auditRepo, log, authorizer, rbacUC)
+	userUC := usecase.NewUserUsecase(usecase.UserDeps{
+		Repo:         repo,
+		Tx:           txManager,
+		Hasher:       hasher,
+		Publisher:    publisher,
+		Cache:        redisCache,
+		Revoker:      revoker,
+		JWT:          jwtSvc,
+		EmailSender:  emailSender,
+		Audit:        auditRepo,
+		Scrubbers:    []repository.UserDataScrubber{outboxRepo, deliveryRepo, invitationRepo, importRepo},
+		EmailChanges: emailChanges,
+		SMS:          smsSender,
+		Limiter:      sendLimiter,
+		Emails:       emails,
+		Roles:        rbacUC,
+		Authz:        authorizer,
+		Relations:    relationUC,
+		Tenants:      tenantUC,
+	}, usecase.UserConfig{
+		ErasureGrace: cfg.Erasure.GracePeriod,
+		EmailChange: usecase.EmailChangeConfig{
+			CodeTTL: cfg.EmailChange.CodeTTL,
+			UndoTTL: cfg.EmailChange.UndoTTL,
+			UndoURL: cfg.EmailChange.UndoURL,
+		},
+		Phone: usecase.PhoneConfig{
+			DefaultCC:    cfg.SMS.DefaultCountryCode,
+			SendCooldown: cfg.SMS.SendCooldown,
+		},
+		Registration: registration,
+	}, log)
+	webhookUC := usecase.NewWebhookUsecase(webhookRepo, deliveryRepo, webhookGuard, auditRepo, log, authorizer, rbacUC)
+	exportUC := usecase.NewExportUsecase(repo, exportRepo, auditRepo, revoker, log, usecase.ExportConfig{
+		InlineMaxEntries: cfg.Export.InlineMaxEntries,
+		TTL:              cfg.Export.TTL,
+		Lease:            cfg.Export.Lease,
+		TokenTTL:         cfg.JWT.Expiration,
+	}, authorizer, rbacUC)
+	groupUC := usecase.NewGroupUsecase(groupRepo, relationRepo, repo, txManager, publisher, auditRepo, log, authorizer, rbacUC, relationUC)
+	invitationUC := usecase.NewInvitationUsecase(invitationRepo, repo, groupRepo, relationRepo, txManager, hasher, publisher, emailSender, emails, tenantUC, inviteSigner, usecase.InvitationConfig{
+		TTL:       cfg.Invitation.TTL,
+		AcceptURL: cfg.Invitation.AcceptURL,
+	}, auditRepo, log, authorizer, rbacUC, relationUC)
+	if cfg.Invitation.BootstrapAdmin != "" {
+		if err := invitationUC.BootstrapAdmin(ctx, cfg.Invitation.BootstrapAdmin); err != nil {
+			return nil, fmt.Errorf("bootstrap admin invitation: %w", err)
+		}
+	}
+	importUC := usecase.NewImportUsecase(importRepo, repo, groupRepo, relationRepo, txManager, hasher, publisher, revoker, invitationUC, emails, cfg.SMS.DefaultCountryCode, tenantUC, usecase.ImportConfig{
+		Dir:          cfg.Import.Dir,
+		BatchSize:    cfg.Import.BatchSize,
+		Lease:        cfg.Import.Lease,
+		MaxFileBytes: cfg.Import.MaxUploadMB << 20,
+		ResultTTL:    cfg.Import.ResultTTL,
+	}, auditRepo, log, authorizer, rbacUC)
+	scimUC := usecase.NewScimUsecase(scimClientRepo, repo, groupRepo, relationRepo, txManager, hasher, publisher, revoker, emails, tenantUC, usecase.ScimConfig{
+		DefaultRole:  domain.Role(cfg.Scim.DefaultRole),
+		DefaultCC:    cfg.SMS.DefaultCountryCode,
+		ErasureGrace: cfg.Erasure.GracePeriod,
+	}, auditRepo, log, authorizer, rbacUC)
+	checkUC := usecase.NewCheckUsecase(repo, authorizer, rbacUC, relationUC, cfg.Policy.CheckCacheTTL, cfg.Policy.CheckCacheSize)
+
+	// Inbound event consumers
+	consumer := natsadapter.NewConsumer(natsClient, processedRepo, log, natsadapter.ConsumerConfig{
+		Stream:         cfg.Consumer.Stream,
+		DurablePrefix:  cfg.Consumer.DurablePrefix,
+		DLQSubject:     cfg.Consumer.DLQSubject,
+		MaxRetries:     cfg.Consumer.MaxRetries,
+		Backoff:        cfg.Consumer.Backoff,
+		MaxBackoff:     cfg.Consumer.MaxBackoff,
+		HandlerTimeout: cfg.Consumer.HandlerTimeout,
+		AckWait:        cfg.Consumer.AckWait,
+	})
+	inbound := natsadapter.NewInboundHandlers(userUC, relationUC)
+	consumer.Handle(cfg.Consumer.StudentWithdrawnSubject, inbound.StudentWithdrawn)
+	consumer.Handle(cfg.Consumer.StaffTerminatedSubject, inbound.StaffTerminated)
+	consumer.Handle(cfg.Consumer.RelationsSubject, inbound.RelationsChanged)
+	// Durable, so events published during a restart still reach webhooks
+	consumer.HandleStream(cfg.Nats.Stream.Name, cfg.Consumer.WebhookDurable, cfg.Consumer.UserEventsSubject, natsadapter.NewWebhookFanout(webhookUC).UserEvent)
 
 	// gRPC client and clientConn (remove)
 	authClient, authConn, err := grpcadapter.NewAuthClient(cfg)
@@ -102,6 +365,12 @@ func New(ctx context.Context, cfg *config.Config, log *logger.Logger) (*App, err
 		return nil, fmt.Errorf("grpc AuthClient init: %w", err)
 	}
 
+	// Client IPs for audit entries
+	proxies, err := realip.New(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+
 	// Init gRPC interceptor
 	authInt := middleware.NewAuthInterceptor(
 		// public
@@ -111,20 +380,23 @@ func New(ctx context.Context, cfg *config.Config, log *logger.Logger) (*App, err
 			"/auth.AuthService/ValidateToken",
 			"/auth.AuthService/ResetPassword",
 			"/auth.AuthService/ConfirmResetPassword",
+			"/auth.AuthService/RestoreAccount",
+			"/auth.AuthService/UndoEmailChange",
+			"/auth.AuthService/AcceptInvitation",
+			"/auth.AuthService/DownloadDataExport", // the download token is the credential
 		},
-		// private role based
-		map[string][]domain.Role{
-			"/auth.AuthService/ChangePassword":    {domain.ADMIN, domain.TEACHER, domain.STUDENT},
-			"/auth.AuthService/GetUserByID":       {domain.ADMIN, domain.TEACHER, domain.STUDENT},
-			"/auth.AuthService/UpdateUserProfile": {domain.ADMIN, domain.TEACHER},
-		},
+		// role based, from the RBAC store
+		rbacUC,
+		tenantUC,
 		authClient,
 		jwtSvc,
+		revoker,
+		proxies,
 		log,
 	)
 
 	// Create gRPC handler that implements server logic
-	authHandler := grpcadapter.NewHandler(userUC, log)
+	authHandler := grpcadapter.NewHandler(userUC, webhookUC, exportUC, rbacUC, checkUC, relationUC, tenantUC, groupUC, invitationUC, importUC, scimUC, log)
 
 	// Create and configure gRPC server
 	srv, err := grpcpkg.New(
@@ -138,6 +410,11 @@ func New(ctx context.Context, cfg *config.Config, log *logger.Logger) (*App, err
 			authInt.UnaryLoggingInterceptor(),
 			authInt.UnaryAuthentificate(),
 		},
+		// Same for streams
+		[]grpc.StreamServerInterceptor{
+			authInt.StreamLoggingInterceptor(),
+			authInt.StreamAuthentificate(),
+		},
 	)
 	if err != nil {
 		mongoClient.Disconnect(ctx)
@@ -147,23 +424,59 @@ func New(ctx context.Context, cfg *config.Config, log *logger.Logger) (*App, err
 		return nil, fmt.Errorf("grpc server init: %w", err)
 	}
 
+	// SCIM 2.0 provisioning over HTTP, authenticated by client tokens
+	var scimSrv *httpserver.Server
+	if cfg.Scim.Addr != "" {
+		scimHandler := scimadapter.NewHandler(scimUC, cfg.Scim.BaseURL, cfg.Scim.MaxResults, proxies, log)
+		mux := http.NewServeMux()
+		mux.Handle("/scim/v2/", scimHandler.Routes())
+		scimSrv, err = httpserver.New(httpserver.Config{
+			Addr:            cfg.Scim.Addr,
+			CertFile:        cfg.Scim.CertFile,
+			KeyFile:         cfg.Scim.KeyFile,
+			ReadTimeout:     cfg.Scim.RequestTimeout,
+			WriteTimeout:    cfg.Scim.RequestTimeout,
+			ShutdownTimeout: cfg.Scim.ShutdownTimeout,
+		}, mux)
+		if err != nil {
+			srv.Stop()
+			mongoClient.Disconnect(ctx)
+			natsClient.Disconnect()
+			redisClient.Close()
+			authConn.Close()
+			return nil, fmt.Errorf("scim server init: %w", err)
+		}
+	}
+
 	return &App{
 		cfg: cfg,
 		log: log,
 
-		mongo: mongoClient,
-		nats:  natsClient,
-		redis: redisClient,
-		grpc:  srv,
+		mongo:      mongoClient,
+		nats:       natsClient,
+		redis:      redisClient,
+		grpc:       srv,
+		scim:       scimSrv,
+		relay:      relay,
+		consumer:   consumer,
+		dispatcher: dispatcher,
+		users:      userUC,
+		exports:    exportUC,
+		imports:    importUC,
 
 		authClient: authClient,
 		authConn:   authConn,
 
-		emailSender: gomailSender,
+		smtpSender: smtpSender,
 	}, nil
 }
 
 func (a *App) Run(ctx context.Context) error {
+	// Start inbound event consumers, before anything runs that would need stopping
+	if err := a.consumer.Start(); err != nil {
+		return fmt.Errorf("consumer start: %w", err)
+	}
+
 	// Share one ctx (error group)
 	g, ctx := errgroup.WithContext(ctx)
 
@@ -173,6 +486,41 @@ func (a *App) Run(ctx context.Context) error {
 		return a.grpc.Run(ctx)
 	})
 
+	// Start the SCIM server
+	if a.scim != nil {
+		g.Go(func() error {
+			a.log.Info("starting SCIM", "addr", a.cfg.Scim.Addr)
+			return a.scim.Run(ctx)
+		})
+	}
+
+	// Start outbox relay to NATS
+	g.Go(func() error {
+		a.log.Info("starting outbox relay")
+		return a.relay.Run(ctx)
+	})
+
+	// Start webhook deliveries
+	g.Go(func() error {
+		a.log.Info("starting webhook dispatcher")
+		return a.dispatcher.Run(ctx)
+	})
+
+	// Start account erasure job
+	g.Go(func() error {
+		return a.runErasure(ctx)
+	})
+
+	// Start data export worker
+	g.Go(func() error {
+		return a.runExports(ctx)
+	})
+
+	// Start bulk import worker
+	g.Go(func() error {
+		return a.runImports(ctx)
+	})
+
 	// Start Mongo health check
 	g.Go(func() error {
 		return healthLoop(ctx, a.mongo.HealthCheck, a.cfg.Mongo.SocketTimeout)
@@ -198,12 +546,30 @@ func (a *App) Shutdown(ctx context.Context) error {
 	a.log.Info("Gracefully stoping gRPC server")
 	a.grpc.Stop()
 
+	if a.scim != nil {
+		a.log.Info("Stopping SCIM server")
+		a.scim.Stop()
+	}
+
 	a.log.Info("Closing gRPC client connection to AuthService")
 	if err := a.authConn.Close(); err != nil {
 		a.log.Error("Failed to close authConn", "err", err)
 		shutdownErr = errors.Join(shutdownErr, err)
 	}
 
+	if a.smtpSender != nil {
+		a.log.Info("Closing pooled SMTP conns")
+		if err := a.smtpSender.Close(); err != nil {
+			a.log.Error("Failed to close SMTP conns", "err", err)
+		}
+	}
+
+	a.log.Info("Draining inbound consumers")
+	if err := a.consumer.Drain(ctx); err != nil {
+		a.log.Error("Failed to drain consumers", "err", err)
+		shutdownErr = errors.Join(shutdownErr, err)
+	}
+
 	a.log.Info("Disconnecting from NATS server")
 	a.nats.Disconnect()
 
@@ -219,6 +585,113 @@ func (a *App) Shutdown(ctx context.Context) error {
 	return shutdownErr
 }
 
+// Pick the email transport from config
+func newEmailSender(cfg *config.Config, log *logger.Logger) (domain.EmailSender, *gomailpkg.Sender, error) {
+	switch cfg.Email.Transport {
+	case "smtp", "":
+		smtpSender, err := gomailpkg.New(gomailpkg.Config(cfg.Gomail))
+		if err != nil {
+			return nil, nil, err
+		}
+		return gomail.NewGomailService(smtpSender), smtpSender, nil
+	case "file":
+		fileSender, err := email.NewFileSender(cfg.Email.FileDir, cfg.Gomail.From)
+		if err != nil {
+			return nil, nil, err
+		}
+		return fileSender, nil, nil
+	case "log":
+		return email.NewLogSender(log, cfg.Email.LogBody), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown email transport: %q", cfg.Email.Transport)
+	}
+}
+
+func newSMSSender(cfg *config.Config, log *logger.Logger) (domain.SMSSender, error) {
+	switch cfg.SMS.Transport {
+	case "log", "":
+		return sms.NewLogSender(log), nil
+	case "http":
+		if cfg.SMS.HTTPURL == "" {
+			return nil, fmt.Errorf("SMS_HTTP_URL is required for the http transport")
+		}
+		return sms.NewHTTPSender(&http.Client{Timeout: cfg.SMS.Timeout}, cfg.SMS.HTTPURL, cfg.SMS.HTTPToken, cfg.SMS.From), nil
+	default:
+		return nil, fmt.Errorf("unknown sms transport: %q", cfg.SMS.Transport)
+	}
+}
+
+// Anonymise deleted accounts once their grace period is over
+func (a *App) runErasure(ctx context.Context) error {
+	ticker := time.NewTicker(a.cfg.Erasure.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// Drain the backlog batch by batch
+			for ctx.Err() == nil {
+				n, err := a.users.EraseDueAccounts(ctx, a.cfg.Erasure.BatchSize)
+				if err != nil {
+					a.log.Error("account erasure failed", "err", err)
+					break
+				}
+				if n > 0 {
+					a.log.Info("erased deleted accounts", "count", n)
+				}
+				if n < a.cfg.Erasure.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// Build pending data exports and drop expired bundles
+func (a *App) runExports(ctx context.Context) error {
+	ticker := time.NewTicker(a.cfg.Export.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if n, err := a.exports.ProcessPendingExports(ctx); err != nil {
+				a.log.Error("data export worker failed", "err", err)
+			} else if n > 0 {
+				a.log.Info("built data exports", "count", n)
+			}
+			if _, err := a.exports.PurgeExpiredExports(ctx); err != nil {
+				a.log.Error("data export purge failed", "err", err)
+			}
+		}
+	}
+}
+
+func (a *App) runImports(ctx context.Context) error {
+	ticker := time.NewTicker(a.cfg.Import.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if n, err := a.imports.ProcessPendingImports(ctx); err != nil {
+				a.log.Error("import worker failed", "err", err)
+			} else if n > 0 {
+				a.log.Info("finished imports", "count", n)
+			}
+			if _, err := a.imports.PurgeExpiredImports(ctx); err != nil {
+				a.log.Error("import purge failed", "err", err)
+			}
+		}
+	}
+}
+
 func healthLoop(ctx context.Context, hc func(context.Context, time.Duration) error, timeout time.Duration) error {
 	ticker := time.NewTicker(time.Second * 3)
 	defer ticker.Stop()
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/Neroframe/AuthService/config"
+	"github.com/Neroframe/AuthService/internal/adapters/authz"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/usecase"
+	"github.com/Neroframe/AuthService/pkg/policy"
+)
+
+const svc = "/auth.AuthService/"
+
+// Built-in permissions, seeded on startup. Methods missing here are open to any signed-in user.
+func defaultPermissions() []*domain.Permission {
+	return []*domain.Permission{
+		{Name: "authz.check", Description: "Check decisions for other users with CheckPermission", Methods: []string{}},
+		{Name: "users.read", Description: "Read user profiles", Methods: []string{svc + "GetUserByID"}},
+		{Name: "users.update", Description: "Update user profiles", Methods: []string{svc + "UpdateUserProfile"}},
+		{Name: "password.change", Description: "Change own password", Methods: []string{svc + "ChangePassword"}},
+		{Name: "students.create", Description: "Create username-only student accounts", Methods: []string{svc + "CreateStudentAccount"}},
+		{Name: "audit.read", Description: "Read the audit log", Methods: []string{svc + "ListAuditEvents"}},
+		{Name: "users.manage", Description: "List, delete, re-role, verify and block users", Methods: []string{
+			svc + "ListUsers",
+			svc + "DeleteUser",
+			svc + "SetUserRole",
+			svc + "SetUserVerified",
+			svc + "SetAccountStatus",
+		}},
+		{Name: "invitations.send", Description: "Invite users by email, teachers only students", Methods: []string{
+			svc + "CreateInvitation",
+			svc + "RevokeInvitation",
+		}},
+		{Name: "users.import", Description: "Bulk create and update users and class enrollments from CSV or OneRoster files", Methods: []string{
+			svc + "UploadImport",
+			svc + "StartImport",
+			svc + "GetImport",
+			svc + "ListImportResults",
+			svc + "ResumeImport",
+		}},
+		{Name: "scim.manage", Description: "Create and revoke the tokens identity providers provision users and groups with over SCIM", Methods: []string{
+			svc + "CreateScimClient",
+			svc + "ListScimClients",
+			svc + "RevokeScimClient",
+		}},
+		{Name: "exports.manage", Description: "Export any user's data", Methods: []string{svc + "ExportUserData"}},
+		{Name: "webhooks.manage", Description: "Manage webhook endpoints and deliveries", Methods: []string{
+			svc + "CreateWebhook",
+			svc + "ListWebhooks",
+			svc + "UpdateWebhook",
+			svc + "DeleteWebhook",
+			svc + "ListWebhookDeliveries",
+			svc + "ReplayWebhookDelivery",
+		}},
+		{Name: "relations.manage", Description: "Write course relationship tuples", Methods: []string{svc + "WriteRelations"}},
+		{Name: "groups.manage", Description: "Create classes and cohorts and change their rosters", Methods: []string{
+			svc + "CreateGroup",
+			svc + "AddGroupMembers",
+			svc + "RemoveGroupMembers",
+		}},
+		{Name: "tenants.manage", Description: "Manage tenants, outside the default tenant only the caller's own", Methods: []string{
+			svc + "CreateTenant",
+			svc + "GetTenant",
+			svc + "ListTenants",
+			svc + "UpdateTenant",
+		}},
+		{Name: usecase.PermRBACManage, Description: "Manage roles and permissions", Methods: []string{
+			svc + "ListRoles",
+			svc + "CreateRole",
+			svc + "UpdateRole",
+			svc + "DeleteRole",
+			svc + "GrantPermission",
+			svc + "RevokePermission",
+			svc + "ListPermissions",
+			svc + "CreatePermission",
+			svc + "DeletePermission",
+		}},
+	}
+}
+
+// Built-in roles, only inserted when missing. Admin gets every built-in permission on seed.
+func defaultRoles() []*domain.RoleDefinition {
+	return []*domain.RoleDefinition{
+		{Name: domain.ADMIN, Description: "Full access", Permissions: []string{}},
+		{Name: domain.TEACHER, Description: "Teachers", Permissions: []string{"users.read", "users.update", "password.change", "students.create", "invitations.send"}},
+		{Name: domain.STUDENT, Description: "Students", Permissions: []string{"users.read", "password.change"}},
+	}
+}
+
+// Policy file from POLICY_FILE or the built-in one. The cases shipped
+// with the policy must pass, a broken policy stops the service from starting.
+func loadPolicy(cfg config.Policy) (*policy.Engine, error) {
+	var (
+		engine *policy.Engine
+		err    error
+	)
+	if cfg.File != "" {
+		engine, err = policy.LoadFile(cfg.File)
+	} else {
+		engine, err = policy.Load(config.DefaultPolicy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.DecisionLog {
+	case authz.LogAll, authz.LogDeny, authz.LogOff:
+	default:
+		return nil, fmt.Errorf("unknown POLICY_DECISION_LOG %q", cfg.DecisionLog)
+	}
+
+	if err := engine.Verify(); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
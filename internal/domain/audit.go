@@ -0,0 +1,72 @@
+package domain
+
+import "time"
+
+type AuditAction string
+
+const (
+	AuditLogin          AuditAction = "login"
+	AuditLogout         AuditAction = "logout"
+	AuditRegister       AuditAction = "register"
+	AuditPasswordChange AuditAction = "password_change"
+	AuditPasswordReset  AuditAction = "password_reset"
+	AuditEmailVerify    AuditAction = "email_verify"
+	AuditProfileUpdate  AuditAction = "profile_update"
+	AuditRoleChange     AuditAction = "role_change"
+	AuditAccountDisable AuditAction = "account_disable"
+	AuditSessionsRevoke AuditAction = "sessions_revoke"
+	AuditAuditLogViewed AuditAction = "audit_log_view"
+	AuditWebhookManage  AuditAction = "webhook_manage"
+	AuditUserDelete     AuditAction = "user_delete"
+	AuditVerifiedSet    AuditAction = "verified_set"
+	AuditStatusChange   AuditAction = "status_change"
+	AuditAccountDelete  AuditAction = "account_delete"
+	AuditAccountRestore AuditAction = "account_restore"
+	AuditAccountErase   AuditAction = "account_erase"
+	AuditDataExport     AuditAction = "data_export"
+	AuditEmailChange    AuditAction = "email_change"
+	AuditPhoneVerify    AuditAction = "phone_verify"
+	AuditRBACChange     AuditAction = "rbac_change"
+	AuditRelationChange AuditAction = "relation_change"
+	AuditTenantChange   AuditAction = "tenant_change"
+	AuditGroupChange    AuditAction = "group_change"
+	AuditInvitation     AuditAction = "invitation"
+	AuditUserImport     AuditAction = "user_import"
+	AuditScimClient     AuditAction = "scim_client"
+	AuditScimProvision  AuditAction = "scim_provision"
+)
+
+type AuditOutcome string
+
+const (
+	OutcomeSuccess AuditOutcome = "success"
+	OutcomeFailure AuditOutcome = "failure"
+)
+
+// Immutable audit trail entry
+type AuditEvent struct {
+	ID        string            `bson:"_id"`
+	TenantID  string            `bson:"tenant_id"` // tenant of the request, empty for system actions
+	Action    AuditAction       `bson:"action"`
+	ActorID   string            `bson:"actor_id,omitempty"` // empty for anonymous or system actions
+	ActorRole Role              `bson:"actor_role,omitempty"`
+	TargetID  string            `bson:"target_id,omitempty"`
+	IP        string            `bson:"ip,omitempty"`
+	UserAgent string            `bson:"user_agent,omitempty"`
+	RequestID string            `bson:"request_id,omitempty"`
+	Outcome   AuditOutcome      `bson:"outcome"`
+	Details   map[string]string `bson:"details,omitempty"`
+	CreatedAt time.Time         `bson:"created_at"`
+}
+
+type AuditFilter struct {
+	UserID   string // actor or target
+	ActorID  string
+	TargetID string
+	Action   AuditAction
+	Outcome  AuditOutcome
+	From     time.Time // inclusive, zero means unbounded
+	To       time.Time // exclusive, zero means unbounded
+	Cursor   string    // opaque, from the previous page
+	Limit    int
+}
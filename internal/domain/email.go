@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var ErrInvalidEmail = errors.New("invalid email")
+
+// Providers that ignore parts of the local part, only applied with FoldAliases
+var aliasRules = map[string]struct {
+	canonical string // domain the key uses
+	dropDots  bool
+	tagSep    byte
+}{
+	"gmail.com":      {canonical: "gmail.com", dropDots: true, tagSep: '+'},
+	"googlemail.com": {canonical: "gmail.com", dropDots: true, tagSep: '+'},
+	"outlook.com":    {canonical: "outlook.com", tagSep: '+'},
+	"hotmail.com":    {canonical: "hotmail.com", tagSep: '+'},
+	"live.com":       {canonical: "live.com", tagSep: '+'},
+	"icloud.com":     {canonical: "icloud.com", tagSep: '+'},
+	"me.com":         {canonical: "icloud.com", tagSep: '+'},
+	"fastmail.com":   {canonical: "fastmail.com", tagSep: '+'},
+	"proton.me":      {canonical: "proton.me", tagSep: '+'},
+	"protonmail.com": {canonical: "proton.me", tagSep: '+'},
+	"yahoo.com":      {canonical: "yahoo.com", tagSep: '-'},
+}
+
+// Turns user input into the stored address and the key accounts are matched by
+type EmailNormalizer struct {
+	FoldAliases bool // e.g. j.doe+school@gmail.com matches jdoe@gmail.com
+}
+
+// Stored address: trimmed, domain lowercased and punycoded.
+// Key: the address lowercased, aliases folded when enabled.
+func (n EmailNormalizer) Normalize(raw string) (email, key string, err error) {
+	raw = strings.TrimSpace(raw)
+	at := strings.LastIndexByte(raw, '@')
+	if at <= 0 || at == len(raw)-1 {
+		return "", "", ErrInvalidEmail
+	}
+	local, host := raw[:at], raw[at+1:]
+	if strings.ContainsAny(local, " \t\r\n") {
+		return "", "", ErrInvalidEmail
+	}
+
+	host, err = idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil || !strings.Contains(host, ".") {
+		return "", "", ErrInvalidEmail
+	}
+	email = local + "@" + host
+
+	keyLocal, keyHost := strings.ToLower(local), host
+	if rule, ok := aliasRules[host]; ok && n.FoldAliases {
+		if i := strings.IndexByte(keyLocal, rule.tagSep); i > 0 {
+			keyLocal = keyLocal[:i]
+		}
+		if rule.dropDots {
+			keyLocal = strings.ReplaceAll(keyLocal, ".", "")
+		}
+		keyHost = rule.canonical
+	}
+
+	return email, keyLocal + "@" + keyHost, nil
+}
+
+// Outcome of backfilling email keys for users created before them
+type EmailKeyReport struct {
+	Scanned    int
+	Keyed      int
+	Invalid    []string            // user IDs whose email can't be normalised
+	Duplicates map[string][]string // key -> user IDs left without it, resolve by hand
+	Owners     map[string]string   // key -> user ID that kept it, for duplicate keys only
+}
+
+// Key only, for lookups
+func (n EmailNormalizer) Key(raw string) (string, error) {
+	_, key, err := n.Normalize(raw)
+	return key, err
+}
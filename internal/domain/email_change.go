@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNoPendingEmailChange    = errors.New("no pending email change")
+	ErrEmailUnchanged          = errors.New("email unchanged")
+	ErrEmailChangeNeedsConfirm = errors.New("email can only be changed with RequestEmailChange")
+	ErrUndoTokenInvalid        = errors.New("invalid or expired undo token")
+)
+
+// Requested change, the new address is applied once the code is confirmed
+type EmailChange struct {
+	UserID    string    `json:"user_id"`
+	OldEmail  string    `json:"old_email"`
+	NewEmail  string    `json:"new_email"`
+	Code      string    `json:"code"`
+	Attempts  int       `json:"attempts"`  // wrong codes so far
+	UndoHash  string    `json:"undo_hash"` // undo token sent to the old address
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Lets the old address cancel the request or revert the change
+type EmailChangeUndo struct {
+	UserID    string     `json:"user_id"`
+	TenantID  string     `json:"tenant_id"` // the link works without a tenant header
+	OldEmail  string     `json:"old_email"`
+	NewEmail  string     `json:"new_email"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"` // nil while still pending
+}
+
+type EmailChangeStore interface {
+	SavePending(ctx context.Context, c *EmailChange, ttl time.Duration) error
+	GetPending(ctx context.Context, userID string) (*EmailChange, error) // ErrNoPendingEmailChange if none
+	DeletePending(ctx context.Context, userID string) error
+
+	SaveUndo(ctx context.Context, tokenHash string, u *EmailChangeUndo, ttl time.Duration) error
+	GetUndo(ctx context.Context, tokenHash string) (*EmailChangeUndo, error) // ErrUndoTokenInvalid if none
+	DeleteUndo(ctx context.Context, tokenHash string) error
+
+	// Pending change and undo tokens of the user, on erasure
+	DeleteUser(ctx context.Context, userID string) error
+}
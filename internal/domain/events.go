@@ -5,34 +5,167 @@ import (
 	"time"
 )
 
+// Bumped on breaking changes to any event payload, matches auth.events.vN in proto/events.proto
+const EventSchemaVersion = 1
+
+type EventType string
+
+const (
+	EventUserRegistered      EventType = "user.registered"
+	EventUserLoggedIn        EventType = "user.logged_in"
+	EventUserLoggedOut       EventType = "user.logged_out"
+	EventUserProfileUpdated  EventType = "user.profile_updated"
+	EventUserEmailVerified   EventType = "user.email_verified"
+	EventUserRoleChanged     EventType = "user.role_changed"
+	EventPasswordChanged     EventType = "user.password_changed"
+	EventPasswordReset       EventType = "user.password_reset"
+	EventUserDeleted         EventType = "user.deleted"
+	EventUserVerifiedSet     EventType = "user.verified_set"
+	EventUserRestored        EventType = "user.restored"
+	EventUserErased          EventType = "user.erased"
+	EventUserEmailChanged    EventType = "user.email_changed"
+	EventUserPhoneVerified   EventType = "user.phone_verified"
+	EventUserStatusChanged   EventType = "user.status_changed"
+	EventGroupMembersAdded   EventType = "user.group_members_added"
+	EventGroupMembersRemoved EventType = "user.group_members_removed"
+)
+
 type UserRegisteredEvent struct {
 	UserID    string    `json:"user_id"`
-	Email     string    `json:"email"`
+	Email     string    `json:"email"` // empty for username-only accounts
+	Username  string    `json:"username,omitempty"`
 	Role      Role      `json:"role"`
-	CreatedAt time.Time `json:"created_at"` // UTC
+	CreatedBy string    `json:"created_by,omitempty"` // teacher or admin who created the account
+	CreatedAt time.Time `json:"created_at"`           // UTC
 }
 
 type UserLoggedInEvent struct {
-	UserID     string
-	IP         string
-	UserAgent  string
-	Created_at time.Time
+	UserID    string    `json:"user_id"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"` // UTC
 }
 
-type UserDeletedEvent struct {
-	UserID     string
-	Created_at time.Time
+type UserLoggedOutEvent struct {
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"` // UTC
+}
+
+type UserProfileUpdatedEvent struct {
+	UserID    string    `json:"user_id"`
+	UpdatedBy string    `json:"updated_by"`
+	Fields    []string  `json:"fields"`
+	CreatedAt time.Time `json:"created_at"` // UTC
+}
+
+type UserEmailVerifiedEvent struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"` // UTC
+}
+
+type UserRoleChangedEvent struct {
+	UserID    string    `json:"user_id"`
+	OldRole   Role      `json:"old_role"`
+	NewRole   Role      `json:"new_role"`
+	ChangedBy string    `json:"changed_by"`
+	CreatedAt time.Time `json:"created_at"` // UTC
 }
 
 type PasswordChangedEvent struct {
-	UserID     string
-	Created_at time.Time
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"` // UTC
+}
+
+type PasswordResetEvent struct {
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"` // UTC
+}
+
+// Soft delete, the account can be restored until PurgeAt
+type UserDeletedEvent struct {
+	UserID    string    `json:"user_id"`
+	DeletedBy string    `json:"deleted_by"`
+	PurgeAt   time.Time `json:"purge_at"`   // UTC
+	CreatedAt time.Time `json:"created_at"` // UTC
+}
+
+type UserRestoredEvent struct {
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"` // UTC
+}
+
+// Personal data is gone, consumers must erase their copies
+type UserErasedEvent struct {
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"` // UTC
+}
+
+// Admin changed the verified flag
+type UserVerifiedSetEvent struct {
+	UserID    string    `json:"user_id"`
+	Verified  bool      `json:"verified"`
+	ChangedBy string    `json:"changed_by"`
+	CreatedAt time.Time `json:"created_at"` // UTC
+}
+
+type UserEmailChangedEvent struct {
+	UserID    string    `json:"user_id"`
+	OldEmail  string    `json:"old_email"`
+	NewEmail  string    `json:"new_email"`
+	Reverted  bool      `json:"reverted"`   // undone from the old address
+	CreatedAt time.Time `json:"created_at"` // UTC
+}
+
+type UserPhoneVerifiedEvent struct {
+	UserID    string    `json:"user_id"`
+	Phone     string    `json:"phone"`      // E.164
+	CreatedAt time.Time `json:"created_at"` // UTC
+}
+
+// ChangedBy is empty when an event from another service changed the status
+type UserStatusChangedEvent struct {
+	UserID    string     `json:"user_id"`
+	Status    UserStatus `json:"status"`
+	Reason    string     `json:"reason,omitempty"`
+	Until     *time.Time `json:"until,omitempty"` // end of a temporary suspension, UTC
+	ChangedBy string     `json:"changed_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"` // UTC
+}
+
+// Relation is member or owner, users already in the group are included again
+type GroupMembersAddedEvent struct {
+	GroupID   string    `json:"group_id"`
+	Relation  string    `json:"relation"`
+	UserIDs   []string  `json:"user_ids"`
+	ChangedBy string    `json:"changed_by"`
+	CreatedAt time.Time `json:"created_at"` // UTC
+}
+
+type GroupMembersRemovedEvent struct {
+	GroupID   string    `json:"group_id"`
+	Relation  string    `json:"relation"`
+	UserIDs   []string  `json:"user_ids"`
+	ChangedBy string    `json:"changed_by"`
+	CreatedAt time.Time `json:"created_at"` // UTC
 }
 
 type UserEventPublisher interface {
 	PublishUserRegistered(ctx context.Context, e *UserRegisteredEvent) error
-	// PublishUserLoggedIn(ctx context.Context, e *UserLoggedInEvent) error
-
-	// PublishPasswordChanged(ctx context.Context, e *PasswordChangedEvent) error
-	// PublishUserDeleted(ctx context.Context, e *UserDeletedEvent) error
+	PublishUserLoggedIn(ctx context.Context, e *UserLoggedInEvent) error
+	PublishUserLoggedOut(ctx context.Context, e *UserLoggedOutEvent) error
+	PublishUserProfileUpdated(ctx context.Context, e *UserProfileUpdatedEvent) error
+	PublishUserEmailVerified(ctx context.Context, e *UserEmailVerifiedEvent) error
+	PublishUserRoleChanged(ctx context.Context, e *UserRoleChangedEvent) error
+	PublishPasswordChanged(ctx context.Context, e *PasswordChangedEvent) error
+	PublishPasswordReset(ctx context.Context, e *PasswordResetEvent) error
+	PublishUserDeleted(ctx context.Context, e *UserDeletedEvent) error
+	PublishUserVerifiedSet(ctx context.Context, e *UserVerifiedSetEvent) error
+	PublishUserRestored(ctx context.Context, e *UserRestoredEvent) error
+	PublishUserErased(ctx context.Context, e *UserErasedEvent) error
+	PublishUserEmailChanged(ctx context.Context, e *UserEmailChangedEvent) error
+	PublishUserPhoneVerified(ctx context.Context, e *UserPhoneVerifiedEvent) error
+	PublishUserStatusChanged(ctx context.Context, e *UserStatusChangedEvent) error
+	PublishGroupMembersAdded(ctx context.Context, e *GroupMembersAddedEvent) error
+	PublishGroupMembersRemoved(ctx context.Context, e *GroupMembersRemovedEvent) error
 }
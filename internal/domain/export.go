@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrExportNotFound    = errors.New("data export not found")
+	ErrExportNotReady    = errors.New("data export not ready")
+	ErrInvalidExportType = errors.New("invalid export format")
+)
+
+type ExportFormat string
+
+const (
+	ExportJSON ExportFormat = "json"
+	ExportZIP  ExportFormat = "zip" // one JSON file per section
+)
+
+func (f ExportFormat) Valid() bool {
+	return f == ExportJSON || f == ExportZIP
+}
+
+type ExportStatus string
+
+const (
+	ExportPending ExportStatus = "pending"
+	ExportReady   ExportStatus = "ready"
+	ExportFailed  ExportStatus = "failed"
+)
+
+// Background export job, the bundle is stored separately
+type DataExport struct {
+	ID          string       `bson:"_id"`
+	TenantID    string       `bson:"tenant_id"`
+	UserID      string       `bson:"user_id"`
+	RequestedBy string       `bson:"requested_by"`
+	Format      ExportFormat `bson:"format"`
+	Status      ExportStatus `bson:"status"`
+	Error       string       `bson:"error,omitempty"`
+	TokenHash   string       `bson:"token_hash"` // sha256 of the download token
+	Size        int64        `bson:"size,omitempty"`
+	Attempts    int          `bson:"attempts"`
+	LockedUntil time.Time    `bson:"locked_until"`
+	CreatedAt   time.Time    `bson:"created_at"`
+	CompletedAt *time.Time   `bson:"completed_at,omitempty"`
+	ExpiresAt   time.Time    `bson:"expires_at"` // bundle deleted after
+}
+
+// Everything held about a user (right of access)
+type UserDataBundle struct {
+	GeneratedAt  time.Time        `json:"generated_at"`
+	Profile      ExportProfile    `json:"profile"`
+	Sessions     ExportSessions   `json:"sessions"`
+	LoginHistory []ExportAuditRow `json:"login_history"`
+	AuditLog     []ExportAuditRow `json:"audit_log"` // actions by or about the user
+}
+
+// User without the password hash
+type ExportProfile struct {
+	ID           string     `json:"id"`
+	Email        string     `json:"email"`
+	Username     string     `json:"username,omitempty"`
+	Phone        string     `json:"phone,omitempty"`
+	Role         Role       `json:"role"`
+	Verified     bool       `json:"verified"`
+	Status       UserStatus `json:"status"`
+	StatusReason string     `json:"status_reason,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Access tokens are stateless, sessions are the logins whose tokens may still be valid
+type ExportSessions struct {
+	RevokedBefore *time.Time       `json:"revoked_before,omitempty"`
+	Active        []ExportAuditRow `json:"active"`
+}
+
+type ExportAuditRow struct {
+	Action    AuditAction       `json:"action"`
+	ActorID   string            `json:"actor_id,omitempty"`
+	TargetID  string            `json:"target_id,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	Outcome   AuditOutcome      `json:"outcome"`
+	Details   map[string]string `json:"details,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Small exports are returned inline, large ones run as a job
+type ExportResult struct {
+	Bundle        []byte      // inline bundle, nil when a job was started
+	Export        *DataExport // started job
+	DownloadToken string      // for the job's bundle, only returned here
+}
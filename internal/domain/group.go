@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrGroupNotFound  = errors.New("group not found")
+	ErrInvalidGroup   = errors.New("invalid group")
+	ErrTooManyMembers = errors.New("too many users in one request")
+)
+
+// Owners and members are relation tuples, "group:7a#member@user:x", so a
+// course can take a whole class with "course:101#student@group:7a#member"
+const (
+	ObjectGroup    = "group"
+	RelationOwner  = "owner"
+	RelationMember = "member"
+)
+
+type GroupKind string
+
+const (
+	GroupClass  GroupKind = "class"
+	GroupCohort GroupKind = "cohort"
+)
+
+func (k GroupKind) Valid() bool {
+	return k == GroupClass || k == GroupCohort
+}
+
+// A class or cohort of a tenant
+type Group struct {
+	ID        string    `bson:"_id"`
+	TenantID  string    `bson:"tenant_id"`
+	Name      string    `bson:"name"`
+	Kind      GroupKind `bson:"kind"`
+	SourcedID string    `bson:"sourced_id,omitempty"` // OneRoster classSourcedId or SCIM externalId
+	CreatedBy string    `bson:"created_by"`
+	CreatedAt time.Time `bson:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// A user's place in a group, Relation is RelationOwner or RelationMember
+type GroupMembership struct {
+	Group    *Group
+	Relation string
+}
+
+// A group's owner or member, User is nil when the account is gone
+type GroupMember struct {
+	UserID   string
+	Relation string
+	User     *User
+}
+
+// Outcome of a bulk add or remove
+type GroupMembersResult struct {
+	Changed  []string // added or removed
+	NotFound []string // no such user in the tenant
+}
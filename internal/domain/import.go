@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrImportNotFound     = errors.New("import not found")
+	ErrInvalidImport      = errors.New("invalid import options")
+	ErrImportFileTooLarge = errors.New("import file too large")
+	ErrImportPathsOff     = errors.New("imports from server paths are not enabled")
+	ErrImportNotResumable = errors.New("only failed imports can be resumed")
+	ErrExternalIDExists   = errors.New("external id already used")
+)
+
+type ImportFormat string
+
+const (
+	ImportCSV       ImportFormat = "csv"       // one users file
+	ImportOneRoster ImportFormat = "oneroster" // users.csv and optionally enrollments.csv
+)
+
+func (f ImportFormat) Valid() bool {
+	return f == ImportCSV || f == ImportOneRoster
+}
+
+// How new accounts get their first password. A password column in the file
+// always wins.
+type ImportCredentials string
+
+const (
+	CredentialsInvite   ImportCredentials = "invite"   // emailed invitation, rows need an email
+	CredentialsPassword ImportCredentials = "password" // generated, returned with the row results
+	CredentialsNone     ImportCredentials = "none"     // SSO or ResetPassword
+)
+
+func (c ImportCredentials) Valid() bool {
+	return c == CredentialsInvite || c == CredentialsPassword || c == CredentialsNone
+}
+
+type ImportStatus string
+
+const (
+	ImportUploading ImportStatus = "uploading"
+	ImportPending   ImportStatus = "pending" // queued or running
+	ImportDone      ImportStatus = "done"
+	ImportFailed    ImportStatus = "failed"
+)
+
+type ImportOptions struct {
+	Format      ImportFormat
+	Credentials ImportCredentials
+	DefaultRole Role // for rows without a role, OneRoster 1.2 users.csv has none
+	DryRun      bool // validate and report, nothing is written
+}
+
+// One file of a job, Rows is where processing resumes
+type ImportFile struct {
+	Name string `bson:"name"`           // users.csv or enrollments.csv
+	Path string `bson:"path,omitempty"` // under the import dir, empty for uploads
+	Rows int    `bson:"rows"`           // data rows committed
+	Done bool   `bson:"done"`
+}
+
+type ImportCounts struct {
+	Created   int `bson:"created"`
+	Updated   int `bson:"updated"`
+	Unchanged int `bson:"unchanged"`
+	Removed   int `bson:"removed"`
+	Skipped   int `bson:"skipped"`
+	Invalid   int `bson:"invalid"`
+	Failed    int `bson:"failed"`
+}
+
+// Background import job, uploaded files are stored separately
+type ImportJob struct {
+	ID          string            `bson:"_id"`
+	TenantID    string            `bson:"tenant_id"`
+	CreatedBy   string            `bson:"created_by"`
+	Format      ImportFormat      `bson:"format"`
+	Credentials ImportCredentials `bson:"credentials"`
+	DefaultRole Role              `bson:"default_role"`
+	DryRun      bool              `bson:"dry_run"`
+	Files       []ImportFile      `bson:"files"`
+	Status      ImportStatus      `bson:"status"`
+	Error       string            `bson:"error,omitempty"`
+	Counts      ImportCounts      `bson:"counts"`
+	Attempts    int               `bson:"attempts"`
+	LockedUntil time.Time         `bson:"locked_until"`
+	CreatedAt   time.Time         `bson:"created_at"`
+	UpdatedAt   time.Time         `bson:"updated_at"`
+	CompletedAt *time.Time        `bson:"completed_at,omitempty"`
+	ExpiresAt   time.Time         `bson:"expires_at"` // job, files and row results deleted after
+}
+
+type ImportOutcome string
+
+const (
+	RowCreated   ImportOutcome = "created" // account created or enrollment written
+	RowUpdated   ImportOutcome = "updated"
+	RowUnchanged ImportOutcome = "unchanged"
+	RowRemoved   ImportOutcome = "removed" // enrollment marked tobedeleted
+	RowSkipped   ImportOutcome = "skipped"
+	RowInvalid   ImportOutcome = "invalid"
+	RowFailed    ImportOutcome = "failed" // valid but couldn't be written
+)
+
+func (o ImportOutcome) Valid() bool {
+	switch o {
+	case RowCreated, RowUpdated, RowUnchanged, RowRemoved, RowSkipped, RowInvalid, RowFailed:
+		return true
+	}
+	return false
+}
+
+// Result of one row, kept until the job expires
+type ImportRow struct {
+	ID         string        `bson:"_id"` // job/file/line, a retried row overwrites its result
+	JobID      string        `bson:"job_id"`
+	TenantID   string        `bson:"tenant_id"`
+	File       string        `bson:"file"`
+	Line       int           `bson:"line"`
+	ExternalID string        `bson:"external_id,omitempty"`
+	Email      string        `bson:"email,omitempty"`
+	Username   string        `bson:"username,omitempty"`
+	UserID     string        `bson:"user_id,omitempty"`
+	GroupID    string        `bson:"group_id,omitempty"` // enrollments
+	Outcome    ImportOutcome `bson:"outcome"`
+	Fields     []string      `bson:"fields,omitempty"` // changed by an update
+	Error      string        `bson:"error,omitempty"`
+	Password   string        `bson:"password,omitempty"` // generated, CredentialsPassword only, removed by the first read
+	// Created with CredentialsInvite, cleared once the invitation is sent
+	InvitePending bool      `bson:"invite_pending,omitempty"`
+	ExpiresAt     time.Time `bson:"expires_at"`
+}
+
+func (c *ImportCounts) Add(o ImportOutcome) {
+	switch o {
+	case RowCreated:
+		c.Created++
+	case RowUpdated:
+		c.Updated++
+	case RowUnchanged:
+		c.Unchanged++
+	case RowRemoved:
+		c.Removed++
+	case RowSkipped:
+		c.Skipped++
+	case RowInvalid:
+		c.Invalid++
+	case RowFailed:
+		c.Failed++
+	}
+}
+
+// Uploaded files one after another, Next returns io.EOF after the last
+type ImportUpload interface {
+	Next() (name string, data io.Reader, err error)
+}
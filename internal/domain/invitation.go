@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvitationInvalid = errors.New("invalid invitation code")
+	ErrInvitationExpired = errors.New("invitation expired")
+	ErrInvitationUsed    = errors.New("invitation already used or revoked")
+	ErrRegistrationOff   = errors.New("registration is by invitation only")
+	ErrRoleNotAllowed    = errors.New("role can only be given by invitation")
+)
+
+// Open registration, teachers and admins always join by invitation
+type RegistrationMode string
+
+const (
+	RegistrationStudent  RegistrationMode = "student"  // anyone can sign up as a student
+	RegistrationDisabled RegistrationMode = "disabled" // invitations only
+)
+
+func (m RegistrationMode) Valid() bool {
+	return m == RegistrationStudent || m == RegistrationDisabled
+}
+
+// Signup invite for one email, the role, tenant and group are fixed by the sender
+type Invitation struct {
+	ID         string     `bson:"_id"`
+	TenantID   string     `bson:"tenant_id"`
+	Email      string     `bson:"email"`
+	EmailKey   string     `bson:"email_key"`
+	Role       Role       `bson:"role"`
+	GroupID    string     `bson:"group_id,omitempty"` // joined as a member on accept
+	UserID     string     `bson:"user_id,omitempty"`  // provisioned account, accept only sets its password
+	InvitedBy  string     `bson:"invited_by"`
+	ExpiresAt  time.Time  `bson:"expires_at"`
+	AcceptedAt *time.Time `bson:"accepted_at,omitempty"`
+	AcceptedBy string     `bson:"accepted_by,omitempty"` // user created by the invite
+	RevokedAt  *time.Time `bson:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `bson:"created_at"`
+}
+
+// Error to return when the invite can't be accepted at the given time
+func (i *Invitation) UsableErr(now time.Time) error {
+	switch {
+	case i.AcceptedAt != nil || i.RevokedAt != nil:
+		return ErrInvitationUsed
+	case !now.Before(i.ExpiresAt):
+		return ErrInvitationExpired
+	}
+	return nil
+}
+
+// Invite codes carry the invitation and its tenant so the link works without
+// a tenant header. The signature stops forged or edited codes before any lookup.
+type InviteSigner interface {
+	Sign(inviteID, tenantID string, expiresAt time.Time) string
+	Verify(code string) (inviteID, tenantID string, err error) // ErrInvitationInvalid or ErrInvitationExpired
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// Event waiting in the outbox to be relayed to NATS
+type OutboxRecord struct {
+	ID            string            `bson:"_id"` // same as the CloudEvent ID
+	AggregateID   string            `bson:"aggregate_id"`
+	Subject       string            `bson:"subject"`
+	EventType     EventType         `bson:"event_type"`
+	Payload       []byte            `bson:"payload"` // encoded CloudEvent body
+	Headers       map[string]string `bson:"headers"`
+	Seq           int64             `bson:"seq"` // per aggregate, set by the outbox on Add
+	Attempts      int               `bson:"attempts"`
+	LastError     string            `bson:"last_error,omitempty"`
+	NextAttemptAt time.Time         `bson:"next_attempt_at"`
+	CreatedAt     time.Time         `bson:"created_at"`
+	SentAt        *time.Time        `bson:"sent_at"`
+	DeadAt        *time.Time        `bson:"dead_at,omitempty"` // gave up, left for an operator
+}
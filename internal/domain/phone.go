@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidPhone     = errors.New("invalid phone number")
+	ErrPhoneNotSet      = errors.New("phone number not set")
+	ErrPhoneNotVerified = errors.New("phone number not verified")
+)
+
+// Normalise a phone number to E.164 (+<country code><number>).
+// Spaces, dashes, dots and parentheses are dropped, a leading 00 is read as +.
+// Numbers without a country code get defaultCC, empty means they are rejected.
+func NormalizePhone(raw, defaultCC string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ', r == '-', r == '.', r == '(', r == ')':
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+	phone := b.String()
+
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(phone, "00"):
+		phone = "+" + phone[2:]
+	case defaultCC != "":
+		phone = "+" + strings.TrimPrefix(defaultCC, "+") + strings.TrimLeft(phone, "0")
+	default:
+		return "", ErrInvalidPhone
+	}
+
+	// E.164 allows at most 15 digits, country codes never start with 0
+	digits := phone[1:]
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+	return phone, nil
+}
+
+type SMSSender interface {
+	Send(to, message string) error // to is E.164
+}
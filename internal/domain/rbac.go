@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrRoleExists         = errors.New("role already exists")
+	ErrRoleInUse          = errors.New("role is assigned to users")
+	ErrBuiltinRole        = errors.New("built-in role can't be deleted")
+	ErrInvalidRoleName    = errors.New("invalid role name")
+	ErrPermNotFound       = errors.New("permission not found")
+	ErrPermExists         = errors.New("permission already exists")
+	ErrBuiltinPerm        = errors.New("built-in permission can't be changed")
+	ErrInvalidPermName    = errors.New("invalid permission name")
+	ErrInvalidPermMethods = errors.New("permission methods must be full gRPC method names")
+	ErrAdminLockout       = errors.New("admin role must keep rbac permissions")
+)
+
+// Grants access to a set of RPCs
+type Permission struct {
+	Name        string    `bson:"_id"` // e.g. "users.read"
+	Description string    `bson:"description"`
+	Methods     []string  `bson:"methods"` // full gRPC method names
+	Builtin     bool      `bson:"builtin"` // seeded on startup, methods kept in sync with the code
+	CreatedAt   time.Time `bson:"created_at"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+}
+
+// Role with its permission bindings, custom roles are created by admins
+type RoleDefinition struct {
+	Name        Role      `bson:"_id"`
+	Description string    `bson:"description"`
+	Permissions []string  `bson:"permissions"`
+	Builtin     bool      `bson:"builtin"` // admin, teacher and student
+	CreatedAt   time.Time `bson:"created_at"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+}
+
+// Role and permission names: lowercase letters, digits, "_" and "."
+func validName(name string) bool {
+	if len(name) < 2 || len(name) > 64 {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func ValidRoleName(name Role) bool { return validName(string(name)) }
+
+func ValidPermissionName(name string) bool { return validName(name) }
+
+// Read-only snapshot of roles and permissions for access checks
+type AccessPolicy struct {
+	roles   map[Role]map[string]struct{} // role -> permissions
+	methods map[string][]string          // method -> permissions granting it
+}
+
+func NewAccessPolicy(roles []*RoleDefinition, perms []*Permission) *AccessPolicy {
+	p := &AccessPolicy{
+		roles:   make(map[Role]map[string]struct{}, len(roles)),
+		methods: make(map[string][]string),
+	}
+	for _, r := range roles {
+		set := make(map[string]struct{}, len(r.Permissions))
+		for _, name := range r.Permissions {
+			set[name] = struct{}{}
+		}
+		p.roles[r.Name] = set
+	}
+	for _, perm := range perms {
+		for _, m := range perm.Methods {
+			p.methods[m] = append(p.methods[m], perm.Name)
+		}
+	}
+	return p
+}
+
+func (p *AccessPolicy) RoleExists(role Role) bool {
+	_, ok := p.roles[role]
+	return ok
+}
+
+func (p *AccessPolicy) HasPermission(role Role, perm string) bool {
+	_, ok := p.roles[role][perm]
+	return ok
+}
+
+// Sorted permission names of a role, nil for unknown roles
+func (p *AccessPolicy) Permissions(role Role) []string {
+	set, ok := p.roles[role]
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Methods no permission mentions are open to every signed-in user
+func (p *AccessPolicy) AllowsMethod(role Role, method string) bool {
+	perms, guarded := p.methods[method]
+	if !guarded {
+		return true
+	}
+	for _, perm := range perms {
+		if p.HasPermission(role, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+type RoleCatalog interface {
+	RoleExists(ctx context.Context, role Role) (bool, error)
+	Permissions(ctx context.Context, role Role) ([]string, error)
+}
+
+// Tells other replicas to reload roles and permissions
+type RBACNotifier interface {
+	PublishRBACChanged(ctx context.Context) error
+}
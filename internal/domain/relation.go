@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidTuple   = errors.New("invalid relation tuple")
+	ErrEmptyRelations = errors.New("no relation tuples given")
+)
+
+// Object types and relations written by the course service
+const (
+	ObjectCourse    = "course"
+	ObjectUser      = "user"
+	RelationTeacher = "teacher"
+	RelationStudent = "student"
+)
+
+const maxTupleFieldLen = 128
+
+// Who a tuple points at: a user ("user:x") or everyone holding a relation
+// on another object ("course:101#student")
+type SubjectRef struct {
+	Type     string `bson:"type"`
+	ID       string `bson:"id"`
+	Relation string `bson:"relation,omitempty"`
+}
+
+func UserSubject(userID string) SubjectRef {
+	return SubjectRef{Type: ObjectUser, ID: userID}
+}
+
+func (s SubjectRef) String() string {
+	if s.Relation != "" {
+		return s.Type + ":" + s.ID + "#" + s.Relation
+	}
+	return s.Type + ":" + s.ID
+}
+
+func ParseSubject(raw string) (SubjectRef, error) {
+	var s SubjectRef
+	obj, rel, hasRel := strings.Cut(raw, "#")
+	typ, id, err := ParseObject(obj)
+	if err != nil {
+		return s, err
+	}
+	if hasRel && !validTupleName(rel) {
+		return s, ErrInvalidTuple
+	}
+	return SubjectRef{Type: typ, ID: id, Relation: rel}, nil
+}
+
+// Zanzibar style tuple, "course:101#teacher@user:x"
+type RelationTuple struct {
+	ObjectType string     `bson:"object_type"`
+	ObjectID   string     `bson:"object_id"`
+	Relation   string     `bson:"relation"`
+	Subject    SubjectRef `bson:"subject"`
+}
+
+func (t RelationTuple) String() string {
+	return t.ObjectType + ":" + t.ObjectID + "#" + t.Relation + "@" + t.Subject.String()
+}
+
+func ParseTuple(raw string) (RelationTuple, error) {
+	var t RelationTuple
+	left, subject, ok := strings.Cut(strings.TrimSpace(raw), "@")
+	if !ok {
+		return t, ErrInvalidTuple
+	}
+	obj, rel, ok := strings.Cut(left, "#")
+	if !ok || !validTupleName(rel) {
+		return t, ErrInvalidTuple
+	}
+	typ, id, err := ParseObject(obj)
+	if err != nil {
+		return t, err
+	}
+	sub, err := ParseSubject(subject)
+	if err != nil {
+		return t, err
+	}
+	return RelationTuple{ObjectType: typ, ObjectID: id, Relation: rel, Subject: sub}, nil
+}
+
+// "course:101" into type and id
+func ParseObject(raw string) (typ, id string, err error) {
+	typ, id, ok := strings.Cut(raw, ":")
+	if !ok || !validTupleName(typ) || id == "" || len(id) > maxTupleFieldLen || strings.ContainsAny(id, ":#@ ") {
+		return "", "", ErrInvalidTuple
+	}
+	return typ, id, nil
+}
+
+func validTupleName(s string) bool {
+	if s == "" || len(s) > maxTupleFieldLen {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// Writes are applied before deletes
+type RelationChange struct {
+	Writes  []RelationTuple
+	Deletes []RelationTuple
+}
+
+// From tuple strings, used by the API and the NATS consumer
+func ParseRelationChange(writes, deletes []string) (RelationChange, error) {
+	var c RelationChange
+	for _, raw := range writes {
+		t, err := ParseTuple(raw)
+		if err != nil {
+			return c, fmt.Errorf("%w: %q", err, raw)
+		}
+		c.Writes = append(c.Writes, t)
+	}
+	for _, raw := range deletes {
+		t, err := ParseTuple(raw)
+		if err != nil {
+			return c, fmt.Errorf("%w: %q", err, raw)
+		}
+		c.Deletes = append(c.Deletes, t)
+	}
+	return c, nil
+}
+
+// Used by authorization to turn the graph into policy attributes
+type RelationChecker interface {
+	// A course has the teacher as teacher and the student as student,
+	// or a group has the teacher as owner and the student as member
+	TeachesStudent(ctx context.Context, teacherID, studentID string) (bool, error)
+	// IDs of the groups the user is a member of
+	GroupsOf(ctx context.Context, userID string) ([]string, error)
+}
@@ -0,0 +1,8 @@
+package domain
+
+// Caller info of the current request, filled by the logging interceptor
+type RequestMeta struct {
+	RequestID string
+	IP        string
+	UserAgent string
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrScimClientNotFound = errors.New("scim client not found")
+	ErrInvalidScimClient  = errors.New("invalid scim client")
+	ErrScimUnauthorized   = errors.New("invalid scim token")
+	ErrVersionMismatch    = errors.New("resource was modified since the given version")
+	ErrNoLoginIdentifier  = errors.New("a username or email is required")
+)
+
+// Identity provider or SIS that provisions users and groups of one tenant
+// over SCIM. The bearer token is shown once, only its hash is stored.
+type ScimClient struct {
+	ID        string     `bson:"_id"`
+	TenantID  string     `bson:"tenant_id"`
+	Name      string     `bson:"name"`
+	TokenHash string     `bson:"token_hash"`
+	CreatedBy string     `bson:"created_by"`
+	CreatedAt time.Time  `bson:"created_at"`
+	RevokedAt *time.Time `bson:"revoked_at,omitempty"`
+}
+
+// Actor of its changes in events and the audit log
+func (c *ScimClient) ActorID() string {
+	return "scim:" + c.ID
+}
+
+// Version of a resource for ETags. Millisecond precision, which is what
+// Mongo keeps of UpdatedAt.
+func ScimVersion(updated time.Time) string {
+	return strconv.FormatInt(updated.UnixMilli(), 36)
+}
+
+// Indexed attribute a SCIM list request narrows its candidates by
+type ScimLookup string
+
+const (
+	LookupNone       ScimLookup = ""           // every resource of the tenant
+	LookupID         ScimLookup = "id"         // users and groups
+	LookupExternalID ScimLookup = "externalId" // users and groups
+	LookupUserName   ScimLookup = "userName"   // username, or email for an "@"
+	LookupEmail      ScimLookup = "email"
+)
+
+type ScimUserQuery struct {
+	Lookup     ScimLookup
+	Value      string
+	Match      func(*User) bool // the full filter, nil matches all
+	StartIndex int              // 1-based
+	Count      int              // page size, 0 only counts
+}
+
+// Group with the IDs of its members
+type ScimGroup struct {
+	Group   *Group
+	Members []string
+}
+
+type ScimGroupQuery struct {
+	Lookup     ScimLookup
+	Value      string
+	Match      func(*ScimGroup) bool
+	StartIndex int
+	Count      int
+}
@@ -0,0 +1,222 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrTenantNotFound = errors.New("tenant not found")
+	ErrTenantExists   = errors.New("tenant already exists")
+	ErrTenantDisabled = errors.New("tenant disabled")
+	ErrInvalidTenant  = errors.New("invalid tenant")
+	ErrNoTenant       = errors.New("no tenant in context")
+	ErrWeakPassword   = errors.New("password does not meet the tenant's policy")
+	ErrIdPNotAllowed  = errors.New("sign-in method not allowed for this tenant")
+)
+
+// Users stored before tenants existed belong here. Its admins manage the other tenants.
+const DefaultTenantID = "default"
+
+// Lowercase slug, also used in tokens and the x-tenant-id header
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+
+func ValidTenantID(id string) bool { return tenantIDPattern.MatchString(id) }
+
+type TenantStatus string
+
+const (
+	TenantActive   TenantStatus = "active"
+	TenantDisabled TenantStatus = "disabled" // nobody can sign in or call the API
+)
+
+// A school or organisation, users and their data never cross tenants
+type Tenant struct {
+	ID        string       `bson:"_id"`
+	Name      string       `bson:"name"`
+	Status    TenantStatus `bson:"status"`
+	Config    TenantConfig `bson:"config"`
+	CreatedAt time.Time    `bson:"created_at"`
+	UpdatedAt time.Time    `bson:"updated_at"`
+}
+
+func (t *Tenant) IsActive() bool {
+	return t.Status == TenantActive
+}
+
+type TenantConfig struct {
+	Password    PasswordPolicy `bson:"password"`
+	AllowedIdPs []string       `bson:"allowed_idps"` // empty allows every method
+	Branding    EmailBranding  `bson:"branding"`
+}
+
+// Sign-in methods a tenant can allow, federated ones are named "oidc:<name>" or "saml:<name>"
+const IdPPassword = "password"
+
+var idpPattern = regexp.MustCompile(`^(password|(oidc|saml):[a-z0-9][a-z0-9._-]{0,62})$`)
+
+func (c TenantConfig) AllowsIdP(idp string) bool {
+	if len(c.AllowedIdPs) == 0 {
+		return true
+	}
+	for _, allowed := range c.AllowedIdPs {
+		if allowed == idp {
+			return true
+		}
+	}
+	return false
+}
+
+func (c TenantConfig) Validate() error {
+	if err := c.Password.Validate(); err != nil {
+		return err
+	}
+	for _, idp := range c.AllowedIdPs {
+		if !idpPattern.MatchString(idp) {
+			return ErrInvalidTenant
+		}
+	}
+	return c.Branding.Validate()
+}
+
+type PasswordPolicy struct {
+	MinLength     int  `bson:"min_length"`
+	RequireUpper  bool `bson:"require_upper"`
+	RequireLower  bool `bson:"require_lower"`
+	RequireDigit  bool `bson:"require_digit"`
+	RequireSymbol bool `bson:"require_symbol"`
+}
+
+const maxPasswordLength = 72 // bcrypt ignores the rest
+
+func (p PasswordPolicy) Validate() error {
+	if p.MinLength < 0 || p.MinLength > maxPasswordLength {
+		return ErrInvalidTenant
+	}
+	return nil
+}
+
+// ErrWeakPassword when a new password breaks the policy
+func (p PasswordPolicy) Check(password string) error {
+	if len(password) < p.MinLength {
+		return ErrWeakPassword
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	if (p.RequireUpper && !upper) || (p.RequireLower && !lower) || (p.RequireDigit && !digit) || (p.RequireSymbol && !symbol) {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+// Look of the emails a tenant's users get, zero values keep the plain templates
+type EmailBranding struct {
+	ProductName  string `bson:"product_name"` // subject prefix and header
+	LogoURL      string `bson:"logo_url"`
+	PrimaryColor string `bson:"primary_color"` // #rrggbb
+	FooterText   string `bson:"footer_text"`
+}
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func (b EmailBranding) Validate() error {
+	if b.PrimaryColor != "" && !colorPattern.MatchString(b.PrimaryColor) {
+		return ErrInvalidTenant
+	}
+	if b.LogoURL != "" && !strings.HasPrefix(b.LogoURL, "https://") {
+		return ErrInvalidTenant
+	}
+	return nil
+}
+
+// Branded subject and body around a message template
+func (b EmailBranding) Apply(subject, body string) (string, string) {
+	if b.ProductName != "" {
+		subject = "[" + b.ProductName + "] " + subject
+	}
+
+	var header, footer string
+	if b.LogoURL != "" {
+		header += `<img src="` + html.EscapeString(b.LogoURL) + `" alt="` + html.EscapeString(b.ProductName) + `" height="40">`
+	}
+	if b.ProductName != "" {
+		style := ""
+		if b.PrimaryColor != "" {
+			style = ` style="color:` + b.PrimaryColor + `"`
+		}
+		header += `<h1` + style + `>` + html.EscapeString(b.ProductName) + `</h1>`
+	}
+	if b.FooterText != "" {
+		footer = `<p style="color:#888;font-size:12px">` + html.EscapeString(b.FooterText) + `</p>`
+	}
+	if header == "" && footer == "" {
+		return subject, body
+	}
+	return subject, "<div>" + header + body + footer + "</div>"
+}
+
+// UpdateTenant fields, nil ones are kept
+type TenantUpdate struct {
+	ID     string
+	Name   *string
+	Status *TenantStatus
+	Config *TenantConfig
+}
+
+// Resolves tenants for requests, cached by the tenant usecase
+type TenantDirectory interface {
+	Tenant(ctx context.Context, id string) (*Tenant, error)
+}
+
+// Tenant scope of a request. Repositories holding tenant data refuse to run
+// without one, so a query can't reach another tenant's documents by accident.
+type tenantCtxKey struct{}
+
+const allTenants = "*"
+
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantID)
+}
+
+// For background jobs that work across tenants, never for request handling
+func WithAllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, allTenants)
+}
+
+// Tenant of ctx, all is true for the WithAllTenants scope
+func TenantScope(ctx context.Context) (tenantID string, all bool, err error) {
+	id, _ := ctx.Value(tenantCtxKey{}).(string)
+	switch id {
+	case "":
+		return "", false, ErrNoTenant
+	case allTenants:
+		return "", true, nil
+	}
+	return id, false, nil
+}
+
+// Tenant of ctx, empty without one or in the all tenants scope
+func TenantOf(ctx context.Context) string {
+	id, all, err := TenantScope(ctx)
+	if err != nil || all {
+		return ""
+	}
+	return id
+}
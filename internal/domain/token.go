@@ -7,6 +7,7 @@ import (
 
 type TokenPayload struct {
 	UserID    string    `json:"user_id"`
+	TenantID  string    `json:"tenant_id"`
 	Email     string    `json:"email"`
 	Role      Role      `json:"role"`
 	IssuedAt  time.Time `json:"issued_at"`  // UTC
@@ -17,7 +18,9 @@ type VerificationCode struct {
 	UserID    string
 	Code      string
 	ExpiresAt time.Time
-	Purpose   string // "email_verification" or "reset_password"
+	Purpose   string // "email_verification", "reset_password" or "phone_verification"
+	Target    string // phone the code was sent to, phone_verification only
+	Attempts  int    // wrong codes so far
 }
 
 // Creates a verification code with TTL
@@ -32,8 +35,24 @@ func NewVerificationCode(userID, code, purpose string, ttl time.Duration) *Verif
 
 // TODO: handle refresh token
 type JWTService interface {
-	Generate(userID string, role Role) (accessToken string, issuedAt time.Time, expiresAt int64, err error) // generate access_token
-	Validate(ctx context.Context, token string) (*TokenPayload, error)                                      // validate access_token
+	Generate(userID, tenantID string, role Role) (accessToken string, issuedAt time.Time, expiresAt int64, err error) // generate access_token
+	Validate(ctx context.Context, token string) (*TokenPayload, error)                                                // validate access_token
+}
+
+// Invalidates all access tokens of a user issued before the revocation, or a
+// single one on logout
+type SessionRevoker interface {
+	RevokeAll(ctx context.Context, userID string) error
+	Revoke(ctx context.Context, userID string, issuedAt, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, userID string, issuedAt time.Time) (bool, error)
+	// Zero time if the user never revoked
+	RevokedBefore(ctx context.Context, userID string) (time.Time, error)
+}
+
+// Spaces out codes sent to the same user or phone
+type SendLimiter interface {
+	// False when the key was already taken within the window
+	Allow(ctx context.Context, key string, window time.Duration) (bool, error)
 }
 
 type CodeCache interface {
@@ -10,13 +10,22 @@ import (
 
 var (
 	// User errors
-	ErrEmailAlreadyExists    = errors.New("email already exists")
-	ErrUsernameAlreadyExists = errors.New("username already exists")
-	ErrUserNotFound          = errors.New("user not found")
-	ErrPasswordUnchanged     = errors.New("password unchanged")
-	ErrInvalidCredentials    = errors.New("invalid credentials")
-	ErrInvalidArgument       = errors.New("must specify either ID or Email")
-	ErrPermissionDenied      = errors.New("permission denied")
+	ErrEmailAlreadyExists     = errors.New("email already exists")
+	ErrUsernameAlreadyExists  = errors.New("username already exists")
+	ErrUserNotFound           = errors.New("user not found")
+	ErrPasswordUnchanged      = errors.New("password unchanged")
+	ErrPasswordChangeRequired = errors.New("password change required")
+	ErrInvalidCredentials     = errors.New("invalid credentials")
+	ErrInvalidArgument        = errors.New("must specify either ID or Email")
+	ErrPermissionDenied       = errors.New("permission denied")
+	ErrAccountDisabled        = errors.New("account disabled")
+	ErrUnexpectedRole         = errors.New("user doesn't hold the expected role")
+	ErrAccountSuspended       = errors.New("account suspended")
+	ErrAccountNotVerified     = errors.New("account pending verification")
+	ErrInvalidStatus          = errors.New("invalid account status")
+	ErrAccountDeleted         = errors.New("account deleted")
+	ErrNotRestorable          = errors.New("account can't be restored")
+	ErrInvalidCursor          = errors.New("invalid page cursor")
 
 	// Token errors
 	ErrInvalidToken = errors.New("invalid token")
@@ -28,12 +37,14 @@ var (
 	ErrPasswordResetCodeExpired = errors.New("password reset code expired")
 	ErrPasswordResetCodeInvalid = errors.New("invalid password reset code")
 	ErrInvalidPurpose           = errors.New("invalid code purpose")
+	ErrCodeSentRecently         = errors.New("a code was sent recently, try again later")
 )
 
 // Purposes for code verification
 const (
 	PurposeEmailVerification = "email_verification"
 	PurposeResetPassword     = "reset_password"
+	PurposePhoneVerification = "phone_verification"
 )
 
 type Role string
@@ -45,32 +56,145 @@ const (
 	STUDENT     Role = "student"
 )
 
+type UserStatus string
+
+const (
+	StatusActive              UserStatus = "active"
+	StatusSuspended           UserStatus = "suspended" // temporary when StatusUntil is set
+	StatusDisabled            UserStatus = "disabled"
+	StatusPendingVerification UserStatus = "pending_verification"
+
+	// Set by account deletion only
+	StatusDeleted UserStatus = "deleted" // restorable until PurgeAt
+	StatusErased  UserStatus = "erased"  // personal data anonymised
+)
+
+// Statuses an admin can set with SetAccountStatus
+func (s UserStatus) Valid() bool {
+	switch s {
+	case StatusActive, StatusSuspended, StatusDisabled, StatusPendingVerification:
+		return true
+	}
+	return false
+}
+
 type User struct {
 	ID        string    `bson:"_id"`
+	TenantID  string    `bson:"tenant_id"` // set by the repository from the request's tenant
 	Email     string    `bson:"email"`
+	EmailKey  string    `bson:"email_key"` // normalised, unique per tenant, see EmailNormalizer
 	Username  string    `bson:"username"`
-	Password  string    `bson:"password"`
+	Password  string    `bson:"password"` // empty for provisioned accounts until they set one
 	Role      Role      `bson:"role"`
-	Phone     string    `bson:"phone"`
+	Phone     string    `bson:"phone"` // E.164
 	Verified  bool      `bson:"verified"`
 	CreatedAt time.Time `bson:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at"`
+
+	Status       UserStatus `bson:"status"`
+	StatusReason string     `bson:"status_reason,omitempty"`
+	StatusUntil  *time.Time `bson:"status_until,omitempty"` // end of a temporary suspension
+
+	PhoneVerified bool `bson:"phone_verified"` // phone can receive codes
+
+	MustChangePassword bool `bson:"must_change_password,omitempty"` // generated password, Login asks for a new one first
+
+	ExternalID string `bson:"external_id,omitempty"` // id in the school's SIS, unique per tenant
+
+	DeletedAt     *time.Time `bson:"deleted_at,omitempty"`
+	PurgeAt       *time.Time `bson:"purge_at,omitempty"`       // erasure job anonymises the user after
+	RestoreStatus UserStatus `bson:"restore_status,omitempty"` // status before deletion
+}
+
+func (u *User) IsDeleted() bool {
+	return u.Status == StatusDeleted || u.Status == StatusErased
+}
+
+// Replace personal data with placeholders, the ID is kept for referential integrity
+func (u *User) Anonymize(now time.Time) {
+	u.Email = "erased+" + u.ID + "@invalid" // keeps the unique email index happy
+	u.EmailKey = u.Email
+	u.Username = ""
+	u.ExternalID = ""
+	u.Phone = ""
+	u.PhoneVerified = false
+	u.Password = ""
+	u.Verified = false
+	u.Status = StatusErased
+	u.StatusReason = ""
+	u.StatusUntil = nil
+	u.RestoreStatus = ""
+	u.PurgeAt = nil
+	u.UpdatedAt = now
+}
+
+// Status at the given time, an expired suspension counts as active.
+// Users created before statuses existed have an empty status.
+func (u *User) EffectiveStatus(now time.Time) UserStatus {
+	switch {
+	case u.Status == "":
+		return StatusActive
+	case u.Status == StatusSuspended && u.StatusUntil != nil && !now.Before(*u.StatusUntil):
+		return StatusActive
+	}
+	return u.Status
+}
+
+func (u *User) IsActive() bool {
+	return u.EffectiveStatus(time.Now()) == StatusActive
+}
+
+// Error to return when a non-active user tries to authenticate
+func (u *User) StatusErr() error {
+	switch u.EffectiveStatus(time.Now()) {
+	case StatusActive:
+		return nil
+	case StatusSuspended:
+		return ErrAccountSuspended
+	case StatusPendingVerification:
+		return ErrAccountNotVerified
+	case StatusDeleted, StatusErased:
+		return ErrAccountDeleted
+	default:
+		return ErrAccountDisabled
+	}
+}
+
+type SetStatusParams struct {
+	UserID string
+	Status UserStatus
+	Reason string
+	Until  *time.Time // suspended only, nil = until lifted
+	Roles  []Role     // only users holding one of them, nil = any
 }
 
 type UpdateUserProfileParams struct {
 	ID       string
-	Email    string
+	Email    string // must be empty or unchanged, see RequestEmailChange
 	Username string
 	Phone    string
 }
 
-func NewUser(email, hashedPwd string, role Role) *User {
+// Admin user listing, zero values are ignored
+type UserFilter struct {
+	Role        Role
+	Verified    *bool
+	CreatedFrom time.Time // inclusive
+	CreatedTo   time.Time // exclusive
+	Query       string    // prefix of the email key (the lowercased email) or, ignoring case, of the username
+	Cursor      string
+	Limit       int
+}
+
+func NewUser(email, emailKey, hashedPwd string, role Role) *User {
 	return &User{
 		ID:        uuid.NewString(),
 		Email:     email,
+		EmailKey:  emailKey,
 		Password:  hashedPwd,
 		Role:      role,
 		Verified:  false,
+		Status:    StatusActive,
 		CreatedAt: time.Now().UTC(),
 	}
 }
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidUsername = errors.New("invalid username")
+
+// Usernames are matched case-insensitively, the spelling is kept as entered.
+// No "@" so a login identifier is never ambiguous with an email.
+func NormalizeUsername(raw string) (string, error) {
+	name := strings.TrimSpace(raw)
+	if n := utf8.RuneCountInString(name); n < 3 || n > 32 {
+		return "", ErrInvalidUsername
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '_', r == '-':
+		default:
+			return "", ErrInvalidUsername
+		}
+	}
+	return name, nil
+}
+
+// Login identifiers with an "@" are emails, anything else a username
+func IsEmailIdentifier(identifier string) bool {
+	return strings.Contains(identifier, "@")
+}
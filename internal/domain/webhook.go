@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound   = errors.New("webhook not found")
+	ErrDeliveryNotFound  = errors.New("webhook delivery not found")
+	ErrInvalidWebhookURL = errors.New("invalid webhook url")
+	ErrWebhookTarget     = errors.New("webhook url points at a blocked address")
+)
+
+// Keeps webhooks off internal addresses, see pkg/webhook.Guard
+type WebhookTargetGuard interface {
+	CheckURL(ctx context.Context, rawURL string) error
+}
+
+// Partner endpoint receiving user events over HTTP
+type WebhookSubscription struct {
+	ID             string      `bson:"_id"`
+	TenantID       string      `bson:"tenant_id"` // gets only this tenant's events
+	URL            string      `bson:"url"`
+	EventTypes     []EventType `bson:"event_types"` // empty means all events
+	Secret         string      `bson:"secret"`      // HMAC-SHA256 key
+	Enabled        bool        `bson:"enabled"`
+	FailureCount   int         `bson:"failure_count"` // consecutive failed deliveries
+	DisabledReason string      `bson:"disabled_reason,omitempty"`
+	CreatedAt      time.Time   `bson:"created_at"`
+	UpdatedAt      time.Time   `bson:"updated_at"`
+}
+
+func (s *WebhookSubscription) Matches(typ EventType) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed" // retries exhausted
+)
+
+type WebhookDelivery struct {
+	ID             string            `bson:"_id"`
+	TenantID       string            `bson:"tenant_id"`
+	SubscriptionID string            `bson:"subscription_id"`
+	EventID        string            `bson:"event_id"`
+	EventType      EventType         `bson:"event_type"`
+	Subject        string            `bson:"subject,omitempty"` // the event's user, for erasure
+	Body           []byte            `bson:"body"`
+	Headers        map[string]string `bson:"headers"` // CloudEvent headers for binary mode
+	Status         DeliveryStatus    `bson:"status"`
+	Attempts       int               `bson:"attempts"`
+	NextAttemptAt  time.Time         `bson:"next_attempt_at"`
+	LockedUntil    time.Time         `bson:"locked_until"`
+	LastStatusCode int               `bson:"last_status_code,omitempty"`
+	LastError      string            `bson:"last_error,omitempty"`
+	CreatedAt      time.Time         `bson:"created_at"`
+	DeliveredAt    *time.Time        `bson:"delivered_at,omitempty"`
+}
+
+// User event received from the bus, to be fanned out to webhooks
+type WebhookEvent struct {
+	ID      string
+	Type    EventType
+	Subject string // CloudEvent subject, the user ID of user events
+	Body    []byte
+	Headers map[string]string
+}
+
+type UpdateWebhookParams struct {
+	ID         string
+	URL        string
+	EventTypes []EventType
+	Enabled    *bool // nil keeps the current state
+}
+
+type WebhookDeliveryFilter struct {
+	SubscriptionID string
+	Status         DeliveryStatus
+	Cursor         string
+	Limit          int
+}
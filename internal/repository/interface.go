@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"io"
+	"time"
 
 	"github.com/Neroframe/AuthService/internal/domain"
 )
@@ -10,13 +12,223 @@ import (
 var (
 	ErrNotFound         = errors.New("user not found")
 	ErrEmailAlreadyUsed = errors.New("email already used")
+	ErrUsernameTaken    = errors.New("username already used")
 	ErrNothingToUpdate  = errors.New("no fields specified for update")
+	ErrInvalidCursor    = errors.New("invalid page cursor")
+	ErrAlreadyExists    = errors.New("already exists")
+	ErrOtherTenant      = errors.New("document belongs to another tenant")
 )
 
+// Every method is limited to the tenant of ctx, see domain.WithTenant.
+// Without a tenant scope they fail with domain.ErrNoTenant.
 type UserRepository interface {
 	Create(ctx context.Context, u *domain.User) error
-	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+	// By normalised key, see domain.EmailNormalizer
+	GetByEmail(ctx context.Context, emailKey string) (*domain.User, error)
+	// Case-insensitive
+	GetByUsername(ctx context.Context, username string) (*domain.User, error)
 	GetByID(ctx context.Context, id string) (*domain.User, error)
 	Update(ctx context.Context, u *domain.User, fields ...string) (*domain.User, error)
 	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, f domain.UserFilter) (users []*domain.User, nextCursor string, err error)
+	ListDueForErasure(ctx context.Context, now time.Time, limit int) ([]*domain.User, error)
+	// Write the anonymised user, ErrNotFound unless it's still soft deleted
+	Erase(ctx context.Context, u *domain.User) error
+	// Users created before email keys existed, by ID after afterID
+	ListMissingEmailKey(ctx context.Context, afterID string, limit int) ([]*domain.User, error)
+	// All users with the given email keys
+	ListByEmailKeys(ctx context.Context, keys []string) ([]*domain.User, error)
+	// Missing IDs are skipped
+	ListByIDs(ctx context.Context, ids []string) ([]*domain.User, error)
+	ListByExternalIDs(ctx context.Context, ids []string) ([]*domain.User, error)
+	// Case-insensitive
+	ListByUsernames(ctx context.Context, usernames []string) ([]*domain.User, error)
+}
+
+// Not tenant scoped, only the tenant usecase uses it
+type TenantRepository interface {
+	Create(ctx context.Context, t *domain.Tenant) error
+	// Insert unless it exists, existing tenants are left as they are
+	Ensure(ctx context.Context, t *domain.Tenant) error
+	GetByID(ctx context.Context, id string) (*domain.Tenant, error)
+	List(ctx context.Context) ([]*domain.Tenant, error)
+	Update(ctx context.Context, t *domain.Tenant) error
+}
+
+// Runs fn in a single DB transaction, repos called with the passed ctx join it
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type OutboxRepository interface {
+	// Sets the record's Seq, the next of its aggregate. Call it in the
+	// transaction that made the change, so seqs follow commit order.
+	Add(ctx context.Context, rec *domain.OutboxRecord) error
+	// Lowest-seq unsent record of each aggregate, if due at now, oldest first.
+	// Later records wait for it, and one in backoff doesn't hold up others.
+	FetchPending(ctx context.Context, now time.Time, limit int) ([]*domain.OutboxRecord, error)
+	MarkSent(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id string, cause error, next time.Time) error // retry at next
+	MarkDead(ctx context.Context, id string, cause error) error                   // no more retries
+	AcquireRelayLock(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+	// Drop the user's events, except user.erased that tells others to erase theirs
+	ScrubUser(ctx context.Context, userID string) error
+}
+
+// Inbound message IDs already handled, for idempotent consumers
+type ProcessedMessageRepository interface {
+	IsProcessed(ctx context.Context, msgID string) (bool, error)
+	MarkProcessed(ctx context.Context, msgID, subject string) error
+}
+
+// Keeps copies of users' personal data, purged when an account is erased
+type UserDataScrubber interface {
+	ScrubUser(ctx context.Context, userID string) error
+}
+
+// Append-only, entries are never updated or deleted (only expired by retention)
+type AuditRepository interface {
+	Append(ctx context.Context, e *domain.AuditEvent) error
+	List(ctx context.Context, f domain.AuditFilter) (events []*domain.AuditEvent, nextCursor string, err error)
+	// Drop personal data from entries about the user
+	ScrubDetails(ctx context.Context, userID string) error
+}
+
+// Relation tuples. Subjects are matched by their string form, e.g. "course:101#student".
+type RelationRepository interface {
+	Write(ctx context.Context, tuples []domain.RelationTuple) error // existing tuples are kept
+	Delete(ctx context.Context, tuples []domain.RelationTuple) error
+	// Tuples pointing at any of the subjects
+	ListBySubjects(ctx context.Context, subjects []string) ([]domain.RelationTuple, error)
+	// Whether any of the objects has the relation to any of the subjects
+	ExistsAny(ctx context.Context, objectType string, objectIDs []string, relation string, subjects []string) (bool, error)
+	ListObjects(ctx context.Context, objectType, relation string, subjects []string) ([]string, error)
+	// Tuples on one object, every relation
+	ListByObject(ctx context.Context, objectType, objectID string) ([]domain.RelationTuple, error)
+}
+
+// Group metadata, owners and members are relation tuples
+type GroupRepository interface {
+	Create(ctx context.Context, g *domain.Group) error
+	GetByID(ctx context.Context, id string) (*domain.Group, error)
+	// Missing IDs are skipped
+	ListByIDs(ctx context.Context, ids []string) ([]*domain.Group, error)
+	ListBySourcedIDs(ctx context.Context, ids []string) ([]*domain.Group, error)
+	// By ID after afterID
+	List(ctx context.Context, afterID string, limit int) ([]*domain.Group, error)
+	Update(ctx context.Context, g *domain.Group) error // name, sourced ID and updated_at
+	Delete(ctx context.Context, id string) error
+}
+
+// Signup invitations, expired ones are removed by a TTL index
+type InvitationRepository interface {
+	Create(ctx context.Context, inv *domain.Invitation) error
+	GetByID(ctx context.Context, id string) (*domain.Invitation, error)
+	// Marks the invite used if it's still open at the given time, ErrNotFound otherwise.
+	// Concurrent accepts of one code can't both succeed.
+	Accept(ctx context.Context, id, userID string, at time.Time) error
+	Revoke(ctx context.Context, id string, at time.Time) error // ErrNotFound if no longer open
+	// Blank the address of invites accepted by or provisioned for the user
+	ScrubUser(ctx context.Context, userID string) error
+}
+
+// Roles, permissions and their bindings
+type RBACRepository interface {
+	ListRoles(ctx context.Context) ([]*domain.RoleDefinition, error)
+	GetRole(ctx context.Context, name domain.Role) (*domain.RoleDefinition, error)
+	CreateRole(ctx context.Context, r *domain.RoleDefinition) error // ErrAlreadyExists
+	UpdateRole(ctx context.Context, r *domain.RoleDefinition) error // description and permissions
+	DeleteRole(ctx context.Context, name domain.Role) error
+	AddRolePermissions(ctx context.Context, name domain.Role, perms ...string) error
+	RemoveRolePermissions(ctx context.Context, name domain.Role, perms ...string) error
+	// Drop a permission from every role
+	UnbindPermission(ctx context.Context, perm string) error
+
+	ListPermissions(ctx context.Context) ([]*domain.Permission, error)
+	GetPermission(ctx context.Context, name string) (*domain.Permission, error)
+	CreatePermission(ctx context.Context, p *domain.Permission) error // ErrAlreadyExists
+	DeletePermission(ctx context.Context, name string) error
+
+	// Startup seeding: roles are only inserted, built-in permissions are overwritten
+	EnsureRole(ctx context.Context, r *domain.RoleDefinition) error
+	EnsurePermission(ctx context.Context, p *domain.Permission) error
+}
+
+type ExportRepository interface {
+	Create(ctx context.Context, e *domain.DataExport) error
+	GetByID(ctx context.Context, id string) (*domain.DataExport, error)
+	GetByTokenHash(ctx context.Context, hash string) (*domain.DataExport, error)
+	// Lock the oldest pending export for the lease, nil if none
+	ClaimPending(ctx context.Context, now time.Time, lease time.Duration) (*domain.DataExport, error)
+	// Store the bundle and mark the export ready
+	SaveBundle(ctx context.Context, id string, bundle []byte) error
+	OpenBundle(ctx context.Context, id string) (io.ReadCloser, error)
+	MarkFailed(ctx context.Context, id string, cause error) error
+	// Remove expired exports and their bundles
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+type ImportRepository interface {
+	Create(ctx context.Context, j *domain.ImportJob) error
+	GetByID(ctx context.Context, id string) (*domain.ImportJob, error)
+	SaveFile(ctx context.Context, jobID, name string, r io.Reader) error
+	OpenFile(ctx context.Context, jobID, name string) (io.ReadCloser, error)
+	// Uploaded job is ready for the worker, files are the stored ones
+	Queue(ctx context.Context, id string, files []domain.ImportFile) error
+	// Lock the oldest pending import for the lease, nil if none
+	ClaimPending(ctx context.Context, now time.Time, lease time.Duration) (*domain.ImportJob, error)
+	// Store row results, add them to the counts and move the file's cursor.
+	// Called in the transaction that wrote the rows, so a resumed job starts
+	// after the last committed batch. The lease is renewed until lockedUntil.
+	SaveProgress(ctx context.Context, jobID string, file, rows int, done bool, results []*domain.ImportRow, lockedUntil time.Time) error
+	// Rows whose invitation is still to be sent
+	ListPendingInvites(ctx context.Context, jobID string, limit int) ([]*domain.ImportRow, error)
+	ClearInvitePending(ctx context.Context, rowID string) error
+	MarkDone(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id string, cause error) error
+	// Failed job back to pending, ErrNotFound if it isn't failed
+	Resume(ctx context.Context, id string) error
+	// By file and line, empty outcome lists every row
+	// Rows come without generated passwords, see TakePasswords
+	ListRows(ctx context.Context, jobID string, outcome domain.ImportOutcome, cursor string, limit int) (rows []*domain.ImportRow, nextCursor string, err error)
+	// Generated passwords of the rows by row ID, each removed as it's read so
+	// only the first caller gets it
+	TakePasswords(ctx context.Context, jobID string, rowIDs []string) (map[string]string, error)
+	// Drop the personal fields of rows that matched the user
+	ScrubUser(ctx context.Context, userID string) error
+	// Remove expired jobs and their files, row results expire by TTL
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+// Bearer tokens of SCIM clients
+type ScimClientRepository interface {
+	Create(ctx context.Context, c *domain.ScimClient) error
+	// Revoked clients too, called in the all tenants scope since the token picks the tenant
+	GetByTokenHash(ctx context.Context, hash string) (*domain.ScimClient, error)
+	List(ctx context.Context) ([]*domain.ScimClient, error)
+	Revoke(ctx context.Context, id string, at time.Time) error // ErrNotFound if no longer active
+}
+
+type WebhookRepository interface {
+	Create(ctx context.Context, s *domain.WebhookSubscription) error
+	GetByID(ctx context.Context, id string) (*domain.WebhookSubscription, error)
+	List(ctx context.Context) ([]*domain.WebhookSubscription, error)
+	ListEnabled(ctx context.Context) ([]*domain.WebhookSubscription, error)
+	Update(ctx context.Context, s *domain.WebhookSubscription) error
+	Delete(ctx context.Context, id string) error
+	RecordFailure(ctx context.Context, id string, disableAfter int) (disabled bool, err error)
+	ResetFailures(ctx context.Context, id string) error
+}
+
+type WebhookDeliveryRepository interface {
+	Create(ctx context.Context, d *domain.WebhookDelivery) error
+	GetByID(ctx context.Context, id string) (*domain.WebhookDelivery, error)
+	List(ctx context.Context, f domain.WebhookDeliveryFilter) (deliveries []*domain.WebhookDelivery, nextCursor string, err error)
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*domain.WebhookDelivery, error) // nil when nothing is due
+	MarkSucceeded(ctx context.Context, id string, statusCode int) error
+	MarkRetry(ctx context.Context, id string, statusCode int, cause error, next time.Time) error
+	MarkFailed(ctx context.Context, id string, statusCode int, cause error) error
+	Requeue(ctx context.Context, id string) error
+	// Delete deliveries of the user's events
+	ScrubUser(ctx context.Context, userID string) error
 }
@@ -0,0 +1,183 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+)
+
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 500
+)
+
+func (u *userUsecase) ListUsers(ctx context.Context, f domain.UserFilter) ([]*domain.User, string, error) {
+	if f.Limit <= 0 {
+		f.Limit = defaultUserPageSize
+	}
+	f.Limit = min(f.Limit, maxUserPageSize)
+
+	if err := u.authorize(ctx, domain.ActionUserList, domain.AuthzResource{Type: domain.ResourceUser}); err != nil {
+		return nil, "", err
+	}
+
+	users, next, err := u.repo.List(ctx, f)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, "", domain.ErrInvalidCursor
+		}
+		return nil, "", fmt.Errorf("ListUsers: %w", err)
+	}
+
+	return users, next, nil
+}
+
+// Soft delete, the user can restore the account during the grace period
+func (u *userUsecase) DeleteUser(ctx context.Context, userID string) (err error) {
+	defer func() {
+		u.recordAudit(ctx, domain.AuditUserDelete, userID, outcomeOf(err), errDetails(err))
+	}()
+
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+
+	if err := u.authorize(ctx, domain.ActionUserDelete, userIDResource(userID)); err != nil {
+		return err
+	}
+
+	usr, err := u.repo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrUserNotFound
+		}
+		return fmt.Errorf("DeleteUser fetch: %w", err)
+	}
+
+	return u.softDelete(ctx, usr, claims.UserID)
+}
+
+func (u *userUsecase) SetUserRole(ctx context.Context, userID string, role domain.Role) (_ *domain.User, err error) {
+	var oldRole domain.Role
+	defer func() {
+		details := withDetail(errDetails(err), "new_role", string(role))
+		u.recordAudit(ctx, domain.AuditRoleChange, userID, outcomeOf(err), withDetail(details, "old_role", string(oldRole)))
+	}()
+
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+
+	res := userIDResource(userID)
+	res.Attrs = map[string]string{"new_role": string(role)}
+	if err := u.authorize(ctx, domain.ActionUserSetRole, res); err != nil {
+		return nil, err
+	}
+
+	exists, err := u.roles.RoleExists(ctx, role)
+	if err != nil {
+		return nil, fmt.Errorf("SetUserRole RoleExists: %w", err)
+	}
+	if !exists {
+		return nil, domain.ErrRoleNotFound
+	}
+
+	usr, err := u.repo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("SetUserRole fetch: %w", err)
+	}
+
+	oldRole = usr.Role
+	if oldRole == role {
+		return usr, nil
+	}
+
+	usr.Role = role
+	usr.UpdatedAt = time.Now().UTC()
+
+	var updated *domain.User
+	err = u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		res, err := u.repo.Update(ctx, usr, "role", "updated_at")
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return domain.ErrUserNotFound
+			}
+			return fmt.Errorf("SetUserRole: %w", err)
+		}
+		updated = res
+
+		return u.publisher.PublishUserRoleChanged(ctx, &domain.UserRoleChangedEvent{
+			UserID:    userID,
+			OldRole:   oldRole,
+			NewRole:   role,
+			ChangedBy: claims.UserID,
+			CreatedAt: time.Now().UTC(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Role is baked into issued tokens, force a new login
+	if err := u.revoker.RevokeAll(ctx, userID); err != nil {
+		u.log.Error("SetUserRole revoke sessions failed", "user_id", userID, "err", err)
+	}
+
+	return updated, nil
+}
+
+func (u *userUsecase) SetUserVerified(ctx context.Context, userID string, verified bool) (_ *domain.User, err error) {
+	defer func() {
+		u.recordAudit(ctx, domain.AuditVerifiedSet, userID, outcomeOf(err), withDetail(errDetails(err), "verified", strconv.FormatBool(verified)))
+	}()
+
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+
+	if err := u.authorize(ctx, domain.ActionUserSetVerified, userIDResource(userID)); err != nil {
+		return nil, err
+	}
+
+	usr, err := u.repo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("SetUserVerified fetch: %w", err)
+	}
+
+	if usr.Verified == verified {
+		return usr, nil
+	}
+
+	usr.Verified = verified
+	usr.UpdatedAt = time.Now().UTC()
+
+	var updated *domain.User
+	err = u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		res, err := u.repo.Update(ctx, usr, "verified", "updated_at")
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return domain.ErrUserNotFound
+			}
+			return fmt.Errorf("SetUserVerified: %w", err)
+		}
+		updated = res
+
+		return u.publisher.PublishUserVerifiedSet(ctx, &domain.UserVerifiedSetEvent{
+			UserID:    userID,
+			Verified:  verified,
+			ChangedBy: claims.UserID,
+			CreatedAt: time.Now().UTC(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
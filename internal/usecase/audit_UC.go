@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+func (u *userUsecase) ListAuditEvents(ctx context.Context, f domain.AuditFilter) ([]*domain.AuditEvent, string, error) {
+	if f.Limit <= 0 {
+		f.Limit = defaultAuditPageSize
+	}
+	f.Limit = min(f.Limit, maxAuditPageSize)
+
+	if err := u.authorize(ctx, domain.ActionAuditRead, domain.AuthzResource{Type: domain.ResourceAudit}); err != nil {
+		return nil, "", err
+	}
+
+	events, next, err := u.audit.List(ctx, f)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, "", domain.ErrInvalidCursor
+		}
+		return nil, "", fmt.Errorf("ListAuditEvents: %w", err)
+	}
+
+	u.recordAudit(ctx, domain.AuditAuditLogViewed, "", domain.OutcomeSuccess, nil)
+
+	return events, next, nil
+}
+
+// Shared by usecases that write to the audit log
+type auditor struct {
+	audit    repository.AuditRepository
+	auditLog *logger.Logger
+}
+
+// Append an audit entry, actor and request info come from ctx.
+// Failures are logged, the audited operation has already happened.
+func (a auditor) recordAudit(ctx context.Context, action domain.AuditAction, targetID string, outcome domain.AuditOutcome, details map[string]string) {
+	meta := requestMeta(ctx)
+	e := &domain.AuditEvent{
+		ID:        uuid.NewString(),
+		TenantID:  domain.TenantOf(ctx),
+		Action:    action,
+		TargetID:  targetID,
+		IP:        meta.IP,
+		UserAgent: meta.UserAgent,
+		RequestID: meta.RequestID,
+		Outcome:   outcome,
+		Details:   details,
+		CreatedAt: time.Now().UTC(),
+	}
+	if claims, ok := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload); ok {
+		e.ActorID = claims.UserID
+		e.ActorRole = claims.Role
+	}
+
+	if err := a.audit.Append(ctx, e); err != nil {
+		a.auditLog.Error("failed to write audit event", "action", action, "target_id", targetID, "err", err)
+	}
+}
+
+// Outcome for an operation result
+func outcomeOf(err error) domain.AuditOutcome {
+	if err != nil {
+		return domain.OutcomeFailure
+	}
+	return domain.OutcomeSuccess
+}
+
+// Error details for failed operations
+func errDetails(err error) map[string]string {
+	if err == nil {
+		return nil
+	}
+	return map[string]string{"error": err.Error()}
+}
@@ -5,87 +5,275 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"strconv"
 	"time"
 
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
 	"github.com/Neroframe/AuthService/internal/domain"
 	"github.com/Neroframe/AuthService/internal/repository"
 	"github.com/Neroframe/AuthService/pkg/logger"
 )
 
 type userUsecase struct {
-	log         *logger.Logger
-	repo        repository.UserRepository
-	hasher      domain.PasswordHasher
-	publisher   domain.UserEventPublisher
-	cache       domain.CodeCache
-	jwt         domain.JWTService
-	emailSender domain.EmailSender
+	auditor
+	log       *logger.Logger
+	repo      repository.UserRepository
+	tx        repository.TxManager
+	hasher    domain.PasswordHasher
+	publisher domain.UserEventPublisher
+	cache     domain.CodeCache
+	revoker   domain.SessionRevoker
+	jwt       domain.JWTService
+
+	erasureGrace time.Duration // deleted accounts can be restored for
+	scrubbers    []repository.UserDataScrubber
+
+	emailChanges domain.EmailChangeStore
+	emailChange  EmailChangeConfig
+
+	sms     domain.SMSSender
+	limiter domain.SendLimiter
+	phone   PhoneConfig
+
+	emails domain.EmailNormalizer
+	tenancy
+	authorizer
+
+	registration domain.RegistrationMode
+}
+
+// Stores and services the user usecase works with
+type UserDeps struct {
+	Repo         repository.UserRepository
+	Tx           repository.TxManager
+	Hasher       domain.PasswordHasher
+	Publisher    domain.UserEventPublisher
+	Cache        domain.CodeCache
+	Revoker      domain.SessionRevoker
+	JWT          domain.JWTService
+	EmailSender  domain.EmailSender
+	Audit        repository.AuditRepository
+	Scrubbers    []repository.UserDataScrubber // purge a user's data on erasure
+	EmailChanges domain.EmailChangeStore
+	SMS          domain.SMSSender
+	Limiter      domain.SendLimiter
+	Emails       domain.EmailNormalizer
+	Roles        domain.RoleCatalog
+	Authz        domain.Authorizer
+	Relations    domain.RelationChecker
+	Tenants      domain.TenantDirectory
 }
 
-func NewUserUsecase(
-	r repository.UserRepository,
-	h domain.PasswordHasher,
-	p domain.UserEventPublisher,
-	c domain.CodeCache,
-	log *logger.Logger,
-	jwtSvc domain.JWTService,
-	emailSender domain.EmailSender,
-) UserUsecase {
-	return &userUsecase{repo: r, hasher: h, publisher: p, cache: c, log: log, jwt: jwtSvc, emailSender: emailSender}
+type UserConfig struct {
+	ErasureGrace time.Duration // deleted accounts can be restored for
+	EmailChange  EmailChangeConfig
+	Phone        PhoneConfig
+	Registration domain.RegistrationMode
 }
 
-func (u *userUsecase) Register(ctx context.Context, email, password string, role domain.Role) (*domain.User, error) {
+func NewUserUsecase(d UserDeps, cfg UserConfig, log *logger.Logger) UserUsecase {
+	return &userUsecase{
+		auditor:      auditor{audit: d.Audit, auditLog: log},
+		log:          log,
+		repo:         d.Repo,
+		tx:           d.Tx,
+		hasher:       d.Hasher,
+		publisher:    d.Publisher,
+		cache:        d.Cache,
+		revoker:      d.Revoker,
+		jwt:          d.JWT,
+		erasureGrace: cfg.ErasureGrace,
+		scrubbers:    d.Scrubbers,
+		emailChanges: d.EmailChanges,
+		emailChange:  cfg.EmailChange,
+		sms:          d.SMS,
+		limiter:      d.Limiter,
+		phone:        cfg.Phone,
+		emails:       d.Emails,
+		tenancy:      tenancy{tenants: d.Tenants, emailSender: d.EmailSender},
+		authorizer:   authorizer{authz: d.Authz, roles: d.Roles, relations: d.Relations},
+		registration: cfg.Registration,
+	}
+}
+
+func (u *userUsecase) Register(ctx context.Context, email, password string, role domain.Role) (usr *domain.User, err error) {
+	defer func() {
+		var targetID string
+		if usr != nil {
+			targetID = usr.ID
+		}
+		u.recordAudit(ctx, domain.AuditRegister, targetID, outcomeOf(err), withDetail(errDetails(err), "email", email))
+	}()
+
+	// Other roles are only given by invitation
+	if u.registration == domain.RegistrationDisabled {
+		return nil, domain.ErrRegistrationOff
+	}
+	if role != domain.STUDENT {
+		return nil, domain.ErrRoleNotAllowed
+	}
+
+	normalized, key, err := u.emails.Normalize(email)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.checkPassword(ctx, password); err != nil {
+		return nil, err
+	}
+
 	// hash password
 	hashed, err := u.hasher.Hash(ctx, password)
 	if err != nil {
 		return nil, fmt.Errorf("Register Hash: %w", err)
 	}
 
-	usr := domain.NewUser(email, hashed, role)
+	usr = domain.NewUser(normalized, key, hashed, role)
+
+	// Save user and its event in one transaction
+	err = u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := u.repo.Create(ctx, usr); err != nil {
+			if errors.Is(err, repository.ErrEmailAlreadyUsed) {
+				return domain.ErrEmailAlreadyExists
+			}
+			if errors.Is(err, repository.ErrUsernameTaken) {
+				return domain.ErrUsernameAlreadyExists
+			}
+			return fmt.Errorf("Register Create: %w", err)
+		}
+
+		event := &domain.UserRegisteredEvent{
+			UserID:    usr.ID,
+			Email:     usr.Email,
+			Role:      usr.Role,
+			CreatedAt: time.Now().UTC(),
+		}
+		if err := u.publisher.PublishUserRegistered(ctx, event); err != nil {
+			return fmt.Errorf("Register PublishEvent (registered): %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return usr, nil
+}
+
+// Student account without an email, created by a teacher or admin who hands out the credentials
+func (u *userUsecase) CreateStudentAccount(ctx context.Context, username, password string) (usr *domain.User, err error) {
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
 
-	// Save to DB
-	if err := u.repo.Create(ctx, usr); err != nil {
-		if errors.Is(err, repository.ErrEmailAlreadyUsed) {
-			return nil, domain.ErrEmailAlreadyExists
+	defer func() {
+		var targetID string
+		if usr != nil {
+			targetID = usr.ID
 		}
-		return nil, fmt.Errorf("Register Create: %w", err)
+		details := withDetail(withDetail(errDetails(err), "username", username), "created_by", claims.UserID)
+		u.recordAudit(ctx, domain.AuditRegister, targetID, outcomeOf(err), details)
+	}()
+
+	if err := u.authorize(ctx, domain.ActionStudentCreate, domain.AuthzResource{Type: domain.ResourceUser, Role: domain.STUDENT}); err != nil {
+		return nil, err
+	}
+
+	username, err = domain.NormalizeUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.checkPassword(ctx, password); err != nil {
+		return nil, err
 	}
 
-	// NATS publish
-	event := &domain.UserRegisteredEvent{
-		UserID:    usr.ID,
-		Email:     usr.Email,
-		Role:      usr.Role,
-		CreatedAt: time.Now().UTC(),
+	hashed, err := u.hasher.Hash(ctx, password)
+	if err != nil {
+		return nil, fmt.Errorf("CreateStudentAccount Hash: %w", err)
 	}
-	if err := u.publisher.PublishUserRegistered(ctx, event); err != nil {
-		return nil, fmt.Errorf("Register PublishEvent (registered): %w", err) // TODO: handle or fire-and-forget
+
+	usr = domain.NewUser("", "", hashed, domain.STUDENT)
+	usr.Username = username
+
+	err = u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := u.repo.Create(ctx, usr); err != nil {
+			if errors.Is(err, repository.ErrUsernameTaken) {
+				return domain.ErrUsernameAlreadyExists
+			}
+			return fmt.Errorf("CreateStudentAccount Create: %w", err)
+		}
+
+		return u.publisher.PublishUserRegistered(ctx, &domain.UserRegisteredEvent{
+			UserID:    usr.ID,
+			Username:  usr.Username,
+			Role:      usr.Role,
+			CreatedBy: claims.UserID,
+			CreatedAt: time.Now().UTC(),
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return usr, nil
 }
 
-func (u *userUsecase) Login(ctx context.Context, email, password string) (accessToken string, payload *domain.TokenPayload, err error) {
-	user, err := u.repo.GetByEmail(ctx, email)
+func (u *userUsecase) Login(ctx context.Context, identifier, password, newPassword string) (accessToken string, payload *domain.TokenPayload, err error) {
+	var targetID string
+	defer func() {
+		u.recordAudit(ctx, domain.AuditLogin, targetID, outcomeOf(err), withDetail(errDetails(err), "identifier", identifier))
+	}()
+
+	tenant, err := u.tenant(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if !tenant.Config.AllowsIdP(domain.IdPPassword) {
+		return "", nil, domain.ErrIdPNotAllowed
+	}
+
+	user, err := u.findByIdentifier(ctx, identifier)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			return "", nil, domain.ErrUserNotFound
 		}
-		return "", nil, fmt.Errorf("Login FindByEmail: %w", err)
+		return "", nil, fmt.Errorf("Login findByIdentifier: %w", err)
 	}
+	targetID = user.ID
 
 	ok := u.hasher.Verify(ctx, user.Password, password)
 	if !ok {
 		return "", nil, domain.ErrInvalidCredentials
 	}
 
-	token, iat, exp, err := u.jwt.Generate(user.ID, user.Role)
+	if err := user.StatusErr(); err != nil {
+		return "", nil, err
+	}
+	if user.MustChangePassword {
+		if newPassword == "" {
+			return "", nil, domain.ErrPasswordChangeRequired
+		}
+		if err := u.replaceInitialPassword(ctx, user, password, newPassword); err != nil {
+			return "", nil, err
+		}
+	}
+
+	token, iat, exp, err := u.jwt.Generate(user.ID, user.TenantID, user.Role)
 	if err != nil {
 		return "", nil, fmt.Errorf("Login jwt.Generate: %w", err)
 	}
 
+	meta := requestMeta(ctx)
+	u.publishEvent(ctx, "logged_in", func(ctx context.Context) error {
+		return u.publisher.PublishUserLoggedIn(ctx, &domain.UserLoggedInEvent{
+			UserID:    user.ID,
+			IP:        meta.IP,
+			UserAgent: meta.UserAgent,
+			CreatedAt: time.Now().UTC(),
+		})
+	})
+
 	return token, &domain.TokenPayload{
 		UserID:    user.ID,
+		TenantID:  user.TenantID,
 		Email:     user.Email,
 		Role:      user.Role,
 		IssuedAt:  iat,
@@ -93,18 +281,90 @@ func (u *userUsecase) Login(ctx context.Context, email, password string) (access
 	}, nil
 }
 
+func (u *userUsecase) Logout(ctx context.Context, allSessions bool) (err error) {
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+	defer func() {
+		u.recordAudit(ctx, domain.AuditLogout, claims.UserID, outcomeOf(err), withDetail(errDetails(err), "all_sessions", strconv.FormatBool(allSessions)))
+	}()
+
+	if allSessions {
+		err = u.revoker.RevokeAll(ctx, claims.UserID)
+	} else {
+		err = u.revoker.Revoke(ctx, claims.UserID, claims.IssuedAt, time.Unix(claims.ExpiresAt, 0))
+	}
+	if err != nil {
+		return fmt.Errorf("Logout: %w", err)
+	}
+
+	u.publishEvent(ctx, "logged_out", func(ctx context.Context) error {
+		return u.publisher.PublishUserLoggedOut(ctx, &domain.UserLoggedOutEvent{
+			UserID:    claims.UserID,
+			CreatedAt: time.Now().UTC(),
+		})
+	})
+	return nil
+}
+
+// First sign-in with a generated password sets the user's own
+func (u *userUsecase) replaceInitialPassword(ctx context.Context, user *domain.User, oldPw, newPw string) error {
+	if newPw == oldPw {
+		return domain.ErrPasswordUnchanged
+	}
+	if err := u.checkPassword(ctx, newPw); err != nil {
+		return err
+	}
+	hashed, err := u.hasher.Hash(ctx, newPw)
+	if err != nil {
+		return fmt.Errorf("Login Hash: %w", err)
+	}
+
+	user.Password = hashed
+	user.MustChangePassword = false
+	user.UpdatedAt = time.Now().UTC()
+	return u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := u.repo.Update(ctx, user, "password", "must_change_password", "updated_at"); err != nil {
+			return fmt.Errorf("Login Update: %w", err)
+		}
+		return u.publisher.PublishPasswordChanged(ctx, &domain.PasswordChangedEvent{
+			UserID:    user.ID,
+			CreatedAt: user.UpdatedAt,
+		})
+	})
+}
+
 func (u *userUsecase) ValidateToken(ctx context.Context, jwt string) (*domain.TokenPayload, error) {
 	payload, err := u.jwt.Validate(ctx, jwt)
 	if err != nil {
 		return nil, fmt.Errorf("ValidateToken jwt.Validate: %w", err)
 	}
 
+	revoked, err := u.revoker.IsRevoked(ctx, payload.UserID, payload.IssuedAt)
+	if err != nil {
+		return nil, fmt.Errorf("ValidateToken IsRevoked: %w", err)
+	}
+	if revoked {
+		return nil, domain.ErrInvalidToken
+	}
+
+	// Account may have been blocked after the token was issued
+	ctx = domain.WithTenant(ctx, payload.TenantID)
+	user, err := u.repo.GetByID(ctx, payload.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("ValidateToken FindByID: %w", err)
+	}
+	if err := user.StatusErr(); err != nil {
+		return nil, err
+	}
+
 	return payload, nil
 }
 
 func (u *userUsecase) SendVerificationCode(ctx context.Context, email, purpose string) error {
 	// Find user
-	user, err := u.repo.GetByEmail(ctx, email)
+	user, err := u.getByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			return domain.ErrUserNotFound
@@ -123,16 +383,22 @@ func (u *userUsecase) SendVerificationCode(ctx context.Context, email, purpose s
 	}
 
 	// Send email
-	if err := u.emailSender.Send(user.Email, purpose, buildEmailBody(purpose, code)); err != nil {
+	if err := u.sendEmail(ctx, user.Email, purpose, buildEmailBody(purpose, code)); err != nil {
 		return fmt.Errorf("SendVerificationCode Send: %w", err)
 	}
 
 	return nil
 }
 
-func (u *userUsecase) VerifyCode(ctx context.Context, email string, code string, purpose string) error {
+func (u *userUsecase) VerifyCode(ctx context.Context, email string, code string, purpose string) (err error) {
+	if purpose == domain.PurposeEmailVerification {
+		defer func() {
+			u.recordAudit(ctx, domain.AuditEmailVerify, "", outcomeOf(err), withDetail(errDetails(err), "email", email))
+		}()
+	}
+
 	// Find user
-	user, err := u.repo.GetByEmail(ctx, email)
+	user, err := u.getByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			return domain.ErrUserNotFound
@@ -147,14 +413,14 @@ func (u *userUsecase) VerifyCode(ctx context.Context, email string, code string,
 	}
 
 	// Validate
-	if cachedCode.Code != code {
-		return domain.ErrCodeInvalid
-	}
-
 	if time.Now().After(cachedCode.ExpiresAt) {
 		return domain.ErrCodeExpired
 	}
 
+	if cachedCode.Code != code {
+		return u.codeAttemptFailed(ctx, cachedCode)
+	}
+
 	if cachedCode.Purpose != purpose {
 		return domain.ErrInvalidPurpose
 	}
@@ -162,13 +428,29 @@ func (u *userUsecase) VerifyCode(ctx context.Context, email string, code string,
 	// Purpose specific actions
 	switch purpose {
 	case domain.PurposeEmailVerification:
-		// Update user as verified
+		// Update user as verified, this also activates a pending account
 		user.Verified = true
-		if _, err := u.repo.Update(ctx, user, "verified", "updated_at"); err != nil {
-			if errors.Is(err, repository.ErrNotFound) {
-				return domain.ErrUserNotFound
+		fields := []string{"verified", "updated_at"}
+		if user.Status == domain.StatusPendingVerification {
+			user.Status = domain.StatusActive
+			fields = append(fields, "status")
+		}
+		err := u.tx.WithinTx(ctx, func(ctx context.Context) error {
+			if _, err := u.repo.Update(ctx, user, fields...); err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return domain.ErrUserNotFound
+				}
+				return fmt.Errorf("VerifyCode email Update: %w", err)
 			}
-			return fmt.Errorf("VerifyCode email Update: %w", err)
+
+			return u.publisher.PublishUserEmailVerified(ctx, &domain.UserEmailVerifiedEvent{
+				UserID:    user.ID,
+				Email:     user.Email,
+				CreatedAt: time.Now().UTC(),
+			})
+		})
+		if err != nil {
+			return err
 		}
 	case domain.PurposeResetPassword:
 		// wait for ConfirmResetPassword to set new password
@@ -185,6 +467,84 @@ func (u *userUsecase) VerifyCode(ctx context.Context, email string, code string,
 	return nil
 }
 
+func (u *userUsecase) findByIdentifier(ctx context.Context, identifier string) (*domain.User, error) {
+	if domain.IsEmailIdentifier(identifier) {
+		return u.getByEmail(ctx, identifier)
+	}
+	return u.repo.GetByUsername(ctx, identifier)
+}
+
+// Matches by normalised key, an address that can't be normalised matches nobody
+func (u *userUsecase) getByEmail(ctx context.Context, email string) (*domain.User, error) {
+	key, err := u.emails.Key(email)
+	if err != nil {
+		return nil, repository.ErrNotFound
+	}
+	return u.repo.GetByEmail(ctx, key)
+}
+
+// Settings of the request's tenant: password policy, sign-in methods and email branding
+type tenancy struct {
+	tenants     domain.TenantDirectory
+	emailSender domain.EmailSender
+}
+
+// Tenant of the request, see the auth interceptor
+func (u *tenancy) tenant(ctx context.Context) (*domain.Tenant, error) {
+	tenantID, _, err := domain.TenantScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t, err := u.tenants.Tenant(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %s: %w", tenantID, err)
+	}
+	return t, nil
+}
+
+// New passwords follow the tenant's policy
+func (u *tenancy) checkPassword(ctx context.Context, password string) error {
+	t, err := u.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return t.Config.Password.Check(password)
+}
+
+// Emails carry the branding of the user's tenant
+func (u *tenancy) sendEmail(ctx context.Context, to, subject, body string) error {
+	t, err := u.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	subject, body = t.Config.Branding.Apply(subject, body)
+	return u.emailSender.Send(to, subject, body)
+}
+
+// Publish an event that has no state change to commit with, failures are only logged
+func (u *userUsecase) publishEvent(ctx context.Context, name string, publish func(context.Context) error) {
+	if err := publish(ctx); err != nil {
+		u.log.Error("failed to publish event", "event", name, "err", err)
+	}
+}
+
+// Add a key to audit details, allocating the map if needed
+func withDetail(details map[string]string, key, value string) map[string]string {
+	if details == nil {
+		details = make(map[string]string)
+	}
+	details[key] = value
+	return details
+}
+
+// Caller info injected by the logging interceptor
+func requestMeta(ctx context.Context) *domain.RequestMeta {
+	if meta, ok := ctx.Value(middleware.RequestMetaCtxKey).(*domain.RequestMeta); ok {
+		return meta
+	}
+	return &domain.RequestMeta{}
+}
+
 func buildEmailBody(purpose, code string) string {
 	switch purpose {
 	case domain.PurposeEmailVerification:
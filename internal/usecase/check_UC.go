@@ -0,0 +1,178 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/ttlcache"
+)
+
+const maxBatchChecks = 100
+
+// Decisions for other services, backed by the same policy as our own usecases
+type checkUsecase struct {
+	authorizer
+	users repository.UserRepository
+	cache *ttlcache.Cache[string, domain.AuthzDecision]
+}
+
+func NewCheckUsecase(
+	users repository.UserRepository,
+	authz domain.Authorizer,
+	roles domain.RoleCatalog,
+	relations domain.RelationChecker,
+	cacheTTL time.Duration,
+	cacheSize int,
+) CheckUsecase {
+	return &checkUsecase{
+		users:      users,
+		authorizer: authorizer{authz: authz, roles: roles, relations: relations},
+		cache:      ttlcache.New[string, domain.AuthzDecision](cacheTTL, cacheSize),
+	}
+}
+
+func (c *checkUsecase) CheckPermission(ctx context.Context, subjectID string, check domain.AuthzCheck) (*domain.AuthzDecision, error) {
+	ds, err := c.BatchCheck(ctx, subjectID, []domain.AuthzCheck{check})
+	if err != nil {
+		return nil, err
+	}
+	return ds[0], nil
+}
+
+// Empty subjectID checks the caller, anyone else needs authz.check
+func (c *checkUsecase) BatchCheck(ctx context.Context, subjectID string, checks []domain.AuthzCheck) ([]*domain.AuthzDecision, error) {
+	if len(checks) == 0 {
+		return nil, domain.ErrInvalidCheck
+	}
+	if len(checks) > maxBatchChecks {
+		return nil, domain.ErrTooManyChecks
+	}
+	for _, chk := range checks {
+		if chk.Action == "" {
+			return nil, domain.ErrInvalidCheck
+		}
+	}
+
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+	if subjectID == "" {
+		subjectID = claims.UserID
+	}
+	if subjectID != claims.UserID {
+		if err := c.authorize(ctx, domain.ActionAuthzCheck, userIDResource(subjectID)); err != nil {
+			return nil, err
+		}
+	}
+
+	var sub *domain.AuthzSubject
+	out := make([]*domain.AuthzDecision, len(checks))
+	for i, chk := range checks {
+		key := checkKey(subjectID, chk)
+		if d, ok := c.cache.Get(key); ok {
+			d.Cached = true
+			out[i] = &d
+			continue
+		}
+
+		// Subject is resolved once, and only on a cache miss
+		if sub == nil {
+			s, err := c.subject(ctx, claims, subjectID)
+			if err != nil {
+				return nil, err
+			}
+			sub = s
+		}
+
+		res, err := c.resource(ctx, chk.Resource)
+		if err != nil {
+			return nil, err
+		}
+
+		d, err := c.decide(ctx, *sub, chk.Action, res)
+		if err != nil {
+			return nil, err
+		}
+		c.cache.Set(key, *d)
+		out[i] = d
+	}
+
+	return out, nil
+}
+
+// The caller's role comes from its token like everywhere else, other subjects from the store
+func (c *checkUsecase) subject(ctx context.Context, claims *domain.TokenPayload, subjectID string) (*domain.AuthzSubject, error) {
+	if subjectID == claims.UserID {
+		return &domain.AuthzSubject{ID: claims.UserID, Tenant: claims.TenantID, Role: claims.Role}, nil
+	}
+
+	usr, err := c.users.GetByID(ctx, subjectID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("BatchCheck subject: %w", err)
+	}
+	if usr.IsDeleted() {
+		return nil, domain.ErrUserNotFound
+	}
+
+	// Blocked accounts keep their id but lose every permission
+	sub := &domain.AuthzSubject{ID: usr.ID, Tenant: usr.TenantID, Role: usr.Role}
+	if !usr.IsActive() {
+		sub.Role = domain.UNSPECIFIED
+	}
+	return sub, nil
+}
+
+// User resources get the target's role, so rules like teacher-students work for callers
+func (c *checkUsecase) resource(ctx context.Context, res domain.AuthzResource) (domain.AuthzResource, error) {
+	// Other services only ask about their own tenant's objects
+	res.Tenant = domain.TenantOf(ctx)
+	if res.Type != domain.ResourceUser || res.ID == "" {
+		return res, nil
+	}
+	if res.OwnerID == "" {
+		res.OwnerID = res.ID
+	}
+	if res.Role != domain.UNSPECIFIED {
+		return res, nil
+	}
+
+	usr, err := c.users.GetByID(ctx, res.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return res, nil
+		}
+		return res, fmt.Errorf("BatchCheck resource: %w", err)
+	}
+	res.Role, res.Tenant = usr.Role, usr.TenantID
+	return res, nil
+}
+
+func checkKey(subjectID string, chk domain.AuthzCheck) string {
+	r := chk.Resource
+	var b strings.Builder
+	for _, s := range []string{subjectID, chk.Action, r.Type, r.ID, r.OwnerID, string(r.Role)} {
+		b.WriteString(s)
+		b.WriteByte(0)
+	}
+
+	keys := make([]string, 0, len(r.Attrs))
+	for k := range r.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(r.Attrs[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
@@ -0,0 +1,294 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+)
+
+const maxEmailChangeAttempts = 5
+
+type EmailChangeConfig struct {
+	CodeTTL time.Duration // code sent to the new address is valid for
+	UndoTTL time.Duration // old address can cancel or revert for
+	UndoURL string        // undo token is appended
+}
+
+// Send a code to the new address and an undo link to the current one.
+// The password is asked again so a stolen token isn't enough.
+func (u *userUsecase) RequestEmailChange(ctx context.Context, newEmail, password string) (err error) {
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+
+	defer func() {
+		details := withDetail(withDetail(errDetails(err), "op", "request"), "new_email", newEmail)
+		u.recordAudit(ctx, domain.AuditEmailChange, claims.UserID, outcomeOf(err), details)
+	}()
+
+	usr, err := u.repo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrUserNotFound
+		}
+		return fmt.Errorf("RequestEmailChange fetch: %w", err)
+	}
+
+	if !u.hasher.Verify(ctx, usr.Password, password) {
+		return domain.ErrInvalidCredentials
+	}
+	newEmail, newKey, err := u.emails.Normalize(newEmail)
+	if err != nil {
+		return err
+	}
+	if newKey == usr.EmailKey {
+		return domain.ErrEmailUnchanged
+	}
+	if err := u.ensureEmailFree(ctx, newEmail, usr.ID); err != nil {
+		return err
+	}
+
+	undoToken, err := randomHex(32)
+	if err != nil {
+		return fmt.Errorf("RequestEmailChange undo token: %w", err)
+	}
+
+	change := &domain.EmailChange{
+		UserID:    usr.ID,
+		OldEmail:  usr.Email,
+		NewEmail:  newEmail,
+		Code:      fmt.Sprintf("%06d", rand.Intn(1000000)),
+		UndoHash:  hashToken(undoToken),
+		ExpiresAt: time.Now().Add(u.emailChange.CodeTTL),
+	}
+	if err := u.emailChanges.SavePending(ctx, change, u.emailChange.CodeTTL); err != nil {
+		return fmt.Errorf("RequestEmailChange SavePending: %w", err)
+	}
+	undo := &domain.EmailChangeUndo{UserID: usr.ID, TenantID: usr.TenantID, OldEmail: usr.Email, NewEmail: newEmail}
+	if err := u.emailChanges.SaveUndo(ctx, change.UndoHash, undo, u.emailChange.UndoTTL); err != nil {
+		return fmt.Errorf("RequestEmailChange SaveUndo: %w", err)
+	}
+
+	if err := u.sendEmail(ctx, newEmail, "Confirm your new email", emailChangeCodeBody(change.Code)); err != nil {
+		return fmt.Errorf("RequestEmailChange send code: %w", err)
+	}
+	if err := u.sendEmail(ctx, usr.Email, "Email change requested", emailChangeNoticeBody(newEmail, u.emailChange.UndoURL+undoToken)); err != nil {
+		return fmt.Errorf("RequestEmailChange send notice: %w", err)
+	}
+
+	return nil
+}
+
+// Apply the pending change once the code from the new address is confirmed
+func (u *userUsecase) ConfirmEmailChange(ctx context.Context, code string) (_ *domain.User, err error) {
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+
+	defer func() {
+		u.recordAudit(ctx, domain.AuditEmailChange, claims.UserID, outcomeOf(err), withDetail(errDetails(err), "op", "confirm"))
+	}()
+
+	change, err := u.emailChanges.GetPending(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(change.ExpiresAt) {
+		return nil, domain.ErrCodeExpired
+	}
+	if change.Code != code {
+		return nil, u.emailChangeAttemptFailed(ctx, change)
+	}
+
+	usr, err := u.repo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("ConfirmEmailChange fetch: %w", err)
+	}
+	// Email changed some other way since the request
+	if usr.Email != change.OldEmail {
+		return nil, domain.ErrNoPendingEmailChange
+	}
+
+	// Address may have been taken since the request
+	if err := u.ensureEmailFree(ctx, change.NewEmail, usr.ID); err != nil {
+		return nil, err
+	}
+
+	updated, err := u.swapEmail(ctx, usr, change.NewEmail, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.emailChanges.DeletePending(ctx, usr.ID); err != nil {
+		u.log.Error("ConfirmEmailChange DeletePending failed", "user_id", usr.ID, "err", err)
+	}
+
+	// Undo link now reverts instead of cancelling
+	if undo, err := u.emailChanges.GetUndo(ctx, change.UndoHash); err == nil {
+		now := time.Now().UTC()
+		undo.AppliedAt = &now
+		if err := u.emailChanges.SaveUndo(ctx, change.UndoHash, undo, u.emailChange.UndoTTL); err != nil {
+			u.log.Error("ConfirmEmailChange SaveUndo failed", "user_id", usr.ID, "err", err)
+		}
+	}
+
+	return updated, nil
+}
+
+// Undo link from the old address: cancel a pending change or revert an applied one.
+// A revert also revokes all sessions, the account may have been taken over.
+func (u *userUsecase) UndoEmailChange(ctx context.Context, token string) (err error) {
+	var userID string
+	op := "cancel"
+	defer func() {
+		u.recordAudit(ctx, domain.AuditEmailChange, userID, outcomeOf(err), withDetail(errDetails(err), "op", op))
+	}()
+
+	hash := hashToken(token)
+	undo, err := u.emailChanges.GetUndo(ctx, hash)
+	if err != nil {
+		return err
+	}
+	userID = undo.UserID
+	if undo.TenantID != "" {
+		ctx = domain.WithTenant(ctx, undo.TenantID)
+	}
+
+	if undo.AppliedAt == nil {
+		if change, err := u.emailChanges.GetPending(ctx, undo.UserID); err == nil && change.UndoHash == hash {
+			if err := u.emailChanges.DeletePending(ctx, undo.UserID); err != nil {
+				return fmt.Errorf("UndoEmailChange DeletePending: %w", err)
+			}
+		}
+		return u.emailChanges.DeleteUndo(ctx, hash)
+	}
+
+	op = "revert"
+	usr, err := u.repo.GetByID(ctx, undo.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrUndoTokenInvalid
+		}
+		return fmt.Errorf("UndoEmailChange fetch: %w", err)
+	}
+	// Changed again since, this link is stale
+	if usr.Email != undo.NewEmail {
+		return domain.ErrUndoTokenInvalid
+	}
+	if err := u.ensureEmailFree(ctx, undo.OldEmail, usr.ID); err != nil {
+		return err
+	}
+
+	if _, err := u.swapEmail(ctx, usr, undo.OldEmail, true); err != nil {
+		return err
+	}
+
+	if err := u.emailChanges.DeleteUndo(ctx, hash); err != nil {
+		u.log.Error("UndoEmailChange DeleteUndo failed", "user_id", usr.ID, "err", err)
+	}
+	if err := u.emailChanges.DeletePending(ctx, usr.ID); err != nil {
+		u.log.Error("UndoEmailChange DeletePending failed", "user_id", usr.ID, "err", err)
+	}
+	if err := u.revoker.RevokeAll(ctx, usr.ID); err != nil {
+		return fmt.Errorf("UndoEmailChange RevokeAll: %w", err)
+	}
+
+	return nil
+}
+
+// Both addresses proved ownership, so the account stays verified
+func (u *userUsecase) swapEmail(ctx context.Context, usr *domain.User, email string, reverted bool) (*domain.User, error) {
+	oldEmail := usr.Email
+	_, key, err := u.emails.Normalize(email)
+	if err != nil {
+		return nil, err
+	}
+	usr.Email, usr.EmailKey = email, key
+	usr.Verified = true
+	usr.UpdatedAt = time.Now().UTC()
+
+	var updated *domain.User
+	err = u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		res, err := u.repo.Update(ctx, usr, "email", "email_key", "verified", "updated_at")
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrEmailAlreadyUsed):
+				return domain.ErrEmailAlreadyExists
+			case errors.Is(err, repository.ErrNotFound):
+				return domain.ErrUserNotFound
+			}
+			return fmt.Errorf("swapEmail Update: %w", err)
+		}
+		updated = res
+
+		return u.publisher.PublishUserEmailChanged(ctx, &domain.UserEmailChangedEvent{
+			UserID:    usr.ID,
+			OldEmail:  oldEmail,
+			NewEmail:  email,
+			Reverted:  reverted,
+			CreatedAt: time.Now().UTC(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (u *userUsecase) ensureEmailFree(ctx context.Context, email, userID string) error {
+	other, err := u.getByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("ensureEmailFree: %w", err)
+	}
+	if other.ID != userID {
+		return domain.ErrEmailAlreadyExists
+	}
+	return nil
+}
+
+// Count a wrong code, the request is dropped after too many
+func (u *userUsecase) emailChangeAttemptFailed(ctx context.Context, change *domain.EmailChange) error {
+	change.Attempts++
+	if change.Attempts >= maxEmailChangeAttempts {
+		if err := u.emailChanges.DeletePending(ctx, change.UserID); err != nil {
+			return fmt.Errorf("ConfirmEmailChange DeletePending: %w", err)
+		}
+		return domain.ErrCodeInvalid
+	}
+
+	if err := u.emailChanges.SavePending(ctx, change, time.Until(change.ExpiresAt)); err != nil {
+		return fmt.Errorf("ConfirmEmailChange SavePending: %w", err)
+	}
+	return domain.ErrCodeInvalid
+}
+
+func emailChangeCodeBody(code string) string {
+	return fmt.Sprintf(`
+			<html>
+				<body>
+					<h2>Confirm your new email</h2>
+					<p>Use the following code to confirm this address for your account:</p>
+					<h3>%s</h3>
+					<p>If you didn't request this, ignore the email.</p>
+				</body>
+			</html>`, code)
+}
+
+func emailChangeNoticeBody(newEmail, undoLink string) string {
+	return fmt.Sprintf(`
+			<html>
+				<body>
+					<h2>Email change requested</h2>
+					<p>Someone asked to change your account email to <b>%s</b>.</p>
+					<p>If this wasn't you, <a href="%s">cancel the change</a>. The link also reverts the change after it was confirmed and signs out all sessions.</p>
+				</body>
+			</html>`, newEmail, undoLink)
+}
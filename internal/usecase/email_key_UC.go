@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+)
+
+const emailKeyBatch = 500
+
+// Backfill email keys for users stored before normalisation.
+// Accounts whose addresses now collide are reported, only one of them gets the key:
+// the one already keyed, else the verified one, else the oldest. The others keep
+// their stored email but can't sign in by it until an admin resolves them.
+// With dryRun nothing is written.
+func MigrateEmailKeys(ctx context.Context, repo repository.UserRepository, norm domain.EmailNormalizer, dryRun bool) (*domain.EmailKeyReport, error) {
+	report := &domain.EmailKeyReport{Duplicates: map[string][]string{}, Owners: map[string]string{}}
+
+	type candidate struct {
+		user  *domain.User
+		email string
+	}
+	groups := map[string][]candidate{}
+	var invalid []*domain.User
+
+	// Group unkeyed users by their new key
+	var afterID string
+	for {
+		users, err := repo.ListMissingEmailKey(ctx, afterID, emailKeyBatch)
+		if err != nil {
+			return nil, fmt.Errorf("MigrateEmailKeys list: %w", err)
+		}
+		for _, usr := range users {
+			report.Scanned++
+			switch {
+			case usr.Email == "":
+				invalid = append(invalid, usr) // nothing to match by
+				continue
+			case usr.Status == domain.StatusErased:
+				groups[usr.Email] = append(groups[usr.Email], candidate{user: usr, email: usr.Email})
+				continue
+			}
+
+			email, key, err := norm.Normalize(usr.Email)
+			if err != nil {
+				report.Invalid = append(report.Invalid, usr.ID)
+				invalid = append(invalid, usr)
+				continue
+			}
+			groups[key] = append(groups[key], candidate{user: usr, email: email})
+		}
+		if len(users) < emailKeyBatch {
+			break
+		}
+		afterID = users[len(users)-1].ID
+	}
+
+	// Keys already held by migrated or new accounts
+	owned := map[string]string{}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	for start := 0; start < len(keys); start += emailKeyBatch {
+		end := min(start+emailKeyBatch, len(keys))
+		holders, err := repo.ListByEmailKeys(ctx, keys[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("MigrateEmailKeys holders: %w", err)
+		}
+		for _, h := range holders {
+			owned[h.EmailKey] = h.ID
+		}
+	}
+
+	for key, group := range groups {
+		winner := -1
+		if _, ok := owned[key]; !ok {
+			winner = 0
+			for i, c := range group[1:] {
+				if betterEmailOwner(c.user, group[winner].user) {
+					winner = i + 1
+				}
+			}
+		}
+
+		if winner >= 0 {
+			c := group[winner]
+			if !dryRun {
+				c.user.Email, c.user.EmailKey = c.email, key
+				if _, err := repo.Update(ctx, c.user, "email", "email_key"); err != nil {
+					if !errors.Is(err, repository.ErrEmailAlreadyUsed) {
+						return nil, fmt.Errorf("MigrateEmailKeys update %s: %w", c.user.ID, err)
+					}
+					// Taken meanwhile, report it with the rest
+					winner = -1
+				}
+			}
+			if winner >= 0 {
+				report.Keyed++
+			}
+		}
+
+		if len(group) == 1 && winner == 0 {
+			continue
+		}
+		if owner, ok := owned[key]; ok {
+			report.Owners[key] = owner
+		} else if winner >= 0 {
+			report.Owners[key] = group[winner].user.ID
+		}
+		for i, c := range group {
+			if i == winner {
+				continue
+			}
+			report.Duplicates[key] = append(report.Duplicates[key], c.user.ID)
+			invalid = append(invalid, c.user)
+		}
+	}
+
+	// Mark as processed with an empty key, which the unique index ignores
+	if !dryRun {
+		for _, usr := range invalid {
+			usr.EmailKey = ""
+			if _, err := repo.Update(ctx, usr, "email_key"); err != nil {
+				return nil, fmt.Errorf("MigrateEmailKeys mark %s: %w", usr.ID, err)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func betterEmailOwner(a, b *domain.User) bool {
+	if a.Verified != b.Verified {
+		return a.Verified
+	}
+	return a.CreatedAt.Before(b.CreatedAt)
+}
@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+)
+
+// Self-service deletion, the password is asked again
+func (u *userUsecase) DeleteMyAccount(ctx context.Context, password string) (purgeAt time.Time, err error) {
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+
+	defer func() {
+		u.recordAudit(ctx, domain.AuditAccountDelete, claims.UserID, outcomeOf(err), errDetails(err))
+	}()
+
+	usr, err := u.repo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return time.Time{}, domain.ErrUserNotFound
+		}
+		return time.Time{}, fmt.Errorf("DeleteMyAccount fetch: %w", err)
+	}
+
+	if !u.hasher.Verify(ctx, usr.Password, password) {
+		return time.Time{}, domain.ErrInvalidCredentials
+	}
+
+	if err := u.softDelete(ctx, usr, claims.UserID); err != nil {
+		return time.Time{}, err
+	}
+	return *usr.PurgeAt, nil
+}
+
+// Undo a deletion within the grace period, authenticated with the old credentials.
+// Identifier is an email or a username, as for Login.
+func (u *userUsecase) RestoreAccount(ctx context.Context, identifier, password string) (err error) {
+	var targetID string
+	defer func() {
+		u.recordAudit(ctx, domain.AuditAccountRestore, targetID, outcomeOf(err), withDetail(errDetails(err), "identifier", identifier))
+	}()
+
+	usr, err := u.findByIdentifier(ctx, identifier)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrUserNotFound
+		}
+		return fmt.Errorf("RestoreAccount find: %w", err)
+	}
+	targetID = usr.ID
+
+	if !u.hasher.Verify(ctx, usr.Password, password) {
+		return domain.ErrInvalidCredentials
+	}
+
+	now := time.Now().UTC()
+	if usr.Status != domain.StatusDeleted || usr.PurgeAt == nil || !now.Before(*usr.PurgeAt) {
+		return domain.ErrNotRestorable
+	}
+
+	usr.Status = usr.RestoreStatus
+	if usr.Status == "" {
+		usr.Status = domain.StatusActive
+	}
+	usr.RestoreStatus = ""
+	usr.DeletedAt = nil
+	usr.PurgeAt = nil
+	usr.UpdatedAt = now
+
+	return u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := u.repo.Update(ctx, usr, "status", "restore_status", "deleted_at", "purge_at", "updated_at"); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return domain.ErrUserNotFound
+			}
+			return fmt.Errorf("RestoreAccount Update: %w", err)
+		}
+
+		return u.publisher.PublishUserRestored(ctx, &domain.UserRestoredEvent{
+			UserID:    usr.ID,
+			CreatedAt: now,
+		})
+	})
+}
+
+// Anonymise accounts whose grace period is over, run periodically
+func (u *userUsecase) EraseDueAccounts(ctx context.Context, batchSize int) (int, error) {
+	users, err := u.repo.ListDueForErasure(domain.WithAllTenants(ctx), time.Now().UTC(), batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("EraseDueAccounts list: %w", err)
+	}
+
+	var erased int
+	for _, usr := range users {
+		if err := u.erase(domain.WithTenant(ctx, usr.TenantID), usr); err != nil {
+			if errors.Is(err, domain.ErrUserNotFound) {
+				continue // restored or erased by another replica meanwhile
+			}
+			return erased, err
+		}
+		erased++
+	}
+
+	return erased, nil
+}
+
+func (u *userUsecase) erase(ctx context.Context, usr *domain.User) (err error) {
+	userID := usr.ID
+	now := time.Now().UTC()
+	usr.Anonymize(now)
+
+	err = u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := u.repo.Erase(ctx, usr); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return domain.ErrUserNotFound
+			}
+			return fmt.Errorf("erase Update: %w", err)
+		}
+
+		return u.publisher.PublishUserErased(ctx, &domain.UserErasedEvent{
+			UserID:    userID,
+			CreatedAt: now,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// Purge what lives outside the users collection, the account is already
+	// anonymised so failures are logged and don't undo the erasure
+	if err := u.revoker.RevokeAll(ctx, userID); err != nil {
+		u.log.Error("erase revoke sessions failed", "user_id", userID, "err", err)
+	}
+	if err := u.cache.Delete(ctx, userID); err != nil {
+		u.log.Error("erase delete codes failed", "user_id", userID, "err", err)
+	}
+	if err := u.audit.ScrubDetails(ctx, userID); err != nil {
+		u.log.Error("erase scrub audit log failed", "user_id", userID, "err", err)
+	}
+	if err := u.emailChanges.DeleteUser(ctx, userID); err != nil {
+		u.log.Error("erase delete email changes failed", "user_id", userID, "err", err)
+	}
+	for _, s := range u.scrubbers {
+		if err := s.ScrubUser(ctx, userID); err != nil {
+			u.log.Error("erase scrub failed", "user_id", userID, "store", fmt.Sprintf("%T", s), "err", err)
+		}
+	}
+
+	u.recordAudit(ctx, domain.AuditAccountErase, userID, domain.OutcomeSuccess, nil)
+
+	return nil
+}
+
+func (u *userUsecase) softDelete(ctx context.Context, usr *domain.User, deletedBy string) error {
+	if usr.IsDeleted() {
+		return domain.ErrUserNotFound
+	}
+
+	now := time.Now().UTC()
+	purgeAt := now.Add(u.erasureGrace)
+
+	usr.RestoreStatus = usr.EffectiveStatus(now)
+	usr.Status = domain.StatusDeleted
+	usr.DeletedAt = &now
+	usr.PurgeAt = &purgeAt
+	usr.UpdatedAt = now
+
+	err := u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := u.repo.Update(ctx, usr, "status", "restore_status", "deleted_at", "purge_at", "updated_at"); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return domain.ErrUserNotFound
+			}
+			return fmt.Errorf("softDelete Update: %w", err)
+		}
+
+		return u.publisher.PublishUserDeleted(ctx, &domain.UserDeletedEvent{
+			UserID:    usr.ID,
+			DeletedBy: deletedBy,
+			PurgeAt:   purgeAt,
+			CreatedAt: now,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// Tokens outlive the account otherwise
+	if err := u.revoker.RevokeAll(ctx, usr.ID); err != nil {
+		u.log.Error("softDelete revoke sessions failed", "user_id", usr.ID, "err", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,363 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	exportPageSize    = 500
+	maxExportAttempts = 3
+)
+
+type ExportConfig struct {
+	InlineMaxEntries int           // more audit entries than this runs as a job
+	TTL              time.Duration // bundles are downloadable for
+	Lease            time.Duration // job lock held by one worker
+	TokenTTL         time.Duration // access token lifetime, for active sessions
+}
+
+type exportUsecase struct {
+	auditor
+	log     *logger.Logger
+	users   repository.UserRepository
+	exports repository.ExportRepository
+	revoker domain.SessionRevoker
+	cfg     ExportConfig
+	authorizer
+}
+
+func NewExportUsecase(
+	users repository.UserRepository,
+	exports repository.ExportRepository,
+	audit repository.AuditRepository,
+	revoker domain.SessionRevoker,
+	log *logger.Logger,
+	cfg ExportConfig,
+	authz domain.Authorizer,
+	roles domain.RoleCatalog,
+) ExportUsecase {
+	return &exportUsecase{users: users, exports: exports, revoker: revoker, log: log, cfg: cfg, auditor: auditor{audit: audit, auditLog: log}, authorizer: authorizer{authz: authz, roles: roles}}
+}
+
+func (e *exportUsecase) ExportMyData(ctx context.Context, format domain.ExportFormat) (*domain.ExportResult, error) {
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+	return e.export(ctx, claims.UserID, format)
+}
+
+// Admin export on behalf of a user
+func (e *exportUsecase) ExportUserData(ctx context.Context, userID string, format domain.ExportFormat) (*domain.ExportResult, error) {
+	return e.export(ctx, userID, format)
+}
+
+// Job status, visible to the requester, the subject and admins
+func (e *exportUsecase) GetDataExport(ctx context.Context, id string) (*domain.DataExport, error) {
+	exp, err := e.exports.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrExportNotFound
+		}
+		return nil, fmt.Errorf("GetDataExport: %w", err)
+	}
+
+	res := domain.AuthzResource{Type: domain.ResourceExport, ID: exp.ID, Tenant: exp.TenantID, OwnerID: exp.UserID, Attrs: map[string]string{"requested_by": exp.RequestedBy}}
+	if err := e.authorize(ctx, domain.ActionExportRead, res); err != nil {
+		if errors.Is(err, domain.ErrPermissionDenied) {
+			return nil, domain.ErrExportNotFound
+		}
+		return nil, err
+	}
+	return exp, nil
+}
+
+// The download token is the credential, no session needed
+func (e *exportUsecase) OpenDataExport(ctx context.Context, token string) (*domain.DataExport, io.ReadCloser, error) {
+	exp, err := e.exports.GetByTokenHash(domain.WithAllTenants(ctx), hashToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, domain.ErrExportNotFound
+		}
+		return nil, nil, fmt.Errorf("OpenDataExport: %w", err)
+	}
+	if !time.Now().Before(exp.ExpiresAt) {
+		return nil, nil, domain.ErrExportNotFound
+	}
+	if exp.Status != domain.ExportReady {
+		return nil, nil, domain.ErrExportNotReady
+	}
+
+	bundle, err := e.exports.OpenBundle(domain.WithTenant(ctx, exp.TenantID), exp.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, domain.ErrExportNotFound
+		}
+		return nil, nil, fmt.Errorf("OpenDataExport bundle: %w", err)
+	}
+	return exp, bundle, nil
+}
+
+// Build bundles for pending jobs, run periodically
+func (e *exportUsecase) ProcessPendingExports(ctx context.Context) (int, error) {
+	ctx = domain.WithAllTenants(ctx)
+	var done int
+	for ctx.Err() == nil {
+		exp, err := e.exports.ClaimPending(ctx, time.Now().UTC(), e.cfg.Lease)
+		if err != nil {
+			return done, fmt.Errorf("ProcessPendingExports claim: %w", err)
+		}
+		if exp == nil {
+			return done, nil
+		}
+
+		if err := e.runExport(domain.WithTenant(ctx, exp.TenantID), exp); err != nil {
+			e.log.Error("data export failed", "export_id", exp.ID, "attempt", exp.Attempts, "err", err)
+			// Otherwise picked up again once the lease expires
+			if exp.Attempts >= maxExportAttempts {
+				if err := e.exports.MarkFailed(ctx, exp.ID, err); err != nil {
+					e.log.Error("data export MarkFailed failed", "export_id", exp.ID, "err", err)
+				}
+			}
+			continue
+		}
+		done++
+	}
+	return done, nil
+}
+
+func (e *exportUsecase) PurgeExpiredExports(ctx context.Context) (int, error) {
+	n, err := e.exports.DeleteExpired(domain.WithAllTenants(ctx), time.Now().UTC())
+	if err != nil {
+		return n, fmt.Errorf("PurgeExpiredExports: %w", err)
+	}
+	return n, nil
+}
+
+func (e *exportUsecase) export(ctx context.Context, userID string, format domain.ExportFormat) (_ *domain.ExportResult, err error) {
+	mode := "inline"
+	defer func() {
+		details := withDetail(withDetail(errDetails(err), "format", string(format)), "mode", mode)
+		e.recordAudit(ctx, domain.AuditDataExport, userID, outcomeOf(err), details)
+	}()
+
+	if !format.Valid() {
+		return nil, domain.ErrInvalidExportType
+	}
+
+	usr, err := e.users.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("export fetch: %w", err)
+	}
+	if usr.Status == domain.StatusErased {
+		return nil, domain.ErrUserNotFound
+	}
+	if err := e.authorize(ctx, domain.ActionExportCreate, userResource(usr)); err != nil {
+		return nil, err
+	}
+
+	// Small enough to answer right away
+	rows, complete, err := e.collectAudit(ctx, userID, e.cfg.InlineMaxEntries)
+	if err != nil {
+		return nil, err
+	}
+	if complete {
+		bundle, err := e.buildBundle(ctx, usr, rows, format)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.ExportResult{Bundle: bundle}, nil
+	}
+
+	mode = "async"
+	token, err := randomHex(32)
+	if err != nil {
+		return nil, fmt.Errorf("export token: %w", err)
+	}
+
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+	now := time.Now().UTC()
+	exp := &domain.DataExport{
+		ID:          uuid.NewString(),
+		UserID:      userID,
+		RequestedBy: claims.UserID,
+		Format:      format,
+		Status:      domain.ExportPending,
+		TokenHash:   hashToken(token),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(e.cfg.TTL),
+	}
+	if err := e.exports.Create(ctx, exp); err != nil {
+		return nil, fmt.Errorf("export create job: %w", err)
+	}
+
+	return &domain.ExportResult{Export: exp, DownloadToken: token}, nil
+}
+
+func (e *exportUsecase) runExport(ctx context.Context, exp *domain.DataExport) error {
+	usr, err := e.users.GetByID(ctx, exp.UserID)
+	if err != nil {
+		return fmt.Errorf("runExport fetch: %w", err)
+	}
+
+	rows, _, err := e.collectAudit(ctx, exp.UserID, 0)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := e.buildBundle(ctx, usr, rows, exp.Format)
+	if err != nil {
+		return err
+	}
+
+	return e.exports.SaveBundle(ctx, exp.ID, bundle)
+}
+
+// Audit entries by or about the user, newest first. max 0 means no limit,
+// complete is false if there are more than max entries.
+func (e *exportUsecase) collectAudit(ctx context.Context, userID string, max int) (rows []*domain.AuditEvent, complete bool, err error) {
+	f := domain.AuditFilter{UserID: userID, Limit: exportPageSize}
+	for {
+		if max > 0 {
+			f.Limit = min(exportPageSize, max-len(rows)+1)
+		}
+
+		page, next, err := e.audit.List(ctx, f)
+		if err != nil {
+			return nil, false, fmt.Errorf("collectAudit: %w", err)
+		}
+		rows = append(rows, page...)
+
+		if max > 0 && len(rows) > max {
+			return rows[:max], false, nil
+		}
+		if next == "" {
+			return rows, true, nil
+		}
+		f.Cursor = next
+	}
+}
+
+func (e *exportUsecase) buildBundle(ctx context.Context, usr *domain.User, events []*domain.AuditEvent, format domain.ExportFormat) ([]byte, error) {
+	now := time.Now().UTC()
+
+	b := &domain.UserDataBundle{
+		GeneratedAt: now,
+		Profile: domain.ExportProfile{
+			ID:           usr.ID,
+			Email:        usr.Email,
+			Username:     usr.Username,
+			Phone:        usr.Phone,
+			Role:         usr.Role,
+			Verified:     usr.Verified,
+			Status:       usr.EffectiveStatus(now),
+			StatusReason: usr.StatusReason,
+			CreatedAt:    usr.CreatedAt,
+			UpdatedAt:    usr.UpdatedAt,
+		},
+		Sessions:     domain.ExportSessions{Active: []domain.ExportAuditRow{}},
+		LoginHistory: []domain.ExportAuditRow{},
+		AuditLog:     make([]domain.ExportAuditRow, 0, len(events)),
+	}
+
+	revokedBefore, err := e.revoker.RevokedBefore(ctx, usr.ID)
+	if err != nil {
+		return nil, fmt.Errorf("buildBundle RevokedBefore: %w", err)
+	}
+	if !revokedBefore.IsZero() {
+		b.Sessions.RevokedBefore = &revokedBefore
+	}
+	validSince := now.Add(-e.cfg.TokenTTL)
+
+	for _, ev := range events {
+		row := domain.ExportAuditRow{
+			Action:    ev.Action,
+			ActorID:   ev.ActorID,
+			TargetID:  ev.TargetID,
+			IP:        ev.IP,
+			UserAgent: ev.UserAgent,
+			Outcome:   ev.Outcome,
+			Details:   ev.Details,
+			CreatedAt: ev.CreatedAt,
+		}
+		// Acting on someone else, the target and details are theirs
+		if ev.TargetID != usr.ID {
+			row.TargetID = ""
+			row.Details = nil
+		}
+		// Acted on by someone else, the address and client are theirs. Without
+		// an actor (sign-in, recovery) the request was made for the user.
+		if ev.ActorID != "" && ev.ActorID != usr.ID {
+			row.IP = ""
+			row.UserAgent = ""
+		}
+		b.AuditLog = append(b.AuditLog, row)
+
+		if ev.Action != domain.AuditLogin || ev.TargetID != usr.ID {
+			continue
+		}
+		b.LoginHistory = append(b.LoginHistory, row)
+		if ev.Outcome == domain.OutcomeSuccess && ev.CreatedAt.After(validSince) && ev.CreatedAt.After(revokedBefore) {
+			b.Sessions.Active = append(b.Sessions.Active, row)
+		}
+	}
+
+	return encodeBundle(b, format)
+}
+
+func encodeBundle(b *domain.UserDataBundle, format domain.ExportFormat) ([]byte, error) {
+	if format == domain.ExportJSON {
+		return json.MarshalIndent(b, "", "  ")
+	}
+
+	// One file per section
+	files := []struct {
+		name string
+		data any
+	}{
+		{"metadata.json", map[string]any{"user_id": b.Profile.ID, "generated_at": b.GeneratedAt}},
+		{"profile.json", b.Profile},
+		{"sessions.json", b.Sessions},
+		{"login_history.json", b.LoginHistory},
+		{"audit_log.json", b.AuditLog},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: b.GeneratedAt})
+		if err != nil {
+			return nil, fmt.Errorf("zip %s: %w", f.name, err)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return nil, fmt.Errorf("zip %s: %w", f.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("zip close: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,433 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	maxGroupMembersChange = 500 // users per add or remove
+	maxGroupNameLen       = 128
+)
+
+// Classes and cohorts, owners and members live in the relationship store
+type groupUsecase struct {
+	auditor
+	authorizer
+	log       *logger.Logger
+	groups    repository.GroupRepository
+	relations repository.RelationRepository
+	users     repository.UserRepository
+	tx        repository.TxManager
+	publisher domain.UserEventPublisher
+}
+
+func NewGroupUsecase(
+	groups repository.GroupRepository,
+	relations repository.RelationRepository,
+	users repository.UserRepository,
+	tx repository.TxManager,
+	publisher domain.UserEventPublisher,
+	audit repository.AuditRepository,
+	log *logger.Logger,
+	authz domain.Authorizer,
+	roles domain.RoleCatalog,
+	checker domain.RelationChecker,
+) GroupUsecase {
+	return &groupUsecase{
+		groups:     groups,
+		relations:  relations,
+		users:      users,
+		tx:         tx,
+		publisher:  publisher,
+		log:        log,
+		auditor:    auditor{audit: audit, auditLog: log},
+		authorizer: authorizer{authz: authz, roles: roles, relations: checker},
+	}
+}
+
+// Empty owners makes the caller the owner
+func (g *groupUsecase) CreateGroup(ctx context.Context, name string, kind domain.GroupKind, ownerIDs []string) (grp *domain.Group, err error) {
+	defer func() {
+		var targetID string
+		if grp != nil {
+			targetID = grp.ID
+		}
+		g.recordAudit(ctx, domain.AuditGroupChange, targetID, outcomeOf(err), withDetail(errDetails(err), "op", "create"))
+	}()
+
+	if err := g.authorize(ctx, domain.ActionGroupCreate, domain.AuthzResource{Type: domain.ResourceGroup, Tenant: domain.TenantOf(ctx)}); err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if kind == "" {
+		kind = domain.GroupClass
+	}
+	if name == "" || len(name) > maxGroupNameLen || !kind.Valid() {
+		return nil, domain.ErrInvalidGroup
+	}
+
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+	if len(ownerIDs) == 0 {
+		ownerIDs = []string{claims.UserID}
+	}
+	ownerIDs = uniqueIDs(ownerIDs)
+	if len(ownerIDs) > maxGroupMembersChange {
+		return nil, domain.ErrTooManyMembers
+	}
+	found, missing, err := g.existingUsers(ctx, ownerIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUserNotFound, strings.Join(missing, ", "))
+	}
+
+	now := time.Now().UTC()
+	grp = &domain.Group{
+		ID:        uuid.NewString(),
+		Name:      name,
+		Kind:      kind,
+		CreatedBy: claims.UserID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = g.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := g.groups.Create(ctx, grp); err != nil {
+			return fmt.Errorf("CreateGroup: %w", err)
+		}
+		return g.addTuples(ctx, grp.ID, domain.RelationOwner, found, claims.UserID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return grp, nil
+}
+
+// Users already in the group count as added, unknown users are reported back
+func (g *groupUsecase) AddGroupMembers(ctx context.Context, groupID, relation string, userIDs []string) (res *domain.GroupMembersResult, err error) {
+	defer func() { g.auditMembers(ctx, "add", groupID, relation, res, err) }()
+
+	var grp *domain.Group
+	grp, relation, userIDs, err = g.prepareChange(ctx, groupID, relation, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	found, missing, err := g.existingUsers(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	res = &domain.GroupMembersResult{Changed: found, NotFound: missing}
+	if len(found) == 0 {
+		return res, nil
+	}
+
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+	err = g.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return g.addTuples(ctx, grp.ID, relation, found, claims.UserID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Users not in the group are reported back, erased accounts can still be removed
+func (g *groupUsecase) RemoveGroupMembers(ctx context.Context, groupID, relation string, userIDs []string) (res *domain.GroupMembersResult, err error) {
+	defer func() { g.auditMembers(ctx, "remove", groupID, relation, res, err) }()
+
+	var grp *domain.Group
+	grp, relation, userIDs, err = g.prepareChange(ctx, groupID, relation, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	tuples, err := g.relations.ListByObject(ctx, domain.ObjectGroup, grp.ID)
+	if err != nil {
+		return nil, fmt.Errorf("RemoveGroupMembers list: %w", err)
+	}
+	current := make(map[string]struct{}, len(tuples))
+	for _, t := range tuples {
+		if t.Relation == relation && t.Subject.Type == domain.ObjectUser && t.Subject.Relation == "" {
+			current[t.Subject.ID] = struct{}{}
+		}
+	}
+
+	res = &domain.GroupMembersResult{}
+	var deletes []domain.RelationTuple
+	for _, id := range userIDs {
+		if _, ok := current[id]; !ok {
+			res.NotFound = append(res.NotFound, id)
+			continue
+		}
+		res.Changed = append(res.Changed, id)
+		deletes = append(deletes, groupTuple(grp.ID, relation, id))
+	}
+	if len(deletes) == 0 {
+		return res, nil
+	}
+
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+	err = g.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := g.relations.Delete(ctx, deletes); err != nil {
+			return fmt.Errorf("RemoveGroupMembers: %w", err)
+		}
+		return g.publisher.PublishGroupMembersRemoved(ctx, &domain.GroupMembersRemovedEvent{
+			GroupID:   grp.ID,
+			Relation:  relation,
+			UserIDs:   res.Changed,
+			ChangedBy: claims.UserID,
+			CreatedAt: time.Now().UTC(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Groups the user owns or belongs to, empty userID is the caller
+func (g *groupUsecase) ListUserGroups(ctx context.Context, userID string) ([]*domain.GroupMembership, error) {
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+	if userID == "" {
+		userID = claims.UserID
+	}
+	if userID != claims.UserID {
+		usr, err := g.users.GetByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, domain.ErrUserNotFound
+			}
+			return nil, fmt.Errorf("ListUserGroups user: %w", err)
+		}
+		if err := g.authorize(ctx, domain.ActionUserRead, userResource(usr)); err != nil {
+			return nil, err
+		}
+	}
+
+	tuples, err := g.relations.ListBySubjects(ctx, []string{domain.UserSubject(userID).String()})
+	if err != nil {
+		return nil, fmt.Errorf("ListUserGroups tuples: %w", err)
+	}
+	relationOf := make(map[string]string)
+	var ids []string
+	for _, t := range tuples {
+		if t.ObjectType != domain.ObjectGroup || (t.Relation != domain.RelationOwner && t.Relation != domain.RelationMember) {
+			continue
+		}
+		// Owner wins when a user is both
+		if _, ok := relationOf[t.ObjectID]; !ok {
+			ids = append(ids, t.ObjectID)
+		}
+		if relationOf[t.ObjectID] != domain.RelationOwner {
+			relationOf[t.ObjectID] = t.Relation
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	groups, err := g.groups.ListByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("ListUserGroups: %w", err)
+	}
+	out := make([]*domain.GroupMembership, 0, len(groups))
+	for _, grp := range groups {
+		out = append(out, &domain.GroupMembership{Group: grp, Relation: relationOf[grp.ID]})
+	}
+	return out, nil
+}
+
+// Owners first, then members
+func (g *groupUsecase) ListGroupMembers(ctx context.Context, groupID string) ([]*domain.GroupMember, error) {
+	grp, err := g.group(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	tuples, err := g.relations.ListByObject(ctx, domain.ObjectGroup, grp.ID)
+	if err != nil {
+		return nil, fmt.Errorf("ListGroupMembers tuples: %w", err)
+	}
+	if err := g.authorize(ctx, domain.ActionGroupRead, g.groupResource(ctx, grp, tuples)); err != nil {
+		return nil, err
+	}
+
+	var owners, members []*domain.GroupMember
+	var ids []string
+	for _, t := range tuples {
+		if t.Subject.Type != domain.ObjectUser || t.Subject.Relation != "" {
+			continue
+		}
+		m := &domain.GroupMember{UserID: t.Subject.ID, Relation: t.Relation}
+		switch t.Relation {
+		case domain.RelationOwner:
+			owners = append(owners, m)
+		case domain.RelationMember:
+			members = append(members, m)
+		default:
+			continue
+		}
+		ids = append(ids, t.Subject.ID)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	users, err := g.users.ListByIDs(ctx, uniqueIDs(ids))
+	if err != nil {
+		return nil, fmt.Errorf("ListGroupMembers users: %w", err)
+	}
+	byID := make(map[string]*domain.User, len(users))
+	for _, u := range users {
+		if !u.IsDeleted() {
+			byID[u.ID] = u
+		}
+	}
+
+	out := append(owners, members...)
+	for _, m := range out {
+		m.User = byID[m.UserID]
+	}
+	return out, nil
+}
+
+// Validate a bulk change and authorize it on the group, empty relation is member
+func (g *groupUsecase) prepareChange(ctx context.Context, groupID, relation string, userIDs []string) (*domain.Group, string, []string, error) {
+	if relation == "" {
+		relation = domain.RelationMember
+	}
+	if relation != domain.RelationMember && relation != domain.RelationOwner {
+		return nil, "", nil, domain.ErrInvalidGroup
+	}
+	userIDs = uniqueIDs(userIDs)
+	if len(userIDs) == 0 {
+		return nil, "", nil, domain.ErrInvalidGroup
+	}
+	if len(userIDs) > maxGroupMembersChange {
+		return nil, "", nil, domain.ErrTooManyMembers
+	}
+
+	grp, err := g.group(ctx, groupID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	tuples, err := g.relations.ListByObject(ctx, domain.ObjectGroup, grp.ID)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("group tuples: %w", err)
+	}
+	if err := g.authorize(ctx, domain.ActionGroupManage, g.groupResource(ctx, grp, tuples)); err != nil {
+		return nil, "", nil, err
+	}
+	return grp, relation, userIDs, nil
+}
+
+func (g *groupUsecase) group(ctx context.Context, id string) (*domain.Group, error) {
+	grp, err := g.groups.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrGroupNotFound
+		}
+		return nil, fmt.Errorf("group: %w", err)
+	}
+	return grp, nil
+}
+
+// owned_by_subject for group owners, members are matched with subject.groups
+func (g *groupUsecase) groupResource(ctx context.Context, grp *domain.Group, tuples []domain.RelationTuple) domain.AuthzResource {
+	res := domain.AuthzResource{Type: domain.ResourceGroup, ID: grp.ID, Tenant: grp.TenantID, Attrs: map[string]string{"kind": string(grp.Kind)}}
+
+	claims, ok := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+	if !ok {
+		return res
+	}
+	for _, t := range tuples {
+		if t.Relation == domain.RelationOwner && t.Subject == domain.UserSubject(claims.UserID) {
+			res.Attrs["owned_by_subject"] = "true"
+			break
+		}
+	}
+	return res
+}
+
+// Users of the tenant that exist and aren't deleted, in the given order
+func (g *groupUsecase) existingUsers(ctx context.Context, ids []string) (found, missing []string, err error) {
+	users, err := g.users.ListByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("group users: %w", err)
+	}
+	live := make(map[string]struct{}, len(users))
+	for _, u := range users {
+		if !u.IsDeleted() {
+			live[u.ID] = struct{}{}
+		}
+	}
+	for _, id := range ids {
+		if _, ok := live[id]; ok {
+			found = append(found, id)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return found, missing, nil
+}
+
+// Run inside the caller's transaction, the event goes out with the tuples
+func (g *groupUsecase) addTuples(ctx context.Context, groupID, relation string, userIDs []string, changedBy string) error {
+	writes := make([]domain.RelationTuple, 0, len(userIDs))
+	for _, id := range userIDs {
+		writes = append(writes, groupTuple(groupID, relation, id))
+	}
+	if err := g.relations.Write(ctx, writes); err != nil {
+		return fmt.Errorf("group add %s: %w", relation, err)
+	}
+	return g.publisher.PublishGroupMembersAdded(ctx, &domain.GroupMembersAddedEvent{
+		GroupID:   groupID,
+		Relation:  relation,
+		UserIDs:   userIDs,
+		ChangedBy: changedBy,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+func (g *groupUsecase) auditMembers(ctx context.Context, op, groupID, relation string, res *domain.GroupMembersResult, err error) {
+	details := withDetail(errDetails(err), "op", op)
+	details = withDetail(details, "relation", relation)
+	if res != nil {
+		details = withDetail(details, "changed", strconv.Itoa(len(res.Changed)))
+	}
+	g.recordAudit(ctx, domain.AuditGroupChange, groupID, outcomeOf(err), details)
+}
+
+func groupTuple(groupID, relation, userID string) domain.RelationTuple {
+	return domain.RelationTuple{ObjectType: domain.ObjectGroup, ObjectID: groupID, Relation: relation, Subject: domain.UserSubject(userID)}
+}
+
+// Drops blanks and repeats, keeps the order
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]struct{}, len(ids))
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	return out
+}
@@ -0,0 +1,1288 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/Neroframe/AuthService/pkg/roster"
+	"github.com/google/uuid"
+)
+
+const (
+	maxImportAttempts       = 3
+	defaultImportPageSize   = 100
+	maxImportPageSize       = 1000
+	importInviteBatch       = 100
+	generatedPasswordLength = 16
+)
+
+type ImportConfig struct {
+	Dir          string        // server paths are read from, empty disables StartImport
+	BatchSize    int           // rows written in one transaction
+	Lease        time.Duration // job lock held by one worker, renewed every batch
+	MaxFileBytes int64         // per uploaded file
+	ResultTTL    time.Duration // jobs, files and row results are kept for
+}
+
+// Bulk provisioning from CSV or OneRoster files, run by a background worker
+type importUsecase struct {
+	auditor
+	authorizer
+	tenancy
+	log         *logger.Logger
+	imports     repository.ImportRepository
+	users       repository.UserRepository
+	groups      repository.GroupRepository
+	relations   repository.RelationRepository
+	tx          repository.TxManager
+	hasher      domain.PasswordHasher
+	publisher   domain.UserEventPublisher
+	revoker     domain.SessionRevoker
+	invitations InvitationUsecase
+	emails      domain.EmailNormalizer
+	defaultCC   string
+	cfg         ImportConfig
+}
+
+func NewImportUsecase(
+	imports repository.ImportRepository,
+	users repository.UserRepository,
+	groups repository.GroupRepository,
+	relations repository.RelationRepository,
+	tx repository.TxManager,
+	hasher domain.PasswordHasher,
+	publisher domain.UserEventPublisher,
+	revoker domain.SessionRevoker,
+	invitations InvitationUsecase,
+	emails domain.EmailNormalizer,
+	defaultCC string,
+	tenants domain.TenantDirectory,
+	cfg ImportConfig,
+	audit repository.AuditRepository,
+	log *logger.Logger,
+	authz domain.Authorizer,
+	roles domain.RoleCatalog,
+) ImportUsecase {
+	return &importUsecase{
+		imports:     imports,
+		users:       users,
+		groups:      groups,
+		relations:   relations,
+		tx:          tx,
+		hasher:      hasher,
+		publisher:   publisher,
+		revoker:     revoker,
+		invitations: invitations,
+		emails:      emails,
+		defaultCC:   defaultCC,
+		cfg:         cfg,
+		log:         log,
+		tenancy:     tenancy{tenants: tenants},
+		auditor:     auditor{audit: audit, auditLog: log},
+		authorizer:  authorizer{authz: authz, roles: roles},
+	}
+}
+
+// Files other than users.csv and enrollments.csv of a OneRoster upload are ignored
+func (m *importUsecase) UploadImport(ctx context.Context, opts domain.ImportOptions, upload domain.ImportUpload) (job *domain.ImportJob, err error) {
+	var jobID string
+	defer func() {
+		m.recordAudit(ctx, domain.AuditUserImport, jobID, outcomeOf(err), importDetails(errDetails(err), "upload", opts))
+	}()
+
+	if job, err = m.newJob(ctx, opts); err != nil {
+		return nil, err
+	}
+	job.Status = domain.ImportUploading
+	if err := m.imports.Create(ctx, job); err != nil {
+		return nil, fmt.Errorf("UploadImport Create: %w", err)
+	}
+	jobID = job.ID
+
+	files, err := m.saveUploads(ctx, job, upload)
+	if err == nil {
+		files, err = orderFiles(job.Format, files)
+	}
+	if err != nil {
+		// Kept until it expires so the stored files are purged with it
+		if err := m.imports.MarkFailed(ctx, job.ID, err); err != nil {
+			m.log.Error("import MarkFailed failed", "import_id", job.ID, "err", err)
+		}
+		return nil, err
+	}
+
+	if err := m.imports.Queue(ctx, job.ID, files); err != nil {
+		return nil, fmt.Errorf("UploadImport Queue: %w", err)
+	}
+	job.Files = files
+	job.Status = domain.ImportPending
+	return job, nil
+}
+
+// Paths are relative to the import dir, files are read when the job runs
+func (m *importUsecase) StartImport(ctx context.Context, opts domain.ImportOptions, paths []string) (job *domain.ImportJob, err error) {
+	defer func() {
+		var jobID string
+		if job != nil {
+			jobID = job.ID
+		}
+		m.recordAudit(ctx, domain.AuditUserImport, jobID, outcomeOf(err), importDetails(errDetails(err), "start", opts))
+	}()
+
+	if m.cfg.Dir == "" {
+		return nil, domain.ErrImportPathsOff
+	}
+	if job, err = m.newJob(ctx, opts); err != nil {
+		return nil, err
+	}
+
+	var files []domain.ImportFile
+	for _, p := range paths {
+		if !filepath.IsLocal(p) {
+			return nil, fmt.Errorf("%w: %s is outside the import dir", domain.ErrInvalidImport, p)
+		}
+		name, ok := importFileName(job.Format, p)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not users.csv or enrollments.csv", domain.ErrInvalidImport, p)
+		}
+		if hasImportFile(files, name) {
+			return nil, fmt.Errorf("%w: more than one %s", domain.ErrInvalidImport, name)
+		}
+		if info, err := os.Stat(filepath.Join(m.cfg.Dir, p)); err != nil || info.IsDir() {
+			return nil, fmt.Errorf("%w: %s not found", domain.ErrInvalidImport, p)
+		}
+		files = append(files, domain.ImportFile{Name: name, Path: p})
+	}
+	if job.Files, err = orderFiles(job.Format, files); err != nil {
+		return nil, err
+	}
+
+	job.Status = domain.ImportPending
+	if err := m.imports.Create(ctx, job); err != nil {
+		return nil, fmt.Errorf("StartImport Create: %w", err)
+	}
+	return job, nil
+}
+
+func (m *importUsecase) GetImport(ctx context.Context, id string) (*domain.ImportJob, error) {
+	job, err := m.imports.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrImportNotFound
+		}
+		return nil, fmt.Errorf("GetImport: %w", err)
+	}
+
+	res := domain.AuthzResource{Type: domain.ResourceImport, ID: job.ID, Tenant: job.TenantID, OwnerID: job.CreatedBy}
+	if err := m.authorize(ctx, domain.ActionUserImport, res); err != nil {
+		if errors.Is(err, domain.ErrPermissionDenied) {
+			return nil, domain.ErrImportNotFound
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// By file and line, an empty outcome lists every row
+func (m *importUsecase) ListImportResults(ctx context.Context, id string, outcome domain.ImportOutcome, cursor string, limit int) ([]*domain.ImportRow, string, error) {
+	if outcome != "" && !outcome.Valid() {
+		return nil, "", domain.ErrInvalidImport
+	}
+	if limit <= 0 {
+		limit = defaultImportPageSize
+	}
+	limit = min(limit, maxImportPageSize)
+
+	job, err := m.GetImport(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	rows, next, err := m.imports.ListRows(ctx, job.ID, outcome, cursor, limit)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, "", domain.ErrInvalidCursor
+		}
+		return nil, "", fmt.Errorf("ListImportResults: %w", err)
+	}
+
+	// Generated passwords are handed out once, the first read clears them
+	if job.Credentials == domain.CredentialsPassword && !job.DryRun {
+		var ids []string
+		for _, row := range rows {
+			if row.Outcome == domain.RowCreated {
+				ids = append(ids, row.ID)
+			}
+		}
+		if len(ids) > 0 {
+			passwords, err := m.imports.TakePasswords(ctx, job.ID, ids)
+			if err != nil {
+				return nil, "", fmt.Errorf("ListImportResults TakePasswords: %w", err)
+			}
+			for _, row := range rows {
+				row.Password = passwords[row.ID]
+			}
+		}
+	}
+	return rows, next, nil
+}
+
+// Continues a failed job after the last committed batch
+func (m *importUsecase) ResumeImport(ctx context.Context, id string) (job *domain.ImportJob, err error) {
+	defer func() {
+		m.recordAudit(ctx, domain.AuditUserImport, id, outcomeOf(err), withDetail(errDetails(err), "op", "resume"))
+	}()
+
+	if job, err = m.GetImport(ctx, id); err != nil {
+		return nil, err
+	}
+	// A failed upload never got its files
+	if job.Status != domain.ImportFailed || len(job.Files) == 0 {
+		return nil, domain.ErrImportNotResumable
+	}
+	if err := m.imports.Resume(ctx, job.ID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrImportNotResumable
+		}
+		return nil, fmt.Errorf("ResumeImport: %w", err)
+	}
+
+	job.Status = domain.ImportPending
+	job.Error = ""
+	job.Attempts = 0
+	job.CompletedAt = nil
+	return job, nil
+}
+
+// Run pending jobs, called periodically
+func (m *importUsecase) ProcessPendingImports(ctx context.Context) (int, error) {
+	ctx = domain.WithAllTenants(ctx)
+	var done int
+	for ctx.Err() == nil {
+		job, err := m.imports.ClaimPending(ctx, time.Now().UTC(), m.cfg.Lease)
+		if err != nil {
+			return done, fmt.Errorf("ProcessPendingImports claim: %w", err)
+		}
+		if job == nil {
+			return done, nil
+		}
+
+		jobCtx := domain.WithTenant(ctx, job.TenantID)
+		if err := m.runImport(jobCtx, job); err != nil {
+			m.log.Error("import failed", "import_id", job.ID, "attempt", job.Attempts, "err", err)
+			// Otherwise picked up again once the lease expires, after the last committed batch
+			if job.Attempts >= maxImportAttempts {
+				if err := m.imports.MarkFailed(jobCtx, job.ID, err); err != nil {
+					m.log.Error("import MarkFailed failed", "import_id", job.ID, "err", err)
+				}
+				m.recordAudit(jobCtx, domain.AuditUserImport, job.ID, domain.OutcomeFailure, withDetail(errDetails(err), "op", "run"))
+			}
+			continue
+		}
+		done++
+	}
+	return done, nil
+}
+
+func (m *importUsecase) PurgeExpiredImports(ctx context.Context) (int, error) {
+	n, err := m.imports.DeleteExpired(domain.WithAllTenants(ctx), time.Now().UTC())
+	if err != nil {
+		return n, fmt.Errorf("PurgeExpiredImports: %w", err)
+	}
+	return n, nil
+}
+
+func (m *importUsecase) newJob(ctx context.Context, opts domain.ImportOptions) (*domain.ImportJob, error) {
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+
+	if err := m.authorize(ctx, domain.ActionUserImport, domain.AuthzResource{Type: domain.ResourceImport, Tenant: domain.TenantOf(ctx)}); err != nil {
+		return nil, err
+	}
+	if !opts.Format.Valid() || !opts.Credentials.Valid() {
+		return nil, domain.ErrInvalidImport
+	}
+	if opts.DefaultRole != "" {
+		exists, err := m.roles.RoleExists(ctx, opts.DefaultRole)
+		if err != nil {
+			return nil, fmt.Errorf("import RoleExists: %w", err)
+		}
+		if !exists {
+			return nil, domain.ErrRoleNotFound
+		}
+	}
+
+	now := time.Now().UTC()
+	return &domain.ImportJob{
+		ID:          uuid.NewString(),
+		CreatedBy:   claims.UserID,
+		Format:      opts.Format,
+		Credentials: opts.Credentials,
+		DefaultRole: opts.DefaultRole,
+		DryRun:      opts.DryRun,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ExpiresAt:   now.Add(m.cfg.ResultTTL),
+	}, nil
+}
+
+func (m *importUsecase) saveUploads(ctx context.Context, job *domain.ImportJob, upload domain.ImportUpload) ([]domain.ImportFile, error) {
+	var files []domain.ImportFile
+	for {
+		name, data, err := upload.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name, ok := importFileName(job.Format, name)
+		if !ok {
+			continue // e.g. orgs.csv of a full OneRoster export
+		}
+		if hasImportFile(files, name) {
+			return nil, fmt.Errorf("%w: more than one %s", domain.ErrInvalidImport, name)
+		}
+		if err := m.imports.SaveFile(ctx, job.ID, name, &sizeLimit{r: data, n: m.cfg.MaxFileBytes}); err != nil {
+			if errors.Is(err, domain.ErrImportFileTooLarge) {
+				return nil, domain.ErrImportFileTooLarge
+			}
+			return nil, fmt.Errorf("UploadImport SaveFile: %w", err)
+		}
+		files = append(files, domain.ImportFile{Name: name})
+	}
+}
+
+// State of one run of a job, shared by its batches
+type importRun struct {
+	job     *domain.ImportJob
+	policy  domain.PasswordPolicy
+	roles   map[domain.Role]bool // checked against the catalog
+	planned map[string]bool      // external IDs a dry run would create
+}
+
+// Rows of one batch, results[i] is data row start+i+1 of the file
+type importBatch struct {
+	run     *importRun
+	file    int
+	start   int
+	results []*domain.ImportRow
+	writes  []func(ctx context.Context) error // nil for rows with nothing to write
+	revoke  []string                          // user whose sessions end once written
+}
+
+func (m *importUsecase) runImport(ctx context.Context, job *domain.ImportJob) error {
+	t, err := m.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	run := &importRun{job: job, policy: t.Config.Password, roles: map[domain.Role]bool{}, planned: map[string]bool{}}
+
+	// Invitations of batches committed before the last attempt stopped
+	if err := m.sendPendingInvites(ctx, job); err != nil {
+		return err
+	}
+	for i, f := range job.Files {
+		if f.Done {
+			continue
+		}
+		if err := m.runFile(ctx, run, i); err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	if err := m.imports.MarkDone(ctx, job.ID); err != nil {
+		return fmt.Errorf("import MarkDone: %w", err)
+	}
+
+	// Counts as of the claim, reload for the final ones
+	if done, err := m.imports.GetByID(ctx, job.ID); err == nil {
+		job = done
+	}
+	details := map[string]string{
+		"op":         "run",
+		"dry_run":    strconv.FormatBool(job.DryRun),
+		"created":    strconv.Itoa(job.Counts.Created),
+		"updated":    strconv.Itoa(job.Counts.Updated),
+		"removed":    strconv.Itoa(job.Counts.Removed),
+		"invalid":    strconv.Itoa(job.Counts.Invalid),
+		"failed":     strconv.Itoa(job.Counts.Failed),
+		"created_by": job.CreatedBy,
+	}
+	m.recordAudit(ctx, domain.AuditUserImport, job.ID, domain.OutcomeSuccess, details)
+	return nil
+}
+
+func (m *importUsecase) runFile(ctx context.Context, run *importRun, file int) error {
+	f := run.job.Files[file]
+	var (
+		rc  io.ReadCloser
+		err error
+	)
+	if f.Path != "" {
+		rc, err = os.Open(filepath.Join(m.cfg.Dir, f.Path))
+	} else {
+		rc, err = m.imports.OpenFile(ctx, run.job.ID, f.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer rc.Close()
+
+	enrollments := f.Name == roster.EnrollmentsFile
+	var r *roster.Reader
+	if enrollments {
+		r, err = roster.NewEnrollmentReader(rc)
+	} else {
+		r, err = roster.NewUserReader(rc)
+	}
+	if err != nil {
+		return err
+	}
+	if err := r.Skip(f.Rows); err != nil {
+		return fmt.Errorf("skip to row %d: %w", f.Rows, err)
+	}
+
+	size := max(m.cfg.BatchSize, 1)
+	for {
+		b := &importBatch{run: run, file: file, start: r.Count()}
+		var eof bool
+		if enrollments {
+			eof, err = m.readEnrollments(ctx, b, r, size)
+		} else {
+			eof, err = m.readUsers(ctx, b, r, size)
+		}
+		if err != nil {
+			return err
+		}
+		if err := m.commit(ctx, b, eof); err != nil {
+			return err
+		}
+		if err := m.sendPendingInvites(ctx, run.job); err != nil {
+			return err
+		}
+		if eof {
+			return nil
+		}
+	}
+}
+
+// Writes the batch and its progress in one transaction. If that fails the rows
+// are written one by one, so a row another request made invalid meanwhile
+// doesn't hold back the rest.
+func (m *importUsecase) commit(ctx context.Context, b *importBatch, eof bool) error {
+	job := b.run.job
+	end := b.start + len(b.results)
+	err := m.tx.WithinTx(ctx, func(ctx context.Context) error {
+		for _, write := range b.writes {
+			if write == nil {
+				continue
+			}
+			if err := write(ctx); err != nil {
+				return err
+			}
+		}
+		return m.imports.SaveProgress(ctx, job.ID, b.file, end, eof, b.results, time.Now().UTC().Add(m.cfg.Lease))
+	})
+	if err == nil {
+		m.endSessions(ctx, b.revoke...)
+		return nil
+	}
+	if !isImportRowErr(err) {
+		return fmt.Errorf("write batch: %w", err)
+	}
+
+	for i, res := range b.results {
+		cursor := b.start + i + 1
+		done := eof && cursor == end
+		if write := b.writes[i]; write != nil {
+			err := m.tx.WithinTx(ctx, func(ctx context.Context) error {
+				if err := write(ctx); err != nil {
+					return err
+				}
+				return m.imports.SaveProgress(ctx, job.ID, b.file, cursor, done, []*domain.ImportRow{res}, time.Now().UTC().Add(m.cfg.Lease))
+			})
+			if err == nil {
+				m.endSessions(ctx, b.revoke[i])
+				continue
+			}
+			if !isImportRowErr(err) {
+				return fmt.Errorf("write row %d: %w", res.Line, err)
+			}
+			res.Outcome = domain.RowFailed
+			res.Error = err.Error()
+			res.Fields = nil
+			res.Password = ""
+			res.InvitePending = false
+		}
+		if err := m.imports.SaveProgress(ctx, job.ID, b.file, cursor, done, []*domain.ImportRow{res}, time.Now().UTC().Add(m.cfg.Lease)); err != nil {
+			return fmt.Errorf("save row %d: %w", res.Line, err)
+		}
+	}
+	return nil
+}
+
+// Role changes and disabled accounts apply to issued tokens too
+func (m *importUsecase) endSessions(ctx context.Context, userIDs ...string) {
+	for _, id := range userIDs {
+		if id == "" {
+			continue
+		}
+		if err := m.revoker.RevokeAll(ctx, id); err != nil {
+			m.log.Error("import revoke sessions failed", "user_id", id, "err", err)
+		}
+	}
+}
+
+func (m *importUsecase) sendPendingInvites(ctx context.Context, job *domain.ImportJob) error {
+	for {
+		rows, err := m.imports.ListPendingInvites(ctx, job.ID, importInviteBatch)
+		if err != nil {
+			return fmt.Errorf("import pending invites: %w", err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		for _, row := range rows {
+			if err := m.invite(ctx, job, row); err != nil {
+				return err
+			}
+			if err := m.imports.ClearInvitePending(ctx, row.ID); err != nil {
+				return fmt.Errorf("import ClearInvitePending: %w", err)
+			}
+		}
+	}
+}
+
+// Accounts that got a password or went away since don't need the invite
+func (m *importUsecase) invite(ctx context.Context, job *domain.ImportJob, row *domain.ImportRow) error {
+	usr, err := m.users.GetByID(ctx, row.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("import invite user: %w", err)
+	}
+	if usr.IsDeleted() || usr.Password != "" || usr.Email == "" {
+		return nil
+	}
+	if _, err := m.invitations.InviteProvisioned(ctx, usr, job.CreatedBy); err != nil {
+		return fmt.Errorf("import invite: %w", err)
+	}
+	return nil
+}
+
+// Adds the result of a data row to the batch, the index of its write
+func (b *importBatch) add(line int) int {
+	job := b.run.job
+	b.results = append(b.results, &domain.ImportRow{
+		ID:        job.ID + "/" + job.Files[b.file].Name + "/" + strconv.Itoa(line),
+		JobID:     job.ID,
+		File:      job.Files[b.file].Name,
+		Line:      line,
+		ExpiresAt: job.ExpiresAt,
+	})
+	b.writes = append(b.writes, nil)
+	b.revoke = append(b.revoke, "")
+	return len(b.results) - 1
+}
+
+func (b *importBatch) reject(i int, outcome domain.ImportOutcome, err error) {
+	b.results[i].Outcome = outcome
+	b.results[i].Error = err.Error()
+}
+
+// Account row of the users file, normalised
+type userRow struct {
+	src      *roster.User
+	i        int
+	email    string
+	key      string
+	username string
+	phone    string
+	role     domain.Role
+	status   domain.UserStatus // set by the enabled column or tobedeleted
+}
+
+func (m *importUsecase) readUsers(ctx context.Context, b *importBatch, r *roster.Reader, size int) (bool, error) {
+	var rows []*userRow
+	var eof bool
+	for len(b.results) < size {
+		src, err := r.User()
+		if errors.Is(err, io.EOF) {
+			eof = true
+			break
+		}
+		var rowErr *roster.RowError
+		if errors.As(err, &rowErr) {
+			b.reject(b.add(rowErr.Line), domain.RowInvalid, rowErr.Err)
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("read: %w", err)
+		}
+
+		row := &userRow{src: src, i: b.add(src.Line)}
+		res := b.results[row.i]
+		res.ExternalID, res.Email, res.Username = src.ExternalID, src.Email, src.Username
+		if err := m.parseUser(ctx, b.run, row); err != nil {
+			b.reject(row.i, domain.RowInvalid, err)
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return eof, m.planUsers(ctx, b, rows)
+}
+
+func (m *importUsecase) parseUser(ctx context.Context, run *importRun, row *userRow) error {
+	src := row.src
+	if src.ExternalID == "" && src.Email == "" && src.Username == "" {
+		return errors.New("external_id, email or username is required")
+	}
+
+	var err error
+	if src.Email != "" {
+		if row.email, row.key, err = m.emails.Normalize(src.Email); err != nil {
+			return err
+		}
+	}
+	if src.Username != "" {
+		if row.username, err = domain.NormalizeUsername(src.Username); err != nil {
+			return err
+		}
+	}
+	if src.Phone != "" {
+		if row.phone, err = domain.NormalizePhone(src.Phone, m.defaultCC); err != nil {
+			return err
+		}
+	}
+	if src.Role != "" {
+		if row.role, err = m.importRole(ctx, run, src.Role); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case src.Status == roster.StatusToBeDeleted, src.Enabled != nil && !*src.Enabled:
+		row.status = domain.StatusDisabled
+	case src.Enabled != nil:
+		row.status = domain.StatusActive
+	}
+	return nil
+}
+
+// OneRoster admin roles map to admin, anything else must be a role of the catalog
+func (m *importUsecase) importRole(ctx context.Context, run *importRun, raw string) (domain.Role, error) {
+	role := domain.Role(raw)
+	switch raw {
+	case "administrator", "districtadministrator", "siteadministrator", "systemadministrator":
+		role = domain.ADMIN
+	}
+
+	exists, ok := run.roles[role]
+	if !ok {
+		var err error
+		if exists, err = m.roles.RoleExists(ctx, role); err != nil {
+			return "", fmt.Errorf("import RoleExists: %w", err)
+		}
+		run.roles[role] = exists
+	}
+	if !exists {
+		return "", fmt.Errorf("%w: %s", domain.ErrRoleNotFound, raw)
+	}
+	return role, nil
+}
+
+// Matches rows to accounts by external ID, email or username and plans the writes
+func (m *importUsecase) planUsers(ctx context.Context, b *importBatch, rows []*userRow) error {
+	var extIDs, keys, usernames []string
+	seen := map[string]int{} // identifier -> line
+	var valid []*userRow
+	for _, row := range rows {
+		ids := []string{"external_id:" + row.src.ExternalID, "email:" + row.key, "username:" + strings.ToLower(row.username)}
+		if dup := firstSeen(seen, ids); dup != 0 {
+			b.reject(row.i, domain.RowInvalid, fmt.Errorf("same account as line %d", dup))
+			continue
+		}
+		for _, id := range ids {
+			if !strings.HasSuffix(id, ":") {
+				seen[id] = row.src.Line
+			}
+		}
+		extIDs = appendNonEmpty(extIDs, row.src.ExternalID)
+		keys = appendNonEmpty(keys, row.key)
+		usernames = appendNonEmpty(usernames, row.username)
+		valid = append(valid, row)
+	}
+
+	byExt, byKey, byName := map[string]*domain.User{}, map[string]*domain.User{}, map[string]*domain.User{}
+	if len(extIDs) > 0 {
+		found, err := m.users.ListByExternalIDs(ctx, extIDs)
+		if err != nil {
+			return fmt.Errorf("import ListByExternalIDs: %w", err)
+		}
+		for _, u := range found {
+			byExt[u.ExternalID] = u
+		}
+	}
+	if len(keys) > 0 {
+		found, err := m.users.ListByEmailKeys(ctx, keys)
+		if err != nil {
+			return fmt.Errorf("import ListByEmailKeys: %w", err)
+		}
+		for _, u := range found {
+			byKey[u.EmailKey] = u
+		}
+	}
+	if len(usernames) > 0 {
+		found, err := m.users.ListByUsernames(ctx, usernames)
+		if err != nil {
+			return fmt.Errorf("import ListByUsernames: %w", err)
+		}
+		for _, u := range found {
+			byName[strings.ToLower(u.Username)] = u
+		}
+	}
+
+	matched := map[string]int{} // user ID -> line
+	for _, row := range valid {
+		usr, err := matchUser(row, byExt[row.src.ExternalID], byKey[row.key], byName[strings.ToLower(row.username)])
+		if err == nil && usr != nil {
+			if line, ok := matched[usr.ID]; ok {
+				err = fmt.Errorf("same account as line %d", line)
+			} else {
+				matched[usr.ID] = row.src.Line
+			}
+		}
+		switch {
+		case err != nil:
+			b.reject(row.i, domain.RowInvalid, err)
+		case usr != nil && usr.IsDeleted():
+			b.reject(row.i, domain.RowSkipped, domain.ErrAccountDeleted)
+		case usr != nil:
+			m.planUpdate(b, row, usr)
+		case row.src.Status == roster.StatusToBeDeleted:
+			b.reject(row.i, domain.RowSkipped, domain.ErrUserNotFound)
+		default:
+			if err := m.planCreate(ctx, b, row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// The identifiers of a row must not point at different accounts
+func matchUser(row *userRow, candidates ...*domain.User) (*domain.User, error) {
+	var found *domain.User
+	for _, u := range candidates {
+		if u == nil {
+			continue
+		}
+		if found != nil && found.ID != u.ID {
+			return nil, errors.New("external_id, email and username match different accounts")
+		}
+		found = u
+	}
+	if found != nil && row.src.ExternalID != "" && found.ExternalID != "" && found.ExternalID != row.src.ExternalID {
+		return nil, fmt.Errorf("account already has external_id %s", found.ExternalID)
+	}
+	return found, nil
+}
+
+// Only columns with a value are applied, a password is never changed
+func (m *importUsecase) planUpdate(b *importBatch, row *userRow, usr *domain.User) {
+	job := b.run.job
+	res := b.results[row.i]
+	res.UserID = usr.ID
+
+	var changed, fields []string
+	if row.src.ExternalID != "" && usr.ExternalID != row.src.ExternalID {
+		usr.ExternalID = row.src.ExternalID
+		changed = append(changed, "external_id")
+		fields = append(fields, "external_id")
+	}
+	if row.email != "" && usr.Email != row.email {
+		usr.Email, usr.EmailKey = row.email, row.key
+		usr.Verified = false // new address isn't proven
+		changed = append(changed, "email")
+		fields = append(fields, "email", "email_key", "verified")
+	}
+	if row.username != "" && usr.Username != row.username {
+		usr.Username = row.username
+		changed = append(changed, "username")
+		fields = append(fields, "username")
+	}
+	if row.phone != "" && usr.Phone != row.phone {
+		usr.Phone = row.phone
+		usr.PhoneVerified = false
+		changed = append(changed, "phone")
+		fields = append(fields, "phone", "phone_verified")
+	}
+	oldRole := usr.Role
+	if row.role != "" && usr.Role != row.role {
+		usr.Role = row.role
+		changed = append(changed, "role")
+		fields = append(fields, "role")
+	}
+	// Enabling doesn't lift a suspension
+	disabled := row.status == domain.StatusDisabled && usr.Status != domain.StatusDisabled
+	if disabled || row.status == domain.StatusActive && usr.Status == domain.StatusDisabled {
+		usr.Status, usr.StatusReason, usr.StatusUntil = row.status, "", nil
+		if disabled {
+			usr.StatusReason = "roster import"
+		}
+		changed = append(changed, "status")
+		fields = append(fields, "status", "status_reason", "status_until")
+	}
+
+	if len(changed) == 0 {
+		res.Outcome = domain.RowUnchanged
+		return
+	}
+	res.Outcome = domain.RowUpdated
+	res.Fields = changed
+	if job.DryRun {
+		return
+	}
+	if oldRole != usr.Role || disabled {
+		b.revoke[row.i] = usr.ID
+	}
+
+	fields = append(fields, "updated_at")
+	b.writes[row.i] = func(ctx context.Context) error {
+		usr.UpdatedAt = time.Now().UTC()
+		if _, err := m.users.Update(ctx, usr, fields...); err != nil {
+			return importUserErr(err)
+		}
+		if oldRole != usr.Role {
+			if err := m.publisher.PublishUserRoleChanged(ctx, &domain.UserRoleChangedEvent{
+				UserID:    usr.ID,
+				OldRole:   oldRole,
+				NewRole:   usr.Role,
+				ChangedBy: job.CreatedBy,
+				CreatedAt: usr.UpdatedAt,
+			}); err != nil {
+				return fmt.Errorf("import PublishEvent (role): %w", err)
+			}
+		}
+		return m.publisher.PublishUserProfileUpdated(ctx, &domain.UserProfileUpdatedEvent{
+			UserID:    usr.ID,
+			UpdatedBy: job.CreatedBy,
+			Fields:    changed,
+			CreatedAt: usr.UpdatedAt,
+		})
+	}
+}
+
+func (m *importUsecase) planCreate(ctx context.Context, b *importBatch, row *userRow) error {
+	job := b.run.job
+	res := b.results[row.i]
+
+	role := row.role
+	if role == "" {
+		role = job.DefaultRole
+	}
+	switch {
+	case role == "":
+		b.reject(row.i, domain.RowInvalid, errors.New("role is required, or a default role"))
+		return nil
+	case row.email == "" && row.username == "":
+		b.reject(row.i, domain.RowInvalid, errors.New("email or username is required for a new account"))
+		return nil
+	case row.src.Password == "" && job.Credentials == domain.CredentialsInvite && row.email == "":
+		b.reject(row.i, domain.RowInvalid, errors.New("email is required to send an invitation"))
+		return nil
+	}
+	if row.src.Password != "" {
+		if err := b.run.policy.Check(row.src.Password); err != nil {
+			b.reject(row.i, domain.RowInvalid, err)
+			return nil
+		}
+	}
+
+	usr := domain.NewUser(row.email, row.key, "", role)
+	usr.Username = row.username
+	usr.Phone = row.phone
+	usr.ExternalID = row.src.ExternalID
+	if row.status == domain.StatusDisabled {
+		usr.Status, usr.StatusReason = domain.StatusDisabled, "roster import"
+	}
+	res.Outcome = domain.RowCreated
+	if job.DryRun {
+		if usr.ExternalID != "" {
+			b.run.planned[usr.ExternalID] = true
+		}
+		return nil
+	}
+	res.UserID = usr.ID
+
+	// A password column wins over the job's credentials
+	password := row.src.Password
+	switch {
+	case password != "":
+	case job.Credentials == domain.CredentialsPassword:
+		generated, err := generatePassword(max(generatedPasswordLength, b.run.policy.MinLength))
+		if err != nil {
+			return fmt.Errorf("import generate password: %w", err)
+		}
+		password = generated
+		res.Password = generated
+		usr.MustChangePassword = true
+	case job.Credentials == domain.CredentialsInvite:
+		res.InvitePending = usr.Status == domain.StatusActive
+	}
+	if password != "" {
+		hashed, err := m.hasher.Hash(ctx, password)
+		if err != nil {
+			return fmt.Errorf("import Hash: %w", err)
+		}
+		usr.Password = hashed
+	}
+
+	b.writes[row.i] = func(ctx context.Context) error {
+		if err := m.users.Create(ctx, usr); err != nil {
+			return importUserErr(err)
+		}
+		return m.publisher.PublishUserRegistered(ctx, &domain.UserRegisteredEvent{
+			UserID:    usr.ID,
+			Email:     usr.Email,
+			Username:  usr.Username,
+			Role:      usr.Role,
+			CreatedBy: job.CreatedBy,
+			CreatedAt: usr.CreatedAt,
+		})
+	}
+	return nil
+}
+
+// Enrollment row, relation is the group relation of the role
+type enrollmentRow struct {
+	src      *roster.Enrollment
+	i        int
+	relation string
+}
+
+func (m *importUsecase) readEnrollments(ctx context.Context, b *importBatch, r *roster.Reader, size int) (bool, error) {
+	var rows []*enrollmentRow
+	var eof bool
+	for len(b.results) < size {
+		src, err := r.Enrollment()
+		if errors.Is(err, io.EOF) {
+			eof = true
+			break
+		}
+		var rowErr *roster.RowError
+		if errors.As(err, &rowErr) {
+			b.reject(b.add(rowErr.Line), domain.RowInvalid, rowErr.Err)
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("read: %w", err)
+		}
+
+		row := &enrollmentRow{src: src, i: b.add(src.Line)}
+		b.results[row.i].ExternalID = src.User
+		switch src.Role {
+		case "student":
+			row.relation = domain.RelationMember
+		case "teacher":
+			row.relation = domain.RelationOwner
+		default:
+			b.reject(row.i, domain.RowSkipped, fmt.Errorf("role %q isn't imported", src.Role))
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return eof, m.planEnrollments(ctx, b, rows)
+}
+
+// Students become class members and teachers owners, classes are created on
+// first use and named after their sourcedId
+func (m *importUsecase) planEnrollments(ctx context.Context, b *importBatch, rows []*enrollmentRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	job := b.run.job
+
+	var userIDs, classIDs []string
+	for _, row := range rows {
+		userIDs = append(userIDs, row.src.User)
+		classIDs = append(classIDs, row.src.Class)
+	}
+	found, err := m.users.ListByExternalIDs(ctx, uniqueIDs(userIDs))
+	if err != nil {
+		return fmt.Errorf("import ListByExternalIDs: %w", err)
+	}
+	users := make(map[string]*domain.User, len(found))
+	subjects := make([]string, 0, len(found))
+	for _, u := range found {
+		users[u.ExternalID] = u
+		subjects = append(subjects, domain.UserSubject(u.ID).String())
+	}
+	groups, err := m.groups.ListBySourcedIDs(ctx, uniqueIDs(classIDs))
+	if err != nil {
+		return fmt.Errorf("import ListBySourcedIDs: %w", err)
+	}
+	classes := make(map[string]*domain.Group, len(groups))
+	for _, g := range groups {
+		classes[g.SourcedID] = g
+	}
+
+	existing := map[string]bool{}
+	if len(subjects) > 0 {
+		tuples, err := m.relations.ListBySubjects(ctx, subjects)
+		if err != nil {
+			return fmt.Errorf("import ListBySubjects: %w", err)
+		}
+		for _, t := range tuples {
+			existing[t.String()] = true
+		}
+	}
+
+	for _, row := range rows {
+		remove := row.src.Status == roster.StatusToBeDeleted
+		usr := users[row.src.User]
+		switch {
+		case usr == nil && job.DryRun && b.run.planned[row.src.User]:
+			b.results[row.i].Outcome = domain.RowCreated
+			continue
+		case usr == nil:
+			b.reject(row.i, domain.RowInvalid, fmt.Errorf("%w: %s", domain.ErrUserNotFound, row.src.User))
+			continue
+		case usr.IsDeleted():
+			b.reject(row.i, domain.RowSkipped, domain.ErrAccountDeleted)
+			continue
+		}
+		b.results[row.i].UserID = usr.ID
+
+		grp := classes[row.src.Class]
+		switch {
+		case grp == nil && remove:
+			b.reject(row.i, domain.RowSkipped, fmt.Errorf("%w: %s", domain.ErrGroupNotFound, row.src.Class))
+			continue
+		case grp == nil && job.DryRun:
+			b.results[row.i].Outcome = domain.RowCreated
+			continue
+		case grp == nil:
+			if grp, err = m.importClass(ctx, job, row.src.Class); err != nil {
+				return err
+			}
+			classes[row.src.Class] = grp
+		}
+		b.results[row.i].GroupID = grp.ID
+
+		tuple := groupTuple(grp.ID, row.relation, usr.ID)
+		if existing[tuple.String()] != !remove {
+			b.results[row.i].Outcome = domain.RowUnchanged
+			continue
+		}
+		existing[tuple.String()] = !remove
+		if remove {
+			b.results[row.i].Outcome = domain.RowRemoved
+		} else {
+			b.results[row.i].Outcome = domain.RowCreated
+		}
+		if job.DryRun {
+			continue
+		}
+
+		b.writes[row.i] = func(ctx context.Context) error {
+			if remove {
+				if err := m.relations.Delete(ctx, []domain.RelationTuple{tuple}); err != nil {
+					return fmt.Errorf("import remove enrollment: %w", err)
+				}
+				return m.publisher.PublishGroupMembersRemoved(ctx, &domain.GroupMembersRemovedEvent{
+					GroupID:   grp.ID,
+					Relation:  row.relation,
+					UserIDs:   []string{usr.ID},
+					ChangedBy: job.CreatedBy,
+					CreatedAt: time.Now().UTC(),
+				})
+			}
+			if err := m.relations.Write(ctx, []domain.RelationTuple{tuple}); err != nil {
+				return fmt.Errorf("import write enrollment: %w", err)
+			}
+			return m.publisher.PublishGroupMembersAdded(ctx, &domain.GroupMembersAddedEvent{
+				GroupID:   grp.ID,
+				Relation:  row.relation,
+				UserIDs:   []string{usr.ID},
+				ChangedBy: job.CreatedBy,
+				CreatedAt: time.Now().UTC(),
+			})
+		}
+	}
+	return nil
+}
+
+// Class for a classSourcedId, a concurrent import may have just created it
+func (m *importUsecase) importClass(ctx context.Context, job *domain.ImportJob, sourcedID string) (*domain.Group, error) {
+	now := time.Now().UTC()
+	grp := &domain.Group{
+		ID:        uuid.NewString(),
+		Name:      sourcedID,
+		Kind:      domain.GroupClass,
+		SourcedID: sourcedID,
+		CreatedBy: job.CreatedBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err := m.groups.Create(ctx, grp)
+	if err == nil {
+		return grp, nil
+	}
+	if !errors.Is(err, repository.ErrAlreadyExists) {
+		return nil, fmt.Errorf("import create class: %w", err)
+	}
+	found, err := m.groups.ListBySourcedIDs(ctx, []string{sourcedID})
+	if err != nil || len(found) == 0 {
+		return nil, fmt.Errorf("import find class %s: %w", sourcedID, err)
+	}
+	return found[0], nil
+}
+
+// Row problems a write can run into, anything else fails the batch
+func importUserErr(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrEmailAlreadyUsed):
+		return domain.ErrEmailAlreadyExists
+	case errors.Is(err, repository.ErrUsernameTaken):
+		return domain.ErrUsernameAlreadyExists
+	case errors.Is(err, repository.ErrAlreadyExists):
+		return domain.ErrExternalIDExists
+	case errors.Is(err, repository.ErrNotFound):
+		return domain.ErrUserNotFound
+	}
+	return fmt.Errorf("import write user: %w", err)
+}
+
+func isImportRowErr(err error) bool {
+	return errors.Is(err, domain.ErrEmailAlreadyExists) ||
+		errors.Is(err, domain.ErrUsernameAlreadyExists) ||
+		errors.Is(err, domain.ErrExternalIDExists) ||
+		errors.Is(err, domain.ErrUserNotFound)
+}
+
+// Stored name of an uploaded or server file, false for files that aren't imported
+func importFileName(format domain.ImportFormat, name string) (string, bool) {
+	if format == domain.ImportCSV {
+		return roster.UsersFile, true
+	}
+	switch base := strings.ToLower(path.Base(filepath.ToSlash(name))); base {
+	case roster.UsersFile, roster.EnrollmentsFile:
+		return base, true
+	}
+	return "", false
+}
+
+func hasImportFile(files []domain.ImportFile, name string) bool {
+	for _, f := range files {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Users first, enrollments can refer to accounts the same job creates
+func orderFiles(format domain.ImportFormat, files []domain.ImportFile) ([]domain.ImportFile, error) {
+	if len(files) == 0 {
+		if format == domain.ImportOneRoster {
+			return nil, fmt.Errorf("%w: no users.csv or enrollments.csv", domain.ErrInvalidImport)
+		}
+		return nil, fmt.Errorf("%w: no file", domain.ErrInvalidImport)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Name == roster.UsersFile && files[j].Name != roster.UsersFile
+	})
+	return files, nil
+}
+
+func importDetails(details map[string]string, op string, opts domain.ImportOptions) map[string]string {
+	details = withDetail(withDetail(details, "op", op), "format", string(opts.Format))
+	details = withDetail(details, "credentials", string(opts.Credentials))
+	return withDetail(details, "dry_run", strconv.FormatBool(opts.DryRun))
+}
+
+// First line that already had one of the identifiers, 0 if none. Empty
+// identifiers end with ":" and are ignored.
+func firstSeen(seen map[string]int, ids []string) int {
+	for _, id := range ids {
+		if strings.HasSuffix(id, ":") {
+			continue
+		}
+		if line, ok := seen[id]; ok {
+			return line
+		}
+	}
+	return 0
+}
+
+func appendNonEmpty(s []string, v string) []string {
+	if v == "" {
+		return s
+	}
+	return append(s, v)
+}
+
+// Has a character of every class, so it meets any tenant policy of its length
+func generatePassword(n int) (string, error) {
+	const (
+		lower   = "abcdefghijkmnopqrstuvwxyz"
+		upper   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+		digits  = "23456789"
+		symbols = "!#%+-=?@"
+	)
+	sets := []string{lower, upper, digits, symbols}
+	all := lower + upper + digits + symbols
+
+	b := make([]byte, n)
+	for i := range b {
+		set := all
+		if i < len(sets) {
+			set = sets[i]
+		}
+		c, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = set[c.Int64()]
+	}
+	// Fisher-Yates, so the classes aren't always in front
+	for i := len(b) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		b[i], b[j.Int64()] = b[j.Int64()], b[i]
+	}
+	return string(b), nil
+}
+
+// Fails with ErrImportFileTooLarge once more than n bytes were read
+type sizeLimit struct {
+	r io.Reader
+	n int64
+}
+
+func (s *sizeLimit) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.n -= int64(n)
+	if s.n < 0 {
+		return n, domain.ErrImportFileTooLarge
+	}
+	return n, err
+}
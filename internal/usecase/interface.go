@@ -2,6 +2,8 @@ package usecase
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"github.com/Neroframe/AuthService/internal/domain"
 )
@@ -9,7 +11,11 @@ import (
 type UserUsecase interface {
 	// Auth
 	Register(ctx context.Context, email, password string, role domain.Role) (*domain.User, error)
-	Login(ctx context.Context, email, password string) (accessToken string, payload *domain.TokenPayload, err error)
+	CreateStudentAccount(ctx context.Context, username, password string) (*domain.User, error)
+	// newPassword replaces a generated password, ErrPasswordChangeRequired when it's missing
+	Login(ctx context.Context, identifier, password, newPassword string) (accessToken string, payload *domain.TokenPayload, err error)
+	// Revokes the caller's token, or every token of the caller with allSessions
+	Logout(ctx context.Context, allSessions bool) error
 
 	// Token validation
 	ValidateToken(ctx context.Context, jwt string) (*domain.TokenPayload, error)
@@ -23,5 +29,165 @@ type UserUsecase interface {
 	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
 	UpdateProfile(ctx context.Context, p domain.UpdateUserProfileParams) (*domain.User, error)
 	ChangePassword(ctx context.Context, userID, oldPw, newPw string) error
-	VerifyAccount(ctx context.Context, userID string) error
+	ResetPassword(ctx context.Context, email, newPw string) error // after VerifyCode
+	VerifyAccount(ctx context.Context, email string) error        // after VerifyCode
+
+	// Phone
+	SendPhoneVerificationCode(ctx context.Context) error
+	VerifyPhone(ctx context.Context, code string) error
+	SendRecoverySMS(ctx context.Context, email string) error
+
+	// Email change
+	RequestEmailChange(ctx context.Context, newEmail, password string) error
+	ConfirmEmailChange(ctx context.Context, code string) (*domain.User, error)
+	UndoEmailChange(ctx context.Context, token string) error
+
+	// Account lifecycle
+	DisableAccount(ctx context.Context, userID, reason string, roles ...domain.Role) error
+	SetAccountStatus(ctx context.Context, p domain.SetStatusParams) (*domain.User, error)
+
+	// Account deletion
+	DeleteMyAccount(ctx context.Context, password string) (purgeAt time.Time, err error)
+	RestoreAccount(ctx context.Context, identifier, password string) error
+	EraseDueAccounts(ctx context.Context, batchSize int) (erased int, err error)
+	RevokeSessions(ctx context.Context, userID string) error
+
+	// Admin user management
+	ListUsers(ctx context.Context, f domain.UserFilter) (users []*domain.User, nextCursor string, err error)
+	DeleteUser(ctx context.Context, userID string) error
+	SetUserRole(ctx context.Context, userID string, role domain.Role) (*domain.User, error)
+	SetUserVerified(ctx context.Context, userID string, verified bool) (*domain.User, error)
+
+	// Audit
+	ListAuditEvents(ctx context.Context, f domain.AuditFilter) (events []*domain.AuditEvent, nextCursor string, err error)
+}
+
+type RBACUsecase interface {
+	// Startup and cache
+	Seed(ctx context.Context, roles []*domain.RoleDefinition, perms []*domain.Permission) error
+	Reload(ctx context.Context) error
+	Policy(ctx context.Context) (*domain.AccessPolicy, error)
+	AllowsMethod(ctx context.Context, role domain.Role, method string) (bool, error)
+	RoleExists(ctx context.Context, role domain.Role) (bool, error)
+	Permissions(ctx context.Context, role domain.Role) ([]string, error)
+
+	// Admin management
+	ListRoles(ctx context.Context) ([]*domain.RoleDefinition, error)
+	CreateRole(ctx context.Context, role *domain.RoleDefinition) error
+	UpdateRole(ctx context.Context, role *domain.RoleDefinition) error
+	DeleteRole(ctx context.Context, name domain.Role) error
+	GrantPermission(ctx context.Context, role domain.Role, perm string) error
+	RevokePermission(ctx context.Context, role domain.Role, perm string) error
+	ListPermissions(ctx context.Context) ([]*domain.Permission, error)
+	CreatePermission(ctx context.Context, p *domain.Permission) error
+	DeletePermission(ctx context.Context, name string) error
+}
+
+type TenantUsecase interface {
+	domain.TenantDirectory
+	// Startup, the default tenant is created with cfg when missing
+	Seed(ctx context.Context, name string, cfg domain.TenantConfig) error
+
+	// Admin management
+	CreateTenant(ctx context.Context, t *domain.Tenant) (*domain.Tenant, error)
+	GetTenant(ctx context.Context, id string) (*domain.Tenant, error)
+	ListTenants(ctx context.Context) ([]*domain.Tenant, error)
+	UpdateTenant(ctx context.Context, upd domain.TenantUpdate) (*domain.Tenant, error)
+}
+
+type CheckUsecase interface {
+	CheckPermission(ctx context.Context, subjectID string, check domain.AuthzCheck) (*domain.AuthzDecision, error)
+	BatchCheck(ctx context.Context, subjectID string, checks []domain.AuthzCheck) ([]*domain.AuthzDecision, error)
+}
+
+type RelationUsecase interface {
+	domain.RelationChecker
+	WriteRelations(ctx context.Context, change domain.RelationChange) error
+	// Empty subject is the caller
+	CheckRelation(ctx context.Context, objectType, objectID, relation string, subject *domain.SubjectRef) (bool, error)
+	ListObjects(ctx context.Context, objectType, relation string, subject *domain.SubjectRef) ([]string, error)
+
+	// Course service updates from NATS
+	ApplyRelationChange(ctx context.Context, change domain.RelationChange) error
+}
+
+type GroupUsecase interface {
+	CreateGroup(ctx context.Context, name string, kind domain.GroupKind, ownerIDs []string) (*domain.Group, error)
+	// Bulk roster changes, relation is member or owner
+	AddGroupMembers(ctx context.Context, groupID, relation string, userIDs []string) (*domain.GroupMembersResult, error)
+	RemoveGroupMembers(ctx context.Context, groupID, relation string, userIDs []string) (*domain.GroupMembersResult, error)
+	// Empty userID is the caller
+	ListUserGroups(ctx context.Context, userID string) ([]*domain.GroupMembership, error)
+	ListGroupMembers(ctx context.Context, groupID string) ([]*domain.GroupMember, error)
+}
+
+type ImportUsecase interface {
+	// Stores the streamed files and queues the job
+	UploadImport(ctx context.Context, opts domain.ImportOptions, upload domain.ImportUpload) (*domain.ImportJob, error)
+	// Files under the server's import dir, read when the job runs
+	StartImport(ctx context.Context, opts domain.ImportOptions, paths []string) (*domain.ImportJob, error)
+	GetImport(ctx context.Context, id string) (*domain.ImportJob, error)
+	ListImportResults(ctx context.Context, id string, outcome domain.ImportOutcome, cursor string, limit int) (rows []*domain.ImportRow, nextCursor string, err error)
+	ResumeImport(ctx context.Context, id string) (*domain.ImportJob, error)
+	// Background worker
+	ProcessPendingImports(ctx context.Context) (int, error)
+	PurgeExpiredImports(ctx context.Context) (int, error)
+}
+
+type InvitationUsecase interface {
+	// Emails a signed code, the role, tenant and group are fixed by the sender
+	CreateInvitation(ctx context.Context, email string, role domain.Role, groupID string) (*domain.Invitation, error)
+	RevokeInvitation(ctx context.Context, id string) error
+	// Public, creates the invited account, or sets the password of a provisioned one, and joins the group
+	AcceptInvitation(ctx context.Context, code, password string) (*domain.User, error)
+	// Startup, invites the first admin of the default tenant
+	BootstrapAdmin(ctx context.Context, email string) error
+	// Invite to an account created by an import, the caller is authorized already
+	InviteProvisioned(ctx context.Context, usr *domain.User, invitedBy string) (*domain.Invitation, error)
+}
+
+type WebhookUsecase interface {
+	// Admin management
+	CreateWebhook(ctx context.Context, url string, eventTypes []domain.EventType) (*domain.WebhookSubscription, error)
+	ListWebhooks(ctx context.Context) ([]*domain.WebhookSubscription, error)
+	UpdateWebhook(ctx context.Context, p domain.UpdateWebhookParams) (*domain.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	ListWebhookDeliveries(ctx context.Context, f domain.WebhookDeliveryFilter) (deliveries []*domain.WebhookDelivery, nextCursor string, err error)
+	ReplayWebhookDelivery(ctx context.Context, deliveryID string) error
+
+	// Fan out a user event to matching subscriptions
+	EnqueueWebhookEvent(ctx context.Context, e *domain.WebhookEvent) error
+}
+
+type ExportUsecase interface {
+	ExportMyData(ctx context.Context, format domain.ExportFormat) (*domain.ExportResult, error)
+	ExportUserData(ctx context.Context, userID string, format domain.ExportFormat) (*domain.ExportResult, error)
+	GetDataExport(ctx context.Context, id string) (*domain.DataExport, error)
+	OpenDataExport(ctx context.Context, token string) (*domain.DataExport, io.ReadCloser, error)
+
+	// Background job
+	ProcessPendingExports(ctx context.Context) (int, error)
+	PurgeExpiredExports(ctx context.Context) (int, error)
+}
+
+type ScimUsecase interface {
+	// Admin management, the token is only returned on create
+	CreateScimClient(ctx context.Context, name string) (*domain.ScimClient, string, error)
+	ListScimClients(ctx context.Context) ([]*domain.ScimClient, error)
+	RevokeScimClient(ctx context.Context, id string) error
+	// Context of the client's tenant, with the client as the caller
+	AuthenticateScim(ctx context.Context, token string) (context.Context, error)
+
+	// Provisioning, a non-empty version must match the resource's
+	GetScimUser(ctx context.Context, id string) (*domain.User, error)
+	ListScimUsers(ctx context.Context, q domain.ScimUserQuery) ([]*domain.User, int, error)
+	CreateScimUser(ctx context.Context, in *domain.User, password string) (*domain.User, error)
+	ReplaceScimUser(ctx context.Context, id, version string, in *domain.User, password string) (*domain.User, error)
+	DeleteScimUser(ctx context.Context, id, version string) error
+
+	GetScimGroup(ctx context.Context, id string) (*domain.ScimGroup, error)
+	ListScimGroups(ctx context.Context, q domain.ScimGroupQuery) ([]*domain.ScimGroup, int, error)
+	CreateScimGroup(ctx context.Context, in *domain.ScimGroup) (*domain.ScimGroup, error)
+	ReplaceScimGroup(ctx context.Context, id, version string, in *domain.ScimGroup) (*domain.ScimGroup, error)
+	DeleteScimGroup(ctx context.Context, id, version string) error
 }
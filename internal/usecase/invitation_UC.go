@@ -0,0 +1,381 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/google/uuid"
+)
+
+type InvitationConfig struct {
+	TTL       time.Duration // codes can be accepted for
+	AcceptURL string        // code is appended
+}
+
+// Signup by invitation, the only way to get a role other than student
+type invitationUsecase struct {
+	auditor
+	authorizer
+	tenancy
+	log       *logger.Logger
+	invites   repository.InvitationRepository
+	users     repository.UserRepository
+	groups    repository.GroupRepository
+	relations repository.RelationRepository
+	tx        repository.TxManager
+	hasher    domain.PasswordHasher
+	publisher domain.UserEventPublisher
+	emails    domain.EmailNormalizer
+	signer    domain.InviteSigner
+	cfg       InvitationConfig
+}
+
+func NewInvitationUsecase(
+	invites repository.InvitationRepository,
+	users repository.UserRepository,
+	groups repository.GroupRepository,
+	relations repository.RelationRepository,
+	tx repository.TxManager,
+	hasher domain.PasswordHasher,
+	publisher domain.UserEventPublisher,
+	emailSender domain.EmailSender,
+	emails domain.EmailNormalizer,
+	tenants domain.TenantDirectory,
+	signer domain.InviteSigner,
+	cfg InvitationConfig,
+	audit repository.AuditRepository,
+	log *logger.Logger,
+	authz domain.Authorizer,
+	roles domain.RoleCatalog,
+	checker domain.RelationChecker,
+) InvitationUsecase {
+	return &invitationUsecase{
+		invites:    invites,
+		users:      users,
+		groups:     groups,
+		relations:  relations,
+		tx:         tx,
+		hasher:     hasher,
+		publisher:  publisher,
+		emails:     emails,
+		signer:     signer,
+		cfg:        cfg,
+		log:        log,
+		tenancy:    tenancy{tenants: tenants, emailSender: emailSender},
+		auditor:    auditor{audit: audit, auditLog: log},
+		authorizer: authorizer{authz: authz, roles: roles, relations: checker},
+	}
+}
+
+// Teachers may invite students, to a class they own if a group is given.
+// Any other role or group needs users.manage, see the policy.
+func (i *invitationUsecase) CreateInvitation(ctx context.Context, email string, role domain.Role, groupID string) (inv *domain.Invitation, err error) {
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+
+	defer func() {
+		var targetID string
+		if inv != nil {
+			targetID = inv.ID
+		}
+		details := withDetail(withDetail(errDetails(err), "op", "create"), "role", string(role))
+		i.recordAudit(ctx, domain.AuditInvitation, targetID, outcomeOf(err), withDetail(details, "email", email))
+	}()
+
+	res := domain.AuthzResource{Type: domain.ResourceInvite, Tenant: domain.TenantOf(ctx), Role: role, Attrs: map[string]string{}}
+	if groupID != "" {
+		grp, err := i.group(ctx, groupID)
+		if err != nil {
+			return nil, err
+		}
+		res.Attrs["group"] = grp.ID
+		owned, err := i.relations.ExistsAny(ctx, domain.ObjectGroup, []string{grp.ID}, domain.RelationOwner, []string{domain.UserSubject(claims.UserID).String()})
+		if err != nil {
+			return nil, fmt.Errorf("CreateInvitation group owner: %w", err)
+		}
+		if owned {
+			res.Attrs["group_owned_by_subject"] = "true"
+		}
+	}
+	if err := i.authorize(ctx, domain.ActionInviteCreate, res); err != nil {
+		return nil, err
+	}
+
+	exists, err := i.roles.RoleExists(ctx, role)
+	if err != nil {
+		return nil, fmt.Errorf("CreateInvitation RoleExists: %w", err)
+	}
+	if !exists {
+		return nil, domain.ErrRoleNotFound
+	}
+
+	return i.issue(ctx, email, role, groupID, "", claims.UserID)
+}
+
+func (i *invitationUsecase) RevokeInvitation(ctx context.Context, id string) (err error) {
+	defer func() {
+		i.recordAudit(ctx, domain.AuditInvitation, id, outcomeOf(err), withDetail(errDetails(err), "op", "revoke"))
+	}()
+
+	inv, err := i.invitation(ctx, id)
+	if err != nil {
+		return err
+	}
+	res := domain.AuthzResource{Type: domain.ResourceInvite, ID: inv.ID, Tenant: inv.TenantID, OwnerID: inv.InvitedBy, Role: inv.Role}
+	if err := i.authorize(ctx, domain.ActionInviteRevoke, res); err != nil {
+		return err
+	}
+
+	if err := i.invites.Revoke(ctx, inv.ID, time.Now().UTC()); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrInvitationUsed
+		}
+		return fmt.Errorf("RevokeInvitation: %w", err)
+	}
+	return nil
+}
+
+// The code names the tenant, so no tenant header is needed. The email was
+// proven by receiving the code, the account starts verified.
+func (i *invitationUsecase) AcceptInvitation(ctx context.Context, code, password string) (usr *domain.User, err error) {
+	var inv *domain.Invitation
+	defer func() {
+		var targetID string
+		if usr != nil {
+			targetID = usr.ID
+		}
+		details := errDetails(err)
+		if inv != nil {
+			details = withDetail(withDetail(details, "invitation", inv.ID), "email", inv.Email)
+		}
+		i.recordAudit(ctx, domain.AuditRegister, targetID, outcomeOf(err), details)
+	}()
+
+	inviteID, tenantID, err := i.signer.Verify(code)
+	if err != nil {
+		return nil, err
+	}
+	ctx = domain.WithTenant(ctx, tenantID)
+
+	if inv, err = i.invitation(ctx, inviteID); err != nil {
+		if errors.Is(err, domain.ErrInvitationInvalid) {
+			return nil, domain.ErrInvitationExpired // signed by us, so removed by the TTL index
+		}
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if err := inv.UsableErr(now); err != nil {
+		return nil, err
+	}
+	t, err := i.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !t.IsActive() {
+		return nil, domain.ErrTenantDisabled
+	}
+	if err := t.Config.Password.Check(password); err != nil {
+		return nil, err
+	}
+
+	hashed, err := i.hasher.Hash(ctx, password)
+	if err != nil {
+		return nil, fmt.Errorf("AcceptInvitation Hash: %w", err)
+	}
+	provisioned := inv.UserID != ""
+	if provisioned {
+		if usr, err = i.provisionedUser(ctx, inv); err != nil {
+			return nil, err
+		}
+		usr.Password = hashed
+		usr.Verified = true
+		usr.UpdatedAt = now
+	} else {
+		usr = domain.NewUser(inv.Email, inv.EmailKey, hashed, inv.Role)
+		usr.Verified = true
+	}
+
+	err = i.tx.WithinTx(ctx, func(ctx context.Context) error {
+		// Claims the code first, a second accept of it fails here
+		if err := i.invites.Accept(ctx, inv.ID, usr.ID, now); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return domain.ErrInvitationUsed
+			}
+			return fmt.Errorf("AcceptInvitation Accept: %w", err)
+		}
+
+		if provisioned {
+			if _, err := i.users.Update(ctx, usr, "password", "verified", "updated_at"); err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return domain.ErrInvitationExpired
+				}
+				return fmt.Errorf("AcceptInvitation Update: %w", err)
+			}
+			if err := i.publisher.PublishPasswordChanged(ctx, &domain.PasswordChangedEvent{UserID: usr.ID, CreatedAt: now}); err != nil {
+				return fmt.Errorf("AcceptInvitation PublishEvent (password): %w", err)
+			}
+		} else {
+			if err := i.users.Create(ctx, usr); err != nil {
+				if errors.Is(err, repository.ErrEmailAlreadyUsed) {
+					return domain.ErrEmailAlreadyExists
+				}
+				return fmt.Errorf("AcceptInvitation Create: %w", err)
+			}
+
+			event := &domain.UserRegisteredEvent{
+				UserID:    usr.ID,
+				Email:     usr.Email,
+				Role:      usr.Role,
+				CreatedBy: inv.InvitedBy,
+				CreatedAt: now,
+			}
+			if err := i.publisher.PublishUserRegistered(ctx, event); err != nil {
+				return fmt.Errorf("AcceptInvitation PublishEvent (registered): %w", err)
+			}
+		}
+
+		if inv.GroupID == "" {
+			return nil
+		}
+		if err := i.relations.Write(ctx, []domain.RelationTuple{groupTuple(inv.GroupID, domain.RelationMember, usr.ID)}); err != nil {
+			return fmt.Errorf("AcceptInvitation join group: %w", err)
+		}
+		return i.publisher.PublishGroupMembersAdded(ctx, &domain.GroupMembersAddedEvent{
+			GroupID:   inv.GroupID,
+			Relation:  domain.RelationMember,
+			UserIDs:   []string{usr.ID},
+			ChangedBy: inv.InvitedBy,
+			CreatedAt: now,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usr, nil
+}
+
+// Open registration can't create admins, so the first one is invited on
+// every start until an account with the email exists
+func (i *invitationUsecase) BootstrapAdmin(ctx context.Context, email string) error {
+	ctx = domain.WithTenant(ctx, domain.DefaultTenantID)
+
+	_, key, err := i.emails.Normalize(email)
+	if err != nil {
+		return err
+	}
+	if _, err := i.users.GetByEmail(ctx, key); err == nil {
+		return nil
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("BootstrapAdmin GetByEmail: %w", err)
+	}
+
+	inv, err := i.issue(ctx, email, domain.ADMIN, "", "", "")
+	if err != nil {
+		return err
+	}
+	i.log.Info("bootstrap admin invited", "email", inv.Email, "invitation_id", inv.ID)
+	return nil
+}
+
+func (i *invitationUsecase) InviteProvisioned(ctx context.Context, usr *domain.User, invitedBy string) (*domain.Invitation, error) {
+	if usr.Email == "" {
+		return nil, domain.ErrInvalidEmail
+	}
+	return i.issue(ctx, usr.Email, usr.Role, "", usr.ID, invitedBy)
+}
+
+// Store the invite and email its code. userID is set for provisioned accounts,
+// invitedBy is empty for the system.
+func (i *invitationUsecase) issue(ctx context.Context, email string, role domain.Role, groupID, userID, invitedBy string) (*domain.Invitation, error) {
+	normalized, key, err := i.emails.Normalize(email)
+	if err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		if _, err := i.users.GetByEmail(ctx, key); err == nil {
+			return nil, domain.ErrEmailAlreadyExists
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("invitation GetByEmail: %w", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	inv := &domain.Invitation{
+		ID:        uuid.NewString(),
+		Email:     normalized,
+		EmailKey:  key,
+		Role:      role,
+		GroupID:   groupID,
+		UserID:    userID,
+		InvitedBy: invitedBy,
+		ExpiresAt: now.Add(i.cfg.TTL),
+		CreatedAt: now,
+	}
+	if err := i.invites.Create(ctx, inv); err != nil {
+		return nil, fmt.Errorf("invitation Create: %w", err)
+	}
+
+	code := i.signer.Sign(inv.ID, inv.TenantID, inv.ExpiresAt)
+	if err := i.sendEmail(ctx, inv.Email, "You're invited", invitationBody(inv.Role, i.cfg.AcceptURL+code, inv.ExpiresAt)); err != nil {
+		return nil, fmt.Errorf("invitation send: %w", err)
+	}
+	return inv, nil
+}
+
+func (i *invitationUsecase) invitation(ctx context.Context, id string) (*domain.Invitation, error) {
+	inv, err := i.invites.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrInvitationInvalid
+		}
+		return nil, fmt.Errorf("invitation: %w", err)
+	}
+	return inv, nil
+}
+
+// Account the invite was sent for. It's void once the account has another
+// email or a password, e.g. set through ResetPassword.
+func (i *invitationUsecase) provisionedUser(ctx context.Context, inv *domain.Invitation) (*domain.User, error) {
+	usr, err := i.users.GetByID(ctx, inv.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrInvitationExpired
+		}
+		return nil, fmt.Errorf("invitation user: %w", err)
+	}
+	switch {
+	case usr.IsDeleted(), usr.EmailKey != inv.EmailKey:
+		return nil, domain.ErrInvitationExpired
+	case usr.Password != "":
+		return nil, domain.ErrInvitationUsed
+	}
+	return usr, nil
+}
+
+func (i *invitationUsecase) group(ctx context.Context, id string) (*domain.Group, error) {
+	grp, err := i.groups.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrGroupNotFound
+		}
+		return nil, fmt.Errorf("invitation group: %w", err)
+	}
+	return grp, nil
+}
+
+func invitationBody(role domain.Role, link string, expiresAt time.Time) string {
+	return fmt.Sprintf(`
+			<html>
+				<body>
+					<h2>You're invited</h2>
+					<p>You have been invited to join as <b>%s</b>.</p>
+					<p><a href="%s">Accept the invitation</a> and choose a password before %s.</p>
+					<p>If you weren't expecting this, ignore the email.</p>
+				</body>
+			</html>`, role, link, expiresAt.Format("2 Jan 2006 15:04 MST"))
+}
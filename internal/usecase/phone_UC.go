@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+)
+
+// Wrong codes before a code is dropped and a new one has to be sent
+const maxCodeAttempts = 5
+
+type PhoneConfig struct {
+	DefaultCC    string        // country code for phones entered without one
+	SendCooldown time.Duration // between codes to the same user or phone
+}
+
+// Text a verification code to the caller's phone
+func (u *userUsecase) SendPhoneVerificationCode(ctx context.Context) error {
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+
+	usr, err := u.repo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrUserNotFound
+		}
+		return fmt.Errorf("SendPhoneVerificationCode fetch: %w", err)
+	}
+	if usr.Phone == "" {
+		return domain.ErrPhoneNotSet
+	}
+	if err := u.allowSMS(ctx, usr); err != nil {
+		return fmt.Errorf("SendPhoneVerificationCode: %w", err)
+	}
+
+	code := domain.NewVerificationCode(usr.ID, fmt.Sprintf("%06d", rand.Intn(1000000)), domain.PurposePhoneVerification, 5*time.Minute)
+	code.Target = usr.Phone
+	if err := u.cache.Set(ctx, code); err != nil {
+		return fmt.Errorf("SendPhoneVerificationCode cache.Set: %w", err)
+	}
+
+	if err := u.sms.Send(usr.Phone, smsCodeBody(domain.PurposePhoneVerification, code.Code)); err != nil {
+		return fmt.Errorf("SendPhoneVerificationCode Send: %w", err)
+	}
+	return nil
+}
+
+func (u *userUsecase) VerifyPhone(ctx context.Context, code string) (err error) {
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+
+	defer func() {
+		u.recordAudit(ctx, domain.AuditPhoneVerify, claims.UserID, outcomeOf(err), errDetails(err))
+	}()
+
+	usr, err := u.repo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrUserNotFound
+		}
+		return fmt.Errorf("VerifyPhone fetch: %w", err)
+	}
+
+	cached, err := u.cache.Get(ctx, usr.ID)
+	if err != nil {
+		return fmt.Errorf("VerifyPhone cache.Get: %w", err)
+	}
+	if cached.Purpose != domain.PurposePhoneVerification {
+		return domain.ErrInvalidPurpose
+	}
+	if time.Now().After(cached.ExpiresAt) {
+		return domain.ErrCodeExpired
+	}
+	// Phone changed after the code was sent
+	if cached.Code != code || cached.Target != usr.Phone {
+		return u.codeAttemptFailed(ctx, cached)
+	}
+
+	usr.PhoneVerified = true
+	usr.UpdatedAt = time.Now().UTC()
+	err = u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := u.repo.Update(ctx, usr, "phone_verified", "updated_at"); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return domain.ErrUserNotFound
+			}
+			return fmt.Errorf("VerifyPhone Update: %w", err)
+		}
+
+		return u.publisher.PublishUserPhoneVerified(ctx, &domain.UserPhoneVerifiedEvent{
+			UserID:    usr.ID,
+			Phone:     usr.Phone,
+			CreatedAt: time.Now().UTC(),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := u.cache.Delete(ctx, usr.ID); err != nil {
+		return fmt.Errorf("VerifyPhone cache.Delete: %w", err)
+	}
+	return nil
+}
+
+// Password reset code over SMS, confirmed with ConfirmResetPassword like the email one.
+// Unauthenticated, so unknown accounts, unverified phones and cooldowns all
+// succeed silently and the caller can't tell them apart.
+func (u *userUsecase) SendRecoverySMS(ctx context.Context, email string) error {
+	usr, err := u.getByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("SendRecoverySMS FindByEmail: %w", err)
+	}
+	if usr.Phone == "" || !usr.PhoneVerified {
+		return nil
+	}
+	if err := u.allowSMS(ctx, usr); err != nil {
+		if errors.Is(err, domain.ErrCodeSentRecently) {
+			return nil
+		}
+		return fmt.Errorf("SendRecoverySMS: %w", err)
+	}
+
+	code := domain.NewVerificationCode(usr.ID, fmt.Sprintf("%06d", rand.Intn(1000000)), domain.PurposeResetPassword, 5*time.Minute)
+	code.Target = usr.Phone
+	if err := u.cache.Set(ctx, code); err != nil {
+		return fmt.Errorf("SendRecoverySMS cache.Set: %w", err)
+	}
+
+	if err := u.sms.Send(usr.Phone, smsCodeBody(domain.PurposeResetPassword, code.Code)); err != nil {
+		return fmt.Errorf("SendRecoverySMS Send: %w", err)
+	}
+	return nil
+}
+
+// One code per SendCooldown to a user, and to a phone shared by several
+func (u *userUsecase) allowSMS(ctx context.Context, usr *domain.User) error {
+	for _, key := range []string{"sms:user:" + usr.ID, "sms:phone:" + usr.Phone} {
+		ok, err := u.limiter.Allow(ctx, key, u.phone.SendCooldown)
+		if err != nil {
+			return fmt.Errorf("limiter.Allow: %w", err)
+		}
+		if !ok {
+			return domain.ErrCodeSentRecently
+		}
+	}
+	return nil
+}
+
+// Count a wrong code, the code is dropped after too many
+func (u *userUsecase) codeAttemptFailed(ctx context.Context, code *domain.VerificationCode) error {
+	code.Attempts++
+	if code.Attempts >= maxCodeAttempts {
+		if err := u.cache.Delete(ctx, code.UserID); err != nil {
+			return fmt.Errorf("cache.Delete: %w", err)
+		}
+		return domain.ErrCodeInvalid
+	}
+
+	if err := u.cache.Set(ctx, code); err != nil {
+		return fmt.Errorf("cache.Set: %w", err)
+	}
+	return domain.ErrCodeInvalid
+}
+
+func smsCodeBody(purpose, code string) string {
+	switch purpose {
+	case domain.PurposeResetPassword:
+		return fmt.Sprintf("Your password reset code is %s. If you didn't request this, ignore this message.", code)
+	default:
+		return fmt.Sprintf("Your verification code is %s. It expires in 5 minutes.", code)
+	}
+}
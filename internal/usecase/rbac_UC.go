@@ -0,0 +1,391 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+)
+
+// Permission the admin role can't lose, or nobody could fix RBAC anymore
+const PermRBACManage = "rbac.manage"
+
+type rbacUsecase struct {
+	auditor
+	log      *logger.Logger
+	repo     repository.RBACRepository
+	users    repository.UserRepository
+	notifier domain.RBACNotifier
+
+	ttl      time.Duration // cached policy is reloaded after, in case a notice was missed
+	mu       sync.RWMutex
+	policy   *domain.AccessPolicy
+	loadedAt time.Time
+	authorizer
+}
+
+func NewRBACUsecase(
+	repo repository.RBACRepository,
+	users repository.UserRepository,
+	notifier domain.RBACNotifier,
+	audit repository.AuditRepository,
+	log *logger.Logger,
+	ttl time.Duration,
+	authz domain.Authorizer,
+) RBACUsecase {
+	r := &rbacUsecase{repo: repo, users: users, notifier: notifier, log: log, ttl: ttl, auditor: auditor{audit: audit, auditLog: log}}
+	r.authorizer = authorizer{authz: authz, roles: r}
+	return r
+}
+
+// Insert missing built-in roles and sync built-in permissions, then load the cache
+func (r *rbacUsecase) Seed(ctx context.Context, roles []*domain.RoleDefinition, perms []*domain.Permission) error {
+	now := time.Now().UTC()
+	for _, p := range perms {
+		p.Builtin = true
+		p.CreatedAt, p.UpdatedAt = now, now
+		if err := r.repo.EnsurePermission(ctx, p); err != nil {
+			return fmt.Errorf("Seed permission %s: %w", p.Name, err)
+		}
+	}
+	for _, role := range roles {
+		role.Builtin = true
+		role.CreatedAt, role.UpdatedAt = now, now
+		if err := r.repo.EnsureRole(ctx, role); err != nil {
+			return fmt.Errorf("Seed role %s: %w", role.Name, err)
+		}
+	}
+
+	// Admin always holds every built-in permission, including ones added by a deploy
+	names := make([]string, 0, len(perms))
+	for _, p := range perms {
+		names = append(names, p.Name)
+	}
+	if err := r.repo.AddRolePermissions(ctx, domain.ADMIN, names...); err != nil {
+		return fmt.Errorf("Seed admin permissions: %w", err)
+	}
+
+	return r.Reload(ctx)
+}
+
+func (r *rbacUsecase) Reload(ctx context.Context) error {
+	roles, err := r.repo.ListRoles(ctx)
+	if err != nil {
+		return fmt.Errorf("Reload roles: %w", err)
+	}
+	perms, err := r.repo.ListPermissions(ctx)
+	if err != nil {
+		return fmt.Errorf("Reload permissions: %w", err)
+	}
+
+	policy := domain.NewAccessPolicy(roles, perms)
+	r.mu.Lock()
+	r.policy, r.loadedAt = policy, time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// Cached policy, reloaded once stale. A failed reload keeps serving the old one.
+func (r *rbacUsecase) Policy(ctx context.Context) (*domain.AccessPolicy, error) {
+	r.mu.RLock()
+	policy, loadedAt := r.policy, r.loadedAt
+	r.mu.RUnlock()
+
+	if policy != nil && time.Since(loadedAt) < r.ttl {
+		return policy, nil
+	}
+	if err := r.Reload(ctx); err != nil {
+		if policy != nil {
+			r.log.Error("rbac reload failed, using cached policy", "err", err)
+			return policy, nil
+		}
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.policy, nil
+}
+
+func (r *rbacUsecase) AllowsMethod(ctx context.Context, role domain.Role, method string) (bool, error) {
+	policy, err := r.Policy(ctx)
+	if err != nil {
+		return false, err
+	}
+	return policy.AllowsMethod(role, method), nil
+}
+
+func (r *rbacUsecase) RoleExists(ctx context.Context, role domain.Role) (bool, error) {
+	policy, err := r.Policy(ctx)
+	if err != nil {
+		return false, err
+	}
+	return policy.RoleExists(role), nil
+}
+
+func (r *rbacUsecase) Permissions(ctx context.Context, role domain.Role) ([]string, error) {
+	policy, err := r.Policy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Permissions(role), nil
+}
+
+func (r *rbacUsecase) ListRoles(ctx context.Context) ([]*domain.RoleDefinition, error) {
+	if err := r.authorize(ctx, domain.ActionRBACManage, domain.AuthzResource{Type: domain.ResourceRBAC}); err != nil {
+		return nil, err
+	}
+
+	return r.repo.ListRoles(ctx)
+}
+
+func (r *rbacUsecase) CreateRole(ctx context.Context, role *domain.RoleDefinition) (err error) {
+	defer func() {
+		r.recordAudit(ctx, domain.AuditRBACChange, string(role.Name), outcomeOf(err), withDetail(errDetails(err), "op", "create_role"))
+	}()
+
+	if err := r.authorize(ctx, domain.ActionRBACManage, domain.AuthzResource{Type: domain.ResourceRBAC, ID: string(role.Name)}); err != nil {
+		return err
+	}
+
+	if !domain.ValidRoleName(role.Name) {
+		return domain.ErrInvalidRoleName
+	}
+	if err := r.checkPermissionsExist(ctx, role.Permissions); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	role.Builtin = false
+	role.CreatedAt, role.UpdatedAt = now, now
+	if err := r.repo.CreateRole(ctx, role); err != nil {
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			return domain.ErrRoleExists
+		}
+		return fmt.Errorf("CreateRole: %w", err)
+	}
+
+	r.changed(ctx)
+	return nil
+}
+
+// Replace description and permission bindings
+func (r *rbacUsecase) UpdateRole(ctx context.Context, role *domain.RoleDefinition) (err error) {
+	defer func() {
+		r.recordAudit(ctx, domain.AuditRBACChange, string(role.Name), outcomeOf(err), withDetail(errDetails(err), "op", "update_role"))
+	}()
+
+	if err := r.authorize(ctx, domain.ActionRBACManage, domain.AuthzResource{Type: domain.ResourceRBAC, ID: string(role.Name)}); err != nil {
+		return err
+	}
+
+	if role.Name == domain.ADMIN && !slices.Contains(role.Permissions, PermRBACManage) {
+		return domain.ErrAdminLockout
+	}
+	if err := r.checkPermissionsExist(ctx, role.Permissions); err != nil {
+		return err
+	}
+
+	role.UpdatedAt = time.Now().UTC()
+	if err := r.repo.UpdateRole(ctx, role); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrRoleNotFound
+		}
+		return fmt.Errorf("UpdateRole: %w", err)
+	}
+
+	r.changed(ctx)
+	return nil
+}
+
+func (r *rbacUsecase) DeleteRole(ctx context.Context, name domain.Role) (err error) {
+	defer func() {
+		r.recordAudit(ctx, domain.AuditRBACChange, string(name), outcomeOf(err), withDetail(errDetails(err), "op", "delete_role"))
+	}()
+
+	if err := r.authorize(ctx, domain.ActionRBACManage, domain.AuthzResource{Type: domain.ResourceRBAC, ID: string(name)}); err != nil {
+		return err
+	}
+
+	role, err := r.repo.GetRole(ctx, name)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrRoleNotFound
+		}
+		return fmt.Errorf("DeleteRole fetch: %w", err)
+	}
+	if role.Builtin {
+		return domain.ErrBuiltinRole
+	}
+
+	// Roles are shared by every tenant
+	assigned, _, err := r.users.List(domain.WithAllTenants(ctx), domain.UserFilter{Role: name, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("DeleteRole users: %w", err)
+	}
+	if len(assigned) > 0 {
+		return domain.ErrRoleInUse
+	}
+
+	if err := r.repo.DeleteRole(ctx, name); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrRoleNotFound
+		}
+		return fmt.Errorf("DeleteRole: %w", err)
+	}
+
+	r.changed(ctx)
+	return nil
+}
+
+func (r *rbacUsecase) GrantPermission(ctx context.Context, role domain.Role, perm string) (err error) {
+	defer func() {
+		details := withDetail(withDetail(errDetails(err), "op", "grant"), "permission", perm)
+		r.recordAudit(ctx, domain.AuditRBACChange, string(role), outcomeOf(err), details)
+	}()
+
+	if err := r.authorize(ctx, domain.ActionRBACManage, domain.AuthzResource{Type: domain.ResourceRBAC, ID: string(role)}); err != nil {
+		return err
+	}
+
+	if err := r.checkPermissionsExist(ctx, []string{perm}); err != nil {
+		return err
+	}
+	if err := r.repo.AddRolePermissions(ctx, role, perm); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrRoleNotFound
+		}
+		return fmt.Errorf("GrantPermission: %w", err)
+	}
+
+	r.changed(ctx)
+	return nil
+}
+
+func (r *rbacUsecase) RevokePermission(ctx context.Context, role domain.Role, perm string) (err error) {
+	defer func() {
+		details := withDetail(withDetail(errDetails(err), "op", "revoke"), "permission", perm)
+		r.recordAudit(ctx, domain.AuditRBACChange, string(role), outcomeOf(err), details)
+	}()
+
+	if err := r.authorize(ctx, domain.ActionRBACManage, domain.AuthzResource{Type: domain.ResourceRBAC, ID: string(role)}); err != nil {
+		return err
+	}
+
+	if role == domain.ADMIN && perm == PermRBACManage {
+		return domain.ErrAdminLockout
+	}
+	if err := r.repo.RemoveRolePermissions(ctx, role, perm); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrRoleNotFound
+		}
+		return fmt.Errorf("RevokePermission: %w", err)
+	}
+
+	r.changed(ctx)
+	return nil
+}
+
+func (r *rbacUsecase) ListPermissions(ctx context.Context) ([]*domain.Permission, error) {
+	if err := r.authorize(ctx, domain.ActionRBACManage, domain.AuthzResource{Type: domain.ResourceRBAC}); err != nil {
+		return nil, err
+	}
+
+	return r.repo.ListPermissions(ctx)
+}
+
+// Custom permission over existing RPCs, e.g. to split users.manage for a registrar
+func (r *rbacUsecase) CreatePermission(ctx context.Context, p *domain.Permission) (err error) {
+	defer func() {
+		r.recordAudit(ctx, domain.AuditRBACChange, p.Name, outcomeOf(err), withDetail(errDetails(err), "op", "create_permission"))
+	}()
+
+	if err := r.authorize(ctx, domain.ActionRBACManage, domain.AuthzResource{Type: domain.ResourceRBAC, ID: p.Name}); err != nil {
+		return err
+	}
+
+	if !domain.ValidPermissionName(p.Name) {
+		return domain.ErrInvalidPermName
+	}
+	if len(p.Methods) == 0 {
+		return domain.ErrInvalidPermMethods
+	}
+	for _, m := range p.Methods {
+		if !strings.HasPrefix(m, "/") || strings.Count(m, "/") != 2 {
+			return domain.ErrInvalidPermMethods
+		}
+	}
+
+	now := time.Now().UTC()
+	p.Builtin = false
+	p.CreatedAt, p.UpdatedAt = now, now
+	if err := r.repo.CreatePermission(ctx, p); err != nil {
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			return domain.ErrPermExists
+		}
+		return fmt.Errorf("CreatePermission: %w", err)
+	}
+
+	r.changed(ctx)
+	return nil
+}
+
+func (r *rbacUsecase) DeletePermission(ctx context.Context, name string) (err error) {
+	defer func() {
+		r.recordAudit(ctx, domain.AuditRBACChange, name, outcomeOf(err), withDetail(errDetails(err), "op", "delete_permission"))
+	}()
+
+	if err := r.authorize(ctx, domain.ActionRBACManage, domain.AuthzResource{Type: domain.ResourceRBAC, ID: name}); err != nil {
+		return err
+	}
+
+	perm, err := r.repo.GetPermission(ctx, name)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrPermNotFound
+		}
+		return fmt.Errorf("DeletePermission fetch: %w", err)
+	}
+	if perm.Builtin {
+		return domain.ErrBuiltinPerm
+	}
+
+	if err := r.repo.UnbindPermission(ctx, name); err != nil {
+		return fmt.Errorf("DeletePermission unbind: %w", err)
+	}
+	if err := r.repo.DeletePermission(ctx, name); err != nil {
+		return fmt.Errorf("DeletePermission: %w", err)
+	}
+
+	r.changed(ctx)
+	return nil
+}
+
+func (r *rbacUsecase) checkPermissionsExist(ctx context.Context, names []string) error {
+	for _, name := range names {
+		if _, err := r.repo.GetPermission(ctx, name); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("%w: %s", domain.ErrPermNotFound, name)
+			}
+			return fmt.Errorf("checkPermissionsExist: %w", err)
+		}
+	}
+	return nil
+}
+
+// Reload locally and tell the other replicas, a lost notice is covered by the TTL
+func (r *rbacUsecase) changed(ctx context.Context) {
+	if err := r.Reload(ctx); err != nil {
+		r.log.Error("rbac reload after change failed", "err", err)
+	}
+	if err := r.notifier.PublishRBACChanged(ctx); err != nil {
+		r.log.Error("rbac change notice failed", "err", err)
+	}
+}
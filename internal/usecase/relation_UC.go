@@ -0,0 +1,198 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+)
+
+const (
+	maxRelationChange = 500 // tuples per write
+	maxExpandDepth    = 5   // nested subject sets followed by check and list
+	maxExpandSubjects = 1000
+)
+
+// Zanzibar style relationship graph, fed by the course service
+type relationUsecase struct {
+	auditor
+	authorizer
+	log  *logger.Logger
+	repo repository.RelationRepository
+}
+
+func NewRelationUsecase(
+	repo repository.RelationRepository,
+	audit repository.AuditRepository,
+	log *logger.Logger,
+	authz domain.Authorizer,
+	roles domain.RoleCatalog,
+) RelationUsecase {
+	return &relationUsecase{repo: repo, log: log, auditor: auditor{audit: audit, auditLog: log}, authorizer: authorizer{authz: authz, roles: roles}}
+}
+
+// Admin writes through the API, the course service goes through ApplyRelationChange
+func (r *relationUsecase) WriteRelations(ctx context.Context, change domain.RelationChange) (err error) {
+	defer func() { r.auditChange(ctx, change, "api", err) }()
+
+	if err := r.authorize(ctx, domain.ActionRelationManage, domain.AuthzResource{Type: domain.ResourceRelation}); err != nil {
+		return err
+	}
+	return r.apply(ctx, change)
+}
+
+func (r *relationUsecase) ApplyRelationChange(ctx context.Context, change domain.RelationChange) (err error) {
+	defer func() { r.auditChange(ctx, change, "event", err) }()
+	return r.apply(ctx, change)
+}
+
+func (r *relationUsecase) auditChange(ctx context.Context, change domain.RelationChange, source string, err error) {
+	details := withDetail(errDetails(err), "source", source)
+	details = withDetail(details, "writes", strconv.Itoa(len(change.Writes)))
+	r.recordAudit(ctx, domain.AuditRelationChange, "", outcomeOf(err), withDetail(details, "deletes", strconv.Itoa(len(change.Deletes))))
+}
+
+func (r *relationUsecase) apply(ctx context.Context, change domain.RelationChange) error {
+	n := len(change.Writes) + len(change.Deletes)
+	if n == 0 {
+		return domain.ErrEmptyRelations
+	}
+	if n > maxRelationChange {
+		return fmt.Errorf("%w: at most %d tuples per change", domain.ErrInvalidTuple, maxRelationChange)
+	}
+
+	if len(change.Writes) > 0 {
+		if err := r.repo.Write(ctx, change.Writes); err != nil {
+			return fmt.Errorf("ApplyRelationChange write: %w", err)
+		}
+	}
+	if len(change.Deletes) > 0 {
+		if err := r.repo.Delete(ctx, change.Deletes); err != nil {
+			return fmt.Errorf("ApplyRelationChange delete: %w", err)
+		}
+	}
+	return nil
+}
+
+// Empty subject checks the caller, anyone else needs authz.check
+func (r *relationUsecase) CheckRelation(ctx context.Context, objectType, objectID, relation string, subject *domain.SubjectRef) (bool, error) {
+	sub, err := r.callerOr(ctx, subject)
+	if err != nil {
+		return false, err
+	}
+	return r.check(ctx, objectType, []string{objectID}, relation, sub)
+}
+
+func (r *relationUsecase) ListObjects(ctx context.Context, objectType, relation string, subject *domain.SubjectRef) ([]string, error) {
+	sub, err := r.callerOr(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	return r.listObjects(ctx, objectType, relation, sub)
+}
+
+func (r *relationUsecase) TeachesStudent(ctx context.Context, teacherID, studentID string) (bool, error) {
+	teacher, student := domain.UserSubject(teacherID), domain.UserSubject(studentID)
+
+	courses, err := r.listObjects(ctx, domain.ObjectCourse, domain.RelationTeacher, teacher)
+	if err != nil {
+		return false, err
+	}
+	if len(courses) > 0 {
+		ok, err := r.check(ctx, domain.ObjectCourse, courses, domain.RelationStudent, student)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	// Class rosters the teacher owns
+	classes, err := r.listObjects(ctx, domain.ObjectGroup, domain.RelationOwner, teacher)
+	if err != nil || len(classes) == 0 {
+		return false, err
+	}
+	return r.check(ctx, domain.ObjectGroup, classes, domain.RelationMember, student)
+}
+
+// Direct memberships only, groups don't nest
+func (r *relationUsecase) GroupsOf(ctx context.Context, userID string) ([]string, error) {
+	ids, err := r.repo.ListObjects(ctx, domain.ObjectGroup, domain.RelationMember, []string{domain.UserSubject(userID).String()})
+	if err != nil {
+		return nil, fmt.Errorf("GroupsOf: %w", err)
+	}
+	return ids, nil
+}
+
+func (r *relationUsecase) listObjects(ctx context.Context, objectType, relation string, sub domain.SubjectRef) ([]string, error) {
+	keys, err := r.expand(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := r.repo.ListObjects(ctx, objectType, relation, keys)
+	if err != nil {
+		return nil, fmt.Errorf("ListObjects: %w", err)
+	}
+	return ids, nil
+}
+
+func (r *relationUsecase) check(ctx context.Context, objectType string, objectIDs []string, relation string, sub domain.SubjectRef) (bool, error) {
+	keys, err := r.expand(ctx, sub)
+	if err != nil {
+		return false, err
+	}
+	ok, err := r.repo.ExistsAny(ctx, objectType, objectIDs, relation, keys)
+	if err != nil {
+		return false, fmt.Errorf("check relation: %w", err)
+	}
+	return ok, nil
+}
+
+// The subject and every subject set it belongs to, e.g. user:x is in course:101#student
+func (r *relationUsecase) expand(ctx context.Context, sub domain.SubjectRef) ([]string, error) {
+	seen := map[string]struct{}{sub.String(): {}}
+	keys := []string{sub.String()}
+	frontier := keys
+
+	for depth := 0; depth < maxExpandDepth && len(frontier) > 0; depth++ {
+		tuples, err := r.repo.ListBySubjects(ctx, frontier)
+		if err != nil {
+			return nil, fmt.Errorf("expand subject: %w", err)
+		}
+
+		frontier = nil
+		for _, t := range tuples {
+			set := domain.SubjectRef{Type: t.ObjectType, ID: t.ObjectID, Relation: t.Relation}.String()
+			if _, ok := seen[set]; ok {
+				continue
+			}
+			if len(keys) >= maxExpandSubjects {
+				r.log.Warn("relation expansion truncated", "subject", sub.String())
+				return keys, nil
+			}
+			seen[set] = struct{}{}
+			keys = append(keys, set)
+			frontier = append(frontier, set)
+		}
+	}
+	return keys, nil
+}
+
+func (r *relationUsecase) callerOr(ctx context.Context, subject *domain.SubjectRef) (domain.SubjectRef, error) {
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+	caller := domain.UserSubject(claims.UserID)
+	if subject == nil || *subject == caller {
+		return caller, nil
+	}
+
+	res := domain.AuthzResource{Type: domain.ResourceRelation, ID: subject.String()}
+	if subject.Type == domain.ObjectUser && subject.Relation == "" {
+		res = userIDResource(subject.ID)
+	}
+	if err := r.authorize(ctx, domain.ActionAuthzCheck, res); err != nil {
+		return domain.SubjectRef{}, err
+	}
+	return *subject, nil
+}
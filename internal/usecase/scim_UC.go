@@ -0,0 +1,911 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	scimTokenPrefix      = "scim_"
+	scimScanPage         = 500 // users or groups read per query of a list scan
+	maxScimGroupMembers  = 10000
+	maxScimClientNameLen = 128
+	scimStatusReason     = "deprovisioned by scim"
+)
+
+type ScimConfig struct {
+	DefaultRole  domain.Role   // for users created without a role
+	DefaultCC    string        // phone numbers without a country code
+	ErasureGrace time.Duration // deleted users are restorable for
+}
+
+// SCIM provisioning. Requests come from a client token, not a signed-in
+// user, so they aren't checked against the policy: the token is scoped to
+// one tenant and only admins with scim.manage can create one.
+type scimUsecase struct {
+	auditor
+	authorizer
+	tenancy
+	log       *logger.Logger
+	clients   repository.ScimClientRepository
+	users     repository.UserRepository
+	groups    repository.GroupRepository
+	relations repository.RelationRepository
+	tx        repository.TxManager
+	hasher    domain.PasswordHasher
+	publisher domain.UserEventPublisher
+	revoker   domain.SessionRevoker
+	emails    domain.EmailNormalizer
+	cfg       ScimConfig
+}
+
+func NewScimUsecase(
+	clients repository.ScimClientRepository,
+	users repository.UserRepository,
+	groups repository.GroupRepository,
+	relations repository.RelationRepository,
+	tx repository.TxManager,
+	hasher domain.PasswordHasher,
+	publisher domain.UserEventPublisher,
+	revoker domain.SessionRevoker,
+	emails domain.EmailNormalizer,
+	tenants domain.TenantDirectory,
+	cfg ScimConfig,
+	audit repository.AuditRepository,
+	log *logger.Logger,
+	authz domain.Authorizer,
+	roles domain.RoleCatalog,
+) ScimUsecase {
+	return &scimUsecase{
+		clients:    clients,
+		users:      users,
+		groups:     groups,
+		relations:  relations,
+		tx:         tx,
+		hasher:     hasher,
+		publisher:  publisher,
+		revoker:    revoker,
+		emails:     emails,
+		cfg:        cfg,
+		log:        log,
+		tenancy:    tenancy{tenants: tenants},
+		auditor:    auditor{audit: audit, auditLog: log},
+		authorizer: authorizer{authz: authz, roles: roles},
+	}
+}
+
+// ------------ clients ------------
+
+func (s *scimUsecase) CreateScimClient(ctx context.Context, name string) (client *domain.ScimClient, token string, err error) {
+	defer func() {
+		var targetID string
+		if client != nil {
+			targetID = client.ID
+		}
+		s.recordAudit(ctx, domain.AuditScimClient, targetID, outcomeOf(err), withDetail(errDetails(err), "op", "create"))
+	}()
+
+	if err := s.authorize(ctx, domain.ActionScimManage, domain.AuthzResource{Type: domain.ResourceScim, Tenant: domain.TenantOf(ctx)}); err != nil {
+		return nil, "", err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxScimClientNameLen {
+		return nil, "", domain.ErrInvalidScimClient
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", fmt.Errorf("CreateScimClient token: %w", err)
+	}
+	token = scimTokenPrefix + secret
+
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+	client = &domain.ScimClient{
+		ID:        uuid.NewString(),
+		Name:      name,
+		TokenHash: hashToken(token),
+		CreatedBy: claims.UserID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.clients.Create(ctx, client); err != nil {
+		return nil, "", fmt.Errorf("CreateScimClient: %w", err)
+	}
+	return client, token, nil
+}
+
+func (s *scimUsecase) ListScimClients(ctx context.Context) ([]*domain.ScimClient, error) {
+	if err := s.authorize(ctx, domain.ActionScimManage, domain.AuthzResource{Type: domain.ResourceScim, Tenant: domain.TenantOf(ctx)}); err != nil {
+		return nil, err
+	}
+	clients, err := s.clients.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ListScimClients: %w", err)
+	}
+	return clients, nil
+}
+
+func (s *scimUsecase) RevokeScimClient(ctx context.Context, id string) (err error) {
+	defer func() {
+		s.recordAudit(ctx, domain.AuditScimClient, id, outcomeOf(err), withDetail(errDetails(err), "op", "revoke"))
+	}()
+
+	if err := s.authorize(ctx, domain.ActionScimManage, domain.AuthzResource{Type: domain.ResourceScim, ID: id, Tenant: domain.TenantOf(ctx)}); err != nil {
+		return err
+	}
+	if err := s.clients.Revoke(ctx, id, time.Now().UTC()); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrScimClientNotFound
+		}
+		return fmt.Errorf("RevokeScimClient: %w", err)
+	}
+	return nil
+}
+
+// The client's tenant scope, with the client as the actor of events and audit entries
+func (s *scimUsecase) AuthenticateScim(ctx context.Context, token string) (context.Context, error) {
+	if !strings.HasPrefix(token, scimTokenPrefix) {
+		return nil, domain.ErrScimUnauthorized
+	}
+	client, err := s.clients.GetByTokenHash(domain.WithAllTenants(ctx), hashToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrScimUnauthorized
+		}
+		return nil, fmt.Errorf("AuthenticateScim: %w", err)
+	}
+	if client.RevokedAt != nil {
+		return nil, domain.ErrScimUnauthorized
+	}
+
+	t, err := s.tenants.Tenant(ctx, client.TenantID)
+	if err != nil {
+		return nil, fmt.Errorf("AuthenticateScim tenant: %w", err)
+	}
+	if !t.IsActive() {
+		return nil, domain.ErrTenantDisabled
+	}
+
+	ctx = domain.WithTenant(ctx, client.TenantID)
+	return context.WithValue(ctx, middleware.UserCtxKey, &domain.TokenPayload{
+		UserID:   client.ActorID(),
+		TenantID: client.TenantID,
+	}), nil
+}
+
+// ------------ users ------------
+
+// Deleted accounts are gone for SCIM
+func (s *scimUsecase) GetScimUser(ctx context.Context, id string) (*domain.User, error) {
+	usr, err := s.users.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("GetScimUser: %w", err)
+	}
+	if usr.IsDeleted() {
+		return nil, domain.ErrUserNotFound
+	}
+	return usr, nil
+}
+
+func (s *scimUsecase) ListScimUsers(ctx context.Context, q domain.ScimUserQuery) ([]*domain.User, int, error) {
+	var (
+		page  []*domain.User
+		total int
+	)
+	visit := func(users []*domain.User) {
+		for _, u := range users {
+			if u.IsDeleted() || q.Match != nil && !q.Match(u) {
+				continue
+			}
+			total++
+			if total >= q.StartIndex && len(page) < q.Count {
+				page = append(page, u)
+			}
+		}
+	}
+
+	switch q.Lookup {
+	case domain.LookupNone:
+		f := domain.UserFilter{Limit: scimScanPage}
+		for {
+			users, next, err := s.users.List(ctx, f)
+			if err != nil {
+				return nil, 0, fmt.Errorf("ListScimUsers scan: %w", err)
+			}
+			visit(users)
+			if next == "" {
+				break
+			}
+			f.Cursor = next
+		}
+		return page, total, nil
+
+	case domain.LookupID:
+		usr, err := s.users.GetByID(ctx, q.Value)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return nil, 0, fmt.Errorf("ListScimUsers id: %w", err)
+		}
+		if usr != nil {
+			visit([]*domain.User{usr})
+		}
+		return page, total, nil
+	}
+
+	users, err := s.lookupUsers(ctx, q.Lookup, q.Value)
+	if err != nil {
+		return nil, 0, err
+	}
+	visit(users)
+	return page, total, nil
+}
+
+func (s *scimUsecase) lookupUsers(ctx context.Context, by domain.ScimLookup, value string) ([]*domain.User, error) {
+	if by == domain.LookupUserName && !domain.IsEmailIdentifier(value) {
+		users, err := s.users.ListByUsernames(ctx, []string{value})
+		if err != nil {
+			return nil, fmt.Errorf("ListScimUsers username: %w", err)
+		}
+		return users, nil
+	}
+	if by == domain.LookupExternalID {
+		users, err := s.users.ListByExternalIDs(ctx, []string{value})
+		if err != nil {
+			return nil, fmt.Errorf("ListScimUsers external id: %w", err)
+		}
+		return users, nil
+	}
+
+	// An address that can't be normalised matches nobody
+	_, key, err := s.emails.Normalize(value)
+	if err != nil {
+		return nil, nil
+	}
+	users, err := s.users.ListByEmailKeys(ctx, []string{key})
+	if err != nil {
+		return nil, fmt.Errorf("ListScimUsers email: %w", err)
+	}
+	return users, nil
+}
+
+// in holds the SCIM attributes: ExternalID, Email, Username, Phone, Role and
+// Status active or disabled. An empty role is the default one.
+func (s *scimUsecase) CreateScimUser(ctx context.Context, in *domain.User, password string) (usr *domain.User, err error) {
+	defer func() {
+		var targetID string
+		if usr != nil {
+			targetID = usr.ID
+		}
+		s.recordAudit(ctx, domain.AuditScimProvision, targetID, outcomeOf(err), withDetail(errDetails(err), "op", "create_user"))
+	}()
+
+	if in.Role == "" {
+		in.Role = s.cfg.DefaultRole
+	}
+	if err := s.normalizeUser(ctx, in); err != nil {
+		return nil, err
+	}
+
+	var hashed string
+	if password != "" {
+		if hashed, err = s.hashPassword(ctx, password); err != nil {
+			return nil, err
+		}
+	}
+
+	usr = domain.NewUser(in.Email, in.EmailKey, hashed, in.Role)
+	usr.Username = in.Username
+	usr.Phone = in.Phone
+	usr.ExternalID = in.ExternalID
+	usr.UpdatedAt = usr.CreatedAt
+	if in.Status == domain.StatusDisabled {
+		usr.Status, usr.StatusReason = domain.StatusDisabled, scimStatusReason
+	}
+
+	actor := s.actor(ctx)
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.users.Create(ctx, usr); err != nil {
+			return scimUserErr("CreateScimUser", err)
+		}
+		return s.publisher.PublishUserRegistered(ctx, &domain.UserRegisteredEvent{
+			UserID:    usr.ID,
+			Email:     usr.Email,
+			Username:  usr.Username,
+			Role:      usr.Role,
+			CreatedBy: actor,
+			CreatedAt: usr.CreatedAt,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usr, nil
+}
+
+// Replaces the SCIM attributes, cleared ones included. An empty role or
+// status keeps the current one, a password is only set when given.
+func (s *scimUsecase) ReplaceScimUser(ctx context.Context, id, version string, in *domain.User, password string) (usr *domain.User, err error) {
+	var changed []string
+	defer func() {
+		details := withDetail(errDetails(err), "op", "replace_user")
+		if len(changed) > 0 {
+			details = withDetail(details, "fields", strings.Join(changed, ","))
+		}
+		s.recordAudit(ctx, domain.AuditScimProvision, id, outcomeOf(err), details)
+	}()
+
+	if usr, err = s.GetScimUser(ctx, id); err != nil {
+		return nil, err
+	}
+	if version != "" && version != domain.ScimVersion(usr.UpdatedAt) {
+		return nil, domain.ErrVersionMismatch
+	}
+	if err := s.normalizeUser(ctx, in); err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	if usr.ExternalID != in.ExternalID {
+		usr.ExternalID = in.ExternalID
+		changed = append(changed, "external_id")
+		fields = append(fields, "external_id")
+	}
+	if usr.Email != in.Email {
+		usr.Email, usr.EmailKey = in.Email, in.EmailKey
+		usr.Verified = false
+		changed = append(changed, "email")
+		fields = append(fields, "email", "email_key", "verified")
+	}
+	if usr.Username != in.Username {
+		usr.Username = in.Username
+		changed = append(changed, "username")
+		fields = append(fields, "username")
+	}
+	if usr.Phone != in.Phone {
+		usr.Phone = in.Phone
+		usr.PhoneVerified = false
+		changed = append(changed, "phone")
+		fields = append(fields, "phone", "phone_verified")
+	}
+	oldRole := usr.Role
+	if in.Role != "" && usr.Role != in.Role {
+		usr.Role = in.Role
+		changed = append(changed, "role")
+		fields = append(fields, "role")
+	}
+	// Reactivating doesn't lift a suspension
+	disabled := in.Status == domain.StatusDisabled && usr.Status != domain.StatusDisabled
+	if disabled || in.Status == domain.StatusActive && usr.Status == domain.StatusDisabled {
+		usr.Status, usr.StatusReason, usr.StatusUntil = in.Status, "", nil
+		if disabled {
+			usr.StatusReason = scimStatusReason
+		}
+		changed = append(changed, "status")
+		fields = append(fields, "status", "status_reason", "status_until")
+	}
+	if password != "" {
+		if usr.Password, err = s.hashPassword(ctx, password); err != nil {
+			return nil, err
+		}
+		fields = append(fields, "password")
+	}
+	if len(fields) == 0 {
+		return usr, nil
+	}
+
+	actor := s.actor(ctx)
+	usr.UpdatedAt = nextVersion(usr.UpdatedAt)
+	fields = append(fields, "updated_at")
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.users.Update(ctx, usr, fields...); err != nil {
+			return scimUserErr("ReplaceScimUser", err)
+		}
+		if oldRole != usr.Role {
+			if err := s.publisher.PublishUserRoleChanged(ctx, &domain.UserRoleChangedEvent{
+				UserID:    usr.ID,
+				OldRole:   oldRole,
+				NewRole:   usr.Role,
+				ChangedBy: actor,
+				CreatedAt: usr.UpdatedAt,
+			}); err != nil {
+				return fmt.Errorf("ReplaceScimUser PublishEvent (role): %w", err)
+			}
+		}
+		if password != "" {
+			if err := s.publisher.PublishPasswordChanged(ctx, &domain.PasswordChangedEvent{
+				UserID:    usr.ID,
+				CreatedAt: usr.UpdatedAt,
+			}); err != nil {
+				return fmt.Errorf("ReplaceScimUser PublishEvent (password): %w", err)
+			}
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+		return s.publisher.PublishUserProfileUpdated(ctx, &domain.UserProfileUpdatedEvent{
+			UserID:    usr.ID,
+			UpdatedBy: actor,
+			Fields:    changed,
+			CreatedAt: usr.UpdatedAt,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Role is baked into issued tokens, and a new password or a disabled account ends sessions
+	if oldRole != usr.Role || disabled || password != "" {
+		s.endSessions(ctx, usr.ID)
+	}
+	return usr, nil
+}
+
+// Soft delete, the account is restorable during the erasure grace period
+func (s *scimUsecase) DeleteScimUser(ctx context.Context, id, version string) (err error) {
+	defer func() {
+		s.recordAudit(ctx, domain.AuditScimProvision, id, outcomeOf(err), withDetail(errDetails(err), "op", "delete_user"))
+	}()
+
+	usr, err := s.GetScimUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if version != "" && version != domain.ScimVersion(usr.UpdatedAt) {
+		return domain.ErrVersionMismatch
+	}
+
+	now := time.Now().UTC()
+	purgeAt := now.Add(s.cfg.ErasureGrace)
+	usr.RestoreStatus = usr.EffectiveStatus(now)
+	usr.Status = domain.StatusDeleted
+	usr.DeletedAt = &now
+	usr.PurgeAt = &purgeAt
+	usr.UpdatedAt = now
+
+	actor := s.actor(ctx)
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.users.Update(ctx, usr, "status", "restore_status", "deleted_at", "purge_at", "updated_at"); err != nil {
+			return scimUserErr("DeleteScimUser", err)
+		}
+		return s.publisher.PublishUserDeleted(ctx, &domain.UserDeletedEvent{
+			UserID:    usr.ID,
+			DeletedBy: actor,
+			PurgeAt:   purgeAt,
+			CreatedAt: now,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	s.endSessions(ctx, usr.ID)
+	return nil
+}
+
+// Normalise the identifiers and check the role of a SCIM user
+func (s *scimUsecase) normalizeUser(ctx context.Context, in *domain.User) error {
+	var err error
+	in.EmailKey = ""
+	if in.Email != "" {
+		if in.Email, in.EmailKey, err = s.emails.Normalize(in.Email); err != nil {
+			return err
+		}
+	}
+	if in.Username != "" {
+		if in.Username, err = domain.NormalizeUsername(in.Username); err != nil {
+			return err
+		}
+	}
+	if in.Email == "" && in.Username == "" {
+		return domain.ErrNoLoginIdentifier
+	}
+	if in.Phone != "" {
+		if in.Phone, err = domain.NormalizePhone(in.Phone, s.cfg.DefaultCC); err != nil {
+			return err
+		}
+	}
+	if in.Role != "" {
+		exists, err := s.roles.RoleExists(ctx, in.Role)
+		if err != nil {
+			return fmt.Errorf("scim RoleExists: %w", err)
+		}
+		if !exists {
+			return domain.ErrRoleNotFound
+		}
+	}
+	return nil
+}
+
+// New passwords follow the tenant's policy
+func (s *scimUsecase) hashPassword(ctx context.Context, password string) (string, error) {
+	if err := s.checkPassword(ctx, password); err != nil {
+		return "", err
+	}
+	hashed, err := s.hasher.Hash(ctx, password)
+	if err != nil {
+		return "", fmt.Errorf("scim Hash: %w", err)
+	}
+	return hashed, nil
+}
+
+func (s *scimUsecase) endSessions(ctx context.Context, userID string) {
+	if err := s.revoker.RevokeAll(ctx, userID); err != nil {
+		s.log.Error("scim revoke sessions failed", "user_id", userID, "err", err)
+	}
+}
+
+// Client acting, see AuthenticateScim
+func (s *scimUsecase) actor(ctx context.Context) string {
+	if claims, ok := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload); ok {
+		return claims.UserID
+	}
+	return ""
+}
+
+// Update time of a change, at least a millisecond after the last one so
+// two changes in the same millisecond still get different ETags
+func nextVersion(last time.Time) time.Time {
+	now := time.Now().UTC()
+	if now.UnixMilli() <= last.UnixMilli() {
+		return last.Truncate(time.Millisecond).Add(time.Millisecond)
+	}
+	return now
+}
+
+func scimUserErr(op string, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrEmailAlreadyUsed):
+		return domain.ErrEmailAlreadyExists
+	case errors.Is(err, repository.ErrUsernameTaken):
+		return domain.ErrUsernameAlreadyExists
+	case errors.Is(err, repository.ErrAlreadyExists):
+		return domain.ErrExternalIDExists
+	case errors.Is(err, repository.ErrNotFound):
+		return domain.ErrUserNotFound
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// ------------ groups ------------
+
+func (s *scimUsecase) GetScimGroup(ctx context.Context, id string) (*domain.ScimGroup, error) {
+	grp, err := s.groups.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrGroupNotFound
+		}
+		return nil, fmt.Errorf("GetScimGroup: %w", err)
+	}
+	members, err := s.members(ctx, grp.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.ScimGroup{Group: grp, Members: members}, nil
+}
+
+func (s *scimUsecase) ListScimGroups(ctx context.Context, q domain.ScimGroupQuery) ([]*domain.ScimGroup, int, error) {
+	var (
+		page  []*domain.ScimGroup
+		total int
+	)
+	visit := func(groups []*domain.Group) error {
+		for _, grp := range groups {
+			members, err := s.members(ctx, grp.ID)
+			if err != nil {
+				return err
+			}
+			g := &domain.ScimGroup{Group: grp, Members: members}
+			if q.Match != nil && !q.Match(g) {
+				continue
+			}
+			total++
+			if total >= q.StartIndex && len(page) < q.Count {
+				page = append(page, g)
+			}
+		}
+		return nil
+	}
+
+	var (
+		groups []*domain.Group
+		err    error
+	)
+	switch q.Lookup {
+	case domain.LookupNone:
+		var after string
+		for {
+			groups, err := s.groups.List(ctx, after, scimScanPage)
+			if err != nil {
+				return nil, 0, fmt.Errorf("ListScimGroups scan: %w", err)
+			}
+			if err := visit(groups); err != nil {
+				return nil, 0, err
+			}
+			if len(groups) < scimScanPage {
+				return page, total, nil
+			}
+			after = groups[len(groups)-1].ID
+		}
+	case domain.LookupID:
+		groups, err = s.groups.ListByIDs(ctx, []string{q.Value})
+	case domain.LookupExternalID:
+		groups, err = s.groups.ListBySourcedIDs(ctx, []string{q.Value})
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("ListScimGroups: %w", err)
+	}
+	if err := visit(groups); err != nil {
+		return nil, 0, err
+	}
+	return page, total, nil
+}
+
+// Groups created over SCIM are cohorts, members are user IDs
+func (s *scimUsecase) CreateScimGroup(ctx context.Context, in *domain.ScimGroup) (g *domain.ScimGroup, err error) {
+	defer func() {
+		var targetID string
+		if g != nil {
+			targetID = g.Group.ID
+		}
+		s.recordAudit(ctx, domain.AuditScimProvision, targetID, outcomeOf(err), withDetail(errDetails(err), "op", "create_group"))
+	}()
+
+	name, members, err := s.checkGroup(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	actor := s.actor(ctx)
+	now := time.Now().UTC()
+	grp := &domain.Group{
+		ID:        uuid.NewString(),
+		Name:      name,
+		Kind:      domain.GroupCohort,
+		SourcedID: in.Group.SourcedID,
+		CreatedBy: actor,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.groups.Create(ctx, grp); err != nil {
+			return scimGroupErr("CreateScimGroup", err)
+		}
+		return s.changeMembers(ctx, grp.ID, members, nil, actor)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &domain.ScimGroup{Group: grp, Members: members}, nil
+}
+
+// Name, external ID and the full member list. Owners are left alone.
+func (s *scimUsecase) ReplaceScimGroup(ctx context.Context, id, version string, in *domain.ScimGroup) (g *domain.ScimGroup, err error) {
+	var added, removed []string
+	defer func() {
+		details := withDetail(errDetails(err), "op", "replace_group")
+		details = withDetail(details, "added", fmt.Sprint(len(added)))
+		details = withDetail(details, "removed", fmt.Sprint(len(removed)))
+		s.recordAudit(ctx, domain.AuditScimProvision, id, outcomeOf(err), details)
+	}()
+
+	cur, err := s.GetScimGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	grp := cur.Group
+	if version != "" && version != domain.ScimVersion(grp.UpdatedAt) {
+		return nil, domain.ErrVersionMismatch
+	}
+	name, members, err := s.checkGroup(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	want := make(map[string]bool, len(members))
+	for _, m := range members {
+		want[m] = true
+	}
+	have := make(map[string]bool, len(cur.Members))
+	for _, m := range cur.Members {
+		have[m] = true
+		if !want[m] {
+			removed = append(removed, m)
+		}
+	}
+	for _, m := range members {
+		if !have[m] {
+			added = append(added, m)
+		}
+	}
+	if grp.Name == name && grp.SourcedID == in.Group.SourcedID && len(added) == 0 && len(removed) == 0 {
+		return cur, nil
+	}
+
+	grp.Name, grp.SourcedID = name, in.Group.SourcedID
+	grp.UpdatedAt = nextVersion(grp.UpdatedAt)
+	actor := s.actor(ctx)
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.groups.Update(ctx, grp); err != nil {
+			return scimGroupErr("ReplaceScimGroup", err)
+		}
+		return s.changeMembers(ctx, grp.ID, added, removed, actor)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &domain.ScimGroup{Group: grp, Members: members}, nil
+}
+
+// Removes the group and every tuple on it or pointing at it
+func (s *scimUsecase) DeleteScimGroup(ctx context.Context, id, version string) (err error) {
+	defer func() {
+		s.recordAudit(ctx, domain.AuditScimProvision, id, outcomeOf(err), withDetail(errDetails(err), "op", "delete_group"))
+	}()
+
+	grp, err := s.groups.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrGroupNotFound
+		}
+		return fmt.Errorf("DeleteScimGroup fetch: %w", err)
+	}
+	if version != "" && version != domain.ScimVersion(grp.UpdatedAt) {
+		return domain.ErrVersionMismatch
+	}
+
+	tuples, err := s.relations.ListByObject(ctx, domain.ObjectGroup, grp.ID)
+	if err != nil {
+		return fmt.Errorf("DeleteScimGroup tuples: %w", err)
+	}
+	var subjects []string
+	for _, rel := range []string{domain.RelationMember, domain.RelationOwner} {
+		subjects = append(subjects, domain.SubjectRef{Type: domain.ObjectGroup, ID: grp.ID, Relation: rel}.String())
+	}
+	using, err := s.relations.ListBySubjects(ctx, subjects)
+	if err != nil {
+		return fmt.Errorf("DeleteScimGroup subject tuples: %w", err)
+	}
+	users := map[string][]string{} // relation -> user IDs
+	for _, t := range tuples {
+		if t.Subject.Type == domain.ObjectUser && t.Subject.Relation == "" {
+			users[t.Relation] = append(users[t.Relation], t.Subject.ID)
+		}
+	}
+
+	actor := s.actor(ctx)
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.groups.Delete(ctx, grp.ID); err != nil {
+			return scimGroupErr("DeleteScimGroup", err)
+		}
+		if all := append(tuples, using...); len(all) > 0 {
+			if err := s.relations.Delete(ctx, all); err != nil {
+				return fmt.Errorf("DeleteScimGroup tuples: %w", err)
+			}
+		}
+		for _, rel := range []string{domain.RelationMember, domain.RelationOwner} {
+			if len(users[rel]) == 0 {
+				continue
+			}
+			if err := s.publisher.PublishGroupMembersRemoved(ctx, &domain.GroupMembersRemovedEvent{
+				GroupID:   grp.ID,
+				Relation:  rel,
+				UserIDs:   users[rel],
+				ChangedBy: actor,
+				CreatedAt: time.Now().UTC(),
+			}); err != nil {
+				return fmt.Errorf("DeleteScimGroup PublishEvent: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Trimmed name and the member IDs, which must be live users of the tenant
+func (s *scimUsecase) checkGroup(ctx context.Context, in *domain.ScimGroup) (string, []string, error) {
+	name := strings.TrimSpace(in.Group.Name)
+	if name == "" || len(name) > maxGroupNameLen {
+		return "", nil, domain.ErrInvalidGroup
+	}
+	members := uniqueIDs(in.Members)
+	if len(members) > maxScimGroupMembers {
+		return "", nil, domain.ErrTooManyMembers
+	}
+	if len(members) == 0 {
+		return name, nil, nil
+	}
+
+	users, err := s.users.ListByIDs(ctx, members)
+	if err != nil {
+		return "", nil, fmt.Errorf("scim group members: %w", err)
+	}
+	live := make(map[string]bool, len(users))
+	for _, u := range users {
+		live[u.ID] = !u.IsDeleted()
+	}
+	var missing []string
+	for _, id := range members {
+		if !live[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return "", nil, fmt.Errorf("%w: %s", domain.ErrUserNotFound, strings.Join(missing, ", "))
+	}
+	return name, members, nil
+}
+
+// User members of the group, owners aren't SCIM members
+func (s *scimUsecase) members(ctx context.Context, groupID string) ([]string, error) {
+	tuples, err := s.relations.ListByObject(ctx, domain.ObjectGroup, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("scim group tuples: %w", err)
+	}
+	var ids []string
+	for _, t := range tuples {
+		if t.Relation == domain.RelationMember && t.Subject.Type == domain.ObjectUser && t.Subject.Relation == "" {
+			ids = append(ids, t.Subject.ID)
+		}
+	}
+	return ids, nil
+}
+
+// Run inside the caller's transaction, the events go out with the tuples
+func (s *scimUsecase) changeMembers(ctx context.Context, groupID string, added, removed []string, actor string) error {
+	now := time.Now().UTC()
+	if len(added) > 0 {
+		writes := make([]domain.RelationTuple, 0, len(added))
+		for _, id := range added {
+			writes = append(writes, groupTuple(groupID, domain.RelationMember, id))
+		}
+		if err := s.relations.Write(ctx, writes); err != nil {
+			return fmt.Errorf("scim add members: %w", err)
+		}
+		if err := s.publisher.PublishGroupMembersAdded(ctx, &domain.GroupMembersAddedEvent{
+			GroupID:   groupID,
+			Relation:  domain.RelationMember,
+			UserIDs:   added,
+			ChangedBy: actor,
+			CreatedAt: now,
+		}); err != nil {
+			return fmt.Errorf("scim PublishEvent (added): %w", err)
+		}
+	}
+	if len(removed) > 0 {
+		deletes := make([]domain.RelationTuple, 0, len(removed))
+		for _, id := range removed {
+			deletes = append(deletes, groupTuple(groupID, domain.RelationMember, id))
+		}
+		if err := s.relations.Delete(ctx, deletes); err != nil {
+			return fmt.Errorf("scim remove members: %w", err)
+		}
+		if err := s.publisher.PublishGroupMembersRemoved(ctx, &domain.GroupMembersRemovedEvent{
+			GroupID:   groupID,
+			Relation:  domain.RelationMember,
+			UserIDs:   removed,
+			ChangedBy: actor,
+			CreatedAt: now,
+		}); err != nil {
+			return fmt.Errorf("scim PublishEvent (removed): %w", err)
+		}
+	}
+	return nil
+}
+
+func scimGroupErr(op string, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrAlreadyExists):
+		return domain.ErrExternalIDExists
+	case errors.Is(err, repository.ErrNotFound):
+		return domain.ErrGroupNotFound
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
@@ -0,0 +1,194 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/Neroframe/AuthService/pkg/ttlcache"
+)
+
+const maxTenantsCached = 1000
+
+// Schools hosted on this deployment, looked up on every request
+type tenantUsecase struct {
+	auditor
+	authorizer
+	log   *logger.Logger
+	repo  repository.TenantRepository
+	cache *ttlcache.Cache[string, *domain.Tenant]
+}
+
+func NewTenantUsecase(
+	repo repository.TenantRepository,
+	audit repository.AuditRepository,
+	log *logger.Logger,
+	authz domain.Authorizer,
+	roles domain.RoleCatalog,
+	cacheTTL time.Duration,
+) TenantUsecase {
+	return &tenantUsecase{
+		repo:       repo,
+		log:        log,
+		auditor:    auditor{audit: audit, auditLog: log},
+		authorizer: authorizer{authz: authz, roles: roles},
+		cache:      ttlcache.New[string, *domain.Tenant](cacheTTL, maxTenantsCached),
+	}
+}
+
+func (t *tenantUsecase) Seed(ctx context.Context, name string, cfg domain.TenantConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("Seed tenant config: %w", err)
+	}
+	now := time.Now().UTC()
+	err := t.repo.Ensure(ctx, &domain.Tenant{
+		ID:        domain.DefaultTenantID,
+		Name:      name,
+		Status:    domain.TenantActive,
+		Config:    cfg,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("Seed tenant: %w", err)
+	}
+	return nil
+}
+
+// Cached, other replicas see updates after the cache TTL
+func (t *tenantUsecase) Tenant(ctx context.Context, id string) (*domain.Tenant, error) {
+	if tn, ok := t.cache.Get(id); ok {
+		return tn, nil
+	}
+
+	tn, err := t.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrTenantNotFound
+		}
+		return nil, fmt.Errorf("Tenant: %w", err)
+	}
+	t.cache.Set(id, tn)
+	return tn, nil
+}
+
+func (t *tenantUsecase) CreateTenant(ctx context.Context, tn *domain.Tenant) (_ *domain.Tenant, err error) {
+	defer func() {
+		t.recordAudit(ctx, domain.AuditTenantChange, "", outcomeOf(err), withDetail(errDetails(err), "created", tn.ID))
+	}()
+
+	if err := t.authorize(ctx, domain.ActionTenantCreate, domain.AuthzResource{Type: domain.ResourceTenant, ID: tn.ID}); err != nil {
+		return nil, err
+	}
+
+	tn.Name = strings.TrimSpace(tn.Name)
+	if !domain.ValidTenantID(tn.ID) || tn.Name == "" {
+		return nil, domain.ErrInvalidTenant
+	}
+	if err := tn.Config.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	tn.Status = domain.TenantActive
+	tn.CreatedAt, tn.UpdatedAt = now, now
+	if err := t.repo.Create(ctx, tn); err != nil {
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			return nil, domain.ErrTenantExists
+		}
+		return nil, fmt.Errorf("CreateTenant: %w", err)
+	}
+	return tn, nil
+}
+
+func (t *tenantUsecase) GetTenant(ctx context.Context, id string) (*domain.Tenant, error) {
+	if err := t.authorize(ctx, domain.ActionTenantRead, domain.AuthzResource{Type: domain.ResourceTenant, ID: id}); err != nil {
+		return nil, err
+	}
+
+	tn, err := t.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrTenantNotFound
+		}
+		return nil, fmt.Errorf("GetTenant: %w", err)
+	}
+	return tn, nil
+}
+
+func (t *tenantUsecase) ListTenants(ctx context.Context) ([]*domain.Tenant, error) {
+	if err := t.authorize(ctx, domain.ActionTenantList, domain.AuthzResource{Type: domain.ResourceTenant}); err != nil {
+		return nil, err
+	}
+
+	tenants, err := t.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ListTenants: %w", err)
+	}
+	return tenants, nil
+}
+
+// Tenant admins can change their own config, only platform admins the status
+func (t *tenantUsecase) UpdateTenant(ctx context.Context, upd domain.TenantUpdate) (_ *domain.Tenant, err error) {
+	defer func() {
+		t.recordAudit(ctx, domain.AuditTenantChange, "", outcomeOf(err), withDetail(errDetails(err), "updated", upd.ID))
+	}()
+
+	res := domain.AuthzResource{Type: domain.ResourceTenant, ID: upd.ID}
+	if err := t.authorize(ctx, domain.ActionTenantUpdate, res); err != nil {
+		return nil, err
+	}
+	if upd.Status != nil {
+		if err := t.authorize(ctx, domain.ActionTenantSetStatus, res); err != nil {
+			return nil, err
+		}
+	}
+
+	tn, err := t.repo.GetByID(ctx, upd.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrTenantNotFound
+		}
+		return nil, fmt.Errorf("UpdateTenant get: %w", err)
+	}
+
+	if upd.Name != nil {
+		tn.Name = strings.TrimSpace(*upd.Name)
+		if tn.Name == "" {
+			return nil, domain.ErrInvalidTenant
+		}
+	}
+	if upd.Status != nil {
+		switch *upd.Status {
+		case domain.TenantActive, domain.TenantDisabled:
+		default:
+			return nil, domain.ErrInvalidTenant
+		}
+		// Its admins manage every tenant, disabling it would lock everyone out
+		if tn.ID == domain.DefaultTenantID && *upd.Status != domain.TenantActive {
+			return nil, domain.ErrInvalidTenant
+		}
+		tn.Status = *upd.Status
+	}
+	if upd.Config != nil {
+		if err := upd.Config.Validate(); err != nil {
+			return nil, err
+		}
+		tn.Config = *upd.Config
+	}
+
+	tn.UpdatedAt = time.Now().UTC()
+	if err := t.repo.Update(ctx, tn); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrTenantNotFound
+		}
+		return nil, fmt.Errorf("UpdateTenant: %w", err)
+	}
+	t.cache.Delete(tn.ID)
+	return tn, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/Neroframe/AuthService/internal/adapters/grpc/middleware"
 	"github.com/Neroframe/AuthService/internal/domain"
@@ -11,32 +13,6 @@ import (
 )
 
 func (u *userUsecase) GetUserByID(ctx context.Context, userID string) (*domain.User, error) {
-	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
-
-	target, err := u.repo.GetByID(ctx, userID)
-	if err != nil {
-		if errors.Is(err, repository.ErrNotFound) {
-			return nil, domain.ErrUserNotFound
-		}
-		return nil, fmt.Errorf("UpdateProfile fetch: %w", err)
-	}
-
-	// Check for role permission
-	switch claims.Role {
-	case domain.ADMIN:
-		// allowed
-	case domain.TEACHER: // only itself and students
-		if target.Role == domain.ADMIN || (target.Role == domain.TEACHER && target.ID != userID) {
-			return nil, domain.ErrPermissionDenied
-		}
-	case domain.STUDENT: // only itself
-		if target.Role == domain.ADMIN || target.Role == domain.TEACHER || (target.Role == domain.STUDENT && target.ID != userID) {
-			return nil, domain.ErrPermissionDenied
-		}
-	default:
-		return nil, domain.ErrPermissionDenied
-	}
-
 	usr, err := u.repo.GetByID(ctx, userID)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
@@ -45,11 +21,15 @@ func (u *userUsecase) GetUserByID(ctx context.Context, userID string) (*domain.U
 		return nil, fmt.Errorf("GetUserByID: %w", err)
 	}
 
+	if err := u.authorize(ctx, domain.ActionUserRead, userResource(usr)); err != nil {
+		return nil, err
+	}
+
 	return usr, nil
 }
 
 func (u *userUsecase) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
-	usr, err := u.repo.GetByEmail(ctx, email)
+	usr, err := u.getByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			return nil, domain.ErrUserNotFound
@@ -57,10 +37,18 @@ func (u *userUsecase) GetUserByEmail(ctx context.Context, email string) (*domain
 		return nil, fmt.Errorf("GetUserByEmail: %w", err)
 	}
 
+	if err := u.authorize(ctx, domain.ActionUserRead, userResource(usr)); err != nil {
+		return nil, err
+	}
+
 	return usr, nil
 }
 
-func (u *userUsecase) UpdateProfile(ctx context.Context, p domain.UpdateUserProfileParams) (*domain.User, error) {
+func (u *userUsecase) UpdateProfile(ctx context.Context, p domain.UpdateUserProfileParams) (_ *domain.User, err error) {
+	defer func() {
+		u.recordAudit(ctx, domain.AuditProfileUpdate, p.ID, outcomeOf(err), errDetails(err))
+	}()
+
 	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
 
 	target, err := u.repo.GetByID(ctx, p.ID)
@@ -71,42 +59,84 @@ func (u *userUsecase) UpdateProfile(ctx context.Context, p domain.UpdateUserProf
 		return nil, fmt.Errorf("UpdateProfile fetch: %w", err)
 	}
 
-	// Check for role permission
-	switch claims.Role {
-	case domain.ADMIN:
-		// allowed
-	case domain.TEACHER: // only itself and students
-		if target.Role == domain.ADMIN || (target.Role == domain.TEACHER && target.ID != p.ID) {
-			return nil, domain.ErrPermissionDenied
+	if err := u.authorize(ctx, domain.ActionUserUpdate, userResource(target)); err != nil {
+		return nil, err
+	}
+
+	// Email changes go through RequestEmailChange
+	if p.Email != "" && p.Email != target.Email {
+		return nil, domain.ErrEmailChangeNeedsConfirm
+	}
+
+	// Username-only accounts can't drop their username
+	username := p.Username
+	if username != "" || target.Email == "" {
+		if username, err = domain.NormalizeUsername(p.Username); err != nil {
+			return nil, err
 		}
-	case domain.STUDENT: // only itself
-		if target.Role == domain.ADMIN || target.Role == domain.TEACHER || (target.Role == domain.STUDENT && target.ID != p.ID) {
-			return nil, domain.ErrPermissionDenied
+	}
+
+	phone := p.Phone
+	if phone != "" {
+		if phone, err = domain.NormalizePhone(p.Phone, u.phone.DefaultCC); err != nil {
+			return nil, err
 		}
-	default:
-		return nil, domain.ErrPermissionDenied
 	}
 
-	// Set new values
-	target.Email = p.Email
-	target.Username = p.Username
-	target.Phone = p.Phone
+	// Only what differs is written and announced
+	var changed []string
+	if username != target.Username {
+		changed = append(changed, "username")
+	}
+	if phone != target.Phone {
+		changed = append(changed, "phone")
+	}
+	if len(changed) == 0 {
+		return target, nil
+	}
+
+	// Set new values, a new phone has to be verified again
+	fields := append([]string{"updated_at"}, changed...)
+	if phone != target.Phone {
+		target.PhoneVerified = false
+		fields = append(fields, "phone_verified")
+	}
+	target.Username = username
+	target.Phone = phone
+	target.UpdatedAt = time.Now().UTC()
 
 	// Update user profile
-	updated, err := u.repo.Update(ctx, target, "email", "username", "phone", "updated_at")
-	if err != nil {
-		if errors.Is(err, repository.ErrNotFound) {
-			return nil, domain.ErrUserNotFound
+	var updated *domain.User
+	err = u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		res, err := u.repo.Update(ctx, target, fields...)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return domain.ErrUserNotFound
+			}
+			if errors.Is(err, repository.ErrUsernameTaken) {
+				return domain.ErrUsernameAlreadyExists
+			}
+			return fmt.Errorf("UpdateProfile: %w", err)
 		}
-		return nil, fmt.Errorf("UpdateProfile: %w", err)
+		updated = res
+
+		return u.publisher.PublishUserProfileUpdated(ctx, &domain.UserProfileUpdatedEvent{
+			UserID:    updated.ID,
+			UpdatedBy: claims.UserID,
+			Fields:    changed,
+			CreatedAt: time.Now().UTC(),
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return updated, nil
 }
 
-func (u *userUsecase) VerifyAccount(ctx context.Context, userID string) error {
-	// Get user
-	usr, err := u.repo.GetByID(ctx, userID)
+// Mark the account verified, once the code sent to email was confirmed
+func (u *userUsecase) VerifyAccount(ctx context.Context, email string) error {
+	usr, err := u.getByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			return domain.ErrUserNotFound
@@ -123,36 +153,238 @@ func (u *userUsecase) VerifyAccount(ctx context.Context, userID string) error {
 	return nil
 }
 
-func (u *userUsecase) ChangePassword(ctx context.Context, userID, oldPw, newPw string) error {
+func (u *userUsecase) ChangePassword(ctx context.Context, userID, oldPw, newPw string) (err error) {
+	defer func() {
+		u.recordAudit(ctx, domain.AuditPasswordChange, userID, outcomeOf(err), errDetails(err))
+	}()
+
+	claims := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload)
+
+	usr, err := u.repo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrUserNotFound
+		}
+		return fmt.Errorf("ChangePassword FindByID: %w", err)
+	}
+
+	if err := u.authorize(ctx, domain.ActionPasswordChange, userResource(usr)); err != nil {
+		return err
+	}
+
+	// Own password needs the old one, users.manage sets someone else's without it
+	if claims.UserID == usr.ID && !u.hasher.Verify(ctx, usr.Password, oldPw) {
+		return domain.ErrInvalidCredentials
+	}
+	if u.hasher.Verify(ctx, usr.Password, newPw) {
+		return domain.ErrPasswordUnchanged
+	}
+
+	if err := u.checkPassword(ctx, newPw); err != nil {
+		return err
+	}
+
 	hashed, err := u.hasher.Hash(ctx, newPw)
 	if err != nil {
 		return fmt.Errorf("ChangePassword Hash: %w", err)
 	}
 
-	if oldPw == hashed {
-		return domain.ErrPasswordUnchanged
+	// Update password
+	usr.Password = hashed
+	usr.MustChangePassword = false
+	return u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := u.repo.Update(ctx, usr, "password", "must_change_password", "updated_at"); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return domain.ErrUserNotFound
+			}
+			return fmt.Errorf("ChangePassword Update: %w", err)
+		}
+
+		return u.publisher.PublishPasswordChanged(ctx, &domain.PasswordChangedEvent{
+			UserID:    usr.ID,
+			CreatedAt: time.Now().UTC(),
+		})
+	})
+}
+
+// Set a new password after the reset code sent for email was confirmed
+func (u *userUsecase) ResetPassword(ctx context.Context, email, newPw string) (err error) {
+	var userID string
+	defer func() {
+		u.recordAudit(ctx, domain.AuditPasswordReset, userID, outcomeOf(err), errDetails(err))
+	}()
+
+	if err := u.checkPassword(ctx, newPw); err != nil {
+		return err
+	}
+
+	hashed, err := u.hasher.Hash(ctx, newPw)
+	if err != nil {
+		return fmt.Errorf("ResetPassword Hash: %w", err)
 	}
 
 	// Get user
-	usr, err := u.repo.GetByID(ctx, userID)
+	usr, err := u.getByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			return domain.ErrUserNotFound
 		}
-		return fmt.Errorf("ChangePassword FindByID: %w", err)
+		return fmt.Errorf("ResetPassword FindByEmail: %w", err)
 	}
+	userID = usr.ID
 
 	// Update password
 	usr.Password = hashed
-	_, err = u.repo.Update(ctx, usr, "password", "updated_at")
+	usr.MustChangePassword = false
+	return u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := u.repo.Update(ctx, usr, "password", "must_change_password", "updated_at"); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return domain.ErrUserNotFound
+			}
+			return fmt.Errorf("ResetPassword Update: %w", err)
+		}
+
+		return u.publisher.PublishPasswordReset(ctx, &domain.PasswordResetEvent{
+			UserID:    usr.ID,
+			CreatedAt: time.Now().UTC(),
+		})
+	})
+}
+
+// Block the account and revoke its sessions. For events from other services,
+// so there is no caller to authorize, roles limits whom the event may disable.
+func (u *userUsecase) DisableAccount(ctx context.Context, userID, reason string, roles ...domain.Role) (err error) {
+	defer func() {
+		u.recordAudit(ctx, domain.AuditAccountDisable, userID, outcomeOf(err), withDetail(errDetails(err), "reason", reason))
+	}()
+
+	_, err = u.changeStatus(ctx, domain.SetStatusParams{UserID: userID, Status: domain.StatusDisabled, Reason: reason, Roles: roles})
+	return err
+}
+
+// Admin status change, suspending or disabling revokes all sessions
+func (u *userUsecase) SetAccountStatus(ctx context.Context, p domain.SetStatusParams) (_ *domain.User, err error) {
+	defer func() {
+		details := withDetail(errDetails(err), "status", string(p.Status))
+		if p.Reason != "" {
+			details = withDetail(details, "reason", p.Reason)
+		}
+		if p.Until != nil {
+			details = withDetail(details, "until", p.Until.UTC().Format(time.RFC3339))
+		}
+		u.recordAudit(ctx, domain.AuditStatusChange, p.UserID, outcomeOf(err), details)
+	}()
+
+	if !p.Status.Valid() {
+		return nil, domain.ErrInvalidStatus
+	}
+	if p.Until != nil && (p.Status != domain.StatusSuspended || !p.Until.After(time.Now())) {
+		return nil, domain.ErrInvalidStatus
+	}
+
+	if err := u.authorize(ctx, domain.ActionUserSetStatus, userIDResource(p.UserID)); err != nil {
+		return nil, err
+	}
+
+	return u.changeStatus(ctx, p)
+}
+
+func (u *userUsecase) changeStatus(ctx context.Context, p domain.SetStatusParams) (*domain.User, error) {
+	usr, err := u.repo.GetByID(ctx, p.UserID)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
-			return domain.ErrUserNotFound
+			return nil, domain.ErrUserNotFound
 		}
-		return fmt.Errorf("ChangePassword Update: %w", err)
+		return nil, fmt.Errorf("changeStatus FindByID: %w", err)
+	}
+	if usr.IsDeleted() {
+		return nil, domain.ErrUserNotFound
+	}
+	if len(p.Roles) > 0 && !slices.Contains(p.Roles, usr.Role) {
+		return nil, domain.ErrUnexpectedRole
 	}
+	// Callers may run in all tenants, the rest runs in the user's
+	ctx = domain.WithTenant(ctx, usr.TenantID)
 
-	return nil
+	// Reason is cleared on reactivation
+	reason := p.Reason
+	if p.Status == domain.StatusActive {
+		reason = ""
+	}
+
+	// Unchanged status still revokes below, a retried call must not skip it
+	if usr.Status != p.Status || usr.StatusReason != reason || !equalTimes(usr.StatusUntil, p.Until) {
+		usr.Status = p.Status
+		usr.StatusReason = reason
+		usr.StatusUntil = p.Until
+		usr.UpdatedAt = time.Now().UTC()
+
+		// Inbound events have no caller
+		var changedBy string
+		if claims, ok := ctx.Value(middleware.UserCtxKey).(*domain.TokenPayload); ok {
+			changedBy = claims.UserID
+		}
+
+		err = u.tx.WithinTx(ctx, func(ctx context.Context) error {
+			res, err := u.repo.Update(ctx, usr, "status", "status_reason", "status_until", "updated_at")
+			if err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return domain.ErrUserNotFound
+				}
+				return fmt.Errorf("changeStatus Update: %w", err)
+			}
+			usr = res
+
+			return u.publisher.PublishUserStatusChanged(ctx, &domain.UserStatusChangedEvent{
+				UserID:    usr.ID,
+				Status:    usr.Status,
+				Reason:    usr.StatusReason,
+				Until:     usr.StatusUntil,
+				ChangedBy: changedBy,
+				CreatedAt: time.Now().UTC(),
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Status == domain.StatusSuspended || p.Status == domain.StatusDisabled {
+		if err := u.revoker.RevokeAll(ctx, p.UserID); err != nil {
+			return nil, fmt.Errorf("changeStatus RevokeAll: %w", err)
+		}
+	}
+
+	return usr, nil
 }
 
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// Invalidate every token issued to the user so far
+func (u *userUsecase) RevokeSessions(ctx context.Context, userID string) (err error) {
+	defer func() {
+		u.recordAudit(ctx, domain.AuditSessionsRevoke, userID, outcomeOf(err), errDetails(err))
+	}()
+
+	usr, err := u.repo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrUserNotFound
+		}
+		return fmt.Errorf("RevokeSessions fetch: %w", err)
+	}
+
+	if err := u.authorize(ctx, domain.ActionSessionRevoke, userResource(usr)); err != nil {
+		return err
+	}
 
+	if err := u.revoker.RevokeAll(ctx, userID); err != nil {
+		return fmt.Errorf("RevokeSessions: %w", err)
+	}
+	return nil
+}
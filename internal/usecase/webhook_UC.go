@@ -0,0 +1,246 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Neroframe/AuthService/internal/domain"
+	"github.com/Neroframe/AuthService/internal/repository"
+	"github.com/Neroframe/AuthService/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultDeliveryPageSize = 50
+	maxDeliveryPageSize     = 500
+)
+
+type webhookUsecase struct {
+	auditor
+	log        *logger.Logger
+	subs       repository.WebhookRepository
+	deliveries repository.WebhookDeliveryRepository
+	targets    domain.WebhookTargetGuard
+	authorizer
+}
+
+func NewWebhookUsecase(
+	subs repository.WebhookRepository,
+	deliveries repository.WebhookDeliveryRepository,
+	targets domain.WebhookTargetGuard,
+	audit repository.AuditRepository,
+	log *logger.Logger,
+	authz domain.Authorizer,
+	roles domain.RoleCatalog,
+) WebhookUsecase {
+	return &webhookUsecase{subs: subs, deliveries: deliveries, targets: targets, log: log, auditor: auditor{audit: audit, auditLog: log}, authorizer: authorizer{authz: authz, roles: roles}}
+}
+
+func (w *webhookUsecase) CreateWebhook(ctx context.Context, rawURL string, eventTypes []domain.EventType) (sub *domain.WebhookSubscription, err error) {
+	defer func() {
+		var targetID string
+		if sub != nil {
+			targetID = sub.ID
+		}
+		w.recordAudit(ctx, domain.AuditWebhookManage, targetID, outcomeOf(err), withDetail(errDetails(err), "op", "create"))
+	}()
+
+	if err := w.authorize(ctx, domain.ActionWebhookManage, domain.AuthzResource{Type: domain.ResourceWebhook}); err != nil {
+		return nil, err
+	}
+
+	if err := w.validateURL(ctx, rawURL); err != nil {
+		return nil, err
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, fmt.Errorf("CreateWebhook secret: %w", err)
+	}
+
+	now := time.Now().UTC()
+	sub = &domain.WebhookSubscription{
+		ID:         uuid.NewString(),
+		URL:        rawURL,
+		EventTypes: eventTypes,
+		Secret:     secret,
+		Enabled:    true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := w.subs.Create(ctx, sub); err != nil {
+		return nil, fmt.Errorf("CreateWebhook: %w", err)
+	}
+
+	return sub, nil
+}
+
+func (w *webhookUsecase) ListWebhooks(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	if err := w.authorize(ctx, domain.ActionWebhookManage, domain.AuthzResource{Type: domain.ResourceWebhook}); err != nil {
+		return nil, err
+	}
+
+	subs, err := w.subs.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ListWebhooks: %w", err)
+	}
+	return subs, nil
+}
+
+func (w *webhookUsecase) UpdateWebhook(ctx context.Context, p domain.UpdateWebhookParams) (_ *domain.WebhookSubscription, err error) {
+	defer func() {
+		w.recordAudit(ctx, domain.AuditWebhookManage, p.ID, outcomeOf(err), withDetail(errDetails(err), "op", "update"))
+	}()
+
+	if err := w.authorize(ctx, domain.ActionWebhookManage, domain.AuthzResource{Type: domain.ResourceWebhook, ID: p.ID}); err != nil {
+		return nil, err
+	}
+
+	if err := w.validateURL(ctx, p.URL); err != nil {
+		return nil, err
+	}
+
+	sub, err := w.subs.GetByID(ctx, p.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("UpdateWebhook fetch: %w", err)
+	}
+
+	sub.URL = p.URL
+	sub.EventTypes = p.EventTypes
+	if p.Enabled != nil {
+		// Re-enabling starts with a clean failure counter
+		if *p.Enabled && !sub.Enabled {
+			sub.FailureCount = 0
+			sub.DisabledReason = ""
+		}
+		sub.Enabled = *p.Enabled
+	}
+	sub.UpdatedAt = time.Now().UTC()
+
+	if err := w.subs.Update(ctx, sub); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, domain.ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("UpdateWebhook: %w", err)
+	}
+
+	return sub, nil
+}
+
+func (w *webhookUsecase) DeleteWebhook(ctx context.Context, id string) (err error) {
+	defer func() {
+		w.recordAudit(ctx, domain.AuditWebhookManage, id, outcomeOf(err), withDetail(errDetails(err), "op", "delete"))
+	}()
+
+	if err := w.authorize(ctx, domain.ActionWebhookManage, domain.AuthzResource{Type: domain.ResourceWebhook, ID: id}); err != nil {
+		return err
+	}
+
+	if err := w.subs.Delete(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrWebhookNotFound
+		}
+		return fmt.Errorf("DeleteWebhook: %w", err)
+	}
+	return nil
+}
+
+func (w *webhookUsecase) ListWebhookDeliveries(ctx context.Context, f domain.WebhookDeliveryFilter) ([]*domain.WebhookDelivery, string, error) {
+	if err := w.authorize(ctx, domain.ActionWebhookManage, domain.AuthzResource{Type: domain.ResourceWebhook}); err != nil {
+		return nil, "", err
+	}
+
+	if f.Limit <= 0 {
+		f.Limit = defaultDeliveryPageSize
+	}
+	f.Limit = min(f.Limit, maxDeliveryPageSize)
+
+	deliveries, next, err := w.deliveries.List(ctx, f)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, "", domain.ErrInvalidCursor
+		}
+		return nil, "", fmt.Errorf("ListWebhookDeliveries: %w", err)
+	}
+	return deliveries, next, nil
+}
+
+func (w *webhookUsecase) ReplayWebhookDelivery(ctx context.Context, deliveryID string) (err error) {
+	defer func() {
+		w.recordAudit(ctx, domain.AuditWebhookManage, deliveryID, outcomeOf(err), withDetail(errDetails(err), "op", "replay"))
+	}()
+
+	if err := w.authorize(ctx, domain.ActionWebhookManage, domain.AuthzResource{Type: domain.ResourceWebhook, ID: deliveryID}); err != nil {
+		return err
+	}
+
+	if err := w.deliveries.Requeue(ctx, deliveryID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.ErrDeliveryNotFound
+		}
+		return fmt.Errorf("ReplayWebhookDelivery: %w", err)
+	}
+	return nil
+}
+
+func (w *webhookUsecase) EnqueueWebhookEvent(ctx context.Context, e *domain.WebhookEvent) error {
+	subs, err := w.subs.ListEnabled(ctx)
+	if err != nil {
+		return fmt.Errorf("EnqueueWebhookEvent list: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for _, sub := range subs {
+		if !sub.Matches(e.Type) {
+			continue
+		}
+
+		// Deterministic ID, a redelivered event doesn't create a second delivery
+		d := &domain.WebhookDelivery{
+			ID:             uuid.NewSHA1(uuid.NameSpaceOID, []byte(sub.ID+"/"+e.ID)).String(),
+			SubscriptionID: sub.ID,
+			EventID:        e.ID,
+			EventType:      e.Type,
+			Subject:        e.Subject,
+			Body:           e.Body,
+			Headers:        e.Headers,
+			Status:         domain.DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		if err := w.deliveries.Create(ctx, d); err != nil && !errors.Is(err, repository.ErrAlreadyExists) {
+			return fmt.Errorf("EnqueueWebhookEvent create: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Absolute http(s) URL whose host isn't an internal address
+func (w *webhookUsecase) validateURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return domain.ErrInvalidWebhookURL
+	}
+	if err := w.targets.CheckURL(ctx, raw); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrWebhookTarget, err)
+	}
+	return nil
+}
+
+// Hex encoded secret of n random bytes
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
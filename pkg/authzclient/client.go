@@ -0,0 +1,153 @@
+// Package authzclient lets other services ask the auth service for decisions.
+//
+// Create one client per connection to the auth service:
+//
+//	authz := authzclient.New(conn, authzclient.DefaultCacheTTL)
+//
+// then guard a handler with one line:
+//
+//	if err := authz.Require(ctx, "grade.write", authzclient.Resource("grade", req.GradeId)); err != nil {
+//		return nil, err
+//	}
+//
+// or a whole server with an interceptor:
+//
+//	grpc.NewServer(grpc.UnaryInterceptor(authz.UnaryInterceptor(map[string]authzclient.Rule{
+//		"/grading.Grading/SetGrade": authzclient.Protect("grade.write", "grade"),
+//	})))
+package authzclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Neroframe/AuthService/pkg/ttlcache"
+	authpb "github.com/Neroframe/AuthService/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultCacheTTL  = 5 * time.Second
+	defaultCacheSize = 10000
+)
+
+// Client checks the caller of the current request, its bearer token is
+// forwarded from the incoming metadata. Decisions are cached per token.
+type Client struct {
+	api   authpb.AuthServiceClient
+	cache *ttlcache.Cache[string, *authpb.CheckDecision]
+}
+
+// A zero cacheTTL disables the local cache
+func New(conn grpc.ClientConnInterface, cacheTTL time.Duration) *Client {
+	return &Client{
+		api:   authpb.NewAuthServiceClient(conn),
+		cache: ttlcache.New[string, *authpb.CheckDecision](cacheTTL, defaultCacheSize),
+	}
+}
+
+func Resource(typ, id string) *authpb.CheckResource {
+	return &authpb.CheckResource{Type: typ, Id: id}
+}
+
+func (c *Client) Check(ctx context.Context, action string, res *authpb.CheckResource) (*authpb.CheckDecision, error) {
+	token := bearer(ctx)
+	key := cacheKey(token, action, res)
+	if d, ok := c.cache.Get(key); ok {
+		return d, nil
+	}
+
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", token)
+	}
+	resp, err := c.api.CheckPermission(ctx, &authpb.CheckPermissionRequest{Action: action, Resource: res})
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.Set(key, resp.Decision)
+	return resp.Decision, nil
+}
+
+// PermissionDenied status with the policy's reason when not allowed
+func (c *Client) Require(ctx context.Context, action string, res *authpb.CheckResource) error {
+	d, err := c.Check(ctx, action, res)
+	if err != nil {
+		return err
+	}
+	if !d.Allowed {
+		return status.Error(codes.PermissionDenied, d.Reason)
+	}
+	return nil
+}
+
+// How a protected method maps its request to a check
+type Rule struct {
+	Action   string
+	Resource func(req any) *authpb.CheckResource // nil checks without a resource
+}
+
+// Resource id from a GetId() method on the request, the common case
+func Protect(action, resourceType string) Rule {
+	return Rule{Action: action, Resource: func(req any) *authpb.CheckResource {
+		res := &authpb.CheckResource{Type: resourceType}
+		if r, ok := req.(interface{ GetId() string }); ok {
+			res.Id = r.GetId()
+		}
+		return res
+	}}
+}
+
+// Checks methods listed in rules, the rest pass through
+func (c *Client) UnaryInterceptor(rules map[string]Rule) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		rule, ok := rules[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		var res *authpb.CheckResource
+		if rule.Resource != nil {
+			res = rule.Resource(req)
+		}
+		if err := c.Require(ctx, rule.Action, res); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func bearer(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if v := md.Get("authorization"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Tokens are hashed so they don't sit in memory as map keys
+func cacheKey(token, action string, res *authpb.CheckResource) string {
+	sum := sha256.Sum256([]byte(token))
+	parts := []string{hex.EncodeToString(sum[:]), action, res.GetType(), res.GetId(), res.GetOwnerId(), res.GetRole()}
+
+	attrs := res.GetAttrs()
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, k+"="+attrs[k])
+	}
+	return strings.Join(parts, "\x00")
+}
@@ -0,0 +1,83 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const SpecVersion = "1.0"
+
+// Content types
+const (
+	ContentTypeJSON       = "application/json"
+	ContentTypeProtobuf   = "application/protobuf"
+	ContentTypeStructured = "application/cloudevents+json"
+)
+
+// CloudEvents 1.0 context attributes
+type Event struct {
+	ID              string
+	Source          string
+	Type            string
+	Subject         string
+	Time            time.Time
+	DataContentType string
+	DataSchema      string
+	TenantID        string // "tenantid" extension attribute
+}
+
+type structured struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	TenantID        string          `json:"tenantid,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// Structured mode, attributes and JSON data in one document
+func MarshalStructured(e Event, data []byte) ([]byte, error) {
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("cloudevent %s: data is not valid JSON", e.ID)
+	}
+
+	return json.Marshal(&structured{
+		SpecVersion:     SpecVersion,
+		ID:              e.ID,
+		Source:          e.Source,
+		Type:            e.Type,
+		Subject:         e.Subject,
+		Time:            e.Time.UTC().Format(time.RFC3339Nano),
+		DataContentType: e.DataContentType,
+		DataSchema:      e.DataSchema,
+		TenantID:        e.TenantID,
+		Data:            data,
+	})
+}
+
+// Binary mode, attributes travel as ce- prefixed message headers
+func BinaryHeaders(e Event) map[string]string {
+	h := map[string]string{
+		"ce-specversion": SpecVersion,
+		"ce-id":          e.ID,
+		"ce-source":      e.Source,
+		"ce-type":        e.Type,
+		"ce-time":        e.Time.UTC().Format(time.RFC3339Nano),
+		"content-type":   e.DataContentType,
+	}
+	if e.Subject != "" {
+		h["ce-subject"] = e.Subject
+	}
+	if e.DataSchema != "" {
+		h["ce-dataschema"] = e.DataSchema
+	}
+	if e.TenantID != "" {
+		h["ce-tenantid"] = e.TenantID
+	}
+	return h
+}
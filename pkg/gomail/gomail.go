@@ -1,6 +1,21 @@
 package gomail
 
-import gomail "gopkg.in/mail.v2"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+
+	gomail "gopkg.in/mail.v2"
+)
+
+// TLS modes
+const (
+	TLSModeStartTLS = "starttls" // plain conn upgraded with STARTTLS (587)
+	TLSModeImplicit = "implicit" // TLS from the first byte (465)
+	TLSModeNone     = "none"     // no encryption, local relays only
+)
 
 type Config struct {
 	From         string
@@ -8,19 +23,43 @@ type Config struct {
 	Port         int
 	SMTPUsername string
 	SMTPPassword string
+	TLSMode      string
+	PoolSize     int
 }
 
 type Sender struct {
 	from   string
 	dialer *gomail.Dialer
+	dial   func() (gomail.SendCloser, error) // new pooled conn
+	pool   chan gomail.SendCloser            // idle SMTP conns, nil if pooling disabled
 }
 
-func New(cfg Config) *Sender {
+func New(cfg Config) (*Sender, error) {
 	d := gomail.NewDialer(cfg.Host, cfg.Port, cfg.SMTPUsername, cfg.SMTPPassword)
-	return &Sender{
+
+	switch cfg.TLSMode {
+	case TLSModeStartTLS, "":
+		d.SSL = false
+		d.StartTLSPolicy = gomail.MandatoryStartTLS
+	case TLSModeImplicit:
+		d.SSL = true
+	case TLSModeNone:
+		d.SSL = false
+		d.StartTLSPolicy = gomail.NoStartTLS
+	default:
+		return nil, fmt.Errorf("unknown smtp tls mode: %q", cfg.TLSMode)
+	}
+
+	s := &Sender{
 		from:   cfg.From,
 		dialer: d,
+		dial:   d.Dial,
+	}
+	if cfg.PoolSize > 0 {
+		s.pool = make(chan gomail.SendCloser, cfg.PoolSize)
 	}
+
+	return s, nil
 }
 
 func (s *Sender) Send(to, subject, htmlBody string) error {
@@ -30,5 +69,101 @@ func (s *Sender) Send(to, subject, htmlBody string) error {
 	m.SetHeader("Subject", subject)
 	m.SetBody("text/html", htmlBody)
 
-	return s.dialer.DialAndSend(m)
+	if s.pool == nil {
+		return s.dialer.DialAndSend(m)
+	}
+
+	conn, err := s.acquire()
+	if err != nil {
+		return fmt.Errorf("smtp dial: %w", err)
+	}
+
+	dataSent, err := sendOn(conn, m)
+	if err != nil {
+		conn.Close()
+		// Pooled conn may have been closed by the server, retry once on a fresh
+		// one. Not once DATA began, the server may have taken the message.
+		if dataSent || !isConnError(err) {
+			return fmt.Errorf("smtp send: %w", err)
+		}
+		if conn, err = s.dial(); err != nil {
+			return fmt.Errorf("smtp redial: %w", err)
+		}
+		if _, err := sendOn(conn, m); err != nil {
+			conn.Close()
+			return fmt.Errorf("smtp send: %w", err)
+		}
+	}
+
+	s.release(conn)
+	return nil
+}
+
+// Send m on conn, reporting whether the message body started going out
+func sendOn(conn gomail.SendCloser, m *gomail.Message) (dataSent bool, err error) {
+	err = gomail.Send(gomail.SendFunc(func(from string, to []string, msg io.WriterTo) error {
+		return conn.Send(from, to, writerToFunc(func(w io.Writer) (int64, error) {
+			dataSent = true
+			return msg.WriteTo(w)
+		}))
+	}), m)
+
+	// SendError doesn't unwrap, callers match on the cause
+	var sendErr *gomail.SendError
+	if errors.As(err, &sendErr) {
+		err = sendErr.Cause
+	}
+	return dataSent, err
+}
+
+type writerToFunc func(w io.Writer) (int64, error)
+
+func (f writerToFunc) WriteTo(w io.Writer) (int64, error) { return f(w) }
+
+// Broken or closed connection, as opposed to the server rejecting the message
+func isConnError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.As(err, &netErr)
+}
+
+// Close all idle pooled conns
+func (s *Sender) Close() error {
+	if s.pool == nil {
+		return nil
+	}
+
+	var firstErr error
+	for {
+		select {
+		case conn := <-s.pool:
+			if err := conn.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		default:
+			return firstErr
+		}
+	}
+}
+
+func (s *Sender) acquire() (gomail.SendCloser, error) {
+	select {
+	case conn := <-s.pool:
+		return conn, nil
+	default:
+		return s.dial()
+	}
+}
+
+func (s *Sender) release(conn gomail.SendCloser) {
+	select {
+	case s.pool <- conn:
+	default:
+		// pool is full
+		conn.Close()
+	}
 }
@@ -0,0 +1,140 @@
+package gomail
+
+import (
+	"errors"
+	"io"
+	"net/textproto"
+	"syscall"
+	"testing"
+
+	gomail "gopkg.in/mail.v2"
+)
+
+func TestNewTLSMode(t *testing.T) {
+	for _, c := range []struct {
+		mode     string
+		ssl      bool
+		startTLS gomail.StartTLSPolicy
+	}{
+		{"", false, gomail.MandatoryStartTLS},
+		{TLSModeStartTLS, false, gomail.MandatoryStartTLS},
+		{TLSModeImplicit, true, gomail.MandatoryStartTLS},
+		{TLSModeNone, false, gomail.NoStartTLS},
+	} {
+		s, err := New(Config{Host: "smtp.example.com", Port: 587, TLSMode: c.mode})
+		if err != nil {
+			t.Fatalf("%q: %v", c.mode, err)
+		}
+		if s.dialer.SSL != c.ssl {
+			t.Errorf("%q: SSL = %t, want %t", c.mode, s.dialer.SSL, c.ssl)
+		}
+		if !c.ssl && s.dialer.StartTLSPolicy != c.startTLS {
+			t.Errorf("%q: StartTLSPolicy = %v, want %v", c.mode, s.dialer.StartTLSPolicy, c.startTLS)
+		}
+	}
+
+	if _, err := New(Config{TLSMode: "tls13"}); err == nil {
+		t.Error("New accepted an unknown TLS mode")
+	}
+}
+
+// Fails the first sends with err, after the body went out if inData is set
+type fakeConn struct {
+	fails  int
+	err    error
+	inData bool
+	sent   int
+	closed bool
+}
+
+func (c *fakeConn) Send(from string, to []string, msg io.WriterTo) error {
+	if c.fails > 0 {
+		c.fails--
+		if c.inData {
+			msg.WriteTo(io.Discard)
+		}
+		return c.err
+	}
+	msg.WriteTo(io.Discard)
+	c.sent++
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+// Pooled sender whose pool holds stale and dials fresh
+func pooledSender(stale, fresh *fakeConn) (*Sender, *int) {
+	dials := 0
+	s := &Sender{
+		from: "noreply@example.com",
+		dial: func() (gomail.SendCloser, error) {
+			dials++
+			return fresh, nil
+		},
+		pool: make(chan gomail.SendCloser, 1),
+	}
+	s.pool <- stale
+	return s, &dials
+}
+
+func TestSendRetriesClosedConn(t *testing.T) {
+	for name, err := range map[string]error{
+		"eof":   io.EOF,
+		"reset": syscall.ECONNRESET,
+		"pipe":  syscall.EPIPE,
+	} {
+		t.Run(name, func(t *testing.T) {
+			stale, fresh := &fakeConn{fails: 1, err: err}, &fakeConn{}
+			s, dials := pooledSender(stale, fresh)
+
+			if err := s.Send("a@example.com", "Hi", "<p>hi</p>"); err != nil {
+				t.Fatal(err)
+			}
+			if *dials != 1 || fresh.sent != 1 || !stale.closed {
+				t.Errorf("dials = %d, sent on fresh = %d, stale closed = %t", *dials, fresh.sent, stale.closed)
+			}
+		})
+	}
+}
+
+func TestSendDoesNotRetryAfterData(t *testing.T) {
+	stale, fresh := &fakeConn{fails: 1, err: io.EOF, inData: true}, &fakeConn{}
+	s, dials := pooledSender(stale, fresh)
+
+	if err := s.Send("a@example.com", "Hi", "<p>hi</p>"); !errors.Is(err, io.EOF) {
+		t.Fatalf("Send = %v, want io.EOF", err)
+	}
+	if *dials != 0 || fresh.sent != 0 {
+		t.Errorf("resent after DATA: dials = %d, sent = %d", *dials, fresh.sent)
+	}
+}
+
+func TestSendDoesNotRetryRejection(t *testing.T) {
+	rejected := &textproto.Error{Code: 550, Msg: "mailbox unavailable"}
+	stale, fresh := &fakeConn{fails: 1, err: rejected}, &fakeConn{}
+	s, dials := pooledSender(stale, fresh)
+
+	if err := s.Send("a@example.com", "Hi", "<p>hi</p>"); err == nil {
+		t.Fatal("Send succeeded after a rejection")
+	}
+	if *dials != 0 {
+		t.Errorf("redialled %d times after a rejection", *dials)
+	}
+}
+
+func TestSendReturnsConnToPool(t *testing.T) {
+	conn := &fakeConn{}
+	s, dials := pooledSender(conn, &fakeConn{})
+
+	for i := 0; i < 2; i++ {
+		if err := s.Send("a@example.com", "Hi", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *dials != 0 || conn.sent != 2 || conn.closed {
+		t.Errorf("dials = %d, sent = %d, closed = %t, want the pooled conn reused", *dials, conn.sent, conn.closed)
+	}
+}
@@ -35,7 +35,7 @@ type Server struct {
 	listener net.Listener
 }
 
-func New(cfg Config, registerSrv func(*grpc.Server), unaryInts []grpc.UnaryServerInterceptor) (*Server, error) {
+func New(cfg Config, registerSrv func(*grpc.Server), unaryInts []grpc.UnaryServerInterceptor, streamInts []grpc.StreamServerInterceptor) (*Server, error) {
 	opts := []grpc.ServerOption{
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
 			MinTime:             cfg.KeepaliveEnforcement.MinTime,
@@ -61,6 +61,9 @@ func New(cfg Config, registerSrv func(*grpc.Server), unaryInts []grpc.UnaryServe
 	if len(unaryInts) > 0 {
 		opts = append(opts, grpc.ChainUnaryInterceptor(unaryInts...))
 	}
+	if len(streamInts) > 0 {
+		opts = append(opts, grpc.ChainStreamInterceptor(streamInts...))
+	}
 
 	//  Build options
 	srv := grpc.NewServer(opts...)
@@ -0,0 +1,83 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+type Config struct {
+	Addr string
+
+	CertFile string
+	KeyFile  string
+
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	ShutdownTimeout time.Duration
+}
+
+type Server struct {
+	server   *http.Server
+	listener net.Listener
+	cfg      Config
+}
+
+func New(cfg Config, handler http.Handler) (*Server, error) {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+	}
+
+	// Open TCP listener
+	lis, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", cfg.Addr, err)
+	}
+
+	return &Server{server: srv, listener: lis, cfg: cfg}, nil
+}
+
+func (s *Server) Run(ctx context.Context) error {
+	serveErr := make(chan error, 1)
+
+	// TLS when certs are provided
+	go func() {
+		if s.cfg.CertFile != "" && s.cfg.KeyFile != "" {
+			serveErr <- s.server.ServeTLS(s.listener, s.cfg.CertFile, s.cfg.KeyFile)
+			return
+		}
+		serveErr <- s.server.Serve(s.listener)
+	}()
+
+	select {
+	case <-ctx.Done():
+		// Stop after context canceled
+		s.Stop()
+		return nil
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("http serve: %w", err)
+	}
+}
+
+// Waits for open requests up to the shutdown timeout
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+	}
+}
+
+// Address the server listens on, the port chosen when Addr ends in :0
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
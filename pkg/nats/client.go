@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
 type Config struct {
@@ -15,10 +16,13 @@ type Config struct {
 	Name          string
 	MaxReconnects int
 	ReconnectWait time.Duration
+	AckTimeout    time.Duration // JetStream publish ack wait
 }
 
 type Client struct {
-	conn *nats.Conn
+	conn           *nats.Conn
+	js             jetstream.JetStream
+	publishTimeout time.Duration
 }
 
 func NewClient(cfg Config) (*Client, error) {
@@ -33,7 +37,14 @@ func NewClient(cfg Config) (*Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("nats connect: %w", err)
 	}
-	return &Client{conn: nc}, nil
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("jetstream init: %w", err)
+	}
+
+	return &Client{conn: nc, js: js, publishTimeout: cfg.AckTimeout}, nil
 }
 
 // drain and close
@@ -63,3 +74,12 @@ func (c *Client) Subscribe(subject string, handler nats.MsgHandler) (*nats.Subsc
 	}
 	return sub, nil
 }
+
+// Each message is delivered to one member of the queue group
+func (c *Client) QueueSubscribe(subject, queue string, handler nats.MsgHandler) (*nats.Subscription, error) {
+	sub, err := c.conn.QueueSubscribe(subject, queue, handler)
+	if err != nil {
+		return nil, fmt.Errorf("queue subscribe %s: %w", subject, err)
+	}
+	return sub, nil
+}
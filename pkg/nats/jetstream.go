@@ -0,0 +1,100 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type StreamConfig struct {
+	Name            string
+	Subjects        []string
+	Retention       string // "limits", "interest" or "workqueue"
+	MaxAge          time.Duration
+	Replicas        int
+	DuplicateWindow time.Duration // Nats-Msg-Id dedup window
+}
+
+// Create the stream or update it to match cfg
+func (c *Client) EnsureStream(ctx context.Context, cfg StreamConfig) error {
+	var retention jetstream.RetentionPolicy
+	switch cfg.Retention {
+	case "limits", "":
+		retention = jetstream.LimitsPolicy
+	case "interest":
+		retention = jetstream.InterestPolicy
+	case "workqueue":
+		retention = jetstream.WorkQueuePolicy
+	default:
+		return fmt.Errorf("unknown stream retention: %q", cfg.Retention)
+	}
+
+	_, err := c.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:       cfg.Name,
+		Subjects:   cfg.Subjects,
+		Retention:  retention,
+		MaxAge:     cfg.MaxAge,
+		Replicas:   cfg.Replicas,
+		Duplicates: cfg.DuplicateWindow,
+		Storage:    jetstream.FileStorage,
+	})
+	if err != nil {
+		return fmt.Errorf("ensure stream %s: %w", cfg.Name, err)
+	}
+
+	return nil
+}
+
+// Publish to JetStream and wait for the ack, msgID is used for server side dedup
+func (c *Client) PublishAck(ctx context.Context, subject string, data []byte, headers map[string]string, msgID string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.publishTimeout)
+	defer cancel()
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	for k, v := range headers {
+		msg.Header.Set(k, v)
+	}
+
+	// A duplicate of an earlier attempt is acked as well and counts as sent
+	if _, err := c.js.PublishMsg(ctx, msg, jetstream.WithMsgID(msgID)); err != nil {
+		return fmt.Errorf("jetstream publish %s: %w", subject, err)
+	}
+
+	return nil
+}
+
+type ConsumerConfig struct {
+	Stream        string
+	Durable       string // shared by every replica, survives restarts
+	FilterSubject string
+	AckWait       time.Duration // redelivered when not acked in time
+	MaxDeliver    int
+	MaxAckPending int
+}
+
+// Durable pull consumer with explicit acks. A new consumer starts with the
+// messages published after it was created, an existing one where it left off.
+func (c *Client) Consume(ctx context.Context, cfg ConsumerConfig, handler jetstream.MessageHandler) (jetstream.ConsumeContext, error) {
+	cons, err := c.js.CreateOrUpdateConsumer(ctx, cfg.Stream, jetstream.ConsumerConfig{
+		Durable:       cfg.Durable,
+		FilterSubject: cfg.FilterSubject,
+		DeliverPolicy: jetstream.DeliverNewPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       cfg.AckWait,
+		MaxDeliver:    cfg.MaxDeliver,
+		MaxAckPending: cfg.MaxAckPending,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("jetstream consumer %s/%s: %w", cfg.Stream, cfg.Durable, err)
+	}
+
+	cc, err := cons.Consume(handler)
+	if err != nil {
+		return nil, fmt.Errorf("jetstream consume %s/%s: %w", cfg.Stream, cfg.Durable, err)
+	}
+	return cc, nil
+}
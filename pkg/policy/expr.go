@@ -0,0 +1,509 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expressions are a small CEL subset:
+//
+//	subject.role == "ADMIN" && (resource.id == subject.id || "users.manage" in subject.permissions)
+//
+// Literals are strings, numbers, true, false, null and [lists]. Operators are
+// ! && || == != < <= > >= in, with parentheses for grouping. Missing attributes are null.
+type Expr interface {
+	eval(input map[string]any) (any, error)
+}
+
+// Input roots a rule can reference
+var Roots = []string{"subject", "action", "resource"}
+
+func Parse(src string) (Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return e, nil
+}
+
+// Evaluate an expression that must produce a bool
+func EvalBool(e Expr, input map[string]any) (bool, error) {
+	v, err := e.eval(input)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression is %s, not bool", typeName(v))
+	}
+	return b, nil
+}
+
+// ------------ lexer ------------
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",", "."}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			// Only quotes and backslashes can be escaped
+			var sb strings.Builder
+			end := i + 1
+			for end < len(src) && rune(src[end]) != c {
+				if src[end] == '\\' && end+1 < len(src) {
+					end++
+				}
+				sb.WriteByte(src[end])
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			toks = append(toks, token{kind: tokString, text: sb.String(), pos: i})
+			i = end + 1
+		case c >= '0' && c <= '9':
+			end := i
+			for end < len(src) && (src[end] >= '0' && src[end] <= '9' || src[end] == '.') {
+				end++
+			}
+			toks = append(toks, token{kind: tokNumber, text: src[i:end], pos: i})
+			i = end
+		case c == '_' || unicode.IsLetter(c):
+			end := i
+			for end < len(src) && (src[end] == '_' || unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end]))) {
+				end++
+			}
+			toks = append(toks, token{kind: tokIdent, text: src[i:end], pos: i})
+			i = end
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			toks = append(toks, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// ------------ parser ------------
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); (t.kind == tokOp || t.kind == tokIdent) && t.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return fmt.Errorf("expected %q at %d", op, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (Expr, error) {
+	left, err := p.relation()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.relation()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) relation() (Expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">", "in"} {
+		if p.accept(op) {
+			right, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+			return &binary{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (Expr, error) {
+	if p.accept("!") {
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &not{e: e}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literal{v: t.text}, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q at %d", t.text, t.pos)
+		}
+		return literal{v: n}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literal{v: true}, nil
+		case "false":
+			return literal{v: false}, nil
+		case "null":
+			return literal{v: nil}, nil
+		}
+		if !isRoot(t.text) {
+			return nil, fmt.Errorf("unknown name %q at %d", t.text, t.pos)
+		}
+		path := []string{t.text}
+		for p.accept(".") {
+			f := p.next()
+			if f.kind != tokIdent {
+				return nil, fmt.Errorf("expected field name at %d", f.pos)
+			}
+			path = append(path, f.text)
+		}
+		return attr{path: path}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			e, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+		case "[":
+			var items []Expr
+			for !p.accept("]") {
+				if len(items) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				e, err := p.or()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, e)
+			}
+			return list{items: items}, nil
+		}
+	}
+	if t.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func isRoot(name string) bool {
+	for _, r := range Roots {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ------------ evaluation ------------
+
+type literal struct{ v any }
+
+func (l literal) eval(map[string]any) (any, error) { return l.v, nil }
+
+type list struct{ items []Expr }
+
+func (l list) eval(input map[string]any) (any, error) {
+	out := make([]any, 0, len(l.items))
+	for _, it := range l.items {
+		v, err := it.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+type attr struct{ path []string }
+
+func (a attr) eval(input map[string]any) (any, error) {
+	var cur any = input
+	for _, f := range a.path {
+		switch m := cur.(type) {
+		case map[string]any:
+			cur = m[f]
+		case map[string]string:
+			v, ok := m[f]
+			if !ok {
+				return nil, nil
+			}
+			cur = v
+		default:
+			return nil, nil
+		}
+	}
+	return normalize(cur), nil
+}
+
+type not struct{ e Expr }
+
+func (n *not) eval(input map[string]any) (any, error) {
+	v, err := n.e.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, fmt.Errorf("! on %s", typeName(v))
+	}
+	return !b, nil
+}
+
+// Short-circuits like CEL, the right side isn't evaluated when the left decides
+type logical struct {
+	and         bool
+	left, right Expr
+}
+
+func (l *logical) eval(input map[string]any) (any, error) {
+	for _, side := range []Expr{l.left, l.right} {
+		v, err := side.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s on %s", l.op(), typeName(v))
+		}
+		if b != l.and {
+			return b, nil
+		}
+	}
+	return l.and, nil
+}
+
+func (l *logical) op() string {
+	if l.and {
+		return "&&"
+	}
+	return "||"
+}
+
+type binary struct {
+	op          string
+	left, right Expr
+}
+
+func (b *binary) eval(input map[string]any) (any, error) {
+	l, err := b.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	r, err := b.right.eval(input)
+	if err != nil {
+		return nil, err
+	}
+
+	switch b.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		switch c := r.(type) {
+		case []any:
+			for _, it := range c {
+				if equal(l, it) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]any:
+			k, ok := l.(string)
+			if !ok {
+				return nil, fmt.Errorf("in map needs a string key, got %s", typeName(l))
+			}
+			_, found := c[k]
+			return found, nil
+		case nil:
+			return false, nil
+		}
+		return nil, fmt.Errorf("in on %s", typeName(r))
+	}
+
+	// Ordering works on two numbers or two strings
+	switch lv := l.(type) {
+	case float64:
+		if rv, ok := r.(float64); ok {
+			return compare(b.op, lv < rv, lv == rv), nil
+		}
+	case string:
+		if rv, ok := r.(string); ok {
+			return compare(b.op, lv < rv, lv == rv), nil
+		}
+	}
+	return nil, fmt.Errorf("%s on %s and %s", b.op, typeName(l), typeName(r))
+}
+
+func compare(op string, less, eq bool) bool {
+	switch op {
+	case "<":
+		return less
+	case "<=":
+		return less || eq
+	case ">":
+		return !less && !eq
+	}
+	return !less
+}
+
+func equal(a, b any) bool {
+	switch av := a.(type) {
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		return false
+	}
+	if _, ok := b.([]any); ok {
+		return false
+	}
+	if _, ok := b.(map[string]any); ok {
+		return false
+	}
+	return a == b
+}
+
+// Inputs come from Go structs and JSON, fold them into the expression types
+func normalize(v any) any {
+	switch x := v.(type) {
+	case []string:
+		out := make([]any, len(x))
+		for i, s := range x {
+			out[i] = s
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]any, len(x))
+		for k, s := range x {
+			out[k] = s
+		}
+		return out
+	case int:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case fmt.Stringer:
+		return x.String()
+	}
+	return v
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,207 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Policy file layout. Tests are example inputs with the expected outcome,
+// run by Engine.RunTests before a policy is used.
+type Policy struct {
+	Version int    `json:"version"`
+	Rules   []Rule `json:"rules"`
+	Tests   []Case `json:"tests"`
+}
+
+type Rule struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description,omitempty"`
+	Effect      string   `json:"effect"`  // allow or deny
+	Actions     []string `json:"actions"` // "*" matches every action
+	When        string   `json:"when"`    // expression, empty always matches
+}
+
+type Case struct {
+	Name     string         `json:"name"`
+	Subject  map[string]any `json:"subject"`
+	Action   string         `json:"action"`
+	Resource map[string]any `json:"resource"`
+	Allow    bool           `json:"allow"`
+}
+
+// Deny rules win over allow rules, no matching rule denies
+type Decision struct {
+	Allowed  bool
+	Rule     string   // rule that decided, empty for the default deny
+	Reason   string   // human readable explanation
+	Errors   []string // rules that failed to evaluate
+	Duration time.Duration
+}
+
+type compiledRule struct {
+	Rule
+	actions map[string]struct{}
+	when    Expr
+}
+
+type Engine struct {
+	rules []compiledRule
+	tests []Case
+}
+
+// Parse a JSON policy, a policy with a broken rule is rejected as a whole
+func Load(data []byte) (*Engine, error) {
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("policy decode: %w", err)
+	}
+	return Compile(p)
+}
+
+func LoadFile(path string) (*Engine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("policy read: %w", err)
+	}
+	return Load(data)
+}
+
+func Compile(p Policy) (*Engine, error) {
+	if len(p.Rules) == 0 {
+		return nil, errors.New("policy has no rules")
+	}
+
+	e := &Engine{tests: p.Tests}
+	seen := make(map[string]struct{}, len(p.Rules))
+	for _, r := range p.Rules {
+		if r.ID == "" {
+			return nil, errors.New("policy rule without id")
+		}
+		if _, dup := seen[r.ID]; dup {
+			return nil, fmt.Errorf("policy rule %s: duplicate id", r.ID)
+		}
+		seen[r.ID] = struct{}{}
+		if r.Effect != EffectAllow && r.Effect != EffectDeny {
+			return nil, fmt.Errorf("policy rule %s: effect must be allow or deny", r.ID)
+		}
+		if len(r.Actions) == 0 {
+			return nil, fmt.Errorf("policy rule %s: no actions", r.ID)
+		}
+
+		cr := compiledRule{Rule: r, actions: make(map[string]struct{}, len(r.Actions))}
+		for _, a := range r.Actions {
+			cr.actions[a] = struct{}{}
+		}
+		if r.When != "" {
+			expr, err := Parse(r.When)
+			if err != nil {
+				return nil, fmt.Errorf("policy rule %s: %w", r.ID, err)
+			}
+			cr.when = expr
+		}
+		e.rules = append(e.rules, cr)
+	}
+	return e, nil
+}
+
+func (e *Engine) Evaluate(subject map[string]any, action string, resource map[string]any) Decision {
+	start := time.Now()
+	input := map[string]any{"subject": subject, "action": action, "resource": resource}
+
+	d := e.decide(input, action)
+	d.Duration = time.Since(start)
+	return d
+}
+
+func (e *Engine) decide(input map[string]any, action string) Decision {
+	var d Decision
+	var allowedBy string
+	for _, r := range e.rules {
+		if !r.matchesAction(action) {
+			continue
+		}
+
+		matched := true
+		if r.when != nil {
+			ok, err := EvalBool(r.when, input)
+			if err != nil {
+				d.Errors = append(d.Errors, fmt.Sprintf("%s: %v", r.ID, err))
+				// A deny rule that can't be evaluated still denies
+				if r.Effect == EffectDeny {
+					d.Rule, d.Reason = r.ID, "deny rule failed to evaluate"
+					return d
+				}
+				continue
+			}
+			matched = ok
+		}
+		if !matched {
+			continue
+		}
+
+		if r.Effect == EffectDeny {
+			d.Rule, d.Reason = r.ID, denyReason(r.Rule)
+			return d
+		}
+		if allowedBy == "" {
+			allowedBy = r.ID
+		}
+	}
+
+	if allowedBy == "" {
+		d.Reason = "no rule allows " + action
+		return d
+	}
+	d.Allowed, d.Rule, d.Reason = true, allowedBy, "allowed by "+allowedBy
+	return d
+}
+
+func denyReason(r Rule) string {
+	if r.Description != "" {
+		return r.Description
+	}
+	return "denied by " + r.ID
+}
+
+func (r compiledRule) matchesAction(action string) bool {
+	if _, ok := r.actions["*"]; ok {
+		return true
+	}
+	_, ok := r.actions[action]
+	return ok
+}
+
+type CaseResult struct {
+	Case
+	Decision Decision
+	Passed   bool
+}
+
+// Run the table of cases shipped with the policy
+func (e *Engine) RunTests() []CaseResult {
+	results := make([]CaseResult, 0, len(e.tests))
+	for _, c := range e.tests {
+		d := e.Evaluate(c.Subject, c.Action, c.Resource)
+		results = append(results, CaseResult{Case: c, Decision: d, Passed: d.Allowed == c.Allow && len(d.Errors) == 0})
+	}
+	return results
+}
+
+// Error listing every failed case, nil when all pass
+func (e *Engine) Verify() error {
+	var errs []error
+	for _, r := range e.RunTests() {
+		if !r.Passed {
+			errs = append(errs, fmt.Errorf("policy test %q: want allow=%t, got allow=%t (%s)", r.Name, r.Allow, r.Decision.Allowed, r.Decision.Reason))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,41 @@
+package policy_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Neroframe/AuthService/config"
+	"github.com/Neroframe/AuthService/pkg/policy"
+)
+
+// Runs the test table shipped with the built-in policy, or with the file in
+// POLICY_FILE to check a custom one before deploying it.
+func TestPolicyCases(t *testing.T) {
+	var (
+		engine *policy.Engine
+		err    error
+	)
+	if file := os.Getenv("POLICY_FILE"); file != "" {
+		engine, err = policy.LoadFile(file)
+	} else {
+		engine, err = policy.Load(config.DefaultPolicy)
+	}
+	if err != nil {
+		t.Fatalf("policy: %v", err)
+	}
+
+	results := engine.RunTests()
+	if len(results) == 0 {
+		t.Fatal("policy has no test cases")
+	}
+	for _, r := range results {
+		t.Run(r.Name, func(t *testing.T) {
+			for _, e := range r.Decision.Errors {
+				t.Errorf("rule error: %s", e)
+			}
+			if r.Decision.Allowed != r.Allow {
+				t.Errorf("%s: want allow=%t, got allow=%t (%s)", r.Action, r.Allow, r.Decision.Allowed, r.Decision.Reason)
+			}
+		})
+	}
+}
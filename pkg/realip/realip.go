@@ -0,0 +1,89 @@
+package realip
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// Resolves the client address of a request. X-Forwarded-For is only honoured
+// when the connection comes from a trusted proxy, anyone else could set it.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// Trusted proxies are CIDRs or single addresses, e.g. "10.0.0.0/8"
+func New(trusted []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, s := range trusted {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(s)
+			if aerr != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", s, err)
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		r.trusted = append(r.trusted, p.Masked())
+	}
+	return r, nil
+}
+
+// Client IP from the peer address ("host:port" or a bare host) and the
+// X-Forwarded-For values. Behind trusted proxies the header is read from the
+// right, the first hop that isn't a trusted proxy is the client.
+func (r *Resolver) ClientIP(peerAddr string, forwardedFor []string) string {
+	ip := hostOf(peerAddr)
+	if !r.isTrusted(ip) {
+		return ip
+	}
+
+	var hops []string
+	for _, v := range forwardedFor {
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if _, err := netip.ParseAddr(hops[i]); err != nil {
+			return ip // garbage in the header, keep the last known hop
+		}
+		ip = hops[i]
+		if !r.isTrusted(ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+func (r *Resolver) isTrusted(ip string) bool {
+	if r == nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range r.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
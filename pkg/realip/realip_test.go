@@ -0,0 +1,44 @@
+package realip
+
+import "testing"
+
+func TestClientIP(t *testing.T) {
+	r, err := New([]string{"10.0.0.0/8", "192.168.1.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name string
+		peer string
+		fwd  []string
+		want string
+	}{
+		{"untrusted peer ignores header", "203.0.113.9:5000", []string{"1.2.3.4"}, "203.0.113.9"},
+		{"trusted peer without header", "10.1.2.3:5000", nil, "10.1.2.3"},
+		{"trusted peer", "10.1.2.3:5000", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"spoofed first hop", "10.1.2.3:5000", []string{"1.2.3.4, 198.51.100.7"}, "198.51.100.7"},
+		{"proxy chain", "10.1.2.3:5000", []string{"198.51.100.7, 192.168.1.5", "10.9.9.9"}, "198.51.100.7"},
+		{"only proxies", "10.1.2.3:5000", []string{"10.2.2.2"}, "10.2.2.2"},
+		{"garbage hop", "10.1.2.3:5000", []string{"1.2.3.4, not-an-ip"}, "10.1.2.3"},
+		{"bare peer host", "192.168.1.5", []string{"198.51.100.7"}, "198.51.100.7"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if got := r.ClientIP(c.peer, c.fwd); got != c.want {
+				t.Errorf("ClientIP = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNilResolverTrustsNoOne(t *testing.T) {
+	var r *Resolver
+	if got := r.ClientIP("10.1.2.3:5000", []string{"1.2.3.4"}); got != "10.1.2.3" {
+		t.Errorf("ClientIP = %q, want the peer", got)
+	}
+}
+
+func TestNewRejectsBadProxy(t *testing.T) {
+	if _, err := New([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("New accepted an invalid network")
+	}
+}
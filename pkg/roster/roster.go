@@ -0,0 +1,220 @@
+// Package roster reads user and enrollment rows from CSV files, either a plain
+// users file or OneRoster 1.2 users.csv and enrollments.csv.
+package roster
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// OneRoster file names
+const (
+	UsersFile       = "users.csv"
+	EnrollmentsFile = "enrollments.csv"
+)
+
+// OneRoster status of a row, deletions are marked instead of left out
+const StatusToBeDeleted = "tobedeleted"
+
+var ErrNoHeader = errors.New("csv file has no header row")
+
+// A row that can't be read, the line is 1-based like in an editor
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string { return fmt.Sprintf("line %d: %v", e.Line, e.Err) }
+func (e *RowError) Unwrap() error { return e.Err }
+
+// Column names accepted for each field, matched ignoring case. The first
+// name is the plain CSV one, the others come from OneRoster.
+var (
+	userColumns = map[string][]string{
+		"external_id": {"external_id", "sourcedid"},
+		"status":      {"status"},
+		"enabled":     {"enabled", "enableduser"},
+		"username":    {"username"},
+		"email":       {"email"},
+		"phone":       {"phone", "sms"},
+		"role":        {"role"}, // OneRoster 1.1, 1.2 keeps roles in roles.csv
+		"password":    {"password"},
+	}
+	enrollmentColumns = map[string][]string{
+		"status": {"status"},
+		"class":  {"classsourcedid"},
+		"user":   {"usersourcedid"},
+		"role":   {"role"},
+	}
+)
+
+type User struct {
+	Line       int
+	ExternalID string
+	Status     string // "" or "active", StatusToBeDeleted
+	Enabled    *bool  // nil when the column is missing or empty
+	Username   string
+	Email      string
+	Phone      string
+	Role       string
+	Password   string
+}
+
+type Enrollment struct {
+	Line   int
+	Status string
+	Class  string // classSourcedId
+	User   string // userSourcedId
+	Role   string // "student", "teacher", ...
+}
+
+// Streams rows of one file, the header row is read on creation
+type Reader struct {
+	csv   *csv.Reader
+	cols  map[string]int // field -> column index
+	line  int
+	count int // data rows read
+}
+
+func NewUserReader(r io.Reader) (*Reader, error) {
+	return newReader(r, userColumns)
+}
+
+func NewEnrollmentReader(r io.Reader) (*Reader, error) {
+	return newReader(r, enrollmentColumns)
+}
+
+func newReader(r io.Reader, columns map[string][]string) (*Reader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // short rows are padded, see field
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNoHeader
+		}
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff") // Excel writes a BOM
+		}
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	cols := make(map[string]int, len(columns))
+	for field, names := range columns {
+		for _, name := range names {
+			if i, ok := index[name]; ok {
+				cols[field] = i
+				break
+			}
+		}
+	}
+	return &Reader{csv: cr, cols: cols, line: 1}, nil
+}
+
+// Whether the file has a column for the field
+func (r *Reader) Has(field string) bool {
+	_, ok := r.cols[field]
+	return ok
+}
+
+// Data rows read so far, the position to resume from
+func (r *Reader) Count() int { return r.count }
+
+// Skip the first n data rows, to resume a file
+func (r *Reader) Skip(n int) error {
+	for r.count < n {
+		if _, err := r.next(); err != nil {
+			var rowErr *RowError
+			if errors.As(err, &rowErr) {
+				continue // already reported on the first run
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Next user row, io.EOF at the end. A *RowError is returned for a malformed
+// row, reading can go on after it.
+func (r *Reader) User() (*User, error) {
+	rec, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	u := &User{
+		Line:       r.line,
+		ExternalID: r.field(rec, "external_id"),
+		Status:     strings.ToLower(r.field(rec, "status")),
+		Username:   r.field(rec, "username"),
+		Email:      r.field(rec, "email"),
+		Phone:      r.field(rec, "phone"),
+		Role:       strings.ToLower(r.field(rec, "role")),
+		Password:   r.field(rec, "password"),
+	}
+	switch strings.ToLower(r.field(rec, "enabled")) {
+	case "":
+	case "true", "1", "yes":
+		u.Enabled = boolPtr(true)
+	case "false", "0", "no":
+		u.Enabled = boolPtr(false)
+	default:
+		return nil, &RowError{Line: r.line, Err: errors.New("enabled must be true or false")}
+	}
+	return u, nil
+}
+
+// Next enrollment row, io.EOF at the end
+func (r *Reader) Enrollment() (*Enrollment, error) {
+	rec, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	e := &Enrollment{
+		Line:   r.line,
+		Status: strings.ToLower(r.field(rec, "status")),
+		Class:  r.field(rec, "class"),
+		User:   r.field(rec, "user"),
+		Role:   strings.ToLower(r.field(rec, "role")),
+	}
+	if e.Class == "" || e.User == "" {
+		return nil, &RowError{Line: r.line, Err: errors.New("classSourcedId and userSourcedId are required")}
+	}
+	return e, nil
+}
+
+func (r *Reader) next() ([]string, error) {
+	rec, err := r.csv.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			r.count++
+			r.line = parseErr.StartLine
+			return nil, &RowError{Line: r.line, Err: parseErr.Err}
+		}
+		return nil, err
+	}
+	r.count++
+	r.line, _ = r.csv.FieldPos(0)
+	return rec, nil
+}
+
+func (r *Reader) field(rec []string, field string) string {
+	i, ok := r.cols[field]
+	if !ok || i >= len(rec) {
+		return ""
+	}
+	return strings.TrimSpace(rec[i])
+}
+
+func boolPtr(b bool) *bool { return &b }
@@ -0,0 +1,572 @@
+package scim
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filters of list requests (RFC 7644 3.4.2.2):
+//
+//	userName eq "bjensen" and (emails[type eq "work" and value co "@example.com"] or not (active pr))
+//
+// Operators are eq ne co sw ew gt lt ge le and pr, joined with and, or, not
+// and parentheses. Attribute names and operators are case-insensitive, string
+// comparisons too unless the attribute is caseExact.
+type Filter struct {
+	root node
+}
+
+func ParseFilter(src string) (*Filter, error) {
+	p, err := newFilterParser(src)
+	if err != nil {
+		return nil, err
+	}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, BadRequest(ErrInvalidFilter, "unexpected %q at %d", t.text, t.pos)
+	}
+	return &Filter{root: n}, nil
+}
+
+// Whether the resource matches, unknown attributes are missing
+func (f *Filter) Match(s *Schema, doc map[string]any) bool {
+	return f.root.match(s, doc, "")
+}
+
+// Value of a top-level equality on the attribute, e.g. "bjensen" for userName
+// in `userName eq "bjensen" and active eq true`. Every match has this value,
+// so a store can look it up by index before Match runs.
+// attr is "userName" or "emails.value", which emails[value eq "x"] also gives.
+func (f *Filter) Equality(s *Schema, attr string) (string, bool) {
+	return equality(s, f.root, "", attr)
+}
+
+func equality(s *Schema, n node, parent, attr string) (string, bool) {
+	switch x := n.(type) {
+	case *logicalNode:
+		if !x.and {
+			return "", false
+		}
+		if v, ok := equality(s, x.left, parent, attr); ok {
+			return v, true
+		}
+		return equality(s, x.right, parent, attr)
+	case *valuePathNode:
+		return equality(s, x.filter, x.path.attr, attr)
+	case *compareNode:
+		str, ok := x.value.(string)
+		if x.op != "eq" || !ok {
+			return "", false
+		}
+		name := x.path.attr
+		if x.path.sub != "" {
+			name += "." + x.path.sub
+		}
+		if parent != "" {
+			name = parent + "." + x.path.attr
+		}
+		if strings.EqualFold(name, attr) {
+			return str, true
+		}
+	}
+	return "", false
+}
+
+// ------------ lexer ------------
+
+type tokKind int
+
+const (
+	tokEOF  tokKind = iota
+	tokWord         // attribute paths, operators and keywords
+	tokString
+	tokNumber
+	tokPunct
+)
+
+type token struct {
+	kind  tokKind
+	text  string
+	value any // decoded literal of strings and numbers
+	pos   int
+}
+
+func lexFilter(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			toks = append(toks, token{kind: tokPunct, text: string(c), pos: i})
+			i++
+		case c == '"':
+			// JSON string, escapes included
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, BadRequest(ErrInvalidFilter, "unterminated string at %d", i)
+			}
+			var s string
+			if err := json.Unmarshal([]byte(src[i:end+1]), &s); err != nil {
+				return nil, BadRequest(ErrInvalidFilter, "bad string at %d", i)
+			}
+			toks = append(toks, token{kind: tokString, text: src[i : end+1], value: s, pos: i})
+			i = end + 1
+		case c == '-' || c >= '0' && c <= '9':
+			end := i + 1
+			for end < len(src) && strings.ContainsRune("0123456789.eE+-", rune(src[end])) {
+				end++
+			}
+			n, err := strconv.ParseFloat(src[i:end], 64)
+			if err != nil {
+				return nil, BadRequest(ErrInvalidFilter, "bad number %q at %d", src[i:end], i)
+			}
+			toks = append(toks, token{kind: tokNumber, text: src[i:end], value: n, pos: i})
+			i = end
+		case isWordChar(c):
+			end := i
+			for end < len(src) && isWordChar(rune(src[end])) {
+				end++
+			}
+			toks = append(toks, token{kind: tokWord, text: src[i:end], pos: i})
+			i = end
+		default:
+			return nil, BadRequest(ErrInvalidFilter, "unexpected %q at %d", c, i)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// Attribute paths with their schema URN, "urn:...:2.0:User:name.givenName"
+func isWordChar(c rune) bool {
+	return c == '_' || c == ':' || c == '.' || c == '$' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// ------------ parser ------------
+
+var compareOps = map[string]bool{"eq": true, "ne": true, "co": true, "sw": true, "ew": true, "gt": true, "lt": true, "ge": true, "le": true}
+
+type filterParser struct {
+	toks []token
+	i    int
+}
+
+func newFilterParser(src string) (*filterParser, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, BadRequest(ErrInvalidFilter, "empty filter")
+	}
+	toks, err := lexFilter(src)
+	if err != nil {
+		return nil, err
+	}
+	return &filterParser{toks: toks}, nil
+}
+
+func (p *filterParser) peek() token { return p.toks[p.i] }
+
+func (p *filterParser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// Keywords and punctuation, keywords case-insensitive
+func (p *filterParser) accept(text string) bool {
+	if t := p.peek(); (t.kind == tokWord || t.kind == tokPunct) && strings.EqualFold(t.text, text) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) expect(text string) error {
+	if !p.accept(text) {
+		t := p.peek()
+		return BadRequest(ErrInvalidFilter, "expected %q at %d", text, t.pos)
+	}
+	return nil
+}
+
+func (p *filterParser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) unary() (node, error) {
+	if t := p.peek(); t.kind == tokWord && strings.EqualFold(t.text, "not") {
+		p.i++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{n: n}, p.expect(")")
+	}
+	if p.accept("(") {
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	}
+	return p.attrExp()
+}
+
+func (p *filterParser) attrExp() (node, error) {
+	t := p.next()
+	if t.kind != tokWord {
+		if t.kind == tokEOF {
+			return nil, BadRequest(ErrInvalidFilter, "unexpected end of filter")
+		}
+		return nil, BadRequest(ErrInvalidFilter, "expected attribute at %d", t.pos)
+	}
+	path := splitAttr(t.text)
+
+	// emails[type eq "work"], matches when any value does
+	if p.accept("[") {
+		if path.sub != "" {
+			return nil, BadRequest(ErrInvalidFilter, "filter on a sub-attribute at %d", t.pos)
+		}
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return &valuePathNode{path: path, filter: inner}, nil
+	}
+
+	op := p.next()
+	if op.kind != tokWord {
+		return nil, BadRequest(ErrInvalidFilter, "expected operator at %d", op.pos)
+	}
+	name := strings.ToLower(op.text)
+	if name == "pr" {
+		return &presentNode{path: path}, nil
+	}
+	if !compareOps[name] {
+		return nil, BadRequest(ErrInvalidFilter, "unknown operator %q at %d", op.text, op.pos)
+	}
+
+	v := p.next()
+	var value any
+	switch v.kind {
+	case tokString, tokNumber:
+		value = v.value
+	case tokWord:
+		switch strings.ToLower(v.text) {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			return nil, BadRequest(ErrInvalidFilter, "bad value %q at %d", v.text, v.pos)
+		}
+	default:
+		return nil, BadRequest(ErrInvalidFilter, "expected value at %d", v.pos)
+	}
+	return &compareNode{path: path, op: name, value: value}, nil
+}
+
+// Attribute path of a filter or PATCH, the URN is kept for the schema check
+type attrPath struct {
+	urn  string // lower-cased prefix up to the attribute, empty when relative
+	full string
+	attr string
+	sub  string
+}
+
+func splitAttr(full string) attrPath {
+	p := attrPath{full: full}
+	name := full
+	if strings.HasPrefix(strings.ToLower(full), "urn:") {
+		i := strings.LastIndexByte(full, ':')
+		p.urn, name = strings.ToLower(full[:i]), full[i+1:]
+	}
+	p.attr, p.sub, _ = strings.Cut(name, ".")
+	return p
+}
+
+// The path relative to the schema, false for another schema's attribute
+func (a attrPath) in(s *Schema) bool {
+	return a.urn == "" || strings.EqualFold(a.urn, s.ID)
+}
+
+// ------------ evaluation ------------
+
+type node interface {
+	// parent is the multi-valued attribute of a [filter], its values are
+	// matched one at a time with doc being the value
+	match(s *Schema, doc map[string]any, parent string) bool
+}
+
+type logicalNode struct {
+	and         bool
+	left, right node
+}
+
+func (l *logicalNode) match(s *Schema, doc map[string]any, parent string) bool {
+	if l.and {
+		return l.left.match(s, doc, parent) && l.right.match(s, doc, parent)
+	}
+	return l.left.match(s, doc, parent) || l.right.match(s, doc, parent)
+}
+
+type notNode struct{ n node }
+
+func (n *notNode) match(s *Schema, doc map[string]any, parent string) bool {
+	return !n.n.match(s, doc, parent)
+}
+
+type valuePathNode struct {
+	path   attrPath
+	filter node
+}
+
+func (v *valuePathNode) match(s *Schema, doc map[string]any, parent string) bool {
+	if parent != "" || !v.path.in(s) {
+		return false
+	}
+	raw, _ := Get(doc, v.path.attr)
+	for _, it := range asList(raw) {
+		if m, ok := it.(map[string]any); ok && v.filter.match(s, m, v.path.attr) {
+			return true
+		}
+	}
+	return false
+}
+
+type presentNode struct{ path attrPath }
+
+func (n *presentNode) match(s *Schema, doc map[string]any, parent string) bool {
+	_, values, ok := resolve(s, doc, parent, n.path)
+	if !ok {
+		return false
+	}
+	for _, v := range values {
+		if present(v) {
+			return true
+		}
+	}
+	return false
+}
+
+type compareNode struct {
+	path  attrPath
+	op    string
+	value any
+}
+
+func (c *compareNode) match(s *Schema, doc map[string]any, parent string) bool {
+	def, values, ok := resolve(s, doc, parent, c.path)
+	if !ok {
+		return false
+	}
+	// Missing values count as null
+	if c.value == nil {
+		has := false
+		for _, v := range values {
+			has = has || present(v)
+		}
+		switch c.op {
+		case "eq":
+			return !has
+		case "ne":
+			return has
+		}
+		return false
+	}
+
+	// ne holds when no value equals, the others when any value compares
+	if c.op == "ne" {
+		for _, v := range values {
+			if v != nil && compare(def, "eq", v, c.value) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		if v != nil && compare(def, c.op, v, c.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Values an attribute path points at, complex multi-valued attributes
+// without a sub-attribute compare their "value"
+func resolve(s *Schema, doc map[string]any, parent string, p attrPath) (*Attribute, []any, bool) {
+	if !p.in(s) {
+		return nil, nil, false
+	}
+	if parent != "" {
+		if p.sub != "" {
+			return nil, nil, false
+		}
+		v, _ := Get(doc, p.attr)
+		return s.Attribute(parent, p.attr), []any{v}, true
+	}
+
+	raw, _ := Get(doc, p.attr)
+	def := s.Attribute(p.attr, p.sub)
+	var out []any
+	for _, it := range asList(raw) {
+		m, complex := it.(map[string]any)
+		switch {
+		case p.sub != "" && complex:
+			v, _ := Get(m, p.sub)
+			out = append(out, v)
+		case p.sub != "":
+		case complex:
+			v, _ := Get(m, "value")
+			out = append(out, v)
+			def = s.Attribute(p.attr, "value")
+		default:
+			out = append(out, it)
+		}
+	}
+	if len(out) == 0 {
+		out = []any{nil}
+	}
+	return def, out, true
+}
+
+func asList(v any) []any {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return x
+	}
+	return []any{v}
+}
+
+func present(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case string:
+		return x != ""
+	case []any:
+		return len(x) > 0
+	case map[string]any:
+		return len(x) > 0
+	}
+	return true
+}
+
+func compare(def *Attribute, op string, v, want any) bool {
+	switch w := want.(type) {
+	case bool:
+		b, ok := v.(bool)
+		return ok && op == "eq" && b == w
+	case float64:
+		n, ok := v.(float64)
+		if !ok {
+			return false
+		}
+		return order(op, n < w, n == w)
+	case string:
+		str, ok := v.(string)
+		if !ok {
+			return false
+		}
+		if def != nil && def.Type == TypeDateTime {
+			if a, b, ok := parseTimes(str, w); ok {
+				if op == "eq" {
+					return a.Equal(b)
+				}
+				return order(op, a.Before(b), a.Equal(b))
+			}
+		}
+		if def == nil || !def.CaseExact {
+			str, w = strings.ToLower(str), strings.ToLower(w)
+		}
+		switch op {
+		case "eq":
+			return str == w
+		case "co":
+			return strings.Contains(str, w)
+		case "sw":
+			return strings.HasPrefix(str, w)
+		case "ew":
+			return strings.HasSuffix(str, w)
+		}
+		return order(op, str < w, str == w)
+	}
+	return false
+}
+
+func order(op string, less, eq bool) bool {
+	switch op {
+	case "eq":
+		return eq
+	case "gt":
+		return !less && !eq
+	case "ge":
+		return !less
+	case "lt":
+		return less
+	case "le":
+		return less || eq
+	}
+	return false
+}
+
+func parseTimes(a, b string) (time.Time, time.Time, bool) {
+	ta, err := time.Parse(time.RFC3339Nano, a)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	tb, err := time.Parse(time.RFC3339Nano, b)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return ta, tb, true
+}
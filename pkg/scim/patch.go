@@ -0,0 +1,402 @@
+package scim
+
+import (
+	"reflect"
+	"strings"
+)
+
+type PatchOp struct {
+	Op    string `json:"op"` // add, remove or replace, any case
+	Path  string `json:"path,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+type PatchRequest struct {
+	Schemas    []string  `json:"schemas"`
+	Operations []PatchOp `json:"Operations"`
+}
+
+// Apply the operations in order (RFC 7644 3.5.2). Paths are
+//
+//	userName, name.givenName, emails[type eq "work"], emails[type eq "work"].value
+//
+// with or without the schema URN. Attributes of other schemas, like
+// extensions, are skipped. Read-only attributes fail with mutability.
+func Patch(s *Schema, doc map[string]any, ops []PatchOp) error {
+	if len(ops) == 0 {
+		return BadRequest(ErrInvalidValue, "no operations")
+	}
+	for _, op := range ops {
+		if err := patchOne(s, doc, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func patchOne(s *Schema, doc map[string]any, op PatchOp) error {
+	name := strings.ToLower(op.Op)
+	if name != "add" && name != "remove" && name != "replace" {
+		return BadRequest(ErrInvalidSyntax, "unknown op %q", op.Op)
+	}
+	if op.Path != "" {
+		p, err := parsePath(op.Path)
+		if err != nil {
+			return err
+		}
+		return apply(s, doc, name, p, op.Value)
+	}
+
+	// Without a path the value holds the attributes
+	if name == "remove" {
+		return BadRequest(ErrNoTarget, "remove needs a path")
+	}
+	attrs, ok := op.Value.(map[string]any)
+	if !ok {
+		return BadRequest(ErrInvalidValue, "%s without a path needs an object value", op.Op)
+	}
+	for k, v := range attrs {
+		if strings.EqualFold(k, "schemas") {
+			continue
+		}
+		// The core schema as a key, like an extension would be
+		if core, ok := v.(map[string]any); ok && strings.EqualFold(k, s.ID) {
+			if err := patchOne(s, doc, PatchOp{Op: name, Value: core}); err != nil {
+				return err
+			}
+			continue
+		}
+		p, err := parsePath(k)
+		if err != nil {
+			return err
+		}
+		if err := apply(s, doc, name, p, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type patchPath struct {
+	attrPath
+	filter node // emails[type eq "work"]
+}
+
+func parsePath(raw string) (patchPath, error) {
+	head, rest, bracket := strings.Cut(raw, "[")
+	p := patchPath{attrPath: splitAttr(strings.TrimSpace(head))}
+	if p.attr == "" {
+		return p, BadRequest(ErrInvalidPath, "bad path %q", raw)
+	}
+	if !bracket {
+		return p, nil
+	}
+	if p.sub != "" {
+		return p, BadRequest(ErrInvalidPath, "filter on a sub-attribute in %q", raw)
+	}
+
+	end := strings.LastIndexByte(rest, ']')
+	if end < 0 {
+		return p, BadRequest(ErrInvalidPath, "unclosed filter in %q", raw)
+	}
+	f, err := ParseFilter(rest[:end])
+	if err != nil {
+		return p, BadRequest(ErrInvalidPath, "filter of %q: %s", raw, err.(*Error).Detail)
+	}
+	p.filter = f.root
+
+	switch tail := rest[end+1:]; {
+	case tail == "":
+	case strings.HasPrefix(tail, ".") && len(tail) > 1 && !strings.ContainsAny(tail[1:], ".[]"):
+		p.sub = tail[1:]
+	default:
+		return p, BadRequest(ErrInvalidPath, "bad path %q", raw)
+	}
+	return p, nil
+}
+
+func apply(s *Schema, doc map[string]any, op string, p patchPath, value any) error {
+	if !p.in(s) {
+		return nil
+	}
+	def := s.Attribute(p.attr, "")
+	if def != nil && def.Mutability == ReadOnly {
+		return BadRequest(ErrMutability, "%s is read-only", p.attr)
+	}
+	if p.sub != "" {
+		if sub := s.Attribute(p.attr, p.sub); sub != nil && sub.Mutability == ReadOnly {
+			return BadRequest(ErrMutability, "%s.%s is read-only", p.attr, p.sub)
+		}
+	}
+	if op == "add" && value == nil {
+		return BadRequest(ErrInvalidValue, "add to %s needs a value", p.attr)
+	}
+	if op == "replace" && value == nil {
+		op = "remove"
+	}
+	k := key(doc, def, p.attr)
+	multi := def != nil && def.MultiValued
+
+	switch {
+	case p.filter != nil:
+		return applyFiltered(s, doc, k, op, p, value)
+	case p.sub != "":
+		return applySub(doc, k, op, p.sub, value)
+	}
+
+	switch op {
+	case "remove":
+		// Some clients send the values to remove with the op
+		if multi && value != nil {
+			left := without(asList(doc[k]), asList(value))
+			setList(doc, k, left)
+			return nil
+		}
+		delete(doc, k)
+	case "replace":
+		if multi {
+			setList(doc, k, onePrimary(asList(value)))
+			return nil
+		}
+		// Sub-attributes not given are kept
+		if m, ok := value.(map[string]any); ok && def != nil && def.Type == TypeComplex {
+			merge(doc, k, m)
+			return nil
+		}
+		doc[k] = value
+	case "add":
+		if multi {
+			list := asList(doc[k])
+			for _, v := range asList(value) {
+				if !contains(list, v) {
+					list = append(list, v)
+				}
+			}
+			setList(doc, k, onePrimary(list))
+			return nil
+		}
+		if m, ok := value.(map[string]any); ok {
+			merge(doc, k, m)
+			return nil
+		}
+		doc[k] = value
+	}
+	return nil
+}
+
+// name.givenName, or a sub-attribute of every value of a multi-valued one
+func applySub(doc map[string]any, k, op, sub string, value any) error {
+	switch cur := doc[k].(type) {
+	case []any:
+		for _, it := range cur {
+			if m, ok := it.(map[string]any); ok {
+				setSub(m, op, sub, value)
+			}
+		}
+		if len(cur) == 0 && op != "remove" {
+			doc[k] = []any{map[string]any{sub: value}}
+		}
+	case map[string]any:
+		setSub(cur, op, sub, value)
+		if len(cur) == 0 {
+			delete(doc, k)
+		}
+	default:
+		if op != "remove" {
+			doc[k] = map[string]any{sub: value}
+		}
+	}
+	return nil
+}
+
+func setSub(m map[string]any, op, sub string, value any) {
+	k := key(m, nil, sub)
+	if op == "remove" {
+		delete(m, k)
+		return
+	}
+	m[k] = value
+}
+
+// emails[type eq "work"] and emails[type eq "work"].value. A filter of
+// equalities that matches nothing gets a new value on add and replace,
+// the way most identity providers set a work email.
+func applyFiltered(s *Schema, doc map[string]any, k, op string, p patchPath, value any) error {
+	list := asList(doc[k])
+	var matched []int
+	for i, it := range list {
+		if m, ok := it.(map[string]any); ok && p.filter.match(s, m, p.attr) {
+			matched = append(matched, i)
+		}
+	}
+
+	if op == "remove" {
+		if p.sub != "" {
+			for _, i := range matched {
+				setSub(list[i].(map[string]any), op, p.sub, nil)
+			}
+			return nil
+		}
+		left := make([]any, 0, len(list))
+		for i, it := range list {
+			if !containsIndex(matched, i) {
+				left = append(left, it)
+			}
+		}
+		setList(doc, k, left)
+		return nil
+	}
+
+	if len(matched) == 0 {
+		base, ok := equalities(p.filter)
+		if !ok {
+			return BadRequest(ErrNoTarget, "no value of %s matches the filter", p.attr)
+		}
+		if p.sub != "" {
+			base[p.sub] = value
+		} else {
+			m, ok := value.(map[string]any)
+			if !ok {
+				return BadRequest(ErrInvalidValue, "value of %s must be an object", p.attr)
+			}
+			for kk, v := range m {
+				base[kk] = v
+			}
+		}
+		setList(doc, k, onePrimary(append(list, base)))
+		return nil
+	}
+
+	for _, i := range matched {
+		m := list[i].(map[string]any)
+		if p.sub != "" {
+			setSub(m, op, p.sub, value)
+			continue
+		}
+		nv, ok := value.(map[string]any)
+		if !ok {
+			return BadRequest(ErrInvalidValue, "value of %s must be an object", p.attr)
+		}
+		if op == "replace" {
+			list[i] = nv
+			continue
+		}
+		for kk, v := range nv {
+			m[key(m, nil, kk)] = v
+		}
+	}
+	setList(doc, k, onePrimary(list))
+	return nil
+}
+
+// Attributes a new value gets from `type eq "work" and primary eq true`,
+// false for filters that aren't only equalities
+func equalities(n node) (map[string]any, bool) {
+	switch x := n.(type) {
+	case *compareNode:
+		if x.op != "eq" || x.path.sub != "" || x.value == nil {
+			return nil, false
+		}
+		return map[string]any{x.path.attr: x.value}, true
+	case *logicalNode:
+		if !x.and {
+			return nil, false
+		}
+		l, ok := equalities(x.left)
+		if !ok {
+			return nil, false
+		}
+		r, ok := equalities(x.right)
+		if !ok {
+			return nil, false
+		}
+		for k, v := range r {
+			l[k] = v
+		}
+		return l, true
+	}
+	return nil, false
+}
+
+func merge(doc map[string]any, k string, m map[string]any) {
+	cur, ok := doc[k].(map[string]any)
+	if !ok {
+		cur = map[string]any{}
+	}
+	for kk, v := range m {
+		if v == nil {
+			delete(cur, key(cur, nil, kk))
+			continue
+		}
+		cur[key(cur, nil, kk)] = v
+	}
+	doc[k] = cur
+}
+
+func setList(doc map[string]any, k string, list []any) {
+	if len(list) == 0 {
+		delete(doc, k)
+		return
+	}
+	doc[k] = list
+}
+
+// Only the last value marked primary stays primary
+func onePrimary(list []any) []any {
+	seen := false
+	for i := len(list) - 1; i >= 0; i-- {
+		m, ok := list[i].(map[string]any)
+		if !ok {
+			continue
+		}
+		if v, _ := Get(m, "primary"); v == true {
+			if seen {
+				m[key(m, nil, "primary")] = false
+			}
+			seen = true
+		}
+	}
+	return list
+}
+
+// Complex values are the same when their "value" is
+func sameValue(a, b any) bool {
+	am, aok := a.(map[string]any)
+	bm, bok := b.(map[string]any)
+	if aok && bok {
+		av, ahas := Get(am, "value")
+		bv, bhas := Get(bm, "value")
+		if ahas && bhas {
+			return reflect.DeepEqual(av, bv)
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func contains(list []any, v any) bool {
+	for _, it := range list {
+		if sameValue(it, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func without(list, drop []any) []any {
+	out := make([]any, 0, len(list))
+	for _, it := range list {
+		if !contains(drop, it) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+func containsIndex(idx []int, i int) bool {
+	for _, x := range idx {
+		if x == i {
+			return true
+		}
+	}
+	return false
+}
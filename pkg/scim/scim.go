@@ -0,0 +1,307 @@
+// Package scim holds the protocol parts of SCIM 2.0 (RFC 7643, 7644) that
+// don't depend on the resources served: schemas, filters, PATCH and errors.
+// Resources are JSON objects decoded into map[string]any.
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	UserSchema          = "urn:ietf:params:scim:schemas:core:2.0:User"
+	GroupSchema         = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ListResponseSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema       = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema         = "urn:ietf:params:scim:api:messages:2.0:Error"
+	ServiceConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	ResourceTypeSchema  = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema        = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+
+	// Media type of requests and responses, application/json is accepted too
+	ContentType = "application/scim+json"
+)
+
+// scimType values of error responses
+const (
+	ErrInvalidFilter = "invalidFilter"
+	ErrTooMany       = "tooMany"
+	ErrUniqueness    = "uniqueness"
+	ErrMutability    = "mutability"
+	ErrInvalidSyntax = "invalidSyntax"
+	ErrInvalidPath   = "invalidPath"
+	ErrNoTarget      = "noTarget"
+	ErrInvalidValue  = "invalidValue"
+)
+
+// Error response, also returned by the parsers here
+type Error struct {
+	Status   int
+	ScimType string // empty for errors without one, e.g. 404
+	Detail   string
+}
+
+func (e *Error) Error() string {
+	if e.ScimType == "" {
+		return e.Detail
+	}
+	return e.ScimType + ": " + e.Detail
+}
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"` // a string in SCIM
+		ScimType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail,omitempty"`
+	}{[]string{ErrorSchema}, strconv.Itoa(e.Status), e.ScimType, e.Detail})
+}
+
+// 400 with a scimType
+func BadRequest(scimType, format string, args ...any) *Error {
+	return &Error{Status: http.StatusBadRequest, ScimType: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+type ListResponse struct {
+	Schemas      []string         `json:"schemas"`
+	TotalResults int              `json:"totalResults"`
+	StartIndex   int              `json:"startIndex"`
+	ItemsPerPage int              `json:"itemsPerPage"`
+	Resources    []map[string]any `json:"Resources"`
+}
+
+func NewListResponse(total, startIndex int, resources []map[string]any) *ListResponse {
+	if resources == nil {
+		resources = []map[string]any{}
+	}
+	return &ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// Attribute types
+const (
+	TypeString   = "string"
+	TypeBoolean  = "boolean"
+	TypeDateTime = "dateTime"
+	TypeComplex  = "complex"
+	TypeRef      = "reference"
+	TypeInteger  = "integer"
+)
+
+// Mutability and returned values used by the schemas served here
+const (
+	ReadOnly  = "readOnly"
+	ReadWrite = "readWrite"
+	WriteOnly = "writeOnly"
+	Immutable = "immutable"
+
+	ReturnedAlways  = "always"
+	ReturnedDefault = "default"
+	ReturnedNever   = "never"
+)
+
+// Attribute definition, serves the /Schemas endpoint and tells filters and
+// PATCH how to compare and where lists are
+type Attribute struct {
+	Name          string      `json:"name"`
+	Type          string      `json:"type"`
+	MultiValued   bool        `json:"multiValued"`
+	Description   string      `json:"description,omitempty"`
+	Required      bool        `json:"required"`
+	CaseExact     bool        `json:"caseExact"`
+	Mutability    string      `json:"mutability"`
+	Returned      string      `json:"returned"`
+	Uniqueness    string      `json:"uniqueness"`
+	SubAttributes []Attribute `json:"subAttributes,omitempty"`
+}
+
+type Schema struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Attributes  []Attribute `json:"attributes"`
+}
+
+// Attributes every resource has, they are not listed in the schemas
+var commonAttributes = []Attribute{
+	{Name: "id", Type: TypeString, CaseExact: true, Mutability: ReadOnly, Returned: ReturnedAlways, Uniqueness: "server"},
+	{Name: "externalId", Type: TypeString, CaseExact: true, Mutability: ReadWrite, Returned: ReturnedDefault, Uniqueness: "none"},
+	{Name: "meta", Type: TypeComplex, Mutability: ReadOnly, Returned: ReturnedDefault, Uniqueness: "none", SubAttributes: []Attribute{
+		{Name: "resourceType", Type: TypeString, CaseExact: true, Mutability: ReadOnly, Returned: ReturnedDefault, Uniqueness: "none"},
+		{Name: "created", Type: TypeDateTime, Mutability: ReadOnly, Returned: ReturnedDefault, Uniqueness: "none"},
+		{Name: "lastModified", Type: TypeDateTime, Mutability: ReadOnly, Returned: ReturnedDefault, Uniqueness: "none"},
+		{Name: "location", Type: TypeRef, CaseExact: true, Mutability: ReadOnly, Returned: ReturnedDefault, Uniqueness: "none"},
+		{Name: "version", Type: TypeString, CaseExact: true, Mutability: ReadOnly, Returned: ReturnedDefault, Uniqueness: "none"},
+	}},
+}
+
+// Definition of a top level attribute and, with sub set, of its sub-attribute.
+// Names are case-insensitive, nil when unknown.
+func (s *Schema) Attribute(name, sub string) *Attribute {
+	a := findAttribute(s.Attributes, name)
+	if a == nil {
+		a = findAttribute(commonAttributes, name)
+	}
+	if a == nil || sub == "" {
+		return a
+	}
+	if len(a.SubAttributes) == 0 && a.Type == TypeComplex {
+		return nil
+	}
+	return findAttribute(a.SubAttributes, sub)
+}
+
+func findAttribute(attrs []Attribute, name string) *Attribute {
+	for i := range attrs {
+		if strings.EqualFold(attrs[i].Name, name) {
+			return &attrs[i]
+		}
+	}
+	return nil
+}
+
+// Strip the schema URN from a full attribute name, e.g.
+// "urn:ietf:params:scim:schemas:core:2.0:User:name.givenName" is "name.givenName".
+// other is true for a URN of another schema, like an extension.
+func (s *Schema) relative(path string) (rel string, other bool) {
+	if !strings.HasPrefix(strings.ToLower(path), "urn:") {
+		return path, false
+	}
+	if len(path) > len(s.ID) && strings.EqualFold(path[:len(s.ID)], s.ID) && path[len(s.ID)] == ':' {
+		return path[len(s.ID)+1:], false
+	}
+	return path, true
+}
+
+// Value of a key matched case-insensitively, attribute names are
+func Get(doc map[string]any, name string) (any, bool) {
+	if v, ok := doc[name]; ok {
+		return v, true
+	}
+	for k, v := range doc {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Key of doc for the name, the existing spelling or the schema's
+func key(doc map[string]any, def *Attribute, name string) string {
+	for k := range doc {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	if def != nil {
+		return def.Name
+	}
+	return name
+}
+
+// Keep the requested attributes (attributes) or drop some (excluded), as in
+// the query parameters of the same names. id, schemas and attributes returned
+// always are kept either way.
+func Project(s *Schema, doc map[string]any, attributes, excluded []string) map[string]any {
+	if len(attributes) == 0 && len(excluded) == 0 {
+		return doc
+	}
+	out := make(map[string]any, len(doc))
+	for k, v := range doc {
+		def := s.Attribute(k, "")
+		always := k == "schemas" || def != nil && def.Returned == ReturnedAlways
+		switch {
+		case always:
+			out[k] = v
+		case len(attributes) > 0:
+			if sub, ok := selected(s, k, attributes); ok {
+				out[k] = pick(v, sub)
+			}
+		default:
+			if sub, ok := selected(s, k, excluded); !ok || len(sub) > 0 {
+				out[k] = drop(v, sub)
+			}
+		}
+	}
+	return out
+}
+
+// Whether the list names attr, with the sub-attributes named. An empty sub
+// list with ok means the whole attribute.
+func selected(s *Schema, attr string, paths []string) (sub []string, ok bool) {
+	whole := false
+	for _, p := range paths {
+		p, other := s.relative(strings.TrimSpace(p))
+		if other {
+			continue
+		}
+		name, rest, _ := strings.Cut(p, ".")
+		if !strings.EqualFold(name, attr) {
+			continue
+		}
+		ok = true
+		if rest == "" {
+			whole = true
+		} else {
+			sub = append(sub, rest)
+		}
+	}
+	if whole {
+		return nil, ok
+	}
+	return sub, ok
+}
+
+func pick(v any, sub []string) any {
+	if len(sub) == 0 {
+		return v
+	}
+	return mapComplex(v, func(m map[string]any) map[string]any {
+		out := map[string]any{}
+		for _, s := range sub {
+			if x, ok := Get(m, s); ok {
+				out[s] = x
+			}
+		}
+		return out
+	})
+}
+
+func drop(v any, sub []string) any {
+	return mapComplex(v, func(m map[string]any) map[string]any {
+		out := make(map[string]any, len(m))
+		for k, x := range m {
+			out[k] = x
+		}
+		for _, s := range sub {
+			delete(out, key(out, nil, s))
+		}
+		return out
+	})
+}
+
+func mapComplex(v any, fn func(map[string]any) map[string]any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		return fn(x)
+	case []any:
+		out := make([]any, len(x))
+		for i, it := range x {
+			if m, ok := it.(map[string]any); ok {
+				out[i] = fn(m)
+			} else {
+				out[i] = it
+			}
+		}
+		return out
+	}
+	return v
+}
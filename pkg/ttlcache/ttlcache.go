@@ -0,0 +1,78 @@
+package ttlcache
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache keeps values for a fixed TTL, bounded to max entries.
+// A zero TTL disables caching.
+type Cache[K comparable, V any] struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	max   int
+	items map[K]entry[V]
+}
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+func New[K comparable, V any](ttl time.Duration, max int) *Cache[K, V] {
+	return &Cache[K, V]{ttl: ttl, max: max, items: make(map[K]entry[V])}
+}
+
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	var zero V
+	if c.ttl <= 0 {
+		return zero, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	if time.Now().After(e.expiresAt) {
+		delete(c.items, key)
+		return zero, false
+	}
+	return e.value, true
+}
+
+func (c *Cache[K, V]) Set(key K, value V) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.max > 0 && len(c.items) >= c.max {
+		c.evict(now)
+	}
+	c.items[key] = entry[V]{value: value, expiresAt: now.Add(c.ttl)}
+}
+
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+}
+
+// Expired entries first, then arbitrary ones until there is room
+func (c *Cache[K, V]) evict(now time.Time) {
+	for k, e := range c.items {
+		if now.After(e.expiresAt) {
+			delete(c.items, k)
+		}
+	}
+	for k := range c.items {
+		if len(c.items) < c.max {
+			return
+		}
+		delete(c.items, k)
+	}
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+var ErrBlockedAddress = errors.New("webhook target address not allowed")
+
+// Non-public ranges the IP helpers don't cover
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+}
+
+// Guard keeps webhook requests off the service's own network: loopback,
+// private, link-local and other non-public addresses are refused unless
+// they are in an allowed network.
+type Guard struct {
+	allowed  []netip.Prefix
+	resolver *net.Resolver
+}
+
+// Allowed networks are CIDRs, e.g. "10.20.0.0/16" for an internal receiver
+func NewGuard(allowed []string) (*Guard, error) {
+	g := &Guard{resolver: net.DefaultResolver}
+	for _, s := range allowed {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("webhook allowed network %q: %w", s, err)
+		}
+		g.allowed = append(g.allowed, p.Masked())
+	}
+	return g, nil
+}
+
+func (g *Guard) CheckAddr(ip netip.Addr) error {
+	ip = ip.Unmap()
+	for _, p := range g.allowed {
+		if p.Contains(ip) {
+			return nil
+		}
+	}
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, ip)
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(ip) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, ip)
+		}
+	}
+	return nil
+}
+
+// Every address the URL's host resolves to must be allowed
+func (g *Guard) CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return g.CheckAddr(ip)
+	}
+
+	ips, err := g.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+	for _, ip := range ips {
+		if err := g.CheckAddr(ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// net.Dialer Control, checks the address actually dialled so a host that
+// resolves differently later (or a redirect) can't reach a blocked one
+func (g *Guard) Control(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+	}
+	return g.CheckAddr(ap.Addr())
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestGuardCheckAddr(t *testing.T) {
+	g, err := NewGuard([]string{"10.20.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, blocked := range map[string]bool{
+		"93.184.216.34":    false,
+		"2606:4700::1111":  false,
+		"10.20.3.4":        false, // allowed network
+		"10.21.3.4":        true,
+		"127.0.0.1":        true,
+		"::1":              true,
+		"169.254.169.254":  true, // cloud metadata
+		"fe80::1":          true,
+		"192.168.1.1":      true,
+		"172.16.0.1":       true,
+		"fd00::1":          true,
+		"100.64.0.1":       true,
+		"0.0.0.0":          true,
+		"::ffff:127.0.0.1": true,
+		"224.0.0.1":        true,
+	} {
+		err := g.CheckAddr(netip.MustParseAddr(addr))
+		if got := errors.Is(err, ErrBlockedAddress); got != blocked {
+			t.Errorf("%s blocked = %t, want %t (%v)", addr, got, blocked, err)
+		}
+	}
+}
+
+func TestGuardCheckURL(t *testing.T) {
+	g, err := NewGuard(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, raw := range []string{"http://127.0.0.1:8080/hook", "https://[::1]/hook", "http://localhost/hook"} {
+		if err := g.CheckURL(context.Background(), raw); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("%s: err = %v, want blocked", raw, err)
+		}
+	}
+	if err := g.Control("tcp", "127.0.0.1:443", nil); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Control(127.0.0.1) = %v, want blocked", err)
+	}
+}
+
+func TestNewGuardRejectsBadNetwork(t *testing.T) {
+	if _, err := NewGuard([]string{"10.0.0.0"}); err == nil {
+		t.Error("NewGuard accepted a network without a prefix length")
+	}
+}
@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request headers
+const (
+	HeaderSignature = "X-Signature" // "sha256=<hex hmac>"
+	HeaderTimestamp = "X-Timestamp" // Unix seconds
+	HeaderDelivery  = "X-Delivery-Id"
+	HeaderEvent     = "X-Event-Type"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrStaleTimestamp   = errors.New("webhook timestamp outside tolerance")
+)
+
+// HMAC-SHA256 over "<timestamp>.<body>", so the timestamp can't be swapped
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Receiver side check, rejects replays older than tolerance
+func Verify(secret, timestampHeader, signatureHeader string, body []byte, tolerance time.Duration, now time.Time) error {
+	ts, err := strconv.ParseInt(strings.TrimSpace(timestampHeader), 10, 64)
+	if err != nil {
+		return ErrStaleTimestamp
+	}
+
+	diff := now.Sub(time.Unix(ts, 0))
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > tolerance {
+		return ErrStaleTimestamp
+	}
+
+	expected := Sign(secret, ts, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.TrimSpace(signatureHeader))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
@@ -5,15 +5,16 @@ GRPC_ADDR="localhost:50051"
 PROTO_PKG="auth.AuthService"
 
 # Test credentials
-ADMIN_EMAIL="26@admin.com"
+ADMIN_EMAIL="26@admin.com" # set as INVITE_BOOTSTRAP_ADMIN
+ADMIN_CODE="${ADMIN_CODE:?code from the invite email sent to $ADMIN_EMAIL}"
 ADMIN_PASS="Admin123!"
 STUD_EMAIL="26@student.com"
 STUD_PASS="Stud123!"
 
-echo "Register Admin"
+echo "Accept Admin invitation"
 grpcurl -plaintext \
-  -d "{\"email\":\"$ADMIN_EMAIL\",\"password\":\"$ADMIN_PASS\",\"role\":1}" \
-  $GRPC_ADDR $PROTO_PKG/Register | jq
+  -d "{\"code\":\"$ADMIN_CODE\",\"password\":\"$ADMIN_PASS\"}" \
+  $GRPC_ADDR $PROTO_PKG/AcceptInvitation | jq
 
 echo "Login as Admin (Get admin token)"
 ADMIN_TOKEN=$(grpcurl -plaintext \